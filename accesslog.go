@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/handlers"
+)
+
+// Access logging of API requests
+
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	Referer    string  `json:"referer"`
+	UserAgent  string  `json:"user_agent"`
+}
+
+// statusRecorder keeps track of the status code and the
+// size of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.size += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Connection can not be hijacked")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// JSONLoggingHandler logs every request as JSON object
+// including the duration of the request.
+func JSONLoggingHandler(out io.Writer, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		uri := req.RequestURI
+		h.ServeHTTP(recorder, req)
+
+		entry := accessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339),
+			RemoteAddr: req.RemoteAddr,
+			Method:     req.Method,
+			URI:        uri,
+			Proto:      req.Proto,
+			Status:     recorder.status,
+			Bytes:      recorder.size,
+			DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+			Referer:    req.Referer(),
+			UserAgent:  req.UserAgent(),
+		}
+
+		buf, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		out.Write(append(buf, '\n'))
+	})
+}
+
+// AccessLogHandler wraps the handler with the access logger
+// for the configured format: common, combined or json.
+func AccessLogHandler(format string, out io.Writer, h http.Handler) http.Handler {
+	switch format {
+	case "", "common":
+		return handlers.LoggingHandler(out, h)
+	case "combined":
+		return handlers.CombinedLoggingHandler(out, h)
+	case "json":
+		return JSONLoggingHandler(out, h)
+	}
+
+	log.Println("Unknown access log format:", format, "- using common log format")
+	return handlers.LoggingHandler(out, h)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONLoggingHandler(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := JSONLoggingHandler(out, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("GET", "/status?uncached=true", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := accessLogEntry{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatal("Could not decode access log entry:", err, out.String())
+	}
+
+	if entry.Status != http.StatusTeapot {
+		t.Error("Expected status 418, got:", entry.Status)
+	}
+	if entry.Bytes != 5 {
+		t.Error("Expected 5 bytes, got:", entry.Bytes)
+	}
+	if entry.URI != "/status?uncached=true" {
+		t.Error("Unexpected URI:", entry.URI)
+	}
+}
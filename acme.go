@@ -0,0 +1,663 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Automatic TLS certificates via ACME (RFC 8555)
+//
+// Certificates for the configured hostnames are obtained
+// using the HTTP-01 or the DNS-01 challenge and renewed before
+// they expire. The account key and the certificates are stored
+// in the cache directory.
+//
+// For HTTP-01, the CA validates the challenge on port 80 of
+// every hostname, so the challenge listener (http_listen) must
+// be reachable on port 80 from the internet, regardless of the
+// port and the allow_from list of the API.
+//
+// For DNS-01, e.g. for a looking glass on an internal network,
+// the TXT records are published by the dns_hook command, called
+// as "<dns_hook> present <fqdn> <value>" before the validation
+// and "<dns_hook> cleanup <fqdn> <value>" afterwards.
+
+const (
+	defaultAcmeDirectory   = "https://acme-v02.api.letsencrypt.org/directory"
+	defaultAcmeHTTPListen  = ":80"
+	defaultAcmeCacheDir    = "/var/lib/birdwatcher/acme"
+	defaultAcmeRenewBefore = 30 // days
+
+	acmeChallengePath = "/.well-known/acme-challenge/"
+
+	acmeChallengeHTTP = "http-01"
+	acmeChallengeDNS  = "dns-01"
+)
+
+type AcmeConfig struct {
+	Enabled    bool     `toml:"enabled"`
+	Hostnames  []string `toml:"hostnames"`
+	Email      string   `toml:"email"`
+	Directory  string   `toml:"directory"`
+	CacheDir   string   `toml:"cache_dir"`
+	HTTPListen string   `toml:"http_listen"`
+	// Challenge type: http-01 (default) or dns-01
+	Challenge string `toml:"challenge"`
+	// Command publishing the TXT records of the dns-01 challenge
+	DNSHook string `toml:"dns_hook"`
+	// Wait this many seconds for the TXT record to propagate
+	DNSPropagationWait int `toml:"dns_propagation_wait"`
+	// Renew the certificate this many days before it expires
+	RenewBefore int `toml:"renew_before"`
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Get the fixed size big endian representation of a coordinate
+func paddedBytes(i *big.Int, size int) []byte {
+	b := i.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// The JSON Web Key of the account key. The members are
+// ordered lexicographically as required for the thumbprint.
+func acmeJWK(key *ecdsa.PrivateKey) string {
+	return fmt.Sprintf(
+		`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+		b64(paddedBytes(key.X, 32)),
+		b64(paddedBytes(key.Y, 32)))
+}
+
+// The JWK thumbprint (RFC 7638) used in key authorizations
+func acmeThumbprint(key *ecdsa.PrivateKey) string {
+	sum := sha256.Sum256([]byte(acmeJWK(key)))
+	return b64(sum[:])
+}
+
+// Create a JWS in flattened JSON serialization. Without a
+// payload, the request is a POST-as-GET request.
+func acmeJWS(key *ecdsa.PrivateKey, kid, nonce, url string, payload interface{}) ([]byte, error) {
+	protected := map[string]interface{}{
+		"alg":   "ES256",
+		"nonce": nonce,
+		"url":   url,
+	}
+	if kid != "" {
+		protected["kid"] = kid
+	} else {
+		protected["jwk"] = json.RawMessage(acmeJWK(key))
+	}
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ""
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = b64(data)
+	}
+
+	signingInput := b64(header) + "." + body
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := append(paddedBytes(r, 32), paddedBytes(s, 32)...)
+
+	return json.Marshal(map[string]string{
+		"protected": b64(header),
+		"payload":   body,
+		"signature": b64(signature),
+	})
+}
+
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+type acmeChallenge struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	Status string `json:"status"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeAuthorization struct {
+	Status     string          `json:"status"`
+	Identifier acmeIdentifier  `json:"identifier"`
+	Challenges []acmeChallenge `json:"challenges"`
+}
+
+// acmeSolver publishes the key authorization of a challenge
+type acmeSolver interface {
+	Type() string
+	Present(domain, token, keyAuth string) error
+	CleanUp(domain, token, keyAuth string) error
+}
+
+type acmeClient struct {
+	directoryURL string
+	key          *ecdsa.PrivateKey
+	kid          string
+	nonce        string
+	directory    acmeDirectory
+	client       *http.Client
+
+	// Wait between polling the status of
+	// authorizations and orders
+	pollInterval time.Duration
+}
+
+func (c *acmeClient) fetchDirectory() error {
+	res, err := c.client.Get(c.directoryURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(&c.directory)
+}
+
+func (c *acmeClient) fetchNonce() (string, error) {
+	if c.nonce != "" {
+		nonce := c.nonce
+		c.nonce = ""
+		return nonce, nil
+	}
+
+	res, err := c.client.Head(c.directory.NewNonce)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	return res.Header.Get("Replay-Nonce"), nil
+}
+
+// Send a signed request, retrying once with a fresh nonce
+func (c *acmeClient) post(url string, payload interface{}) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		nonce, err := c.fetchNonce()
+		if err != nil {
+			return nil, nil, err
+		}
+		jws, err := acmeJWS(c.key, c.kid, nonce, url, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		res, err := c.client.Post(url, "application/jose+json", bytes.NewReader(jws))
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.nonce = res.Header.Get("Replay-Nonce")
+
+		if res.StatusCode < 400 {
+			return res, body, nil
+		}
+		if attempt == 0 && strings.Contains(string(body), "badNonce") {
+			continue
+		}
+		return nil, nil, fmt.Errorf("ACME request to %s failed: %s %s", url, res.Status, body)
+	}
+}
+
+func (c *acmeClient) register(email string) error {
+	account := map[string]interface{}{
+		"termsOfServiceAgreed": true,
+	}
+	if email != "" {
+		account["contact"] = []string{"mailto:" + email}
+	}
+
+	res, _, err := c.post(c.directory.NewAccount, account)
+	if err != nil {
+		return err
+	}
+	c.kid = res.Header.Get("Location")
+	return nil
+}
+
+func (c *acmeClient) pollStatus(url string, result interface{}, status func() string) error {
+	for i := 0; i < 30; i++ {
+		_, body, err := c.post(url, nil)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(body, result); err != nil {
+			return err
+		}
+
+		switch status() {
+		case "valid":
+			return nil
+		case "invalid":
+			return fmt.Errorf("ACME validation failed: %s", body)
+		}
+		time.Sleep(c.pollInterval)
+	}
+	return fmt.Errorf("ACME validation timed out: %s", url)
+}
+
+// Solve the challenge of an authorization
+func (c *acmeClient) authorize(url string, solver acmeSolver) error {
+	authz := acmeAuthorization{}
+	_, body, err := c.post(url, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &authz); err != nil {
+		return err
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+
+	for _, challenge := range authz.Challenges {
+		if challenge.Type != solver.Type() {
+			continue
+		}
+
+		domain := authz.Identifier.Value
+		keyAuth := challenge.Token + "." + acmeThumbprint(c.key)
+		if err := solver.Present(domain, challenge.Token, keyAuth); err != nil {
+			return err
+		}
+		defer func() {
+			if err := solver.CleanUp(domain, challenge.Token, keyAuth); err != nil {
+				log.Println("Could not clean up ACME challenge:", err)
+			}
+		}()
+
+		if _, _, err := c.post(challenge.URL, struct{}{}); err != nil {
+			return err
+		}
+		return c.pollStatus(url, &authz, func() string { return authz.Status })
+	}
+
+	return fmt.Errorf("No %s challenge offered", solver.Type())
+}
+
+// Request a certificate for the hostnames. Returns the
+// PEM encoded certificate chain and private key.
+func (c *acmeClient) obtain(hostnames []string, solver acmeSolver) ([]byte, []byte, error) {
+	identifiers := []map[string]string{}
+	for _, hostname := range hostnames {
+		identifiers = append(identifiers, map[string]string{
+			"type":  "dns",
+			"value": hostname,
+		})
+	}
+
+	res, body, err := c.post(c.directory.NewOrder, map[string]interface{}{
+		"identifiers": identifiers,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	orderURL := res.Header.Get("Location")
+	order := acmeOrder{}
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, nil, err
+	}
+
+	for _, authz := range order.Authorizations {
+		if err := c.authorize(authz, solver); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Finalize the order with a new certificate key
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: hostnames[0]},
+		DNSNames: hostnames,
+	}, certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, _, err := c.post(order.Finalize, map[string]string{"csr": b64(csr)}); err != nil {
+		return nil, nil, err
+	}
+	if err := c.pollStatus(orderURL, &order, func() string { return order.Status }); err != nil {
+		return nil, nil, err
+	}
+
+	_, chain, err := c.post(order.Certificate, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := encodeECKey(certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, keyPEM, nil
+}
+
+func encodeECKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// Load the account key from the cache directory or create a new one
+func loadAccountKey(filename string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("Invalid account key: %s", filename)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, ioutil.WriteFile(filename, keyPEM, 0600)
+}
+
+// acmeTokens holds the key authorizations of pending challenges
+type acmeTokens struct {
+	sync.RWMutex
+	tokens map[string]string
+}
+
+func (t *acmeTokens) Set(token, keyAuth string) {
+	t.Lock()
+	t.tokens[token] = keyAuth
+	t.Unlock()
+}
+
+func (t *acmeTokens) Delete(token string) {
+	t.Lock()
+	delete(t.tokens, token)
+	t.Unlock()
+}
+
+func (t *acmeTokens) Get(token string) (string, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	keyAuth, ok := t.tokens[token]
+	return keyAuth, ok
+}
+
+// The tokens are served by the AcmeManager for HTTP-01
+func (t *acmeTokens) Type() string {
+	return acmeChallengeHTTP
+}
+
+func (t *acmeTokens) Present(domain, token, keyAuth string) error {
+	t.Set(token, keyAuth)
+	return nil
+}
+
+func (t *acmeTokens) CleanUp(domain, token, keyAuth string) error {
+	t.Delete(token)
+	return nil
+}
+
+// acmeDNSHook publishes the TXT records of DNS-01
+// challenges by calling the hook command
+type acmeDNSHook struct {
+	command string
+	wait    time.Duration
+}
+
+func (h *acmeDNSHook) Type() string {
+	return acmeChallengeDNS
+}
+
+// The name and the value of the TXT record (RFC 8555, 8.4)
+func acmeDNSRecord(domain, keyAuth string) (string, string) {
+	sum := sha256.Sum256([]byte(keyAuth))
+	return "_acme-challenge." + domain + ".", b64(sum[:])
+}
+
+func (h *acmeDNSHook) run(action, domain, keyAuth string) error {
+	fqdn, value := acmeDNSRecord(domain, keyAuth)
+	out, err := exec.Command(h.command, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("dns_hook %s %s failed: %v: %s",
+			action, fqdn, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (h *acmeDNSHook) Present(domain, token, keyAuth string) error {
+	if err := h.run("present", domain, keyAuth); err != nil {
+		return err
+	}
+	time.Sleep(h.wait)
+	return nil
+}
+
+func (h *acmeDNSHook) CleanUp(domain, token, keyAuth string) error {
+	return h.run("cleanup", domain, keyAuth)
+}
+
+// AcmeManager obtains and renews the certificate
+type AcmeManager struct {
+	config AcmeConfig
+	tokens *acmeTokens
+	solver acmeSolver
+
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewAcmeManager(config AcmeConfig) (*AcmeManager, error) {
+	if len(config.Hostnames) == 0 {
+		return nil, fmt.Errorf("ACME requires at least one hostname")
+	}
+	if config.Directory == "" {
+		config.Directory = defaultAcmeDirectory
+	}
+	if config.CacheDir == "" {
+		config.CacheDir = defaultAcmeCacheDir
+	}
+	if config.HTTPListen == "" {
+		config.HTTPListen = defaultAcmeHTTPListen
+	}
+	if config.RenewBefore <= 0 {
+		config.RenewBefore = defaultAcmeRenewBefore
+	}
+	if config.Challenge == "" {
+		config.Challenge = acmeChallengeHTTP
+	}
+	if config.Challenge != acmeChallengeHTTP && config.Challenge != acmeChallengeDNS {
+		return nil, fmt.Errorf("Unsupported ACME challenge: %s", config.Challenge)
+	}
+	if config.Challenge == acmeChallengeDNS && config.DNSHook == "" {
+		return nil, fmt.Errorf("The dns-01 challenge requires a dns_hook")
+	}
+	if err := os.MkdirAll(config.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	m := &AcmeManager{
+		config: config,
+		tokens: &acmeTokens{tokens: map[string]string{}},
+	}
+	m.solver = m.tokens
+	if config.Challenge == acmeChallengeDNS {
+		m.solver = &acmeDNSHook{
+			command: config.DNSHook,
+			wait:    time.Duration(config.DNSPropagationWait) * time.Second,
+		}
+	}
+
+	// Use the cached certificate until it is renewed
+	if cert, err := tls.LoadX509KeyPair(m.certFile(), m.keyFile()); err == nil {
+		m.cert = &cert
+	}
+
+	return m, nil
+}
+
+func (m *AcmeManager) certFile() string {
+	return filepath.Join(m.config.CacheDir, m.config.Hostnames[0]+".crt")
+}
+
+func (m *AcmeManager) keyFile() string {
+	return filepath.Join(m.config.CacheDir, m.config.Hostnames[0]+".key")
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (m *AcmeManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if m.cert == nil {
+		return nil, fmt.Errorf("No certificate available yet")
+	}
+	return m.cert, nil
+}
+
+// ServeHTTP answers the HTTP-01 challenges
+func (m *AcmeManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, acmeChallengePath) {
+		http.NotFound(w, r)
+		return
+	}
+	keyAuth, ok := m.tokens.Get(strings.TrimPrefix(r.URL.Path, acmeChallengePath))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
+
+// Check if the certificate is missing or expires soon
+func (m *AcmeManager) needsRenewal(now time.Time) bool {
+	m.RLock()
+	defer m.RUnlock()
+	if m.cert == nil || len(m.cert.Certificate) == 0 {
+		return true
+	}
+
+	leaf, err := x509.ParseCertificate(m.cert.Certificate[0])
+	if err != nil {
+		return true
+	}
+	renewAt := leaf.NotAfter.Add(-time.Duration(m.config.RenewBefore) * 24 * time.Hour)
+	return now.After(renewAt)
+}
+
+func (m *AcmeManager) renew() error {
+	key, err := loadAccountKey(filepath.Join(m.config.CacheDir, "account.key"))
+	if err != nil {
+		return err
+	}
+
+	client := &acmeClient{
+		directoryURL: m.config.Directory,
+		key:          key,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		pollInterval: 2 * time.Second,
+	}
+	if err := client.fetchDirectory(); err != nil {
+		return err
+	}
+	if err := client.register(m.config.Email); err != nil {
+		return err
+	}
+
+	chain, keyPEM, err := client.obtain(m.config.Hostnames, m.solver)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.X509KeyPair(chain, keyPEM)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(m.certFile(), chain, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(m.keyFile(), keyPEM, 0600); err != nil {
+		return err
+	}
+
+	m.Lock()
+	m.cert = &cert
+	m.Unlock()
+	return nil
+}
+
+// Run obtains the certificate and renews it before it expires
+func (m *AcmeManager) Run() {
+	for {
+		if m.needsRenewal(time.Now()) {
+			log.Println("Requesting certificate via ACME for:", strings.Join(m.config.Hostnames, ", "))
+			if err := m.renew(); err != nil {
+				log.Println("Could not obtain certificate:", err)
+				time.Sleep(10 * time.Minute)
+				continue
+			}
+			log.Println("Obtained certificate via ACME")
+		}
+		time.Sleep(12 * time.Hour)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A minimal ACME server accepting every order
+type fakeAcmeServer struct {
+	t       *testing.T
+	url     string
+	key     *ecdsa.PrivateKey
+	tokens  *acmeTokens
+	thumb   string
+	solved  bool
+	csrDNS  []string
+	account *ecdsa.PublicKey
+}
+
+func (f *fakeAcmeServer) verify(r *http.Request) []byte {
+	jws := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		f.t.Fatal(err)
+	}
+
+	header, _ := base64.RawURLEncoding.DecodeString(jws["protected"])
+	protected := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		URL string `json:"url"`
+		Jwk struct {
+			X string `json:"x"`
+			Y string `json:"y"`
+		} `json:"jwk"`
+	}{}
+	if err := json.Unmarshal(header, &protected); err != nil {
+		f.t.Fatal(err)
+	}
+	if protected.URL != f.url+r.URL.Path {
+		f.t.Error("Unexpected url in protected header:", protected.URL)
+	}
+
+	if protected.Kid == "" {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.Jwk.X)
+		y, _ := base64.RawURLEncoding.DecodeString(protected.Jwk.Y)
+		f.account = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(jws["signature"])
+	digest := sha256.Sum256([]byte(jws["protected"] + "." + jws["payload"]))
+	rs := new(big.Int).SetBytes(signature[:32])
+	ss := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(f.account, digest[:], rs, ss) {
+		f.t.Error("Invalid JWS signature for", r.URL.Path)
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(jws["payload"])
+	return payload
+}
+
+func (f *fakeAcmeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", "nonce")
+
+	switch r.URL.Path {
+	case "/directory":
+		json.NewEncoder(w).Encode(acmeDirectory{
+			NewNonce:   f.url + "/nonce",
+			NewAccount: f.url + "/account",
+			NewOrder:   f.url + "/order",
+		})
+		return
+	case "/nonce":
+		return
+	}
+
+	payload := f.verify(r)
+
+	switch r.URL.Path {
+	case "/account":
+		w.Header().Set("Location", f.url+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+	case "/order":
+		w.Header().Set("Location", f.url+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(acmeOrder{
+			Status:         "pending",
+			Authorizations: []string{f.url + "/authz/1"},
+			Finalize:       f.url + "/finalize/1",
+		})
+	case "/authz/1":
+		status := "pending"
+		if f.solved {
+			status = "valid"
+		}
+		json.NewEncoder(w).Encode(acmeAuthorization{
+			Status:     status,
+			Identifier: acmeIdentifier{Type: "dns", Value: "lg.example.net"},
+			Challenges: []acmeChallenge{
+				{Type: "dns-01", URL: f.url + "/chall/dns", Token: "dnstoken"},
+				{Type: "http-01", URL: f.url + "/chall/1", Token: "token1"},
+			},
+		})
+	case "/chall/1":
+		keyAuth, ok := f.tokens.Get("token1")
+		if !ok || keyAuth != "token1."+f.thumb {
+			f.t.Error("Unexpected key authorization:", keyAuth)
+		}
+		f.solved = true
+		w.Write([]byte("{}"))
+	case "/chall/dns":
+		f.solved = true
+		w.Write([]byte("{}"))
+	case "/finalize/1":
+		req := map[string]string{}
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req["csr"])
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		f.csrDNS = csr.DNSNames
+		w.Write([]byte("{}"))
+	case "/order/1":
+		json.NewEncoder(w).Encode(acmeOrder{
+			Status:      "valid",
+			Certificate: f.url + "/cert/1",
+		})
+	case "/cert/1":
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			DNSNames:     f.csrDNS,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+		// The public key of the certificate is not checked
+		// by this test, so the CA key is certified.
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &f.key.PublicKey, f.key)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestAcmeObtain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	fake := &fakeAcmeServer{t: t, key: caKey}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fake.url = server.URL
+
+	m, err := NewAcmeManager(AcmeConfig{
+		Hostnames: []string{"lg.example.net"},
+		Directory: server.URL + "/directory",
+		CacheDir:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.tokens = m.tokens
+
+	key, err := loadAccountKey(dir + "/account.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.thumb = acmeThumbprint(key)
+
+	if !m.needsRenewal(time.Now()) {
+		t.Error("Expected renewal without certificate")
+	}
+
+	client := &acmeClient{
+		directoryURL: m.config.Directory,
+		key:          key,
+		client:       server.Client(),
+	}
+	if err := client.fetchDirectory(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.register(""); err != nil {
+		t.Fatal(err)
+	}
+	if client.kid != server.URL+"/account/1" {
+		t.Error("Unexpected account url:", client.kid)
+	}
+
+	chain, _, err := client.obtain(m.config.Hostnames, m.tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(chain), "BEGIN CERTIFICATE") {
+		t.Error("Expected a certificate chain, got:", string(chain))
+	}
+	if len(fake.csrDNS) != 1 || fake.csrDNS[0] != "lg.example.net" {
+		t.Error("Unexpected names in CSR:", fake.csrDNS)
+	}
+	if _, ok := m.tokens.Get("token1"); ok {
+		t.Error("Expected the challenge token to be removed")
+	}
+}
+
+func TestAcmeChallengeHandler(t *testing.T) {
+	m := &AcmeManager{tokens: &acmeTokens{tokens: map[string]string{}}}
+	m.tokens.Set("abc", "abc.thumb")
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", acmeChallengePath+"abc", nil))
+	if rec.Body.String() != "abc.thumb" {
+		t.Error("Unexpected key authorization:", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", acmeChallengePath+"unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected 404 for an unknown token, got:", rec.Code)
+	}
+}
+
+func TestAcmeObtainDNS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The hook logs its arguments
+	hook := filepath.Join(dir, "hook.sh")
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(dir, "hook.log") + "\n"
+	if err := ioutil.WriteFile(hook, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	fake := &fakeAcmeServer{t: t, key: caKey}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fake.url = server.URL
+
+	_, err = NewAcmeManager(AcmeConfig{
+		Hostnames: []string{"lg.example.net"},
+		CacheDir:  dir,
+		Challenge: "dns-01",
+	})
+	if err == nil {
+		t.Error("Expected an error without dns_hook")
+	}
+
+	m, err := NewAcmeManager(AcmeConfig{
+		Hostnames: []string{"lg.example.net"},
+		Directory: server.URL + "/directory",
+		CacheDir:  dir,
+		Challenge: "dns-01",
+		DNSHook:   hook,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := loadAccountKey(dir + "/account.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &acmeClient{
+		directoryURL: m.config.Directory,
+		key:          key,
+		client:       server.Client(),
+	}
+	if err := client.fetchDirectory(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.register(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.obtain(m.config.Hostnames, m.solver); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("dnstoken." + acmeThumbprint(key)))
+	record := "_acme-challenge.lg.example.net. " + base64.RawURLEncoding.EncodeToString(sum[:])
+	expected := "present " + record + "\ncleanup " + record + "\n"
+	log, _ := ioutil.ReadFile(filepath.Join(dir, "hook.log"))
+	if string(log) != expected {
+		t.Error("Unexpected hook calls:", string(log))
+	}
+}
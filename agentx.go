@@ -0,0 +1,455 @@
+package main
+
+// A minimal AgentX (RFC 2741) subagent: The subagent registers
+// subtrees with the master agent (e.g. net-snmp snmpd) and
+// answers Get, GetNext and GetBulk requests. Sets are refused.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PDU types
+const (
+	agentxOpen     = 1
+	agentxClose    = 2
+	agentxRegister = 3
+	agentxGet      = 5
+	agentxGetNext  = 6
+	agentxGetBulk  = 7
+	agentxTestSet  = 8
+	agentxResponse = 18
+)
+
+// Header flags
+const (
+	agentxFlagNonDefaultContext = 0x08
+	agentxFlagNetworkByteOrder  = 0x10
+)
+
+// Value types
+const (
+	snmpInteger        = 2
+	snmpOctetString    = 4
+	snmpOID            = 6
+	snmpIPAddress      = 64
+	snmpCounter32      = 65
+	snmpGauge32        = 66
+	snmpTimeTicks      = 67
+	snmpCounter64      = 70
+	snmpNoSuchObject   = 128
+	snmpNoSuchInstance = 129
+	snmpEndOfMibView   = 130
+)
+
+const agentxNotWritable = 17
+
+type oid []uint32
+
+func parseOID(s string) (oid, error) {
+	res := oid{}
+	for _, part := range strings.Split(strings.Trim(s, "."), ".") {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid OID: %s", s)
+		}
+		res = append(res, uint32(n))
+	}
+	return res, nil
+}
+
+func mustParseOID(s string) oid {
+	o, err := parseOID(s)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+func (o oid) String() string {
+	parts := make([]string, len(o))
+	for i, n := range o {
+		parts[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+func (o oid) append(subids ...uint32) oid {
+	res := make(oid, 0, len(o)+len(subids))
+	res = append(res, o...)
+	return append(res, subids...)
+}
+
+func (o oid) hasPrefix(prefix oid) bool {
+	return len(o) >= len(prefix) && compareOID(o[:len(prefix)], prefix) == 0
+}
+
+func compareOID(a, b oid) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+type varbind struct {
+	name  oid
+	kind  uint16
+	value interface{}
+}
+
+// A view of the MIB, sorted by OID
+type mibView []varbind
+
+func (m mibView) sort() {
+	sort.Slice(m, func(i, j int) bool {
+		return compareOID(m[i].name, m[j].name) < 0
+	})
+}
+
+func (m mibView) get(name oid) varbind {
+	i := sort.Search(len(m), func(i int) bool {
+		return compareOID(m[i].name, name) >= 0
+	})
+	if i < len(m) && compareOID(m[i].name, name) == 0 {
+		return m[i]
+	}
+	return varbind{name: name, kind: snmpNoSuchObject}
+}
+
+// The successor of start, or start itself if include is set.
+// The end OID is excluded, an empty end is unbounded.
+func (m mibView) next(start oid, include bool, end oid) varbind {
+	i := sort.Search(len(m), func(i int) bool {
+		c := compareOID(m[i].name, start)
+		return c > 0 || (include && c == 0)
+	})
+	if i < len(m) && (len(end) == 0 || compareOID(m[i].name, end) < 0) {
+		return m[i]
+	}
+	return varbind{name: start, kind: snmpEndOfMibView}
+}
+
+// Encoding in network byte order
+
+type agentxWriter struct {
+	bytes.Buffer
+}
+
+func (w *agentxWriter) uint16(v uint16) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *agentxWriter) uint32(v uint32) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *agentxWriter) uint64(v uint64) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *agentxWriter) oid(o oid, include bool) {
+	prefix := uint32(0)
+	if len(o) > 5 && compareOID(o[:4], oid{1, 3, 6, 1}) == 0 && o[4] < 256 {
+		prefix = o[4]
+		o = o[5:]
+	}
+
+	w.WriteByte(byte(len(o)))
+	w.WriteByte(byte(prefix))
+	if include {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+	w.WriteByte(0)
+	for _, n := range o {
+		w.uint32(n)
+	}
+}
+
+func (w *agentxWriter) octets(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.Write(b)
+	for i := len(b); i%4 != 0; i++ {
+		w.WriteByte(0)
+	}
+}
+
+func (w *agentxWriter) varbind(vb varbind) {
+	w.uint16(vb.kind)
+	w.uint16(0)
+	w.oid(vb.name, false)
+
+	switch vb.kind {
+	case snmpInteger, snmpCounter32, snmpGauge32, snmpTimeTicks:
+		w.uint32(vb.value.(uint32))
+	case snmpCounter64:
+		w.uint64(vb.value.(uint64))
+	case snmpOctetString, snmpIPAddress:
+		w.octets(vb.value.([]byte))
+	case snmpOID:
+		w.oid(vb.value.(oid), false)
+	}
+}
+
+type agentxHeader struct {
+	kind          byte
+	flags         byte
+	sessionID     uint32
+	transactionID uint32
+	packetID      uint32
+}
+
+func writePDU(w io.Writer, h agentxHeader, payload []byte) error {
+	buf := &agentxWriter{}
+	buf.WriteByte(1) // version
+	buf.WriteByte(h.kind)
+	buf.WriteByte(h.flags | agentxFlagNetworkByteOrder)
+	buf.WriteByte(0)
+	buf.uint32(h.sessionID)
+	buf.uint32(h.transactionID)
+	buf.uint32(h.packetID)
+	buf.uint32(uint32(len(payload)))
+	buf.Write(payload)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Decoding in the byte order of the sender
+
+type agentxReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *agentxReader) take(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = fmt.Errorf("AgentX PDU too short")
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *agentxReader) uint16() uint16 {
+	return r.order.Uint16(r.take(2))
+}
+
+func (r *agentxReader) uint32() uint32 {
+	return r.order.Uint32(r.take(4))
+}
+
+func (r *agentxReader) oid() (oid, bool) {
+	head := r.take(4)
+	n, prefix, include := int(head[0]), head[1], head[2] == 1
+
+	res := oid{}
+	if prefix != 0 {
+		res = append(res, 1, 3, 6, 1, uint32(prefix))
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		res = append(res, r.uint32())
+	}
+	return res, include
+}
+
+func (r *agentxReader) octets() []byte {
+	n := int(r.uint32())
+	b := r.take(n)
+	if pad := (4 - n%4) % 4; pad > 0 {
+		r.take(pad)
+	}
+	return b
+}
+
+func readPDU(rd io.Reader) (agentxHeader, *agentxReader, error) {
+	raw := make([]byte, 20)
+	if _, err := io.ReadFull(rd, raw); err != nil {
+		return agentxHeader{}, nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if raw[2]&agentxFlagNetworkByteOrder != 0 {
+		order = binary.BigEndian
+	}
+
+	h := agentxHeader{
+		kind:          raw[1],
+		flags:         raw[2],
+		sessionID:     order.Uint32(raw[4:8]),
+		transactionID: order.Uint32(raw[8:12]),
+		packetID:      order.Uint32(raw[12:16]),
+	}
+
+	payload := make([]byte, order.Uint32(raw[16:20]))
+	if _, err := io.ReadFull(rd, payload); err != nil {
+		return h, nil, err
+	}
+
+	r := &agentxReader{data: payload, order: order}
+	if h.flags&agentxFlagNonDefaultContext != 0 {
+		r.octets() // The context is ignored
+	}
+	return h, r, nil
+}
+
+// AgentxSession is the connection of the subagent
+// to the master agent.
+type AgentxSession struct {
+	conn      net.Conn
+	sessionID uint32
+	packetID  uint32
+}
+
+func (s *AgentxSession) request(kind byte, payload []byte) (*agentxReader, error) {
+	s.packetID++
+	h := agentxHeader{kind: kind, sessionID: s.sessionID, packetID: s.packetID}
+	if err := writePDU(s.conn, h, payload); err != nil {
+		return nil, err
+	}
+
+	res, r, err := readPDU(s.conn)
+	if err != nil {
+		return nil, err
+	}
+	if res.kind != agentxResponse {
+		return nil, fmt.Errorf("Unexpected AgentX PDU type: %d", res.kind)
+	}
+	s.sessionID = res.sessionID
+
+	r.uint32() // sysUpTime
+	if code := r.uint16(); code != 0 {
+		return nil, fmt.Errorf("AgentX error: %d", code)
+	}
+	return r, nil
+}
+
+// Open a session with the master agent
+func OpenAgentxSession(conn net.Conn, id oid, descr string) (*AgentxSession, error) {
+	s := &AgentxSession{conn: conn}
+
+	payload := &agentxWriter{}
+	payload.WriteByte(0) // default timeout
+	payload.Write([]byte{0, 0, 0})
+	payload.oid(id, false)
+	payload.octets([]byte(descr))
+
+	if _, err := s.request(agentxOpen, payload.Bytes()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Register a subtree
+func (s *AgentxSession) Register(subtree oid) error {
+	payload := &agentxWriter{}
+	payload.WriteByte(0)   // default timeout
+	payload.WriteByte(127) // default priority
+	payload.WriteByte(0)   // no range
+	payload.WriteByte(0)
+	payload.oid(subtree, false)
+
+	_, err := s.request(agentxRegister, payload.Bytes())
+	return err
+}
+
+func (s *AgentxSession) respond(h agentxHeader, code uint16, varbinds []varbind) error {
+	payload := &agentxWriter{}
+	payload.uint32(0) // sysUpTime
+	payload.uint16(code)
+	payload.uint16(0) // index
+	for _, vb := range varbinds {
+		payload.varbind(vb)
+	}
+
+	h.kind = agentxResponse
+	h.flags = 0
+	return writePDU(s.conn, h, payload.Bytes())
+}
+
+// Serve the requests of the master agent. The view is
+// created for every request.
+func (s *AgentxSession) Serve(view func() mibView) error {
+	for {
+		h, r, err := readPDU(s.conn)
+		if err != nil {
+			return err
+		}
+
+		switch h.kind {
+		case agentxGet, agentxGetNext:
+			m := view()
+			varbinds := []varbind{}
+			for len(r.data) > 0 && r.err == nil {
+				start, include := r.oid()
+				end, _ := r.oid()
+				if h.kind == agentxGet {
+					varbinds = append(varbinds, m.get(start))
+				} else {
+					varbinds = append(varbinds, m.next(start, include, end))
+				}
+			}
+			err = s.respond(h, 0, varbinds)
+		case agentxGetBulk:
+			err = s.respond(h, 0, getBulk(view(), r))
+		case agentxTestSet:
+			err = s.respond(h, agentxNotWritable, nil)
+		case agentxClose:
+			return fmt.Errorf("AgentX session closed by master agent")
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// The repeated variables are interleaved as in SNMP GetBulk
+func getBulk(m mibView, r *agentxReader) []varbind {
+	nonRepeaters := int(r.uint16())
+	maxRepetitions := int(r.uint16())
+
+	type searchRange struct {
+		start   oid
+		include bool
+		end     oid
+	}
+	ranges := []searchRange{}
+	for len(r.data) > 0 && r.err == nil {
+		start, include := r.oid()
+		end, _ := r.oid()
+		ranges = append(ranges, searchRange{start, include, end})
+	}
+
+	varbinds := []varbind{}
+	for i := 0; i < nonRepeaters && i < len(ranges); i++ {
+		varbinds = append(varbinds, m.next(ranges[i].start, ranges[i].include, ranges[i].end))
+	}
+
+	repeaters := []searchRange{}
+	if nonRepeaters < len(ranges) {
+		repeaters = ranges[nonRepeaters:]
+	}
+	for j := 0; j < maxRepetitions; j++ {
+		for k := range repeaters {
+			vb := m.next(repeaters[k].start, repeaters[k].include, repeaters[k].end)
+			varbinds = append(varbinds, vb)
+			repeaters[k].start, repeaters[k].include = vb.name, false
+		}
+	}
+	return varbinds
+}
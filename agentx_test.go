@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func testMibView() mibView {
+	status := bird.Parsed{"router_id": "192.0.2.254"}
+	protocols := bird.Parsed{
+		"R192_1": bird.Parsed{
+			"neighbor_address": "192.0.2.1",
+			"neighbor_id":      "198.51.100.1",
+			"neighbor_as":      int64(65001),
+			"local_as":         int64(65000),
+			"bgp_state":        "Established",
+			"state":            "up",
+			"routes":           bird.Parsed{"imported": int64(42)},
+		},
+		"R6": bird.Parsed{
+			"neighbor_address": "2001:db8::1",
+			"bgp_state":        "Established",
+		},
+	}
+	return bgpMibView(status, protocols, mustParseOID("1.3.6.1.4.1.99999.1"))
+}
+
+func TestBgpMibView(t *testing.T) {
+	m := testMibView()
+
+	state := m.get(mustParseOID("1.3.6.1.2.1.15.3.1.2.192.0.2.1"))
+	if state.kind != snmpInteger || state.value.(uint32) != 6 {
+		t.Error("Expected bgpPeerState established, got:", state)
+	}
+
+	remoteAs := m.get(mustParseOID("1.3.6.1.2.1.15.3.1.9.192.0.2.1"))
+	if remoteAs.value.(uint32) != 65001 {
+		t.Error("Unexpected bgpPeerRemoteAs:", remoteAs)
+	}
+
+	imported := m.get(mustParseOID("1.3.6.1.4.1.99999.1.1.1.192.0.2.1"))
+	if imported.kind != snmpGauge32 || imported.value.(uint32) != 42 {
+		t.Error("Unexpected imported prefixes:", imported)
+	}
+
+	// The first object of the MIB is bgpLocalAs
+	first := m.next(mustParseOID("1.3.6.1.2.1.15"), false, nil)
+	if first.name.String() != "1.3.6.1.2.1.15.2.0" {
+		t.Error("Unexpected first object:", first.name)
+	}
+
+	last := m.next(mustParseOID("1.3.6.1.4.1.99999.1.1.4.192.0.2.1"), false, nil)
+	if last.kind != snmpEndOfMibView {
+		t.Error("Expected the end of the MIB view, got:", last)
+	}
+}
+
+// Act as master agent on the other end of the connection
+func TestAgentxSession(t *testing.T) {
+	master, subagent := net.Pipe()
+	defer master.Close()
+
+	done := make(chan error)
+	go func() {
+		session, err := OpenAgentxSession(subagent, bgpMibOid, "birdwatcher")
+		if err != nil {
+			done <- err
+			return
+		}
+		if err := session.Register(bgpMibOid); err != nil {
+			done <- err
+			return
+		}
+		done <- session.Serve(testMibView)
+	}()
+
+	respond := func(kind byte) {
+		h, _, err := readPDU(master)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.kind != kind {
+			t.Fatal("Expected PDU type", kind, "got:", h.kind)
+		}
+		payload := &agentxWriter{}
+		payload.uint32(0)
+		payload.uint16(0)
+		payload.uint16(0)
+		writePDU(master, agentxHeader{kind: agentxResponse, sessionID: 42, packetID: h.packetID}, payload.Bytes())
+	}
+	respond(agentxOpen)
+	respond(agentxRegister)
+
+	request := &agentxWriter{}
+	request.oid(mustParseOID("1.3.6.1.2.1.15.3.1.2"), false)
+	request.oid(nil, false)
+	writePDU(master, agentxHeader{kind: agentxGetNext, sessionID: 42, packetID: 1}, request.Bytes())
+
+	h, r, err := readPDU(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.kind != agentxResponse || h.sessionID != 42 {
+		t.Fatal("Unexpected response header:", h)
+	}
+	r.uint32()
+	if code := r.uint16(); code != 0 {
+		t.Error("Unexpected error code:", code)
+	}
+	r.uint16()
+
+	kind := r.uint16()
+	r.uint16()
+	name, _ := r.oid()
+	value := r.uint32()
+	if kind != snmpInteger || name.String() != "1.3.6.1.2.1.15.3.1.2.192.0.2.1" || value != 6 {
+		t.Error("Unexpected varbind:", kind, name, value)
+	}
+
+	writePDU(master, agentxHeader{kind: agentxClose, sessionID: 42}, []byte{1, 0, 0, 0})
+	if err := <-done; err == nil {
+		t.Error("Expected the session to end")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+// Alerts: Webhooks are sent when a BGP session is down for
+// too long, the number of imported prefixes drops or a
+// session gets close to its import limit.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+type AlertsConfig struct {
+	Enabled  bool `toml:"enabled"`
+	Interval int  `toml:"interval"` // seconds
+
+	WebhookURL string `toml:"webhook_url"`
+	// The format is one of generic, slack or mattermost
+	Format string `toml:"format"`
+
+	// Conditions, a value of 0 disables the condition
+	SessionDownAfter  int     `toml:"session_down_after"` // seconds
+	PrefixDropPercent float64 `toml:"prefix_drop_percent"`
+	MaxPrefixPercent  float64 `toml:"max_prefix_percent"`
+}
+
+type Alert struct {
+	Alert    string    `json:"alert"` // session_down, session_up, prefix_drop or max_prefix
+	Protocol string    `json:"protocol"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// The state of a protocol between the polls
+type alertState struct {
+	downSince      time.Time
+	downAlerted    bool
+	maxPrefixAlert bool
+	imported       int64
+	importedKnown  bool
+}
+
+type Alerter struct {
+	config AlertsConfig
+	client *http.Client
+	states map[string]*alertState
+}
+
+func NewAlerter(config AlertsConfig) *Alerter {
+	return &Alerter{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		states: make(map[string]*alertState),
+	}
+}
+
+// Check the protocols against the conditions
+func (a *Alerter) check(protocols bird.Parsed, now time.Time) []Alert {
+	names := []string{}
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	alerts := []Alert{}
+	alert := func(kind, name, format string, args ...interface{}) {
+		alerts = append(alerts, Alert{
+			Alert:    kind,
+			Protocol: name,
+			Message:  fmt.Sprintf("%s: ", name) + fmt.Sprintf(format, args...),
+			Time:     now,
+		})
+	}
+
+	for _, name := range names {
+		protocol, ok := protocols[name].(bird.Parsed)
+		if !ok {
+			continue
+		}
+		state, ok := a.states[name]
+		if !ok {
+			state = &alertState{}
+			a.states[name] = state
+		}
+
+		// Session state
+		if protocol["state"] != "up" {
+			if state.downSince.IsZero() {
+				state.downSince = now
+			}
+			down := now.Sub(state.downSince)
+			if a.config.SessionDownAfter > 0 && !state.downAlerted &&
+				down >= time.Duration(a.config.SessionDownAfter)*time.Second {
+				state.downAlerted = true
+				alert("session_down", name, "session down for %v (%v)",
+					down, protocol["last_error"])
+			}
+		} else {
+			if state.downAlerted {
+				alert("session_up", name, "session up again")
+			}
+			state.downSince = time.Time{}
+			state.downAlerted = false
+		}
+
+		routes, _ := protocol["routes"].(bird.Parsed)
+		imported, _ := routes["imported"].(int64)
+
+		// Drop of imported prefixes since the last poll
+		if a.config.PrefixDropPercent > 0 && state.importedKnown && state.imported > 0 {
+			drop := float64(state.imported-imported) / float64(state.imported) * 100
+			if drop > a.config.PrefixDropPercent {
+				alert("prefix_drop", name, "imported prefixes dropped by %.1f%% from %d to %d",
+					drop, state.imported, imported)
+			}
+		}
+		state.imported = imported
+		state.importedKnown = true
+
+		// Imported prefixes close to the import limit
+		limit, _ := protocol["import_limit"].(int64)
+		if a.config.MaxPrefixPercent > 0 && limit > 0 {
+			usage := float64(imported) / float64(limit) * 100
+			if usage >= a.config.MaxPrefixPercent {
+				if !state.maxPrefixAlert {
+					alert("max_prefix", name, "%d imported prefixes are %.1f%% of the import limit %d",
+						imported, usage, limit)
+				}
+				state.maxPrefixAlert = true
+			} else {
+				state.maxPrefixAlert = false
+			}
+		}
+	}
+
+	return alerts
+}
+
+func (a *Alerter) payload(alert Alert) ([]byte, error) {
+	switch a.config.Format {
+	case "slack", "mattermost":
+		return json.Marshal(map[string]string{"text": alert.Message})
+	}
+	return json.Marshal(alert)
+}
+
+func (a *Alerter) send(alert Alert) error {
+	payload, err := a.payload(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(a.config.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded: %s", resp.Status)
+	}
+	return nil
+}
+
+// Poll the BGP protocols once and send the alerts
+func (a *Alerter) Poll(now time.Time) {
+	res, _ := bird.ProtocolsBgp(false)
+	if bird.IsSpecial(res) {
+		return
+	}
+	protocols, _ := res["protocols"].(bird.Parsed)
+
+	for _, alert := range a.check(protocols, now) {
+		log.Println("Alert:", alert.Message)
+		if err := a.send(alert); err != nil {
+			log.Println("Could not send alert:", err)
+		}
+	}
+}
+
+func (a *Alerter) Run() {
+	interval := time.Duration(a.config.Interval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	for {
+		a.Poll(time.Now())
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func bgpProtocol(state string, imported, limit int64) bird.Parsed {
+	return bird.Parsed{
+		"state":        state,
+		"import_limit": limit,
+		"routes":       bird.Parsed{"imported": imported},
+	}
+}
+
+func TestAlerterSessionDown(t *testing.T) {
+	a := NewAlerter(AlertsConfig{SessionDownAfter: 60})
+	now := time.Now()
+
+	alerts := a.check(bird.Parsed{"R1": bgpProtocol("start", 0, 0)}, now)
+	if len(alerts) != 0 {
+		t.Error("Expected no alert before the session is down long enough:", alerts)
+	}
+
+	alerts = a.check(bird.Parsed{"R1": bgpProtocol("start", 0, 0)}, now.Add(time.Minute))
+	if len(alerts) != 1 || alerts[0].Alert != "session_down" {
+		t.Error("Expected a session_down alert, got:", alerts)
+	}
+
+	// No repeated alert
+	alerts = a.check(bird.Parsed{"R1": bgpProtocol("start", 0, 0)}, now.Add(2*time.Minute))
+	if len(alerts) != 0 {
+		t.Error("Expected no repeated alert:", alerts)
+	}
+
+	alerts = a.check(bird.Parsed{"R1": bgpProtocol("up", 0, 0)}, now.Add(3*time.Minute))
+	if len(alerts) != 1 || alerts[0].Alert != "session_up" {
+		t.Error("Expected a session_up alert, got:", alerts)
+	}
+}
+
+func TestAlerterPrefixes(t *testing.T) {
+	a := NewAlerter(AlertsConfig{PrefixDropPercent: 50, MaxPrefixPercent: 90})
+	now := time.Now()
+
+	alerts := a.check(bird.Parsed{"R1": bgpProtocol("up", 1000, 2000)}, now)
+	if len(alerts) != 0 {
+		t.Error("Expected no alerts:", alerts)
+	}
+
+	alerts = a.check(bird.Parsed{"R1": bgpProtocol("up", 400, 2000)}, now)
+	if len(alerts) != 1 || alerts[0].Alert != "prefix_drop" {
+		t.Error("Expected a prefix_drop alert, got:", alerts)
+	}
+
+	alerts = a.check(bird.Parsed{"R1": bgpProtocol("up", 1900, 2000)}, now)
+	if len(alerts) != 1 || alerts[0].Alert != "max_prefix" {
+		t.Error("Expected a max_prefix alert, got:", alerts)
+	}
+}
+
+func TestAlerterSend(t *testing.T) {
+	payload := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	a := NewAlerter(AlertsConfig{WebhookURL: server.URL, Format: "slack"})
+	err := a.send(Alert{Alert: "session_down", Protocol: "R1", Message: "R1: down"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload["text"] != "R1: down" {
+		t.Error("Unexpected slack payload:", payload)
+	}
+}
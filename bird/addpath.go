@@ -0,0 +1,58 @@
+package bird
+
+// ADD-PATH (RFC 7911)
+//
+// With add-path, a neighbor announces several paths of a prefix
+// and BIRD lists all of them. The paths of a prefix learned from
+// the same protocol are numbered in the order of BIRD, the best
+// path first, as the path identifiers are not shown by BIRD.
+
+func routePathKey(route Parsed) string {
+	protocol, _ := route["from_protocol"].(string)
+	return routeNetwork(route) + "|" + protocol
+}
+
+// assignPathIds sets the path_id of the routes
+func assignPathIds(routes []Parsed) {
+	paths := map[string]int64{}
+	for _, route := range routes {
+		key := routePathKey(route)
+		paths[key]++
+		route["path_id"] = paths[key]
+	}
+}
+
+// BestPaths keeps the best path of each prefix and protocol,
+// which is the primary route or the first path.
+func BestPaths(routes []Parsed) []Parsed {
+	best := map[string]int{}
+	res := []Parsed{}
+
+	for _, route := range routes {
+		key := routePathKey(route)
+		i, seen := best[key]
+		if !seen {
+			best[key] = len(res)
+			res = append(res, route)
+			continue
+		}
+
+		primary, _ := route["primary"].(bool)
+		selected, _ := res[i]["primary"].(bool)
+		if primary && !selected {
+			res[i] = route
+		}
+	}
+	return res
+}
+
+// HasAddPaths checks if a protocol announced several
+// paths of a prefix
+func HasAddPaths(routes []Parsed) bool {
+	for _, route := range routes {
+		if id, _ := route["path_id"].(int64); id > 1 {
+			return true
+		}
+	}
+	return false
+}
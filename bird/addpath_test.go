@@ -0,0 +1,47 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestAssignPathIds(t *testing.T) {
+	routes := []Parsed{
+		{"network": "10.0.0.0/8", "from_protocol": "R1", "primary": true},
+		{"network": "10.0.0.0/8", "from_protocol": "R1"},
+		{"network": "10.0.0.0/8", "from_protocol": "R2"},
+		{"network": "10.1.0.0/16", "from_protocol": "R1"},
+	}
+	assignPathIds(routes)
+
+	expected := []int64{1, 2, 1, 1}
+	for i, route := range routes {
+		if route["path_id"] != expected[i] {
+			t.Error("Expected path_id", expected[i], "got:", route["path_id"])
+		}
+	}
+	if !HasAddPaths(routes) {
+		t.Error("Expected add-path routes")
+	}
+	if HasAddPaths(routes[2:]) {
+		t.Error("Expected no add-path routes")
+	}
+}
+
+func TestBestPaths(t *testing.T) {
+	routes := []Parsed{
+		{"network": "10.0.0.0/8", "from_protocol": "R1", "gateway": "a"},
+		{"network": "10.0.0.0/8", "from_protocol": "R1", "gateway": "b", "primary": true},
+		{"network": "10.0.0.0/8", "from_protocol": "R2", "gateway": "c"},
+		{"network": "10.1.0.0/16", "from_protocol": "R1", "gateway": "d"},
+	}
+	best := BestPaths(routes)
+	if len(best) != 3 {
+		t.Fatal("Expected 3 paths, got:", len(best))
+	}
+	if best[0]["gateway"] != "b" {
+		t.Error("Expected the primary path, got:", best[0]["gateway"])
+	}
+	if best[1]["gateway"] != "c" || best[2]["gateway"] != "d" {
+		t.Error("Expected the paths in order, got:", best)
+	}
+}
@@ -0,0 +1,198 @@
+package bird
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Route leak analysis: Count the routes each peer originates itself
+// and the routes with third party origins. For peers tagged as
+// customer, routes with an upstream ASN in the AS path are flagged
+// as possible route leaks.
+
+func RouteLeaks(useCache bool) (Parsed, bool) {
+	protocols, from_cache := ProtocolsBgp(useCache)
+	if IsSpecial(protocols) {
+		return protocols, from_cache
+	}
+
+	routes, _ := RoutesTable(useCache, "master")
+	if IsSpecial(routes) {
+		return routes, from_cache
+	}
+
+	bgpProtocols, _ := protocols["protocols"].(Parsed)
+	tableRoutes, _ := routes["routes"].([]Parsed)
+
+	return Parsed{
+		"peers":     analyzeRouteLeaks(bgpProtocols, tableRoutes),
+		"ttl":       routes["ttl"],
+		"cached_at": routes["cached_at"],
+	}, from_cache
+}
+
+func analyzeRouteLeaks(protocols Parsed, routes []Parsed) Parsed {
+	upstreams := map[string]bool{}
+	for _, asn := range AnalysisConf.UpstreamAsns {
+		upstreams[strconv.FormatInt(asn, 10)] = true
+	}
+
+	res := Parsed{}
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+
+		neighborAs, _ := protocol["neighbor_as"].(int64)
+		res[name] = Parsed{
+			"neighbor_as":        neighborAs,
+			"relationship":       PeersConf[name].Relationship,
+			"routes":             int64(0),
+			"own_origin":         int64(0),
+			"third_party_origin": int64(0),
+			"upstream_asns_seen": map[string]int64{},
+			"possible_leak":      false,
+		}
+	}
+
+	for _, route := range routes {
+		name, _ := route["from_protocol"].(string)
+		peer, ok := res[name].(Parsed)
+		if !ok {
+			continue
+		}
+
+		path := routeAsPath(route)
+		if len(path) == 0 {
+			continue
+		}
+
+		peer["routes"] = peer["routes"].(int64) + 1
+
+		origin := path[len(path)-1]
+		if origin == strconv.FormatInt(peer["neighbor_as"].(int64), 10) {
+			peer["own_origin"] = peer["own_origin"].(int64) + 1
+		} else {
+			peer["third_party_origin"] = peer["third_party_origin"].(int64) + 1
+		}
+
+		if peer["relationship"] != "customer" {
+			continue
+		}
+
+		seen := peer["upstream_asns_seen"].(map[string]int64)
+		for _, asn := range uniqueAsns(path[1:]) {
+			if upstreams[asn] {
+				seen[asn]++
+				peer["possible_leak"] = true
+			}
+		}
+	}
+
+	return res
+}
+
+func uniqueAsns(path []string) []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, asn := range path {
+		if seen[asn] {
+			continue
+		}
+		seen[asn] = true
+		res = append(res, asn)
+	}
+	return res
+}
+
+// NextHopReachability checks if every BGP next hop used in a table
+// is covered by a non BGP route (IGP, static or connected) in the
+// igpTable. Unresolved next hops will blackhole traffic.
+func NextHopReachability(useCache bool, table string, igpTable string) (Parsed, bool) {
+	res, from_cache := RoutesTable(useCache, table)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	routes, _ := res["routes"].([]Parsed)
+	nextHops := nextHopUsage(routes)
+
+	resolved := []Parsed{}
+	unresolved := []Parsed{}
+	unknown := []Parsed{}
+
+	for _, nextHop := range sortedKeys(nextHops) {
+		entry := Parsed{"next_hop": nextHop, "routes": nextHops[nextHop]}
+
+		covering, _ := RoutesLookupTable(useCache, nextHop, igpTable)
+		if IsSpecial(covering) {
+			unknown = append(unknown, entry)
+			continue
+		}
+
+		coveringRoutes, _ := covering["routes"].([]Parsed)
+		if isNextHopResolved(coveringRoutes) {
+			resolved = append(resolved, entry)
+		} else {
+			unresolved = append(unresolved, entry)
+		}
+	}
+
+	return Parsed{
+		"next_hops": Parsed{
+			"table":      table,
+			"igp_table":  igpTable,
+			"checked":    len(nextHops),
+			"resolved":   resolved,
+			"unresolved": unresolved,
+			"unknown":    unknown,
+		},
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}, from_cache
+}
+
+// Count the routes per BGP next hop
+func nextHopUsage(routes []Parsed) map[string]int64 {
+	res := map[string]int64{}
+	for _, route := range routes {
+		nextHop, ok := routeBgp(route)["next_hop"].(string)
+		if !ok {
+			continue
+		}
+
+		// IPv6 next hops may contain a link local address as well
+		fields := strings.Fields(nextHop)
+		if len(fields) == 0 {
+			continue
+		}
+		res[fields[0]]++
+	}
+	return res
+}
+
+// A next hop is resolved if there is a covering route
+// which was not learned via BGP.
+func isNextHopResolved(routes []Parsed) bool {
+	for _, route := range routes {
+		routeType, ok := route["type"].([]string)
+		if !ok || len(routeType) == 0 {
+			continue
+		}
+		if routeType[0] != "BGP" {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestAnalyzeRouteLeaks(t *testing.T) {
+	PeersConf = map[string]PeerConfig{
+		"R1": PeerConfig{Relationship: "customer"},
+	}
+	AnalysisConf = AnalysisConfig{UpstreamAsns: []int64{3356}}
+	defer func() {
+		PeersConf = nil
+		AnalysisConf = AnalysisConfig{}
+	}()
+
+	protocols := Parsed{
+		"R1": Parsed{"neighbor_as": int64(64500)},
+		"R2": Parsed{"neighbor_as": int64(64501)},
+	}
+
+	route := func(protocol string, path ...string) Parsed {
+		return Parsed{
+			"from_protocol": protocol,
+			"bgp":           Parsed{"as_path": path},
+		}
+	}
+
+	routes := []Parsed{
+		route("R1", "64500"),
+		route("R1", "64500", "64510"),
+		route("R1", "64500", "3356", "64520"),
+		route("R2", "64501", "3356", "64520"),
+	}
+
+	res := analyzeRouteLeaks(protocols, routes)
+
+	customer := res["R1"].(Parsed)
+	if customer["routes"] != int64(3) {
+		t.Error("Expected 3 routes, got:", customer["routes"])
+	}
+	if customer["own_origin"] != int64(1) {
+		t.Error("Expected 1 own origin route, got:", customer["own_origin"])
+	}
+	if customer["third_party_origin"] != int64(2) {
+		t.Error("Expected 2 third party routes, got:", customer["third_party_origin"])
+	}
+	if customer["possible_leak"] != true {
+		t.Error("Expected customer to be flagged as possible leak")
+	}
+
+	peer := res["R2"].(Parsed)
+	if peer["possible_leak"] != false {
+		t.Error("Upstream ASNs behind non customers are not a leak")
+	}
+}
+
+func TestNextHopUsage(t *testing.T) {
+	routes := []Parsed{
+		Parsed{"bgp": Parsed{"next_hop": "192.0.2.1"}},
+		Parsed{"bgp": Parsed{"next_hop": "192.0.2.1"}},
+		Parsed{"bgp": Parsed{"next_hop": "2001:db8::1 fe80::1"}},
+		Parsed{},
+	}
+
+	usage := nextHopUsage(routes)
+	if usage["192.0.2.1"] != 2 {
+		t.Error("Expected 2 routes via 192.0.2.1, got:", usage["192.0.2.1"])
+	}
+	if usage["2001:db8::1"] != 1 {
+		t.Error("Expected 1 route via 2001:db8::1, got:", usage["2001:db8::1"])
+	}
+}
+
+func TestIsNextHopResolved(t *testing.T) {
+	bgpOnly := []Parsed{
+		Parsed{"type": []string{"BGP", "univ"}},
+	}
+	if isNextHopResolved(bgpOnly) {
+		t.Error("A next hop covered only by BGP routes is not resolved")
+	}
+
+	connected := []Parsed{
+		Parsed{"type": []string{"BGP", "univ"}},
+		Parsed{"type": []string{"device", "univ"}},
+	}
+	if !isNextHopResolved(connected) {
+		t.Error("A next hop covered by a device route is resolved")
+	}
+}
@@ -0,0 +1,128 @@
+package bird
+
+import (
+	"sort"
+)
+
+// Neighbors announcing a prefix. On route servers with a
+// table per peer, the peer tables are searched, as routes
+// rejected by the export filters of the pipes never reach
+// the master table. Otherwise all paths in the master
+// table are used.
+
+const (
+	AnnouncersModeMaster     = "master"
+	AnnouncersModePeerTables = "peer_tables"
+)
+
+// peerTables returns the tables of the BGP protocols
+// besides the master table
+func peerTables(protocols Parsed) []string {
+	master := remapTable("master")
+	seen := map[string]bool{}
+	tables := []string{}
+	for _, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+		table, _ := protocol["table"].(string)
+		if table == "" || table == "master" || table == master || seen[table] {
+			continue
+		}
+		seen[table] = true
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func routesExact(useCache bool, prefix string, table string) (Parsed, bool) {
+	table = remapTable(table)
+	cmd := routesQuery(prefix + " table " + table + " all")
+	return RunAndParse(
+		useCache,
+		GetCacheKey("RoutesExact", prefix, table),
+		cmd,
+		parseRoutes,
+		nil)
+}
+
+// Announcers returns the BGP neighbors with a route
+// for the prefix and the attributes of the route.
+func Announcers(useCache bool, prefix string) (Parsed, bool) {
+	bgp, fromCache := ProtocolsBgp(useCache)
+	if IsSpecial(bgp) {
+		return bgp, fromCache
+	}
+	protocols, _ := bgp["protocols"].(Parsed)
+
+	mode := AnnouncersModeMaster
+	tables := peerTables(protocols)
+	if len(tables) > 0 {
+		mode = AnnouncersModePeerTables
+	} else {
+		tables = []string{"master"}
+	}
+
+	results := make([]Parsed, len(tables))
+	cached := make([]bool, len(tables))
+	err := runConcurrently(len(tables), func(i int) {
+		results[i], cached[i] = routesExact(useCache, prefix, tables[i])
+	})
+	if err != nil {
+		return Parsed{"error": err.Error()}, false
+	}
+
+	announcers := []Parsed{}
+	seen := map[string]bool{}
+	for i, table := range tables {
+		res := results[i]
+		fromCache = fromCache && cached[i]
+		if IsSpecial(res) {
+			return res, false
+		}
+		if _, failed := res["error"]; failed {
+			return res, false
+		}
+
+		routes, _ := res["routes"].([]Parsed)
+		for _, route := range routes {
+			name, _ := route["from_protocol"].(string)
+			protocol, ok := protocols[name].(Parsed)
+			if !ok {
+				continue // not learned from a BGP neighbor
+			}
+			if mode == AnnouncersModePeerTables && protocol["table"] != table {
+				continue // passed on by a pipe
+			}
+			key := routeIdentity(route)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			announcers = append(announcers, Parsed{
+				"protocol":         name,
+				"neighbor_address": protocol["neighbor_address"],
+				"neighbor_as":      protocol["neighbor_as"],
+				"description":      protocol["description"],
+				"table":            table,
+				"network":          route["network"],
+				"gateway":          route["gateway"],
+				"primary":          route["primary"],
+				"bgp":              route["bgp"],
+			})
+		}
+	}
+
+	sort.SliceStable(announcers, func(i, j int) bool {
+		return announcers[i]["protocol"].(string) < announcers[j]["protocol"].(string)
+	})
+
+	return Parsed{
+		"prefix":     prefix,
+		"mode":       mode,
+		"announcers": announcers,
+	}, fromCache
+}
@@ -0,0 +1,63 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnouncers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "announcers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sample, err := ioutil.ReadFile("../test/protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures := map[string][]byte{
+		"protocols all": sample,
+		"route 16.0.0.0/24 table T65001_nada_co_ripe all": []byte(
+			"BIRD 1.6.8 ready.\n" +
+				"16.0.0.0/24        via 1.2.3.16 on eno7 [R194_42 2017-06-21 08:17:33] * (100) [AS1340i]\n" +
+				"\tType: BGP unicast univ\n" +
+				"\tBGP.origin: IGP\n" +
+				"\tBGP.as_path: 1340\n" +
+				"                   via 1.2.3.17 on eno7 [M65001_nada_co_ripe 2017-06-21 08:17:33] (100) [AS1341i]\n" +
+				"\tType: BGP unicast univ\n" +
+				"\tBGP.as_path: 1341\n"),
+	}
+	for cmd, out := range fixtures {
+		err := ioutil.WriteFile(filepath.Join(dir, FixtureName(cmd)), out, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	MockDir = dir
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() {
+		MockDir = ""
+		cache = formerCache
+	}()
+
+	res, _ := Announcers(false, "16.0.0.0/24")
+	if res["mode"] != AnnouncersModePeerTables {
+		t.Error("Expected the peer tables to be searched, got:", res["mode"])
+	}
+	announcers, _ := res["announcers"].([]Parsed)
+	if len(announcers) != 1 {
+		t.Fatal("Expected one announcer, got:", res)
+	}
+	announcer := announcers[0]
+	if announcer["protocol"] != "R194_42" || announcer["table"] != "T65001_nada_co_ripe" {
+		t.Error("Unexpected announcer:", announcer)
+	}
+	if _, ok := announcer["bgp"].(Parsed); !ok {
+		t.Error("Expected the BGP attributes, got:", announcer["bgp"])
+	}
+}
@@ -0,0 +1,55 @@
+package bird
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 4-byte ASNs (RFC 6793)
+//
+// ASNs are unsigned 32 bit numbers. They are kept as int64
+// in the results like all numbers, which holds every ASN
+// without overflow. AS_TRANS is used by speakers without
+// 4-byte ASN support in place of an ASN above 65535: it is
+// labeled in AS paths and protocols with as_trans.
+
+const (
+	// AsTrans is the placeholder for 4-byte ASNs
+	AsTrans = 23456
+
+	// MaxAsn is the largest 4-byte ASN
+	MaxAsn = 4294967295
+
+	// MaxTwoByteAsn is the largest 2-byte ASN
+	MaxTwoByteAsn = 65535
+)
+
+// ParseAsn parses an ASN in asplain or asdot notation
+// (RFC 5396) with an optional "AS" prefix, e.g. AS4200000000
+// or 64086.59904.
+func ParseAsn(value string) (int64, error) {
+	asn := strings.TrimPrefix(strings.ToUpper(value), "AS")
+	if parts := strings.Split(asn, "."); len(parts) == 2 {
+		high, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid ASN: %s", value)
+		}
+		low, err := strconv.ParseUint(parts[1], 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid ASN: %s", value)
+		}
+		return int64(high<<16 | low), nil
+	}
+
+	n, err := strconv.ParseUint(asn, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid ASN: %s", value)
+	}
+	return int64(n), nil
+}
+
+// IsAsTrans checks if the ASN is AS_TRANS
+func IsAsTrans(asn int64) bool {
+	return asn == AsTrans
+}
@@ -0,0 +1,29 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestParseAsn(t *testing.T) {
+	valid := map[string]int64{
+		"64500":        64500,
+		"AS65536":      65536,
+		"as4200000000": 4200000000,
+		"4294967295":   MaxAsn,
+		"1.0":          65536,
+		"64086.59904":  4200000000,
+		"23456":        AsTrans,
+	}
+	for value, expected := range valid {
+		asn, err := ParseAsn(value)
+		if err != nil || asn != expected {
+			t.Error("Expected", value, "to be", expected, "got:", asn, err)
+		}
+	}
+
+	for _, value := range []string{"4294967296", "-1", "65536.1", "1.2.3", "", "AS"} {
+		if asn, err := ParseAsn(value); err == nil {
+			t.Error("Expected", value, "to be invalid, got:", asn)
+		}
+	}
+}
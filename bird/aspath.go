@@ -0,0 +1,80 @@
+package bird
+
+import (
+	"strings"
+)
+
+// AS path fields
+//
+// The origin and neighbor ASN, the length and the path without
+// prepends are derived from the as_path, so consumers do not
+// have to unwind it. The ASNs of an AS_SET, e.g. {64500 64501},
+// are no origin or neighbor ASN and count as a single hop
+// of the path length (RFC 4271). Paths with AS_TRANS are
+// labeled with as_trans.
+
+// asPathHops groups the ASNs of an AS_SET into one hop
+func asPathHops(path []string) [][]string {
+	hops := [][]string{}
+	inSet := false
+	for _, asn := range path {
+		if inSet {
+			hops[len(hops)-1] = append(hops[len(hops)-1], asn)
+		} else {
+			hops = append(hops, []string{asn})
+			inSet = strings.HasPrefix(asn, "{")
+		}
+		if strings.HasSuffix(asn, "}") {
+			inSet = false
+		}
+	}
+	return hops
+}
+
+// stripPrepends removes the repeated hops of prepending,
+// the ASNs of an AS_SET are kept
+func stripPrepends(path []string) []string {
+	res := []string{}
+	former := ""
+	for i, hop := range asPathHops(path) {
+		key := strings.Join(hop, " ")
+		if i > 0 && key == former {
+			continue
+		}
+		former = key
+		res = append(res, hop...)
+	}
+	return res
+}
+
+// setAsPathFields sets origin_asn, neighbor_asn, as_path_length
+// and as_path_unique from the as_path of the bgp attributes
+func setAsPathFields(bgp Parsed) {
+	raw, _ := bgp["as_path"].([]string)
+	path := []string{}
+	for _, asn := range raw {
+		if !emptyString(asn) {
+			path = append(path, asn)
+		}
+	}
+
+	bgp["as_path_length"] = int64(len(asPathHops(path)))
+	bgp["as_path_unique"] = stripPrepends(path)
+	for _, asn := range path {
+		if asn, err := ParseAsn(strings.Trim(asn, "{}")); err == nil && IsAsTrans(asn) {
+			bgp["as_trans"] = true
+			break
+		}
+	}
+	if len(path) == 0 {
+		return
+	}
+	if asn, err := ParseAsn(path[0]); err == nil {
+		bgp["neighbor_asn"] = asn
+	}
+	if origin := path[len(path)-1]; !strings.HasSuffix(origin, "}") {
+		if asn, err := ParseAsn(origin); err == nil {
+			bgp["origin_asn"] = asn
+		}
+	}
+}
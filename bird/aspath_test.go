@@ -0,0 +1,56 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoutesAsPathFields(t *testing.T) {
+	bgp := Parsed{}
+	parseRoutesBgp("\tBGP.as_path: 64500 64501 64501 64501 64502", bgp)
+
+	if bgp["neighbor_asn"] != int64(64500) {
+		t.Error("Expected neighbor_asn 64500, got:", bgp["neighbor_asn"])
+	}
+	if bgp["origin_asn"] != int64(64502) {
+		t.Error("Expected origin_asn 64502, got:", bgp["origin_asn"])
+	}
+	if bgp["as_path_length"] != int64(5) {
+		t.Error("Expected as_path_length 5, got:", bgp["as_path_length"])
+	}
+	unique := []string{"64500", "64501", "64502"}
+	if !reflect.DeepEqual(bgp["as_path_unique"], unique) {
+		t.Error("Expected as_path_unique", unique, "got:", bgp["as_path_unique"])
+	}
+}
+
+func TestParseRoutesAsPathFieldsSpecial(t *testing.T) {
+	bgp := Parsed{"as_path": []string{""}}
+	setAsPathFields(bgp)
+	if bgp["as_path_length"] != int64(0) {
+		t.Error("Expected an empty path, got:", bgp["as_path_length"])
+	}
+	if _, ok := bgp["origin_asn"]; ok {
+		t.Error("Expected no origin_asn for an empty path")
+	}
+
+	bgp = Parsed{}
+	parseRoutesBgp("\tBGP.as_path: 64500 {64510 64511}", bgp)
+	if _, ok := bgp["origin_asn"]; ok {
+		t.Error("Expected no origin_asn for an AS_SET, got:", bgp["origin_asn"])
+	}
+	if bgp["neighbor_asn"] != int64(64500) {
+		t.Error("Expected neighbor_asn 64500, got:", bgp["neighbor_asn"])
+	}
+
+	// An AS_SET is a single hop
+	bgp = Parsed{}
+	parseRoutesBgp("\tBGP.as_path: 64500 64500 {64510 64511 64512}", bgp)
+	if bgp["as_path_length"] != int64(3) {
+		t.Error("Expected as_path_length 3, got:", bgp["as_path_length"])
+	}
+	unique := []string{"64500", "{64510", "64511", "64512}"}
+	if !reflect.DeepEqual(bgp["as_path_unique"], unique) {
+		t.Error("Expected as_path_unique", unique, "got:", bgp["as_path_unique"])
+	}
+}
@@ -0,0 +1,121 @@
+package bird
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Babel protocol support
+//
+// `show babel neighbors` and `show babel entries` print a
+// table per babel protocol instance:
+//
+//    babel1:
+//    IP address                Interface  Metric Routes Hellos Expires
+//    fe80::1                   eth0           96      2     16   5.612
+
+var babelProtocolHeader = regexp.MustCompile(`^([^\s]+):\s*$`)
+
+var babelNeighborFields = []string{
+	"address", "interface", "metric", "routes", "hellos", "expires",
+}
+
+var babelEntryFields = []string{
+	"prefix", "router_id", "metric", "seqno", "routes", "sources",
+}
+
+var babelNumericFields = map[string]bool{
+	"metric":  true,
+	"routes":  true,
+	"hellos":  true,
+	"expires": true,
+	"seqno":   true,
+	"sources": true,
+}
+
+func babelValue(field, value string) interface{} {
+	if !babelNumericFields[field] {
+		return value
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+// Parse the tables of all babel protocol instances into
+// rows with the given fields, keyed by protocol.
+func parseBabelTables(reader io.Reader, fields []string) Parsed {
+	res := Parsed{}
+
+	protocol := ""
+	header := false
+
+	lines := newLineIterator(reader, true)
+	for lines.next() {
+		line := lines.string()
+		if specialLine(line) {
+			continue
+		}
+
+		if groups := babelProtocolHeader.FindStringSubmatch(line); groups != nil {
+			protocol = groups[1]
+			header = true
+			res[protocol] = []Parsed{}
+			continue
+		}
+		if protocol == "" {
+			continue
+		}
+		if header {
+			// Skip the column names
+			header = false
+			continue
+		}
+
+		values := strings.Fields(line)
+		if len(values) < len(fields) {
+			continue
+		}
+
+		row := Parsed{}
+		for i, field := range fields {
+			row[field] = babelValue(field, values[i])
+		}
+
+		res[protocol] = append(res[protocol].([]Parsed), row)
+	}
+
+	return res
+}
+
+func parseBabelNeighbors(reader io.Reader) Parsed {
+	return Parsed{"neighbors": parseBabelTables(reader, babelNeighborFields)}
+}
+
+func parseBabelEntries(reader io.Reader) Parsed {
+	return Parsed{"entries": parseBabelTables(reader, babelEntryFields)}
+}
+
+func BabelNeighbors(useCache bool) (Parsed, bool) {
+	return RunAndParse(
+		useCache,
+		GetCacheKey("BabelNeighbors"),
+		"babel neighbors",
+		parseBabelNeighbors,
+		nil)
+}
+
+func BabelEntries(useCache bool) (Parsed, bool) {
+	return RunAndParse(
+		useCache,
+		GetCacheKey("BabelEntries"),
+		"babel entries",
+		parseBabelEntries,
+		nil)
+}
@@ -0,0 +1,49 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestParseBabelNeighbors(t *testing.T) {
+	f, err := openFile("babel_neighbors.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	neighbors := parseBabelNeighbors(f)["neighbors"].(Parsed)
+
+	babel1 := neighbors["babel1"].([]Parsed)
+	if len(babel1) != 2 {
+		t.Fatal("Expected 2 neighbors for babel1, got:", babel1)
+	}
+	if babel1[0]["address"] != "fe80::1" || babel1[0]["interface"] != "eth0" {
+		t.Error("Unexpected neighbor:", babel1[0])
+	}
+	if babel1[0]["metric"] != int64(96) || babel1[0]["expires"] != 5.612 {
+		t.Error("Unexpected neighbor values:", babel1[0])
+	}
+
+	if babel2 := neighbors["babel2"].([]Parsed); len(babel2) != 0 {
+		t.Error("Expected no neighbors for babel2, got:", babel2)
+	}
+}
+
+func TestParseBabelEntries(t *testing.T) {
+	f, err := openFile("babel_entries.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entries := parseBabelEntries(f)["entries"].(Parsed)["babel1"].([]Parsed)
+	if len(entries) != 2 {
+		t.Fatal("Expected 2 entries, got:", entries)
+	}
+	if entries[1]["prefix"] != "10.1.0.0/24" || entries[1]["router_id"] != "00:00:00:00:0a:00:00:02" {
+		t.Error("Unexpected entry:", entries[1])
+	}
+	if entries[1]["seqno"] != int64(7) {
+		t.Error("Unexpected seqno:", entries[1]["seqno"])
+	}
+}
@@ -0,0 +1,39 @@
+package bird
+
+// Details of a list of protocols, selected from the (cached)
+// details of all protocols, so a batch query runs birdc at
+// most once.
+
+// ProtocolsBatch returns the details of the requested
+// protocols. Unknown protocols are listed in protocol_errors.
+func ProtocolsBatch(useCache bool, names []string) (Parsed, bool) {
+	res, from_cache := Protocols(useCache)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+	if _, ok := res["error"]; ok {
+		return res, from_cache
+	}
+
+	all, _ := res["protocols"].(Parsed)
+	protocols := Parsed{}
+	errors := Parsed{}
+	for _, name := range names {
+		details, ok := all[name]
+		if !ok {
+			errors[name] = "unknown protocol: " + name
+			continue
+		}
+		protocols[name] = details
+	}
+
+	ret := Parsed{
+		"protocols": protocols,
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}
+	if len(errors) > 0 {
+		ret["protocol_errors"] = errors
+	}
+	return ret, from_cache
+}
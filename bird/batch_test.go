@@ -0,0 +1,48 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProtocolsBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sample, err := ioutil.ReadFile("../test/protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, FixtureName("protocols all")), sample, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MockDir = dir
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() {
+		MockDir = ""
+		cache = formerCache
+	}()
+
+	res, _ := ProtocolsBatch(false, []string{"R194_42", "R192_175"})
+	protocols := res["protocols"].(Parsed)
+	if len(protocols) != 1 {
+		t.Error("Expected only the requested protocol, got:", protocols)
+	}
+	details, ok := protocols["R194_42"].(Parsed)
+	if !ok || details["bird_protocol"] != "BGP" {
+		t.Error("Unexpected details of R194_42:", protocols["R194_42"])
+	}
+
+	errors, _ := res["protocol_errors"].(Parsed)
+	if _, ok := errors["R192_175"]; !ok {
+		t.Error("Expected an error for R192_175, got:", res["protocol_errors"])
+	}
+}
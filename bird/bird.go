@@ -2,10 +2,12 @@ package bird
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"reflect"
-	"strconv"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -22,9 +24,17 @@ type Cache interface {
 var ClientConf BirdConfig
 var StatusConf StatusConfig
 var IPVersion = "4"
-var BirdVersion = 0
+
+// Major version of BIRD, updated by the capability detection.
+// Access with MajorVersion and atomic operations.
+var BirdVersion int32 = 0
+
 var cache Cache // stores parsed birdc output
+var CacheKeyPrefix string
 var CacheConf CacheConfig
+var RoutesConf RoutesConfig
+var PeersConf map[string]PeerConfig
+var AnalysisConf AnalysisConfig
 var RateLimitConf struct {
 	sync.RWMutex
 	Conf RateLimitConfig
@@ -33,9 +43,11 @@ var RunQueue sync.Map // queue birdc commands before execution
 
 var NilParse Parsed = (Parsed)(nil) // special Parsed values
 var BirdError Parsed = Parsed{"error": "bird unreachable"}
+var ParserError Parsed = Parsed{"error": "parsing the birdc output failed"}
 
 func IsSpecial(ret Parsed) bool { // test for special Parsed values
-	return reflect.DeepEqual(ret, NilParse) || reflect.DeepEqual(ret, BirdError)
+	return reflect.DeepEqual(ret, NilParse) || reflect.DeepEqual(ret, BirdError) ||
+		reflect.DeepEqual(ret, ParserError) || IsLimitExceeded(ret)
 }
 
 // intitialize the Cache once during setup with either a MemoryCache or
@@ -48,6 +60,19 @@ func InitializeCache() {
 		if err != nil {
 			log.Println("Could not initialize redis cache, falling back to memory cache:", err)
 		}
+	} else if CacheConf.SpillDir != "" && CacheConf.SpillThreshold > 0 {
+		spill, err := NewSpillCache(CacheConf.SpillDir, CacheConf.SpillThreshold<<20)
+		if err != nil {
+			log.Fatal("Could not initialize SpillCache:", err)
+		}
+		if CacheConf.SpillHoldSize > 0 {
+			spill.HoldLimit = CacheConf.SpillHoldSize << 20
+		}
+		// The files of a persisted cache are kept until it is restored
+		if CacheConf.PersistFile == "" {
+			spill.RemoveOrphans()
+		}
+		cache = spill
 	} else { // initialize the MemoryCache
 		cache, err = NewMemoryCache()
 		if err != nil {
@@ -57,7 +82,13 @@ func InitializeCache() {
 }
 
 func ExpireCache() int {
-	return cache.Expire()
+	return cache.Expire() + ExpireCountCache()
+}
+
+// ExpireCountCache removes the expired route counts. The
+// counts are kept in memory, also when using redis.
+func ExpireCountCache() int {
+	return countCache.Expire()
 }
 
 /* Convenience method to make new entries in the cache.
@@ -73,7 +104,7 @@ func toCache(key string, val Parsed) bool {
 		ttl = 5 // five minutes
 	}
 
-	if err := cache.Set(key, val, ttl); err == nil {
+	if err := cache.Set(CacheKeyPrefix+key, val, ttl); err == nil {
 		return true
 	} else {
 		log.Println(err)
@@ -89,7 +120,7 @@ func toCache(key string, val Parsed) bool {
  * possible but currently not implemented.
  */
 func fromCache(key string) (Parsed, bool) {
-	val, err := cache.Get(key)
+	val, err := cache.Get(CacheKeyPrefix + key)
 	if err == nil {
 		return val, true
 	} else {
@@ -115,24 +146,104 @@ func GetCacheKey(fname string, fargs ...interface{}) string {
 }
 
 func Run(args string) (io.Reader, error) {
-	args = "-r " + "show " + args // enforce birdc in restricted mode with "-r" argument
+	return RunContext(context.Background(), args)
+}
+
+// RunContext runs the query, birdc is killed when the
+// context is done.
+func RunContext(ctx context.Context, args string) (io.Reader, error) {
+	return runContextLimited(ctx, args, 0)
+}
+
+// runContextLimited runs the query, birdc is killed when the
+// output exceeds maxParse bytes (if set).
+func runContextLimited(ctx context.Context, args string, maxParse int) (io.Reader, error) {
+	if MockDir != "" {
+		return runMock(args)
+	}
+
+	out, err := runBirdc(ctx, "show "+args, maxParse)
+	if err != nil && err != ErrOutputTruncated {
+		return nil, err
+	}
+
+	if RecordDir != "" {
+		if err := recordRun(args, out, time.Now()); err != nil {
+			log.Println("Could not record birdc output:", err)
+		}
+	}
+
+	// The truncated output is returned with the error
+	return bytes.NewReader(out), err
+}
+
+var netnsName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateNetns checks the name of the network namespace
+func ValidateNetns(name string) error {
+	if name == "" || netnsName.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf("Invalid network namespace: %s", name)
+}
+
+// Arguments of birdc, birdc runs in restricted mode
+// unless configured otherwise.
+func birdcArgs(conf BirdConfig) []string {
+	if conf.BirdcArgs == nil {
+		return []string{"-r"}
+	}
+	return conf.BirdcArgs
+}
+
+// ValidateRestrictedMode checks that birdc runs in restricted
+// mode. All endpoints only read from BIRD, so birdc must
+// not be allowed to run other commands than show.
+func ValidateRestrictedMode(conf BirdConfig) error {
+	args := append(strings.Fields(conf.BirdCmd), birdcArgs(conf)...)
+	for _, arg := range args {
+		if arg == "-r" {
+			return nil
+		}
+	}
+	return fmt.Errorf("birdc is not run in restricted mode, add -r to birdc_args")
+}
+
+// Build the birdc command line. With a network namespace
+// configured, birdc is run with `ip netns exec`.
+func birdcCommand(conf BirdConfig, args string) []string {
 	argsList := strings.Split(args, " ")
 
 	// Allow for arguments in the config
-	cmdArgs := strings.Split(ClientConf.BirdCmd, " ")
-	birdc := cmdArgs[0]
-	cmdArgs = cmdArgs[1:]
+	cmdArgs := strings.Split(conf.BirdCmd, " ")
 
 	cmd := []string{}
+	if conf.Netns != "" {
+		cmd = append(cmd, "ip", "netns", "exec", conf.Netns)
+	}
 	cmd = append(cmd, cmdArgs...)
-	cmd = append(cmd, argsList...)
-
-	out, err := exec.Command(birdc, cmd...).Output()
-	if err != nil {
-		return nil, err
+	if conf.Socket != "" {
+		cmd = append(cmd, "-s", conf.Socket)
+	}
+	cmd = append(cmd, birdcArgs(conf)...)
+	if args != "" {
+		cmd = append(cmd, argsList...)
 	}
 
-	return bytes.NewReader(out), nil
+	return cmd
+}
+
+func runBirdc(ctx context.Context, args string, maxParse int) ([]byte, error) {
+	cmd := birdcCommand(ClientConf, args)
+	if SandboxConf.Enabled {
+		cmd = sandboxCommand(SandboxConf, sandboxExecutable(), cmd)
+	}
+	out, err := readBirdc(exec.CommandContext(ctx, cmd[0], cmd[1:]...),
+		LimitsConf.MaxOutputBytes, maxParse)
+	if err != nil && err != ErrOutputTruncated && err != ErrParseSizeExceeded {
+		recordBirdcError(args, err)
+	}
+	return out, err
 }
 
 func InstallRateLimitReset() {
@@ -170,6 +281,17 @@ func checkRateLimit() bool {
 }
 
 func RunAndParse(useCache bool, key string, cmd string, parser func(io.Reader) Parsed, updateCache func(*Parsed)) (Parsed, bool) {
+	return runAndParse(useCache, cmd, parser, updateCache, fromCache, toCache)
+}
+
+func runAndParse(
+	useCache bool,
+	cmd string,
+	parser func(io.Reader) Parsed,
+	updateCache func(*Parsed),
+	fromCache func(string) (Parsed, bool),
+	toCache func(string, Parsed) bool,
+) (Parsed, bool) {
 	var wg sync.WaitGroup
 
 	if useCache {
@@ -196,16 +318,14 @@ func RunAndParse(useCache bool, key string, cmd string, parser func(io.Reader) P
 		return NilParse, false
 	}
 
-	out, err := Run(cmd)
-	if err != nil {
+	parsed, err := runLimited(cmd, queryLimits(LimitsConf, cmd), parser)
+	if err != nil || IsLimitExceeded(parsed) {
 		// ignore errors for now
 		wg.Done()
 		RunQueue.Delete(cmd)
-		return BirdError, false
+		return parsed, false
 	}
 
-	parsed := parser(out)
-
 	if updateCache != nil {
 		updateCache(&parsed)
 	}
@@ -245,6 +365,11 @@ func Status(useCache bool) (Parsed, bool) {
 		for _, field := range StatusConf.FilterFields {
 			status[field] = nil
 		}
+
+		now := time.Now()
+		for _, field := range []string{"current_server", "last_reboot", "last_reconfig"} {
+			setTimestamp(status, field, now)
+		}
 	}
 
 	birdStatus, from_cache := RunAndParse(useCache, GetCacheKey("Status"), "status", parseStatus, updateParsedCache)
@@ -291,7 +416,7 @@ func ProtocolsBgp(useCache bool) (Parsed, bool) {
 	bgpProtocols := Parsed{}
 
 	for key, protocol := range metaProtocol["bird_protocol"].(Parsed)["BGP"].(Parsed) {
-		bgpProtocols[key] = *(protocol.(*Parsed))
+		bgpProtocols[key] = tagRegion(key, *(protocol.(*Parsed)))
 	}
 
 	return Parsed{"protocols": bgpProtocols,
@@ -305,16 +430,31 @@ func Symbols(useCache bool) (Parsed, bool) {
 
 func routesQuery(filter string) string {
 	cmd := "route " + filter
-	if getBirdVersion() < 2 {
+	if !HasCapability("channels") {
 		return cmd
 	}
 
 	// Add ipversion filter
-	return cmd + " where net.type = NET_IP" + IPVersion
+	where := " where net.type = NET_IP" + IPVersion
+
+	// The vpn4/vpn6, flow4/flow6 and mpls networks are only
+	// included for an explicit table, so the results of the
+	// other queries do not change. Mpls tables have no IP version.
+	if isTableQuery(filter) {
+		where += " || net.type = NET_VPN" + IPVersion +
+			" || net.type = NET_FLOW" + IPVersion +
+			" || net.type = NET_MPLS"
+	}
+	return cmd + where
+}
+
+func isTableQuery(filter string) bool {
+	return strings.HasPrefix(filter, "table ") ||
+		strings.Contains(filter, " table ")
 }
 
 func remapTable(table string) string {
-	if v := getBirdVersion(); v < 2 {
+	if !HasCapability("channels") {
 		return table // Nothing to do for bird1
 	}
 
@@ -373,22 +513,12 @@ func RoutesTableAndPeer(useCache bool, table string, peer string) (Parsed, bool)
 
 func RoutesProtoCount(useCache bool, protocol string) (Parsed, bool) {
 	cmd := routesQuery("protocol " + protocol + " count")
-	return RunAndParse(
-		useCache,
-		GetCacheKey("RoutesProtoCount", protocol),
-		cmd,
-		parseRoutesCount,
-		nil)
+	return RunAndParseCount(useCache, cmd)
 }
 
 func RoutesProtoPrimaryCount(useCache bool, protocol string) (Parsed, bool) {
 	cmd := routesQuery("primary protocol " + protocol + " count")
-	return RunAndParse(
-		useCache,
-		GetCacheKey("RoutesProtoPrimaryCount", protocol),
-		cmd,
-		parseRoutesCount,
-		nil)
+	return RunAndParseCount(useCache, cmd)
 }
 
 func PipeRoutesFilteredCount(useCache bool, pipe string, table string, neighborAddress string) (Parsed, bool) {
@@ -396,12 +526,7 @@ func PipeRoutesFilteredCount(useCache bool, pipe string, table string, neighborA
 	cmd := "route table " + table +
 		" noexport " + pipe +
 		" where from=" + neighborAddress + " count"
-	return RunAndParse(
-		useCache,
-		GetCacheKey("PipeRoutesFilteredCount", table, pipe, neighborAddress),
-		cmd,
-		parseRoutesCount,
-		nil)
+	return RunAndParseCount(useCache, cmd)
 }
 
 func PipeRoutesFiltered(useCache bool, pipe string, table string) (Parsed, bool) {
@@ -415,24 +540,112 @@ func PipeRoutesFiltered(useCache bool, pipe string, table string) (Parsed, bool)
 		nil)
 }
 
-func RoutesFiltered(useCache bool, protocol string) (Parsed, bool) {
-	cmd := routesQuery("all filtered protocol " + protocol)
+func RoutesExport(useCache bool, protocol string) (Parsed, bool) {
+	cmd := routesQuery("all export " + protocol)
 	return RunAndParse(
 		useCache,
-		GetCacheKey("RoutesFiltered", protocol),
+		GetCacheKey("RoutesExport", protocol),
 		cmd,
 		parseRoutes,
 		nil)
 }
 
-func RoutesExport(useCache bool, protocol string) (Parsed, bool) {
-	cmd := routesQuery("all export " + protocol)
-	return RunAndParse(
+// RoutesReceived retrieves the pre-policy RIB-in of a protocol.
+// Depending on the configured source, these are either the accepted
+// and the filtered routes of the protocol (requires
+// 'import keep filtered on') or the routes the protocol imported into
+// its peer table.
+func RoutesReceived(useCache bool, protocol string) (Parsed, bool) {
+	if RoutesConf.ReceivedSource == "peer_table" {
+		return routesReceivedPeerTable(useCache, protocol)
+	}
+
+	return routesReceivedKeepFiltered(useCache, protocol)
+}
+
+func routesReceivedKeepFiltered(useCache bool, protocol string) (Parsed, bool) {
+	accepted, from_cache := RoutesProto(useCache, protocol)
+	if IsSpecial(accepted) {
+		return accepted, from_cache
+	}
+
+	filtered, _ := RoutesFiltered(useCache, protocol)
+	if IsSpecial(filtered) {
+		return filtered, from_cache
+	}
+
+	routes := annotateRoutes(accepted["routes"], "filtered", false)
+	routes = append(routes, annotateRoutes(filtered["routes"], "filtered", true)...)
+
+	return Parsed{
+		"routes":          routes,
+		"rib_in":          "pre_policy",
+		"received_source": "keep_filtered",
+		"ttl":             accepted["ttl"],
+		"cached_at":       accepted["cached_at"],
+	}, from_cache
+}
+
+func routesReceivedPeerTable(useCache bool, protocol string) (Parsed, bool) {
+	protocols, from_cache := Protocols(useCache)
+	if IsSpecial(protocols) {
+		return protocols, from_cache
+	}
+
+	all, ok := protocols["protocols"].(Parsed)
+	if !ok {
+		return protocols, from_cache
+	}
+	details, ok := all[protocol].(Parsed)
+	if !ok {
+		return Parsed{"error": "unknown protocol: " + protocol}, from_cache
+	}
+	table, ok := details["table"].(string)
+	if !ok {
+		return Parsed{"error": "could not determine peer table of " + protocol}, from_cache
+	}
+
+	cmd := routesQuery("table " + table + " all protocol " + protocol)
+	res, from_cache := RunAndParse(
 		useCache,
-		GetCacheKey("RoutesExport", protocol),
+		GetCacheKey("RoutesReceivedPeerTable", table, protocol),
 		cmd,
 		parseRoutes,
 		nil)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	return Parsed{
+		"routes":          res["routes"],
+		"rib_in":          "pre_policy",
+		"received_source": "peer_table",
+		"table":           table,
+		"ttl":             res["ttl"],
+		"cached_at":       res["cached_at"],
+	}, from_cache
+}
+
+// Returns shallow copies of the routes with an additional field set,
+// so routes shared with the cache are not modified.
+func annotateRoutes(routes interface{}, key string, value interface{}) []Parsed {
+	res := []Parsed{}
+
+	parsed, ok := routes.([]Parsed)
+	if !ok {
+		return res
+	}
+
+	for _, route := range parsed {
+		annotated := make(Parsed, len(route)+1)
+		for k, v := range route {
+			annotated[k] = v
+		}
+		annotated[key] = value
+		res = append(res, annotated)
+	}
+
+	return res
 }
 
 func RoutesNoExport(useCache bool, protocol string) (Parsed, bool) {
@@ -447,12 +660,7 @@ func RoutesNoExport(useCache bool, protocol string) (Parsed, bool) {
 
 func RoutesExportCount(useCache bool, protocol string) (Parsed, bool) {
 	cmd := routesQuery("export " + protocol + " count")
-	return RunAndParse(
-		useCache,
-		GetCacheKey("RoutesExportCount", protocol),
-		cmd,
-		parseRoutesCount,
-		nil)
+	return RunAndParseCount(useCache, cmd)
 }
 
 func RoutesTable(useCache bool, table string) (Parsed, bool) {
@@ -480,13 +688,7 @@ func RoutesTableFiltered(useCache bool, table string) (Parsed, bool) {
 func RoutesTableCount(useCache bool, table string) (Parsed, bool) {
 	table = remapTable(table)
 	cmd := routesQuery("table " + table + " count")
-	return RunAndParse(
-		useCache,
-		GetCacheKey("RoutesTableCount", table),
-		cmd,
-		parseRoutesCount,
-		nil,
-	)
+	return RunAndParseCount(useCache, cmd)
 }
 
 func RoutesLookupTable(useCache bool, net string, table string) (Parsed, bool) {
@@ -500,6 +702,23 @@ func RoutesLookupTable(useCache bool, net string, table string) (Parsed, bool) {
 		nil)
 }
 
+// RoutesLookupTableExplain annotates all paths for a prefix
+// in a table with the reason why they were not selected as best path.
+func RoutesLookupTableExplain(useCache bool, net string, table string) (Parsed, bool) {
+	res, from_cache := RoutesLookupTable(useCache, net, table)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	routes, _ := res["routes"].([]Parsed)
+
+	return Parsed{
+		"routes":    explainBestPath(routes),
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}, from_cache
+}
+
 func RoutesLookupProtocol(useCache bool, net string, protocol string) (Parsed, bool) {
 	cmd := routesQuery("for " + net + " protocol " + protocol + " all")
 	return RunAndParse(
@@ -509,38 +728,3 @@ func RoutesLookupProtocol(useCache bool, net string, protocol string) (Parsed, b
 		parseRoutes,
 		nil)
 }
-
-func getBirdVersion() int {
-	// We assume the bird major version does not change during
-	// the time the birdwatcher is running.
-	//
-	// However, this requires now a restart when going
-	// from bird1 to bird2.
-	if BirdVersion != 0 {
-		return BirdVersion
-	}
-
-	// This method is a bit hacky.
-	status, _ := Status(false) // Get status without cache
-	if IsSpecial(status) {
-		return 0
-	}
-
-	birdStatus, ok := status["status"].(Parsed)
-	if !ok {
-		return 0
-	}
-
-	version, ok := birdStatus["version"].(string)
-	if !ok {
-		return 0
-	}
-
-	v, err := strconv.Atoi(string(version[0]))
-	if err != nil {
-		return 0
-	}
-
-	BirdVersion = v
-	return v
-}
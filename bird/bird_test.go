@@ -0,0 +1,112 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotateRoutes(t *testing.T) {
+	routes := []Parsed{
+		Parsed{"network": "192.0.2.0/24"},
+		Parsed{"network": "198.51.100.0/24"},
+	}
+
+	annotated := annotateRoutes(routes, "filtered", true)
+	if len(annotated) != 2 {
+		t.Fatal("Expected 2 annotated routes, got:", len(annotated))
+	}
+
+	for i, route := range annotated {
+		if route["filtered"] != true {
+			t.Error("Expected route to be annotated:", route)
+		}
+		if _, ok := routes[i]["filtered"]; ok {
+			t.Error("Original route should not be modified:", routes[i])
+		}
+	}
+
+	if len(annotateRoutes(nil, "filtered", true)) != 0 {
+		t.Error("Expected no routes for invalid input")
+	}
+}
+
+func TestBirdcCommand(t *testing.T) {
+	conf := BirdConfig{BirdCmd: "birdc -s /run/bird.ctl"}
+
+	cmd := birdcCommand(conf, "show status")
+	expected := []string{"birdc", "-s", "/run/bird.ctl", "-r", "show", "status"}
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Error("Unexpected command:", cmd)
+	}
+
+	conf.Netns = "vrf-blue"
+	cmd = birdcCommand(conf, "show status")
+	expected = append([]string{"ip", "netns", "exec", "vrf-blue"}, expected...)
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Error("Unexpected command:", cmd)
+	}
+
+	if err := ValidateNetns("vrf blue"); err == nil {
+		t.Error("Expected an error for an invalid namespace")
+	}
+}
+
+func TestBirdcArgs(t *testing.T) {
+	conf := BirdConfig{
+		BirdCmd:   "birdc",
+		Socket:    "/run/bird/bird-blue.ctl",
+		BirdcArgs: []string{"-r", "-l"},
+	}
+
+	cmd := birdcCommand(conf, "show status")
+	expected := []string{"birdc", "-s", "/run/bird/bird-blue.ctl", "-r", "-l", "show", "status"}
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Error("Unexpected command:", cmd)
+	}
+	if err := ValidateRestrictedMode(conf); err != nil {
+		t.Error(err)
+	}
+
+	conf.BirdcArgs = []string{}
+	if err := ValidateRestrictedMode(conf); err == nil {
+		t.Error("Expected an error without restricted mode")
+	}
+
+	conf.BirdCmd = "birdc -r"
+	if err := ValidateRestrictedMode(conf); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoutesQueryNetTypes(t *testing.T) {
+	BirdVersion = 2
+	IPVersion = "4"
+	defer func() { BirdVersion = 0 }()
+
+	cmd := routesQuery("all protocol R1")
+	if cmd != "route all protocol R1 where net.type = NET_IP4" {
+		t.Error("Unexpected query without table:", cmd)
+	}
+
+	cmd = routesQuery("table mpls all")
+	expected := "route table mpls all where net.type = NET_IP4 || net.type = NET_VPN4" +
+		" || net.type = NET_FLOW4 || net.type = NET_MPLS"
+	if cmd != expected {
+		t.Error("Unexpected query of a table:", cmd)
+	}
+}
+
+func TestRoutesReceivedPeerTableWithoutProtocols(t *testing.T) {
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() { cache = formerCache }()
+
+	// A result without protocols is returned unchanged
+	failed := Parsed{"error": "no protocols"}
+	cache.Set(CacheKeyPrefix+"protocols all", failed, 5)
+
+	res, _ := routesReceivedPeerTable(true, "R1")
+	if res["error"] != "no protocols" {
+		t.Error("Expected the protocols result, got:", res)
+	}
+}
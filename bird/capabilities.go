@@ -0,0 +1,161 @@
+package bird
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Capabilities of the BIRD version
+//
+// The version is detected from `show status` on startup and
+// periodically, so an upgrade from BIRD 1 to BIRD 2 does not
+// require a restart.
+
+type CapabilitiesConfig struct {
+	// Interval of the detection in minutes
+	Interval int `toml:"interval"`
+}
+
+// Minimum versions of the capabilities
+var capabilityVersions = map[string][3]int{
+	"keep_filtered":     {1, 4, 0}, // import keep filtered
+	"large_communities": {1, 6, 3},
+	"channels":          {2, 0, 0},
+	"filtered_keep":     {2, 0, 0}, // import keep filtered of channels
+	"roa_tables":        {2, 0, 0}, // roa4 and roa6 tables
+	"flowspec":          {2, 0, 0},
+}
+
+var capabilities = struct {
+	sync.RWMutex
+	version    string
+	detectedAt time.Time
+	caps       map[string]bool
+}{}
+
+var versionNumbers = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+func parseVersion(version string) ([3]int, bool) {
+	v := [3]int{}
+	groups := versionNumbers.FindStringSubmatch(version)
+	if groups == nil {
+		return v, false
+	}
+	for i := range v {
+		v[i], _ = strconv.Atoi(groups[i+1])
+	}
+	return v, true
+}
+
+func versionAtLeast(v, min [3]int) bool {
+	for i := range v {
+		if v[i] != min[i] {
+			return v[i] > min[i]
+		}
+	}
+	return true
+}
+
+func versionCapabilities(version [3]int) map[string]bool {
+	caps := make(map[string]bool, len(capabilityVersions))
+	for name, min := range capabilityVersions {
+		caps[name] = versionAtLeast(version, min)
+	}
+	return caps
+}
+
+// DetectCapabilities retrieves the version from the status
+// and updates the capabilities.
+func DetectCapabilities() bool {
+	status, _ := Status(false) // Get status without cache
+	if IsSpecial(status) {
+		return false
+	}
+
+	birdStatus, ok := status["status"].(Parsed)
+	if !ok {
+		return false
+	}
+	version, _ := birdStatus["version"].(string)
+	v, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+
+	capabilities.Lock()
+	capabilities.version = version
+	capabilities.detectedAt = time.Now().UTC()
+	capabilities.caps = versionCapabilities(v)
+	capabilities.Unlock()
+
+	atomic.StoreInt32(&BirdVersion, int32(v[0]))
+	return true
+}
+
+// MajorVersion returns the detected major version of BIRD,
+// 0 if unknown.
+func MajorVersion() int {
+	return int(atomic.LoadInt32(&BirdVersion))
+}
+
+// HasCapability checks the capability of the BIRD version,
+// detecting it first if required.
+func HasCapability(name string) bool {
+	capabilities.RLock()
+	caps := capabilities.caps
+	capabilities.RUnlock()
+
+	if major := MajorVersion(); caps == nil && major != 0 {
+		// With only the major version known, the
+		// latest release of it is assumed.
+		caps = versionCapabilities([3]int{major, math.MaxInt32, 0})
+	} else if caps == nil {
+		if !DetectCapabilities() {
+			return false
+		}
+		capabilities.RLock()
+		caps = capabilities.caps
+		capabilities.RUnlock()
+	}
+	return caps[name]
+}
+
+// Capabilities returns the detected version and capabilities
+func Capabilities() Parsed {
+	capabilities.RLock()
+	defer capabilities.RUnlock()
+
+	caps := Parsed{}
+	for name, available := range capabilities.caps {
+		caps[name] = available
+	}
+
+	res := Parsed{
+		"version":      capabilities.version,
+		"capabilities": caps,
+	}
+	if !capabilities.detectedAt.IsZero() {
+		res["detected_at"] = capabilities.detectedAt
+	}
+	return res
+}
+
+// InstallCapabilityDetection detects the capabilities in the
+// configured interval. The initial detection is done by the
+// startup probe.
+func InstallCapabilityDetection(conf CapabilitiesConfig) {
+	interval := time.Duration(conf.Interval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			DetectCapabilities()
+		}
+	}()
+}
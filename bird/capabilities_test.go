@@ -0,0 +1,52 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	cases := map[string][3]int{
+		"1.6.3":     {1, 6, 3},
+		"2.0.7":     {2, 0, 7},
+		"2.0.8-rc1": {2, 0, 8},
+		"2.13":      {2, 13, 0},
+	}
+	for version, expected := range cases {
+		v, ok := parseVersion(version)
+		if !ok || v != expected {
+			t.Error(version, "- expected:", expected, "got:", v)
+		}
+	}
+
+	if _, ok := parseVersion("unknown"); ok {
+		t.Error("Expected invalid version to be rejected")
+	}
+}
+
+func TestVersionCapabilities(t *testing.T) {
+	caps := versionCapabilities([3]int{1, 6, 2})
+	if !caps["keep_filtered"] || caps["large_communities"] || caps["channels"] {
+		t.Error("Unexpected capabilities of 1.6.2:", caps)
+	}
+
+	caps = versionCapabilities([3]int{1, 6, 3})
+	if !caps["large_communities"] || caps["filtered_keep"] {
+		t.Error("Unexpected capabilities of 1.6.3:", caps)
+	}
+
+	caps = versionCapabilities([3]int{2, 0, 0})
+	for _, name := range []string{"channels", "filtered_keep", "roa_tables", "flowspec"} {
+		if !caps[name] {
+			t.Error("Expected", name, "with 2.0.0")
+		}
+	}
+}
+
+func TestHasCapabilityWithMajorVersion(t *testing.T) {
+	BirdVersion = 1
+	defer func() { BirdVersion = 0 }()
+
+	if !HasCapability("large_communities") || HasCapability("channels") {
+		t.Error("Unexpected capabilities of BIRD 1")
+	}
+}
@@ -0,0 +1,90 @@
+package bird
+
+import (
+	"time"
+)
+
+// Route changes: Routes of a table with an age newer than
+// a given time, computed from the (cached) table. Pollers
+// can fetch the changed routes instead of the full table.
+//
+// Withdrawn routes are not part of the table and therefore
+// not included.
+
+// Formats of the route age. BIRD omits the date for routes
+// learned today, bird2 may include fractions of a second.
+var birdTimeFormats = []string{
+	"2006-01-02 15:04:05.999999",
+	birdDateFormat,
+}
+
+// Older routes may only have a date
+var birdDateFormat = "2006-01-02"
+
+var birdClockFormat = "15:04:05.999999"
+
+// parseBirdTime parses the age of a route in the local time of
+// the router. A time without a date is relative to the day of now.
+func parseBirdTime(value string, now time.Time) (time.Time, bool) {
+	for _, format := range birdTimeFormats {
+		if t, err := time.ParseInLocation(format, value, now.Location()); err == nil {
+			return t, true
+		}
+	}
+
+	clock, err := time.Parse(birdClockFormat, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	t := time.Date(now.Year(), now.Month(), now.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(),
+		now.Location())
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1) // Before midnight
+	}
+	return t, true
+}
+
+// latestBirdTime is the latest time an age may refer to.
+// An age with only a date covers the whole day.
+func latestBirdTime(value string, now time.Time) (time.Time, bool) {
+	t, ok := parseBirdTime(value, now)
+	if ok && len(value) == len(birdDateFormat) {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, ok
+}
+
+// filterRoutesSince selects the routes changed after since.
+// Routes with an age that can not be parsed, or with only
+// a date on the day of since, are included, so no change
+// is missed.
+func filterRoutesSince(routes []Parsed, since time.Time, now time.Time) []Parsed {
+	res := []Parsed{}
+	for _, route := range routes {
+		age, _ := route["age"].(string)
+		changed, ok := latestBirdTime(age, now)
+		if ok && !changed.After(since) {
+			continue
+		}
+		res = append(res, route)
+	}
+	return res
+}
+
+func RoutesChanges(useCache bool, table string, since time.Time) (Parsed, bool) {
+	routes, from_cache := RoutesTable(useCache, table)
+	if IsSpecial(routes) {
+		return routes, from_cache
+	}
+
+	tableRoutes, _ := routes["routes"].([]Parsed)
+
+	return Parsed{
+		"routes":    filterRoutesSince(tableRoutes, since, time.Now().In(birdLocation())),
+		"since":     since.UTC(),
+		"ttl":       routes["ttl"],
+		"cached_at": routes["cached_at"],
+	}, from_cache
+}
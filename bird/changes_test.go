@@ -0,0 +1,54 @@
+package bird
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBirdTime(t *testing.T) {
+	now := time.Date(2019, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	ages := map[string]time.Time{
+		"2019-03-09 08:17:33":     time.Date(2019, 3, 9, 8, 17, 33, 0, time.UTC),
+		"2019-03-09 08:17:33.250": time.Date(2019, 3, 9, 8, 17, 33, 250000000, time.UTC),
+		"2019-03-01":              time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+		"08:17:33":                time.Date(2019, 3, 10, 8, 17, 33, 0, time.UTC),
+		"23:59:00.500":            time.Date(2019, 3, 9, 23, 59, 0, 500000000, time.UTC),
+	}
+
+	for age, expected := range ages {
+		parsed, ok := parseBirdTime(age, now)
+		if !ok {
+			t.Error("Could not parse age:", age)
+			continue
+		}
+		if !parsed.Equal(expected) {
+			t.Error("Expected", expected, "for", age, "got:", parsed)
+		}
+	}
+
+	if _, ok := parseBirdTime("yesterday", now); ok {
+		t.Error("Expected an invalid age to fail")
+	}
+}
+
+func TestFilterRoutesSince(t *testing.T) {
+	now := time.Date(2019, 3, 10, 12, 0, 0, 0, time.UTC)
+	routes := []Parsed{
+		Parsed{"network": "10.0.0.0/24", "age": "2019-03-09 08:17:33"},
+		Parsed{"network": "10.0.1.0/24", "age": "11:00:00"},
+		Parsed{"network": "10.0.2.0/24", "age": "invalid"},
+		Parsed{"network": "10.0.3.0/24", "age": "2019-03-10"},
+		Parsed{"network": "10.0.4.0/24", "age": "2019-03-09"},
+	}
+
+	changed := filterRoutesSince(routes, now.Add(-2*time.Hour), now)
+	if len(changed) != 3 {
+		t.Fatal("Expected 3 changed routes, got:", changed)
+	}
+	if changed[0]["network"] != "10.0.1.0/24" ||
+		changed[1]["network"] != "10.0.2.0/24" ||
+		changed[2]["network"] != "10.0.3.0/24" {
+		t.Error("Unexpected changed routes:", changed)
+	}
+}
@@ -0,0 +1,124 @@
+package bird
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Communities dictionary
+//
+// Communities are mapped to labels by their value, e.g.
+// "65000:1" or "65000:1:2" for large and "rt:65000:1" for
+// extended communities. A component can be the wildcard "*".
+
+var communityLabels = struct {
+	sync.RWMutex
+	labels map[string]CommunityLabel
+}{
+	labels: map[string]CommunityLabel{},
+}
+
+// LoadCommunities loads the labels from the file and the
+// config. Labels from the config override the file.
+func LoadCommunities(config CommunitiesConfig) error {
+	labels := map[string]CommunityLabel{}
+
+	if config.File != "" {
+		data, err := ioutil.ReadFile(config.File)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &labels); err != nil {
+			return fmt.Errorf("Invalid communities file %s: %s", config.File, err)
+		}
+	}
+	for community, label := range config.Labels {
+		labels[community] = label
+	}
+
+	communityLabels.Lock()
+	communityLabels.labels = labels
+	communityLabels.Unlock()
+	return nil
+}
+
+// Communities returns the dictionary
+func Communities() map[string]CommunityLabel {
+	communityLabels.RLock()
+	defer communityLabels.RUnlock()
+
+	res := make(map[string]CommunityLabel, len(communityLabels.labels))
+	for community, label := range communityLabels.labels {
+		res[community] = label
+	}
+	return res
+}
+
+// Find the label of a community, trying wildcards
+// for every component from the right.
+func lookupCommunityLabel(community string) (CommunityLabel, bool) {
+	if label, ok := communityLabels.labels[community]; ok {
+		return label, true
+	}
+
+	parts := strings.Split(community, ":")
+	for i := len(parts) - 1; i >= 0; i-- {
+		wildcard := make([]string, len(parts))
+		copy(wildcard, parts)
+		wildcard[i] = "*"
+		if label, ok := communityLabels.labels[strings.Join(wildcard, ":")]; ok {
+			return label, true
+		}
+	}
+	return CommunityLabel{}, false
+}
+
+func communityKeys(bgp Parsed) []string {
+	keys := []string{}
+	if communities, ok := bgp["communities"].([][]int64); ok {
+		for _, c := range communities {
+			keys = append(keys, fmt.Sprintf("%d:%d", c[0], c[1]))
+		}
+	}
+	if communities, ok := bgp["large_communities"].([][]int64); ok {
+		for _, c := range communities {
+			keys = append(keys, fmt.Sprintf("%d:%d:%d", c[0], c[1], c[2]))
+		}
+	}
+	if communities, ok := bgp["ext_communities"].([]interface{}); ok {
+		for _, c := range communities {
+			if parts, ok := c.([]interface{}); ok && len(parts) == 3 {
+				keys = append(keys, fmt.Sprintf("%v:%v:%v", parts[0], parts[1], parts[2]))
+			}
+		}
+	}
+	return keys
+}
+
+// labelCommunities adds the labels of all known
+// communities of the route as communities_labeled.
+func labelCommunities(bgp Parsed) {
+	communityLabels.RLock()
+	defer communityLabels.RUnlock()
+
+	if len(communityLabels.labels) == 0 {
+		return
+	}
+
+	labeled := []Parsed{}
+	for _, community := range communityKeys(bgp) {
+		label, ok := lookupCommunityLabel(community)
+		if !ok {
+			continue
+		}
+		labeled = append(labeled, Parsed{
+			"community":   community,
+			"name":        label.Name,
+			"description": label.Description,
+		})
+	}
+	bgp["communities_labeled"] = labeled
+}
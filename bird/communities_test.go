@@ -0,0 +1,50 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLabelCommunities(t *testing.T) {
+	f, err := ioutil.TempFile("", "birdwatcher-communities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write([]byte(`{"65000:666": {"name": "file"}, "65000:1:*": {"name": "learned-at"}}`))
+	f.Close()
+
+	err = LoadCommunities(CommunitiesConfig{
+		File: f.Name(),
+		Labels: map[string]CommunityLabel{
+			"65000:666":   {Name: "blackhole", Description: "Discard traffic"},
+			"rt:65000:42": {Name: "vrf-42"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer LoadCommunities(CommunitiesConfig{})
+
+	bgp := Parsed{
+		"communities":       [][]int64{{65000, 666}, {65000, 2}},
+		"large_communities": [][]int64{{65000, 1, 7}},
+		"ext_communities":   []interface{}{[]interface{}{"rt", "65000", "42"}},
+	}
+	labelCommunities(bgp)
+
+	labeled := bgp["communities_labeled"].([]Parsed)
+	if len(labeled) != 3 {
+		t.Fatal("Expected 3 labeled communities, got:", labeled)
+	}
+	if labeled[0]["name"] != "blackhole" {
+		t.Error("Expected the config to override the file, got:", labeled[0])
+	}
+	if labeled[1]["community"] != "65000:1:7" || labeled[1]["name"] != "learned-at" {
+		t.Error("Expected a wildcard match, got:", labeled[1])
+	}
+	if labeled[2]["name"] != "vrf-42" {
+		t.Error("Unexpected extended community label:", labeled[2])
+	}
+}
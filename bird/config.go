@@ -14,10 +14,44 @@ type BirdConfig struct {
 	ConfigFilename string `toml:"config"`
 	BirdCmd        string `toml:"birdc"`
 	CacheTtl       int    `toml:"ttl"`
+
+	// TTL in seconds of the cached route counts
+	CountCacheTtl int `toml:"count_ttl"`
+
+	// Arguments of birdc (default: restricted mode, -r)
+	// and the control socket of BIRD (-s)
+	BirdcArgs []string `toml:"birdc_args"`
+	Socket    string   `toml:"socket"`
+
+	// Run birdc in this network namespace
+	Netns string `toml:"netns"`
 }
 
 type ParserConfig struct {
 	FilterFields []string `toml:"filter_fields"`
+
+	// Timezone of the times reported by BIRD (default: local)
+	// and of the *_timestamp fields (default: UTC)
+	BirdTimezone string `toml:"bird_timezone"`
+	Timezone     string `toml:"timezone"`
+}
+
+// Limits of a query, 0 disables a limit
+type QueryLimits struct {
+	MaxParseBytes int `toml:"max_parse_bytes"`
+	MaxWallTime   int `toml:"max_wall_time"` // seconds
+}
+
+type LimitsConfig struct {
+	MaxParseBytes int `toml:"max_parse_bytes"`
+	MaxWallTime   int `toml:"max_wall_time"`
+
+	// Maximum number of bytes read from birdc
+	MaxOutputBytes int `toml:"max_output_bytes"`
+
+	// Limits by query (e.g. "route all"), the longest
+	// matching prefix of the query applies
+	Queries map[string]QueryLimits `toml:"queries"`
 }
 
 type RateLimitConfig struct {
@@ -27,8 +61,47 @@ type RateLimitConfig struct {
 }
 
 type CacheConfig struct {
-	UseRedis      bool   `toml:"use_redis"`
-	RedisServer   string `toml:"redis_server"`
-	RedisPassword string `toml:"redis_password"`
-	RedisDb       int    `toml:"redis_db"`
+	UseRedis       bool   `toml:"use_redis"`
+	RedisServer    string `toml:"redis_server"`
+	RedisPassword  string `toml:"redis_password"`
+	RedisDb        int    `toml:"redis_db"`
+	SpillDir       string `toml:"spill_dir"`
+	SpillThreshold int    `toml:"spill_threshold"`
+	SpillHoldSize  int    `toml:"spill_hold_size"`
+
+	// Save the cache on shutdown and restore it on startup,
+	// entries older than the max age (seconds) are dropped
+	PersistFile   string `toml:"persist_file"`
+	PersistMaxAge int    `toml:"persist_max_age"`
+}
+
+type RoutesConfig struct {
+	ReceivedSource   string `toml:"received_source"`
+	FilteredStrategy string `toml:"filtered_strategy"`
+}
+
+type PeerConfig struct {
+	Relationship string `toml:"relationship"`
+	Region       string `toml:"region"`
+	Location     string `toml:"location"`
+}
+
+type AnalysisConfig struct {
+	UpstreamAsns []int64 `toml:"upstream_asns"`
+}
+
+type PluginConfig struct {
+	Command string `toml:"command"`
+	Parser  string `toml:"parser"`
+}
+
+type CommunityLabel struct {
+	Name        string `toml:"name" json:"name"`
+	Description string `toml:"description" json:"description,omitempty"`
+}
+
+type CommunitiesConfig struct {
+	// JSON file mapping communities to labels
+	File   string                    `toml:"file"`
+	Labels map[string]CommunityLabel `toml:"labels"`
 }
@@ -0,0 +1,206 @@
+package bird
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Route counts of all tables per address family, queried
+// in a single birdc session: the count commands are written
+// to the stdin of birdc, which answers each with one line.
+
+const routesCountAllKey = "routes count all"
+
+// RunBatch runs the queries in one birdc session and
+// returns the replies in the order of the queries.
+func RunBatch(ctx context.Context, queries []string) (io.Reader, error) {
+	if MockDir != "" {
+		return runMockBatch(queries)
+	}
+
+	input := &strings.Builder{}
+	for _, query := range queries {
+		input.WriteString("show " + query + "\n")
+	}
+
+	cmd := birdcCommand(ClientConf, "")
+	if SandboxConf.Enabled {
+		cmd = sandboxCommand(SandboxConf, sandboxExecutable(), cmd)
+	}
+	birdc := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	birdc.Stdin = strings.NewReader(input.String())
+
+	out, err := readBirdc(birdc, LimitsConf.MaxOutputBytes, 0)
+	if err != nil {
+		recordBirdcError("batch of "+fmt.Sprint(len(queries))+" queries", err)
+		return nil, err
+	}
+	return bytes.NewReader(out), nil
+}
+
+// The fixtures of the single queries are concatenated,
+// a missing fixture yields an error reply.
+func runMockBatch(queries []string) (io.Reader, error) {
+	out := &bytes.Buffer{}
+	for _, query := range queries {
+		reply, err := runMock(query)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		io.Copy(out, reply)
+	}
+	return out, nil
+}
+
+// parseBatchReplies splits the output of a batch into one
+// reply line per query.
+func parseBatchReplies(reader io.Reader, n int) ([]string, error) {
+	replies := []string{}
+
+	lines := newLineIterator(reader, true)
+	for lines.next() {
+		line := lines.string()
+		if specialLine(line) || strings.HasPrefix(line, "bird>") {
+			continue
+		}
+		replies = append(replies, line)
+	}
+
+	if len(replies) != n {
+		return nil, fmt.Errorf("expected %d replies from birdc, got %d", n, len(replies))
+	}
+	return replies, nil
+}
+
+// countFamilies returns the address families to count
+// and the filter selecting their networks.
+func countFamilies() ([]string, map[string]string) {
+	if !HasCapability("channels") {
+		// BIRD 1 runs one daemon per address family
+		family := "ipv" + IPVersion
+		return []string{family}, map[string]string{family: ""}
+	}
+
+	filters := map[string]string{}
+	families := []string{"ipv4", "ipv6"}
+	for _, family := range families {
+		v := strings.TrimPrefix(family, "ipv")
+		filters[family] = " where net.type = NET_IP" + v +
+			" || net.type = NET_VPN" + v +
+			" || net.type = NET_FLOW" + v
+	}
+	return families, filters
+}
+
+// RoutesCountAll counts the routes of every table per
+// address family. The result is kept in the count cache.
+func RoutesCountAll(useCache bool) (Parsed, bool) {
+	if useCache {
+		if val, ok := fromCountCache(routesCountAllKey); ok {
+			return val, true
+		}
+	}
+
+	symbols, _ := Symbols(useCache)
+	if IsSpecial(symbols) {
+		return symbols, false
+	}
+	tables := symbolNames(symbols, "routing table")
+	if len(tables) == 0 {
+		return Parsed{"error": "no routing tables"}, false
+	}
+
+	if !checkRateLimit() {
+		return NilParse, false
+	}
+
+	families, filters := countFamilies()
+	queries := []string{}
+	for _, table := range tables {
+		for _, family := range families {
+			queries = append(queries,
+				"route table "+remapTable(table)+filters[family]+" count")
+		}
+	}
+
+	ctx := context.Background()
+	if limits := queryLimits(LimitsConf, routesCountAllKey); limits.MaxWallTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx,
+			time.Duration(limits.MaxWallTime)*time.Second)
+		defer cancel()
+	}
+
+	out, err := RunBatch(ctx, queries)
+	if ctx.Err() != nil {
+		wallTimeExceededTotal.Inc()
+		return WallTimeExceeded, false
+	}
+	if err != nil {
+		return BirdError, false
+	}
+	replies, err := parseBatchReplies(out, len(queries))
+	if err != nil {
+		return Parsed{"error": err.Error()}, false
+	}
+
+	res := countAllResult(tables, families, replies)
+	toCountCache(routesCountAllKey, res)
+	return res, false
+}
+
+func countAllResult(tables, families []string, replies []string) Parsed {
+	total := int64(0)
+	totalFamilies := Parsed{}
+	errors := Parsed{}
+	counts := Parsed{}
+
+	i := 0
+	for _, table := range tables {
+		tableTotal := int64(0)
+		tableFamilies := Parsed{}
+		for _, family := range families {
+			reply := replies[i]
+			i++
+
+			groups := regex.routeCount.countRx.FindStringSubmatch(reply)
+			if groups == nil {
+				errors[table] = reply
+				continue
+			}
+			n := parseInt(groups[1])
+			tableFamilies[family] = n
+			tableTotal += n
+		}
+		if _, failed := errors[table]; failed {
+			continue
+		}
+
+		for family, n := range tableFamilies {
+			sum, _ := totalFamilies[family].(int64)
+			totalFamilies[family] = sum + n.(int64)
+		}
+
+		counts[table] = Parsed{
+			"routes":   tableTotal,
+			"families": tableFamilies,
+		}
+		total += tableTotal
+	}
+
+	res := Parsed{
+		"tables":   counts,
+		"families": totalFamilies,
+		"routes":   total,
+	}
+	if len(errors) > 0 {
+		res["table_errors"] = errors
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoutesCountAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "countall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixtures := map[string]string{
+		"symbols":                  "master\trouting table\nT1\trouting table\nT2\trouting table\n",
+		"route table master count": "BIRD 1.6.8 ready.\n10 of 12 routes for 10 networks\n",
+		"route table T1 count":     "BIRD 1.6.8 ready.\n3 of 3 routes for 3 networks\n",
+	}
+	for cmd, out := range fixtures {
+		err := ioutil.WriteFile(filepath.Join(dir, FixtureName(cmd)), []byte(out), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	MockDir = dir
+	formerCache := cache
+	formerCountCache := countCache
+	cache, _ = NewMemoryCache()
+	countCache, _ = NewMemoryCache()
+	defer func() {
+		MockDir = ""
+		cache = formerCache
+		countCache = formerCountCache
+	}()
+
+	res, _ := RoutesCountAll(true)
+	if res["routes"] != int64(13) {
+		t.Error("Expected 13 routes in total, got:", res)
+	}
+	families := res["families"].(Parsed)
+	if families["ipv4"] != int64(13) {
+		t.Error("Expected the IPv4 count, got:", families)
+	}
+	tables := res["tables"].(Parsed)
+	if tables["T1"].(Parsed)["routes"] != int64(3) {
+		t.Error("Unexpected count of T1:", tables["T1"])
+	}
+
+	// There is no fixture for T2
+	errors, _ := res["table_errors"].(Parsed)
+	if _, ok := errors["T2"]; !ok {
+		t.Error("Expected an error for T2, got:", res["table_errors"])
+	}
+
+	if _, fromCache := RoutesCountAll(true); !fromCache {
+		t.Error("Expected the counts from the cache")
+	}
+}
+
+func TestParseBatchReplies(t *testing.T) {
+	out := "BIRD 2.0.12 ready.\nAccess restricted\n1 of 1 routes for 1 networks\nsyntax error\n"
+	replies, err := parseBatchReplies(strings.NewReader(out), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replies[1] != "syntax error" {
+		t.Error("Unexpected replies:", replies)
+	}
+
+	if _, err := parseBatchReplies(strings.NewReader(out), 3); err == nil {
+		t.Error("Expected an error for a missing reply")
+	}
+}
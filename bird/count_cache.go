@@ -0,0 +1,43 @@
+package bird
+
+import (
+	"time"
+)
+
+// Route counts are polled far more often than the routes
+// are fetched. They are queried with `show route ... count`
+// only and cached in memory apart from the route dumps, with
+// a TTL in seconds.
+
+const defaultCountCacheTtl = 30 * time.Second
+
+var countCache, _ = NewMemoryCache()
+
+func countCacheTtl() time.Duration {
+	if ClientConf.CountCacheTtl > 0 {
+		return time.Duration(ClientConf.CountCacheTtl) * time.Second
+	}
+	return defaultCountCacheTtl
+}
+
+func fromCountCache(cmd string) (Parsed, bool) {
+	val, err := countCache.Get(cmd)
+	return val, err == nil
+}
+
+func toCountCache(cmd string, val Parsed) bool {
+	countCache.SetTTL(cmd, val, countCacheTtl())
+	return true
+}
+
+// RunAndParseCount runs a route count query using the
+// count cache.
+func RunAndParseCount(useCache bool, cmd string) (Parsed, bool) {
+	return runAndParse(
+		useCache,
+		cmd,
+		parseRoutesCount,
+		nil,
+		fromCountCache,
+		toCountCache)
+}
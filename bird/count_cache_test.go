@@ -0,0 +1,52 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRoutesCountCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, FixtureName("route protocol R1 count")),
+		[]byte("BIRD 1.6.8 ready.\n1234 of 5678 routes for 1234 networks\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MockDir = dir
+	formerCache := cache
+	formerCountCache := countCache
+	cache, _ = NewMemoryCache()
+	countCache, _ = NewMemoryCache()
+	defer func() {
+		MockDir = ""
+		cache = formerCache
+		countCache = formerCountCache
+	}()
+
+	res, fromCache := RoutesProtoCount(true, "R1")
+	if fromCache || res["routes"] != int64(1234) {
+		t.Error("Unexpected result:", res, fromCache)
+	}
+
+	res, fromCache = RoutesProtoCount(true, "R1")
+	if !fromCache {
+		t.Error("Expected the count from the count cache")
+	}
+	ttl := res["ttl"].(time.Time).Sub(res["cached_at"].(time.Time))
+	if ttl != defaultCountCacheTtl {
+		t.Error("Expected the count TTL, got:", ttl)
+	}
+
+	if cache.(*MemoryCache).Len() != 0 {
+		t.Error("Counts should not be stored in the route cache")
+	}
+}
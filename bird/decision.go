@@ -0,0 +1,276 @@
+package bird
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Reconstruction of the BGP best path selection from the
+// parsed route attributes. This follows the order of
+// comparisons in BIRD's bgp_rte_better as far as the
+// attributes are visible in the birdc output. After the MED
+// BIRD prefers eBGP over iBGP routes and compares the IGP
+// metric and the router id, which are not visible. Paths
+// tied up to the MED are therefore reported as undetermined.
+
+type decisionStep struct {
+	name string
+	// Returns a positive value if a is better than b,
+	// a negative value if b is better than a, 0 otherwise.
+	compare func(a, b Parsed) int
+}
+
+var decisionSteps = []decisionStep{
+	{"preference", comparePreference},
+	{"local_pref", compareLocalPref},
+	{"as_path_length", compareAsPathLength},
+	{"origin", compareOrigin},
+	{"med", compareMed},
+}
+
+// Label of paths tied at all visible steps
+const decisionUndetermined = "undetermined"
+
+var originRank = map[string]int64{
+	"igp":        0,
+	"egp":        1,
+	"incomplete": 2,
+}
+
+func routeBgp(route Parsed) Parsed {
+	bgp, ok := route["bgp"].(Parsed)
+	if !ok {
+		return Parsed{}
+	}
+	return bgp
+}
+
+func routeBgpInt(route Parsed, key string, fallback int64) int64 {
+	value, ok := routeBgp(route)[key].(string)
+	if !ok {
+		return fallback
+	}
+	return parseInt(value)
+}
+
+func routeAsPath(route Parsed) []string {
+	path, ok := routeBgp(route)["as_path"].([]string)
+	if !ok {
+		return []string{}
+	}
+
+	res := []string{}
+	for _, asn := range path {
+		if !emptyString(asn) {
+			res = append(res, asn)
+		}
+	}
+	return res
+}
+
+// routeAsPathLength counts an AS_SET as a single hop
+func routeAsPathLength(route Parsed) int {
+	return len(asPathHops(routeAsPath(route)))
+}
+
+func routeNeighbor(route Parsed) net.IP {
+	if from, ok := route["learnt_from"].(string); ok && from != "" {
+		return net.ParseIP(from)
+	}
+	if gateway, ok := route["gateway"].(string); ok {
+		return net.ParseIP(gateway)
+	}
+	return nil
+}
+
+// Higher values are better
+func compareHigher(a, b int64) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
+
+func comparePreference(a, b Parsed) int {
+	prefA, _ := a["metric"].(int64)
+	prefB, _ := b["metric"].(int64)
+	return compareHigher(prefA, prefB)
+}
+
+func compareLocalPref(a, b Parsed) int {
+	return compareHigher(
+		routeBgpInt(a, "local_pref", 100),
+		routeBgpInt(b, "local_pref", 100))
+}
+
+func compareAsPathLength(a, b Parsed) int {
+	return compareHigher(
+		int64(routeAsPathLength(b)),
+		int64(routeAsPathLength(a)))
+}
+
+func compareOrigin(a, b Parsed) int {
+	rank := func(route Parsed) int64 {
+		origin, _ := routeBgp(route)["origin"].(string)
+		if r, ok := originRank[strings.ToLower(origin)]; ok {
+			return r
+		}
+		return originRank["incomplete"]
+	}
+	return compareHigher(rank(b), rank(a))
+}
+
+func compareMed(a, b Parsed) int {
+	// MEDs are only comparable for paths from the same neighbor AS
+	pathA := routeAsPath(a)
+	pathB := routeAsPath(b)
+	if len(pathA) == 0 || len(pathB) == 0 || pathA[0] != pathB[0] {
+		return 0
+	}
+
+	return compareHigher(
+		routeBgpInt(b, "med", 0),
+		routeBgpInt(a, "med", 0))
+}
+
+func compareNeighborAddress(a, b Parsed) int {
+	addrA := routeNeighbor(a)
+	addrB := routeNeighbor(b)
+	if addrA == nil || addrB == nil {
+		return 0
+	}
+
+	// Lower address wins
+	return -bytes.Compare(addrA.To16(), addrB.To16())
+}
+
+func decisionValue(route Parsed, step string) interface{} {
+	switch step {
+	case "preference":
+		return route["metric"]
+	case "local_pref":
+		return routeBgpInt(route, "local_pref", 100)
+	case "as_path_length":
+		return routeAsPathLength(route)
+	case "origin":
+		return routeBgp(route)["origin"]
+	case "med":
+		return routeBgpInt(route, "med", 0)
+	}
+	return nil
+}
+
+// Find the first decision step at which the candidate
+// is worse than the best route.
+func lostAt(candidate, best Parsed) (string, bool) {
+	for _, step := range decisionSteps {
+		cmp := step.compare(candidate, best)
+		if cmp < 0 {
+			return step.name, true
+		}
+		if cmp > 0 {
+			// The candidate would be better at this step;
+			// the result is determined by something we can not see.
+			return "", false
+		}
+	}
+	return decisionUndetermined, true
+}
+
+// isBetterRoute breaks ties at the visible steps by the
+// neighbor address, which is the last step of BIRD.
+func isBetterRoute(a, b Parsed) bool {
+	for _, step := range decisionSteps {
+		if cmp := step.compare(a, b); cmp != 0 {
+			return cmp > 0
+		}
+	}
+	return compareNeighborAddress(a, b) > 0
+}
+
+// Returns the index of the best route. The route marked as
+// primary by BIRD is preferred over our own reconstruction.
+// With per peer tables every table marks its own route as
+// primary, the best of those is reconstructed.
+func selectBestRoute(routes []Parsed) int {
+	candidates := []int{}
+	for i, route := range routes {
+		if primary, _ := route["primary"].(bool); primary {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		for i := range routes {
+			candidates = append(candidates, i)
+		}
+	}
+
+	best := candidates[0]
+	for _, i := range candidates[1:] {
+		if isBetterRoute(routes[i], routes[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// explainBestPath annotates every candidate path of a prefix with
+// the decision step at which it lost against the best path.
+func explainBestPath(routes []Parsed) []Parsed {
+	res := []Parsed{}
+
+	networks := []string{}
+	byNetwork := map[string][]Parsed{}
+	for _, route := range routes {
+		network, _ := route["network"].(string)
+		if _, ok := byNetwork[network]; !ok {
+			networks = append(networks, network)
+		}
+		byNetwork[network] = append(byNetwork[network], route)
+	}
+
+	for _, network := range networks {
+		candidates := byNetwork[network]
+		bestIndex := selectBestRoute(candidates)
+		best := candidates[bestIndex]
+
+		for i, route := range candidates {
+			explained := make(Parsed, len(route)+3)
+			for k, v := range route {
+				explained[k] = v
+			}
+
+			if i == bestIndex {
+				explained["best"] = true
+				res = append(res, explained)
+				continue
+			}
+
+			explained["best"] = false
+			step, ok := lostAt(route, best)
+			if step == decisionUndetermined {
+				explained["lost_at"] = step
+				explained["reason"] = "tied up to the med, decided by eBGP over iBGP, IGP metric, router id or neighbor address"
+			} else if ok {
+				explained["lost_at"] = step
+				explained["reason"] = fmt.Sprintf(
+					"%s: %v (best: %v)",
+					step,
+					decisionValue(route, step),
+					decisionValue(best, step))
+			} else {
+				explained["lost_at"] = "unknown"
+				explained["reason"] = "decided by attributes not visible in birdc output (e.g. router id, IGP metric)"
+			}
+
+			res = append(res, explained)
+		}
+	}
+
+	return res
+}
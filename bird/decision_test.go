@@ -0,0 +1,81 @@
+package bird
+
+import (
+	"testing"
+)
+
+func decisionTestRoute(gateway, localPref string, asPath []string, med string, primary bool) Parsed {
+	bgp := Parsed{
+		"local_pref": localPref,
+		"as_path":    asPath,
+		"origin":     "IGP",
+	}
+	if med != "" {
+		bgp["med"] = med
+	}
+
+	return Parsed{
+		"network": "192.0.2.0/24",
+		"gateway": gateway,
+		"metric":  int64(100),
+		"primary": primary,
+		"bgp":     bgp,
+	}
+}
+
+func TestExplainBestPath(t *testing.T) {
+	routes := []Parsed{
+		decisionTestRoute("10.0.0.1", "100", []string{"64500", "64510"}, "", false),
+		decisionTestRoute("10.0.0.2", "200", []string{"64501", "64502", "64510"}, "", true),
+		decisionTestRoute("10.0.0.3", "200", []string{"64501", "64502", "64503", "64510"}, "", false),
+		decisionTestRoute("10.0.0.4", "200", []string{"64501", "64502", "64510"}, "50", false),
+		decisionTestRoute("10.0.0.5", "200", []string{"64501", "64502", "64510"}, "", false),
+	}
+
+	expected := []string{"local_pref", "", "as_path_length", "med", "undetermined"}
+
+	explained := explainBestPath(routes)
+	if len(explained) != len(routes) {
+		t.Fatal("Expected", len(routes), "routes, got:", len(explained))
+	}
+
+	for i, route := range explained {
+		if expected[i] == "" {
+			if route["best"] != true {
+				t.Error("Expected route", i, "to be the best route")
+			}
+			continue
+		}
+
+		if route["lost_at"] != expected[i] {
+			t.Error("Expected route", i, "to lose at", expected[i], "not", route["lost_at"])
+		}
+	}
+}
+
+func TestSelectBestRouteWithoutPrimary(t *testing.T) {
+	routes := []Parsed{
+		decisionTestRoute("10.0.0.1", "100", []string{"64500"}, "", false),
+		decisionTestRoute("10.0.0.2", "100", []string{"64501"}, "", false),
+		decisionTestRoute("10.0.0.3", "300", []string{"64502", "64503"}, "", false),
+	}
+
+	if best := selectBestRoute(routes); best != 2 {
+		t.Error("Expected route 2 to be selected, got:", best)
+	}
+}
+
+func TestAsPathLengthWithAsSet(t *testing.T) {
+	routes := []Parsed{
+		decisionTestRoute("10.0.0.1", "100", []string{"64500", "64501", "64502"}, "", false),
+		decisionTestRoute("10.0.0.2", "100", []string{"64500", "{64510", "64511", "64512}"}, "", false),
+	}
+
+	// The AS_SET counts as a single hop
+	if best := selectBestRoute(routes); best != 1 {
+		t.Error("Expected route 1 to be selected, got:", best)
+	}
+	if length := decisionValue(routes[1], "as_path_length"); length != 2 {
+		t.Error("Expected an as_path_length of 2, got:", length)
+	}
+}
@@ -0,0 +1,67 @@
+package bird
+
+// Deduplication of routes
+//
+// In per peer table setups the same prefix is listed once
+// per table. The routes of a prefix are either collapsed to
+// the best (primary) path or grouped into a path list.
+
+func routeNetwork(route Parsed) string {
+	network, _ := route["network"].(string)
+	return network
+}
+
+// groupPaths collects the routes of each prefix. The prefixes
+// keep the order of their first route.
+func groupPaths(routes []Parsed) ([]string, [][]Parsed) {
+	index := map[string]int{}
+	groups := [][]Parsed{}
+	networks := []string{}
+
+	for _, route := range routes {
+		network := routeNetwork(route)
+		i, seen := index[network]
+		if !seen {
+			i = len(groups)
+			index[network] = i
+			groups = append(groups, []Parsed{})
+			networks = append(networks, network)
+		}
+		groups[i] = append(groups[i], route)
+	}
+	return networks, groups
+}
+
+// DedupeRoutes keeps the best route of each prefix, see
+// selectBestRoute. The prefixes keep the order of their
+// first route.
+func DedupeRoutes(routes []Parsed) []Parsed {
+	_, groups := groupPaths(routes)
+
+	res := make([]Parsed, len(groups))
+	for i, paths := range groups {
+		res[i] = paths[selectBestRoute(paths)]
+	}
+	return res
+}
+
+// GroupRoutesByPrefix returns the prefixes with the list
+// of their paths, the best path is listed first.
+func GroupRoutesByPrefix(routes []Parsed) []Parsed {
+	networks, groups := groupPaths(routes)
+
+	res := make([]Parsed, len(groups))
+	for i, paths := range groups {
+		best := selectBestRoute(paths)
+		sorted := make([]Parsed, 0, len(paths))
+		sorted = append(sorted, paths[best])
+		sorted = append(sorted, paths[:best]...)
+		sorted = append(sorted, paths[best+1:]...)
+
+		res[i] = Parsed{
+			"network": networks[i],
+			"paths":   sorted,
+		}
+	}
+	return res
+}
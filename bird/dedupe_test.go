@@ -0,0 +1,61 @@
+package bird
+
+import (
+	"testing"
+)
+
+func dedupeTestRoutes() []Parsed {
+	return []Parsed{
+		{"network": "10.0.0.0/24", "from_protocol": "R1", "primary": false},
+		{"network": "10.0.1.0/24", "from_protocol": "R1", "primary": true},
+		{"network": "10.0.0.0/24", "from_protocol": "R2", "primary": true},
+		{"network": "10.0.0.0/24", "from_protocol": "R3", "primary": false},
+	}
+}
+
+func TestDedupeRoutes(t *testing.T) {
+	routes := DedupeRoutes(dedupeTestRoutes())
+	if len(routes) != 2 {
+		t.Fatal("Expected 2 routes, got:", routes)
+	}
+	if routes[0]["network"] != "10.0.0.0/24" || routes[0]["from_protocol"] != "R2" {
+		t.Error("Expected the primary route of 10.0.0.0/24, got:", routes[0])
+	}
+	if routes[1]["network"] != "10.0.1.0/24" {
+		t.Error("Unexpected route:", routes[1])
+	}
+}
+
+func TestGroupRoutesByPrefix(t *testing.T) {
+	groups := GroupRoutesByPrefix(dedupeTestRoutes())
+	if len(groups) != 2 {
+		t.Fatal("Expected 2 prefixes, got:", groups)
+	}
+
+	paths := groups[0]["paths"].([]Parsed)
+	if groups[0]["network"] != "10.0.0.0/24" || len(paths) != 3 {
+		t.Fatal("Unexpected group:", groups[0])
+	}
+	if paths[0]["from_protocol"] != "R2" || paths[1]["from_protocol"] != "R1" {
+		t.Error("Expected the primary path first, got:", paths)
+	}
+}
+
+func TestDedupeRoutesPeerTables(t *testing.T) {
+	// Every peer table marks its own route as primary
+	routes := []Parsed{
+		decisionTestRoute("10.0.0.1", "100", []string{"64500"}, "", true),
+		decisionTestRoute("10.0.0.2", "200", []string{"64501"}, "", true),
+	}
+
+	deduped := DedupeRoutes(routes)
+	if len(deduped) != 1 || deduped[0]["gateway"] != "10.0.0.2" {
+		t.Error("Expected the route with the higher local_pref, got:", deduped)
+	}
+
+	groups := GroupRoutesByPrefix(routes)
+	paths := groups[0]["paths"].([]Parsed)
+	if paths[0]["gateway"] != "10.0.0.2" {
+		t.Error("Expected the best path first, got:", paths)
+	}
+}
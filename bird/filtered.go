@@ -0,0 +1,266 @@
+package bird
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Filtered routes of a protocol
+//
+// How the filtered routes are determined depends on the setup,
+// the strategy is selected with routes.filtered_strategy:
+//
+//   direct              - routes rejected by the import filter of
+//                         the protocol (requires 'import keep filtered')
+//   pipe-diff           - routes of the protocol in its peer table
+//                         which are not exported by any pipe of the
+//                         peer table (per peer table setups)
+//   bird2-filtered-keep - routes rejected by the import filter of the
+//                         channel, kept in the table of the protocol
+//                         (BIRD 2, 'import keep filtered' on the channel)
+//   auto                - bird2-filtered-keep with BIRD 2, falling back
+//                         to pipe-diff if BIRD 2 is not available or the
+//                         query fails, or to direct without pipes
+
+const (
+	FilteredStrategyDirect   = "direct"
+	FilteredStrategyPipeDiff = "pipe-diff"
+	FilteredStrategyBird2    = "bird2-filtered-keep"
+	FilteredStrategyAuto     = "auto"
+)
+
+var filteredStrategies = map[string]string{
+	FilteredStrategyDirect: "routes rejected by the import filter " +
+		"of the protocol",
+	FilteredStrategyPipeDiff: "routes of the protocol in its peer table " +
+		"not exported by any pipe of the peer table",
+	FilteredStrategyBird2: "routes rejected by the import filter " +
+		"of the channel, kept in the table of the protocol",
+}
+
+func filteredStrategy() string {
+	if RoutesConf.FilteredStrategy == "" {
+		return FilteredStrategyDirect
+	}
+	return RoutesConf.FilteredStrategy
+}
+
+// RoutesFiltered retrieves the filtered routes of a protocol
+// using the configured strategy. The strategy is described
+// in the filtered_strategy of the result.
+func RoutesFiltered(useCache bool, protocol string) (Parsed, bool) {
+	strategy := filteredStrategy()
+
+	var res Parsed
+	var fromCache bool
+	if strategy == FilteredStrategyAuto {
+		strategy, res, fromCache = routesFilteredAuto(useCache, protocol)
+		if strategy == "" {
+			return res, fromCache
+		}
+	}
+
+	switch strategy {
+	case FilteredStrategyDirect:
+		res, fromCache = routesFilteredDirect(useCache, protocol)
+	case FilteredStrategyPipeDiff:
+		res, fromCache = routesFilteredPipeDiff(useCache, protocol)
+	case FilteredStrategyBird2:
+		if res == nil {
+			res, fromCache = routesFilteredBird2(useCache, protocol)
+		}
+	default:
+		return Parsed{"error": "unknown filtered strategy: " + strategy}, false
+	}
+	if IsSpecial(res) {
+		return res, fromCache
+	}
+	if _, ok := res["error"]; ok {
+		return res, fromCache
+	}
+
+	ret := make(Parsed, len(res)+1)
+	for k, v := range res {
+		ret[k] = v
+	}
+	ret["filtered_strategy"] = Parsed{
+		"name":      strategy,
+		"semantics": filteredStrategies[strategy],
+		"auto":      filteredStrategy() == FilteredStrategyAuto,
+	}
+	return ret, fromCache
+}
+
+// routesFilteredAuto detects the strategy. The filtered routes
+// are retrieved right away with BIRD 2 and returned if the
+// query succeeds. The strategy is empty if the protocols
+// could not be retrieved.
+func routesFilteredAuto(useCache bool, protocol string) (string, Parsed, bool) {
+	if HasCapability("filtered_keep") {
+		res, fromCache := routesFilteredBird2(useCache, protocol)
+		_, failed := res["error"]
+		if !IsSpecial(res) && !failed {
+			return FilteredStrategyBird2, res, fromCache
+		}
+	}
+
+	table, protocols, fromCache := protocolTable(useCache, protocol)
+	if table == "" {
+		return "", protocols, fromCache
+	}
+	if len(peerTablePipes(protocols["protocols"].(Parsed), table)) > 0 {
+		return FilteredStrategyPipeDiff, nil, fromCache
+	}
+	return FilteredStrategyDirect, nil, fromCache
+}
+
+func routesFilteredDirect(useCache bool, protocol string) (Parsed, bool) {
+	cmd := routesQuery("all filtered protocol " + protocol)
+	return RunAndParse(
+		useCache,
+		GetCacheKey("RoutesFiltered", protocol),
+		cmd,
+		parseRoutes,
+		nil)
+}
+
+// protocolTable looks up the table of the protocol
+func protocolTable(useCache bool, protocol string) (string, Parsed, bool) {
+	protocols, fromCache := Protocols(useCache)
+	if IsSpecial(protocols) {
+		return "", protocols, fromCache
+	}
+
+	details, ok := protocols["protocols"].(Parsed)[protocol].(Parsed)
+	if !ok {
+		return "", Parsed{"error": "unknown protocol: " + protocol}, fromCache
+	}
+	table, ok := details["table"].(string)
+	if !ok {
+		return "", Parsed{"error": "could not determine table of " + protocol}, fromCache
+	}
+	return table, protocols, fromCache
+}
+
+func routesFilteredBird2(useCache bool, protocol string) (Parsed, bool) {
+	if !HasCapability("filtered_keep") {
+		return Parsed{"error": FilteredStrategyBird2 + " requires BIRD 2"}, false
+	}
+
+	table, res, fromCache := protocolTable(useCache, protocol)
+	if table == "" {
+		return res, fromCache
+	}
+	table = remapTable(table)
+
+	cmd := routesQuery("table " + table + " all filtered protocol " + protocol)
+	res, fromCache = RunAndParse(
+		useCache,
+		GetCacheKey("RoutesFilteredBird2", table, protocol),
+		cmd,
+		parseRoutes,
+		nil)
+	if IsSpecial(res) {
+		return res, fromCache
+	}
+
+	ret := make(Parsed, len(res)+1)
+	for k, v := range res {
+		ret[k] = v
+	}
+	ret["table"] = table
+	return ret, fromCache
+}
+
+// peerTablePipes returns the pipes with the table as peer table
+func peerTablePipes(protocols Parsed, table string) []string {
+	pipes := []string{}
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+		if peerTable, _ := protocol["peer_table"].(string); peerTable == table {
+			pipes = append(pipes, name)
+		}
+	}
+	sort.Strings(pipes)
+	return pipes
+}
+
+func routeIdentity(route Parsed) string {
+	return fmt.Sprintf("%v|%v|%v",
+		route["network"], route["gateway"], route["from_protocol"])
+}
+
+// intersectRoutes keeps the routes of the first set which
+// are present in all sets.
+func intersectRoutes(sets [][]Parsed) []Parsed {
+	res := []Parsed{}
+	if len(sets) == 0 {
+		return res
+	}
+
+	counts := map[string]int{}
+	for _, routes := range sets[1:] {
+		seen := map[string]bool{}
+		for _, route := range routes {
+			id := routeIdentity(route)
+			if !seen[id] {
+				seen[id] = true
+				counts[id]++
+			}
+		}
+	}
+
+	for _, route := range sets[0] {
+		if counts[routeIdentity(route)] == len(sets)-1 {
+			res = append(res, route)
+		}
+	}
+	return res
+}
+
+// A route of the peer table is filtered only if none of
+// the pipes exports it, so with multiple pipes the routes
+// not exported by each of them are intersected.
+func routesFilteredPipeDiff(useCache bool, protocol string) (Parsed, bool) {
+	table, protocols, fromCache := protocolTable(useCache, protocol)
+	if table == "" {
+		return protocols, fromCache
+	}
+
+	pipes := peerTablePipes(protocols["protocols"].(Parsed), table)
+	if len(pipes) == 0 {
+		return Parsed{"error": "no pipes with peer table " + table}, fromCache
+	}
+
+	var ttl, cachedAt interface{}
+	sets := [][]Parsed{}
+	for _, pipe := range pipes {
+		cmd := routesQuery("table " + remapTable(table) +
+			" noexport " + pipe + " protocol " + protocol + " all")
+		res, fromCache := RunAndParse(
+			useCache,
+			GetCacheKey("RoutesFilteredPipeDiff", table, pipe, protocol),
+			cmd,
+			parseRoutes,
+			nil)
+		if IsSpecial(res) {
+			return res, fromCache
+		}
+		routes, _ := res["routes"].([]Parsed)
+		sets = append(sets, routes)
+		if ttl == nil {
+			ttl, cachedAt = res["ttl"], res["cached_at"]
+		}
+	}
+
+	return Parsed{
+		"routes":    intersectRoutes(sets),
+		"table":     table,
+		"pipes":     pipes,
+		"ttl":       ttl,
+		"cached_at": cachedAt,
+	}, fromCache
+}
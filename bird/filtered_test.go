@@ -0,0 +1,89 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPeerTablePipes(t *testing.T) {
+	protocols := Parsed{
+		"R1":  Parsed{"bird_protocol": "BGP", "table": "T_R1"},
+		"P2":  Parsed{"bird_protocol": "Pipe", "table": "master", "peer_table": "T_R1"},
+		"P1":  Parsed{"bird_protocol": "Pipe", "table": "master", "peer_table": "T_R1"},
+		"P_3": Parsed{"bird_protocol": "Pipe", "table": "master", "peer_table": "T_R3"},
+	}
+
+	pipes := peerTablePipes(protocols, "T_R1")
+	if len(pipes) != 2 || pipes[0] != "P1" || pipes[1] != "P2" {
+		t.Error("Expected pipes P1 and P2, got:", pipes)
+	}
+}
+
+func TestIntersectRoutes(t *testing.T) {
+	a := Parsed{"network": "10.0.0.0/24", "gateway": "192.0.2.1", "from_protocol": "R1"}
+	b := Parsed{"network": "10.0.1.0/24", "gateway": "192.0.2.1", "from_protocol": "R1"}
+	c := Parsed{"network": "10.0.2.0/24", "gateway": "192.0.2.1", "from_protocol": "R1"}
+
+	// Not exported by the first pipe: a, b, c.
+	// Not exported by the second pipe: b, c.
+	// Not exported by the third pipe: a, c.
+	routes := intersectRoutes([][]Parsed{{a, b, c}, {b, c}, {a, c, c}})
+	if len(routes) != 1 || routes[0]["network"] != "10.0.2.0/24" {
+		t.Error("Expected only 10.0.2.0/24 to be filtered, got:", routes)
+	}
+
+	routes = intersectRoutes([][]Parsed{{a, b}})
+	if len(routes) != 2 {
+		t.Error("Expected all routes with a single pipe, got:", routes)
+	}
+}
+
+func TestRoutesFilteredUnknownStrategy(t *testing.T) {
+	RoutesConf.FilteredStrategy = "unknown"
+	defer func() { RoutesConf.FilteredStrategy = "" }()
+
+	res, _ := RoutesFiltered(false, "R1")
+	if _, ok := res["error"]; !ok {
+		t.Error("Expected an error for an unknown strategy, got:", res)
+	}
+}
+
+func TestRoutesFilteredAutoStrategy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filtered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sample, err := ioutil.ReadFile("../test/protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, FixtureName("protocols all")), sample, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MockDir = dir
+	BirdVersion = 1
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() {
+		MockDir = ""
+		BirdVersion = 0
+		cache = formerCache
+	}()
+
+	// The peer table of R194_42 has a pipe
+	strategy, _, _ := routesFilteredAuto(false, "R194_42")
+	if strategy != FilteredStrategyPipeDiff {
+		t.Error("Expected pipe-diff with BIRD 1 and pipes, got:", strategy)
+	}
+
+	strategy, _, _ = routesFilteredAuto(false, "M65001_nada_co_ripe")
+	if strategy != FilteredStrategyDirect {
+		t.Error("Expected direct without pipes, got:", strategy)
+	}
+}
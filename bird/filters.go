@@ -0,0 +1,95 @@
+package bird
+
+import (
+	"sort"
+)
+
+// Policy introspection: the filters defined in the config
+// and the protocols using them as import or export filter.
+
+func SymbolsFilter(useCache bool) (Parsed, bool) {
+	return RunAndParse(useCache, GetCacheKey("SymbolsFilter"), "symbols filter", parseSymbols, nil)
+}
+
+func Filters(useCache bool) (Parsed, bool) {
+	symbols, from_cache := SymbolsFilter(useCache)
+	if IsSpecial(symbols) {
+		return symbols, from_cache
+	}
+
+	protocols, _ := Protocols(useCache)
+	if IsSpecial(protocols) {
+		return protocols, from_cache
+	}
+
+	filters := symbolNames(symbols, "filter")
+	allProtocols, _ := protocols["protocols"].(Parsed)
+
+	return Parsed{
+		"filters":   filtersOverview(filters, allProtocols),
+		"protocols": protocolFilters(allProtocols),
+		"ttl":       symbols["ttl"],
+		"cached_at": symbols["cached_at"],
+	}, from_cache
+}
+
+// Referenced filters which are not defined by name, like
+// ACCEPT, REJECT or (unnamed) inline filters, are listed
+// with defined set to false.
+func filtersOverview(filters []string, protocols Parsed) Parsed {
+	res := Parsed{}
+	filter := func(name string) Parsed {
+		f, ok := res[name].(Parsed)
+		if !ok {
+			f = Parsed{
+				"defined": false,
+				"import":  []string{},
+				"export":  []string{},
+			}
+			res[name] = f
+		}
+		return f
+	}
+
+	for _, name := range filters {
+		filter(name)["defined"] = true
+	}
+
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+		if input, ok := protocol["input_filter"].(string); ok {
+			f := filter(input)
+			f["import"] = append(f["import"].([]string), name)
+		}
+		if output, ok := protocol["output_filter"].(string); ok {
+			f := filter(output)
+			f["export"] = append(f["export"].([]string), name)
+		}
+	}
+
+	for _, f := range res {
+		sort.Strings(f.(Parsed)["import"].([]string))
+		sort.Strings(f.(Parsed)["export"].([]string))
+	}
+
+	return res
+}
+
+func protocolFilters(protocols Parsed) Parsed {
+	res := Parsed{}
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+		res[name] = Parsed{
+			"bird_protocol": protocol["bird_protocol"],
+			"import_filter": protocol["input_filter"],
+			"export_filter": protocol["output_filter"],
+		}
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+package bird
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFiltersOverview(t *testing.T) {
+	f, err := openFile("protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	protocols := parseProtocols(f)["protocols"].(Parsed)
+
+	symbols := parseSymbols(strings.NewReader(
+		"in_nada_co_ripe \tfilter\nin_unused \tfilter\n"))
+	filters := symbolNames(symbols, "filter")
+
+	overview := filtersOverview(filters, protocols)
+
+	nada := overview["in_nada_co_ripe"].(Parsed)
+	if nada["defined"] != true {
+		t.Error("Expected in_nada_co_ripe to be defined")
+	}
+	if !reflect.DeepEqual(nada["import"], []string{"M65001_nada_co_ripe"}) {
+		t.Error("Unexpected importing protocols:", nada["import"])
+	}
+
+	unused := overview["in_unused"].(Parsed)
+	if len(unused["import"].([]string)) != 0 || len(unused["export"].([]string)) != 0 {
+		t.Error("Expected in_unused not to be referenced:", unused)
+	}
+
+	reject := overview["REJECT"].(Parsed)
+	if reject["defined"] != false {
+		t.Error("Expected REJECT not to be a defined filter")
+	}
+	if !reflect.DeepEqual(reject["export"], []string{"C65003_nada2_co_ripe"}) {
+		t.Error("Unexpected exporting protocols:", reject["export"])
+	}
+
+	peers := protocolFilters(protocols)
+	peer := peers["R194_42"].(Parsed)
+	if peer["import_filter"] != "(unnamed)" {
+		t.Error("Unexpected import filter:", peer["import_filter"])
+	}
+}
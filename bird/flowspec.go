@@ -0,0 +1,129 @@
+package bird
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Flowspec (flow4 / flow6 tables)
+//
+// The network of a flowspec route contains the match
+// components, e.g.:
+//
+//    flow4 { dst 10.0.0.0/8; proto 17; dport 53; }
+//
+// The actions are encoded in extended communities.
+
+var flowNetwork = regexp.MustCompile(`^flow[46]\s*\{(.*)\}$`)
+var flowNumbers = regexp.MustCompile(`^=?\s*\d+(?:\s*,\s*=?\s*\d+)*$`)
+
+// Components with more than one word in their name
+var flowComponents = []string{
+	"icmp type",
+	"icmp code",
+	"tcp flags",
+	"next header",
+}
+
+func isFlowNetwork(network string) bool {
+	return flowNetwork.MatchString(network)
+}
+
+// Parse the match components of a flowspec network
+func parseFlowComponents(network string) Parsed {
+	res := Parsed{}
+
+	groups := flowNetwork.FindStringSubmatch(network)
+	if groups == nil {
+		return res
+	}
+
+	for _, component := range strings.Split(groups[1], ";") {
+		component = strings.TrimSpace(component)
+		if component == "" {
+			continue
+		}
+
+		key := ""
+		for _, name := range flowComponents {
+			if strings.HasPrefix(component, name+" ") {
+				key = name
+				break
+			}
+		}
+		if key == "" {
+			key = strings.SplitN(component, " ", 2)[0]
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(component, key))
+		key = strings.Replace(key, " ", "_", -1)
+
+		switch {
+		case key == "dst" || key == "src":
+			res[key] = value
+		case flowNumbers.MatchString(value):
+			// Plain values, e.g. `dport 80, 443`
+			values := []int64{}
+			for _, v := range strings.Split(value, ",") {
+				values = append(values, parseInt(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(v), "="))))
+			}
+			res[key] = values
+		default:
+			// Keep operator expressions, e.g. `>= 1024 && <= 2048`
+			res[key] = value
+		}
+	}
+
+	return res
+}
+
+func parseHex(value string) (uint64, bool) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 64)
+	return v, err == nil
+}
+
+// Decode the flowspec actions (RFC 5575) from the generic
+// extended communities of a route.
+func flowActions(bgp Parsed) Parsed {
+	res := Parsed{}
+
+	communities, ok := bgp["ext_communities"].([]interface{})
+	if !ok {
+		return res
+	}
+
+	for _, c := range communities {
+		community, ok := c.([]interface{})
+		if !ok || len(community) != 3 || community[0] != "generic" {
+			continue
+		}
+		hiStr, _ := community[1].(string)
+		loStr, _ := community[2].(string)
+		hi, ok1 := parseHex(hiStr)
+		lo, ok2 := parseHex(loStr)
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		switch hi >> 16 {
+		case 0x8006: // traffic-rate, 0 discards the traffic
+			rate := math.Float32frombits(uint32(lo))
+			res["traffic_rate"] = float64(rate)
+			if rate == 0 {
+				res["discard"] = true
+			}
+		case 0x8007: // traffic-action
+			res["terminal"] = lo&0x1 == 0
+			res["sample"] = lo&0x2 != 0
+		case 0x8008: // redirect to VRF
+			res["redirect"] = fmt.Sprintf("%d:%d", hi&0xffff, lo)
+		case 0x8009: // traffic-marking
+			res["dscp"] = int64(lo & 0x3f)
+		}
+	}
+
+	return res
+}
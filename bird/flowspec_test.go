@@ -0,0 +1,51 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoutesFlowspec(t *testing.T) {
+	f, err := openFile("routes_bird2_flow4.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	routes := parseRoutes(f)["routes"].([]Parsed)
+	if len(routes) != 2 {
+		t.Fatalf("Expected 2 routes but got %v", len(routes))
+	}
+
+	flow := routes[0]["flow"].(Parsed)
+	if flow["dst"] != "192.0.2.0/24" {
+		t.Error("Unexpected dst:", flow["dst"])
+	}
+	if !reflect.DeepEqual(flow["proto"], []int64{17}) {
+		t.Error("Unexpected proto:", flow["proto"])
+	}
+	if !reflect.DeepEqual(flow["dport"], []int64{53, 123}) {
+		t.Error("Unexpected dport:", flow["dport"])
+	}
+	if flow["sport"] != ">= 1024 && <= 2048" {
+		t.Error("Unexpected sport:", flow["sport"])
+	}
+
+	actions := routes[0]["flow_actions"].(Parsed)
+	if actions["discard"] != true {
+		t.Error("Expected discard action, got:", actions)
+	}
+
+	flow = routes[1]["flow"].(Parsed)
+	if flow["src"] != "203.0.113.0/24" || flow["tcp_flags"] != "0x2/0x2" {
+		t.Error("Unexpected flow:", flow)
+	}
+
+	actions = routes[1]["flow_actions"].(Parsed)
+	if actions["redirect"] != "65000:100" {
+		t.Error("Unexpected redirect:", actions["redirect"])
+	}
+	if actions["dscp"] != int64(46) {
+		t.Error("Unexpected dscp:", actions["dscp"])
+	}
+}
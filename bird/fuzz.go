@@ -0,0 +1,34 @@
+//go:build gofuzz
+// +build gofuzz
+
+package bird
+
+import (
+	"bytes"
+)
+
+// Fuzz targets for go-fuzz:
+//
+//   go-fuzz-build -func FuzzRoutes github.com/alice-lg/birdwatcher/bird
+//   go-fuzz -workdir test/fuzz/routes
+//
+// Seed the corpus with the samples in test/.
+
+// FuzzRoutes feeds arbitrary input to the route parser.
+func FuzzRoutes(data []byte) int {
+	res := parseRoutes(bytes.NewReader(data))
+	if routes, ok := res["routes"].([]Parsed); ok && len(routes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// FuzzProtocols feeds arbitrary input to the protocol parsers.
+func FuzzProtocols(data []byte) int {
+	parseProtocolsShort(bytes.NewReader(data))
+	res := parseProtocols(bytes.NewReader(data))
+	if protocols, ok := res["protocols"].(Parsed); ok && len(protocols) > 0 {
+		return 1
+	}
+	return 0
+}
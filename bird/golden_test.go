@@ -0,0 +1,174 @@
+package bird
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in test/golden")
+
+// goldenParsers maps sample file prefixes to the parser
+// used for the sample. More specific prefixes come first.
+var goldenParsers = []struct {
+	prefix string
+	parse  func(io.Reader) Parsed
+}{
+	{"babel_entries", parseBabelEntries},
+	{"babel_neighbors", parseBabelNeighbors},
+	{"protocols_short", parseProtocolsShort},
+	{"protocols_", parseProtocols},
+	{"routes_", parseRoutes},
+	{"status_", parseStatus},
+}
+
+func goldenParser(sample string) func(io.Reader) Parsed {
+	for _, p := range goldenParsers {
+		if strings.HasPrefix(sample, p.prefix) {
+			return p.parse
+		}
+	}
+	return nil
+}
+
+// normalizeGolden round trips the result through JSON and drops
+// values derived from the current time, so the golden files
+// stay stable.
+func normalizeGolden(res Parsed) ([]byte, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	stripTimestamps(v)
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func stripTimestamps(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if strings.HasSuffix(k, "_timestamp") {
+				delete(t, k)
+				continue
+			}
+			stripTimestamps(val)
+		}
+	case []interface{}:
+		for _, val := range t {
+			stripTimestamps(val)
+		}
+	}
+}
+
+func TestParserGolden(t *testing.T) {
+	samples, err := filepath.Glob("../test/*.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("no samples found")
+	}
+
+	for _, sample := range samples {
+		name := strings.TrimSuffix(filepath.Base(sample), ".sample")
+		parse := goldenParser(name)
+		if parse == nil {
+			t.Error("No parser for sample:", name)
+			continue
+		}
+
+		f, err := os.Open(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := normalizeGolden(parse(f))
+		f.Close()
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+
+		golden := filepath.Join("../test/golden", name+".json")
+		if *updateGolden {
+			if err := ioutil.WriteFile(golden, append(got, '\n'), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Error("Missing golden file for", name, "- run with -update:", err)
+			continue
+		}
+		if !bytes.Equal(bytes.TrimSpace(expected), got) {
+			t.Error("Parser output for", name, "differs from", golden)
+		}
+	}
+}
+
+// TestParserMalformedLines feeds truncated and mangled
+// versions of every sample line to the parsers. The output
+// is not checked, the parsers just must not panic.
+func TestParserMalformedLines(t *testing.T) {
+	samples, err := filepath.Glob("../test/*.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sample := range samples {
+		name := strings.TrimSuffix(filepath.Base(sample), ".sample")
+		parse := goldenParser(name)
+		if parse == nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(string(data), "\n")
+		for i, line := range lines {
+			for _, mangled := range mangleLine(line) {
+				input := make([]string, len(lines))
+				copy(input, lines)
+				input[i] = mangled
+
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("%s line %d: parser panicked on %q: %v",
+								name, i+1, mangled, r)
+						}
+					}()
+					parse(strings.NewReader(strings.Join(input, "\n")))
+				}()
+			}
+		}
+	}
+}
+
+func mangleLine(line string) []string {
+	res := []string{
+		"",
+		strings.TrimSpace(line),
+		line[:len(line)/2],
+		strings.Replace(line, " ", "", -1),
+		strings.Replace(line, "(", "", -1),
+		strings.Replace(line, ":", "", -1),
+	}
+	if idx := strings.LastIndex(line, " "); idx > 0 {
+		res = append(res, line[:idx])
+	}
+	return res
+}
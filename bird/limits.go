@@ -0,0 +1,227 @@
+package bird
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/metrics"
+)
+
+// Limits of queries
+//
+// A query whose birdc output exceeds max_parse_bytes is not
+// parsed, birdc is killed once the limit is reached. A query running longer than max_wall_time is aborted,
+// either by killing birdc or by stopping the parser.
+//
+// Independent of the query, at most max_output_bytes are read
+// from birdc. When exceeded, birdc is killed and the truncated
+// output is parsed, flagged with output_truncated.
+
+var LimitsConf LimitsConfig
+
+// Special Parsed values for queries exceeding a limit
+var ParseSizeExceeded = Parsed{
+	"error": "parse size limit exceeded",
+	"limit": "max_parse_bytes",
+}
+var WallTimeExceeded = Parsed{
+	"error": "wall time limit exceeded",
+	"limit": "max_wall_time",
+}
+
+// ErrOutputTruncated is returned with the output of birdc
+// cut at max_output_bytes.
+var ErrOutputTruncated = errors.New("birdc output exceeds max_output_bytes")
+
+// ErrParseSizeExceeded is returned without output when the
+// output of birdc exceeds max_parse_bytes.
+var ErrParseSizeExceeded = errors.New("birdc output exceeds max_parse_bytes")
+
+var errParserFailed = errors.New("parser job failed")
+
+var (
+	outputTruncatedTotal = metrics.NewCounter(
+		"birdwatcher_output_truncated_total",
+		"Queries with the birdc output truncated at max_output_bytes")
+	parseSizeExceededTotal = metrics.NewCounter(
+		"birdwatcher_parse_size_exceeded_total",
+		"Queries aborted for exceeding max_parse_bytes")
+	wallTimeExceededTotal = metrics.NewCounter(
+		"birdwatcher_wall_time_exceeded_total",
+		"Queries aborted for exceeding max_wall_time")
+)
+
+// ParsedBytesField holds the size of the birdc output
+// a fresh result was parsed from, for the accounting of
+// the query cost. It is not part of the response.
+const ParsedBytesField = "parsed_bytes"
+
+// ParsedBytes returns the size of the parsed birdc output.
+// Results exceeding max_parse_bytes are charged the limit.
+func ParsedBytes(ret Parsed) (int, bool) {
+	if reflect.DeepEqual(ret, ParseSizeExceeded) {
+		return LimitsConf.MaxParseBytes, true
+	}
+	n, ok := ret[ParsedBytesField].(int)
+	return n, ok
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	r     io.Reader
+	count int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count += n
+	return n, err
+}
+
+// IsLimitExceeded tests for the special values of exceeded limits
+func IsLimitExceeded(ret Parsed) bool {
+	return reflect.DeepEqual(ret, ParseSizeExceeded) ||
+		reflect.DeepEqual(ret, WallTimeExceeded)
+}
+
+// queryLimits returns the limits of the longest matching
+// query prefix, falling back to the default limits.
+func queryLimits(conf LimitsConfig, cmd string) QueryLimits {
+	limits := QueryLimits{
+		MaxParseBytes: conf.MaxParseBytes,
+		MaxWallTime:   conf.MaxWallTime,
+	}
+
+	match := ""
+	for prefix, l := range conf.Queries {
+		if len(prefix) <= len(match) {
+			continue
+		}
+		if cmd == prefix || strings.HasPrefix(cmd, prefix+" ") {
+			match = prefix
+			limits = l
+		}
+	}
+	return limits
+}
+
+// A deadlineReader fails once the deadline has passed,
+// which ends the parsing of the output.
+type deadlineReader struct {
+	r        io.Reader
+	deadline time.Time
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if time.Now().After(d.deadline) {
+		return 0, context.DeadlineExceeded
+	}
+	return d.r.Read(p)
+}
+
+// runLimited runs the query and parses the output within the limits
+func runLimited(cmd string, limits QueryLimits, parser func(io.Reader) Parsed) (Parsed, error) {
+	ctx := context.Background()
+	if limits.MaxWallTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx,
+			time.Duration(limits.MaxWallTime)*time.Second)
+		defer cancel()
+	}
+
+	out, err := runContextLimited(ctx, cmd, limits.MaxParseBytes)
+	if ctx.Err() != nil {
+		wallTimeExceededTotal.Inc()
+		return WallTimeExceeded, nil
+	}
+	if err == ErrParseSizeExceeded {
+		parseSizeExceededTotal.Inc()
+		return ParseSizeExceeded, nil
+	}
+	truncated := err == ErrOutputTruncated
+	if err != nil && !truncated {
+		return BirdError, err
+	}
+
+	// The output of the mock is not limited while reading
+	if limits.MaxParseBytes > 0 {
+		if sized, ok := out.(interface{ Len() int }); ok && sized.Len() > limits.MaxParseBytes {
+			parseSizeExceededTotal.Inc()
+			return ParseSizeExceeded, nil
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		out = &deadlineReader{r: out, deadline: deadline}
+	}
+	counter := &countingReader{r: out}
+	parsed := parser(counter)
+	if ctx.Err() != nil {
+		wallTimeExceededTotal.Inc()
+		return WallTimeExceeded, nil
+	}
+	if reflect.DeepEqual(parsed, ParserError) {
+		// The partial result must not be cached
+		return ParserError, errParserFailed
+	}
+	if truncated && parsed != nil {
+		log.Println("Output of", cmd, "truncated at max_output_bytes")
+		parsed["output_truncated"] = true
+	}
+	if parsed != nil {
+		parsed[ParsedBytesField] = counter.count
+	}
+	return parsed, nil
+}
+
+// readBirdc reads at most max bytes of the output, birdc is
+// killed if there is more. The output is truncated after the
+// last complete line. If the output exceeds maxParse bytes,
+// birdc is killed and no output is returned.
+func readBirdc(cmd *exec.Cmd, max, maxParse int) ([]byte, error) {
+	if max <= 0 && maxParse <= 0 {
+		return cmd.Output()
+	}
+
+	limit := max
+	if maxParse > 0 && (limit <= 0 || maxParse < limit) {
+		limit = maxParse
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	out, err := ioutil.ReadAll(io.LimitReader(stdout, int64(limit)+1))
+	if err != nil || len(out) > limit {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if err != nil {
+			return nil, err
+		}
+		if maxParse > 0 && len(out) > maxParse {
+			return nil, ErrParseSizeExceeded
+		}
+
+		outputTruncatedTotal.Inc()
+		out = out[:max]
+		if i := bytes.LastIndexByte(out, '\n'); i >= 0 {
+			out = out[:i+1]
+		}
+		return out, ErrOutputTruncated
+	}
+
+	return out, cmd.Wait()
+}
@@ -0,0 +1,129 @@
+package bird
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryLimits(t *testing.T) {
+	conf := LimitsConfig{
+		MaxParseBytes: 100,
+		Queries: map[string]QueryLimits{
+			"route":     {MaxParseBytes: 200},
+			"route all": {MaxParseBytes: 300, MaxWallTime: 5},
+		},
+	}
+
+	cases := map[string]QueryLimits{
+		"protocols all":          {MaxParseBytes: 100},
+		"route table master":     {MaxParseBytes: 200},
+		"route all table master": {MaxParseBytes: 300, MaxWallTime: 5},
+		"routes":                 {MaxParseBytes: 100},
+	}
+	for cmd, expected := range cases {
+		if limits := queryLimits(conf, cmd); limits != expected {
+			t.Error(cmd, "- expected:", expected, "got:", limits)
+		}
+	}
+}
+
+func TestRunLimitedParseSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, FixtureName("protocols all")),
+		[]byte("BIRD 1.6.3 ready.\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MockDir = dir
+	defer func() { MockDir = "" }()
+
+	parser := func(r io.Reader) Parsed {
+		t.Error("Parser should not be called")
+		return Parsed{}
+	}
+	parsed, err := runLimited("protocols all", QueryLimits{MaxParseBytes: 10}, parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsLimitExceeded(parsed) || parsed["limit"] != "max_parse_bytes" {
+		t.Error("Expected parse size to be exceeded, got:", parsed)
+	}
+}
+
+func TestDeadlineReader(t *testing.T) {
+	r := &deadlineReader{
+		r:        strings.NewReader("BIRD 1.6.3 ready."),
+		deadline: time.Now().Add(-time.Second),
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("Expected the read to fail after the deadline")
+	}
+}
+
+func TestReadBirdcTruncated(t *testing.T) {
+	out, err := readBirdc(exec.Command("yes", "1007-BIRD"), 95, 0)
+	if err != ErrOutputTruncated {
+		t.Fatal("Expected the output to be truncated, got:", err)
+	}
+	if len(out) != 90 || !strings.HasSuffix(string(out), "BIRD\n") {
+		t.Error("Expected the output truncated after the last line, got:", string(out))
+	}
+
+	out, err = readBirdc(exec.Command("echo", "0001 BIRD ready."), 100, 0)
+	if err != nil || string(out) != "0001 BIRD ready.\n" {
+		t.Error("Unexpected output:", string(out), err)
+	}
+}
+
+func TestReadBirdcParseSize(t *testing.T) {
+	out, err := readBirdc(exec.Command("yes", "1007-BIRD"), 0, 100)
+	if err != ErrParseSizeExceeded || out != nil {
+		t.Error("Expected the parse size to be exceeded, got:", len(out), err)
+	}
+
+	// The smaller output limit truncates the output
+	_, err = readBirdc(exec.Command("yes", "1007-BIRD"), 50, 100)
+	if err != ErrOutputTruncated {
+		t.Error("Expected the output to be truncated, got:", err)
+	}
+
+	out, err = readBirdc(exec.Command("echo", "0001 BIRD ready."), 0, 100)
+	if err != nil || string(out) != "0001 BIRD ready.\n" {
+		t.Error("Unexpected output:", string(out), err)
+	}
+}
+
+func TestRunLimitedParserError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, FixtureName("protocols all")),
+		[]byte("BIRD 1.6.3 ready.\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MockDir = dir
+	defer func() { MockDir = "" }()
+
+	parser := func(r io.Reader) Parsed {
+		return ParserError
+	}
+	parsed, err := runLimited("protocols all", QueryLimits{}, parser)
+	if err == nil || !IsSpecial(parsed) {
+		t.Error("Expected the parser failure, got:", parsed, err)
+	}
+}
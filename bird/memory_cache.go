@@ -44,22 +44,34 @@ func (c *MemoryCache) Set(key string, val Parsed, ttl int) error {
 	case ttl == 0:
 		return nil // do not cache
 	case ttl > 0:
-		cachedAt := time.Now().UTC()
-		cacheTtl := cachedAt.Add(time.Duration(ttl) * time.Minute)
-
-		c.Lock()
-		// This is not a really ... clean way of doing this.
-		val["ttl"] = cacheTtl
-		val["cached_at"] = cachedAt
-
-		c.m[key] = val
-		c.Unlock()
+		c.SetTTL(key, val, time.Duration(ttl)*time.Minute)
 		return nil
 	default: // ttl negative - invalid
 		return errors.New("Negative TTL value for key" + key)
 	}
 }
 
+// SetTTL stores the value with a TTL of arbitrary precision
+func (c *MemoryCache) SetTTL(key string, val Parsed, ttl time.Duration) {
+	cachedAt := time.Now().UTC()
+	cacheTtl := cachedAt.Add(ttl)
+
+	c.Lock()
+	// This is not a really ... clean way of doing this.
+	val["ttl"] = cacheTtl
+	val["cached_at"] = cachedAt
+
+	c.m[key] = val
+	c.Unlock()
+}
+
+// Len returns the number of cached entries
+func (c *MemoryCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.m)
+}
+
 func (c *MemoryCache) Expire() int {
 	c.Lock()
 
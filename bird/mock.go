@@ -0,0 +1,91 @@
+package bird
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Serve recorded birdc output from a fixtures directory
+// instead of running birdc. Every command is read from
+// a file named after the command, e.g. `show protocols all`
+// is read from `protocols_all.txt`.
+//
+// In record mode, the output of every birdc command is
+// written to a timestamped file in the record directory, e.g.
+// `20190102T150405.000000000_protocols_all.txt`. Using this
+// directory as fixtures directory replays the latest recording
+// of each command.
+
+var MockDir string
+var RecordDir string
+
+const recordTimestampFormat = "20060102T150405.000000000"
+
+var fixtureNameSeparator = regexp.MustCompile(`[^A-Za-z0-9.:-]+`)
+
+// FixtureName gets the name of the fixture file for a command
+func FixtureName(args string) string {
+	name := fixtureNameSeparator.ReplaceAllString(args, "_")
+	name = strings.Trim(name, "_")
+	// Colons are not allowed in filenames on every platform
+	name = strings.Replace(name, ":", "-", -1)
+	return name + ".txt"
+}
+
+// Find the latest recording of a command
+func latestRecording(dir string, name string) (string, bool) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+
+	latest := ""
+	for _, file := range files {
+		parts := strings.SplitN(file.Name(), "_", 2)
+		if len(parts) != 2 || parts[1] != name {
+			continue
+		}
+		// Timestamps sort lexicographically
+		if file.Name() > latest {
+			latest = file.Name()
+		}
+	}
+
+	if latest == "" {
+		return "", false
+	}
+	return filepath.Join(dir, latest), true
+}
+
+func runMock(args string) (io.Reader, error) {
+	name := FixtureName(args)
+	filename := filepath.Join(MockDir, name)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		recording, ok := latestRecording(MockDir, name)
+		if !ok {
+			return nil, fmt.Errorf("no fixture for `show %s`: %s", args, filename)
+		}
+		filename = recording
+	}
+
+	out, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(out), nil
+}
+
+// Write the output of a birdc command to the record directory
+func recordRun(args string, out []byte, now time.Time) error {
+	filename := filepath.Join(
+		RecordDir,
+		now.UTC().Format(recordTimestampFormat)+"_"+FixtureName(args))
+	return ioutil.WriteFile(filename, out, 0644)
+}
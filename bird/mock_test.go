@@ -0,0 +1,85 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFixtureName(t *testing.T) {
+	name := FixtureName("route all protocol R192_1 where net.type = NET_IP4")
+	if name != "route_all_protocol_R192_1_where_net.type_NET_IP4.txt" {
+		t.Error("Unexpected fixture name:", name)
+	}
+
+	name = FixtureName("route for 2001:db8::/32 all")
+	if name != "route_for_2001-db8--_32_all.txt" {
+		t.Error("Unexpected fixture name:", name)
+	}
+}
+
+func TestRunMock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sample, err := ioutil.ReadFile("../test/protocols_short.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "protocols.txt"), sample, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MockDir = dir
+	defer func() { MockDir = "" }()
+
+	out, err := Run("protocols")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parseProtocolsShort(out)["protocols"].(Parsed)) == 0 {
+		t.Error("Expected protocols from fixture")
+	}
+
+	if _, err := Run("route all"); err == nil {
+		t.Error("Expected an error for a missing fixture")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-recordings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	RecordDir = dir
+	MockDir = dir
+	defer func() {
+		RecordDir = ""
+		MockDir = ""
+	}()
+
+	now := time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := recordRun("protocols", []byte("first"), now); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordRun("protocols", []byte("second"), now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Run("protocols")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, _ := ioutil.ReadAll(out)
+	if string(replayed) != "second" {
+		t.Error("Expected the latest recording, got:", string(replayed))
+	}
+}
@@ -0,0 +1,77 @@
+package bird
+
+import (
+	"sort"
+	"time"
+)
+
+// Statistics of all BGP neighbors in one response,
+// e.g. for a single dashboard refresh.
+
+func NeighborsStats(useCache bool) (Parsed, bool) {
+	res, from_cache := ProtocolsBgp(useCache)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	protocols, _ := res["protocols"].(Parsed)
+	return Parsed{
+		"neighbors": neighborsStats(protocols, time.Now()),
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}, from_cache
+}
+
+// The uptime of sessions which are not up is 0
+func protocolUptime(protocol Parsed, now time.Time) int64 {
+	if protocol["state"] != "up" {
+		return 0
+	}
+	changed, _ := protocol["state_changed"].(string)
+	ts, ok := birdTimestamp(changed, now)
+	if !ok {
+		return 0
+	}
+	since, err := time.Parse(time.RFC3339, ts)
+	if err != nil || since.After(now) {
+		return 0
+	}
+	return int64(now.Sub(since) / time.Second)
+}
+
+func neighborsStats(protocols Parsed, now time.Time) []Parsed {
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []Parsed{}
+	for _, name := range names {
+		protocol, ok := protocols[name].(Parsed)
+		if !ok {
+			continue
+		}
+
+		routes, _ := protocol["routes"].(Parsed)
+		count := func(key string) int64 {
+			n, _ := routes[key].(int64)
+			return n
+		}
+		lastError, _ := protocol["last_error"].(string)
+
+		res = append(res, Parsed{
+			"protocol":         name,
+			"neighbor_address": protocol["neighbor_address"],
+			"neighbor_as":      protocol["neighbor_as"],
+			"state":            protocol["state"],
+			"imported":         count("imported"),
+			"exported":         count("exported"),
+			"filtered":         count("filtered"),
+			"preferred":        count("preferred"),
+			"uptime_seconds":   protocolUptime(protocol, now),
+			"last_error":       lastError,
+		})
+	}
+	return res
+}
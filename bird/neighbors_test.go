@@ -0,0 +1,34 @@
+package bird
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeighborsStats(t *testing.T) {
+	f, err := openFile("protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ParserConf.BirdTimezone = "UTC"
+	defer func() { ParserConf = ParserConfig{} }()
+
+	protocols := parseProtocols(f)["protocols"].(Parsed)
+	bgp := Parsed{"R194_42": protocols["R194_42"]}
+
+	now := time.Date(2018, 5, 31, 16, 38, 40, 0, time.UTC)
+	stats := neighborsStats(bgp, now)
+	if len(stats) != 1 {
+		t.Fatal("Expected 1 neighbor, got:", len(stats))
+	}
+
+	neighbor := stats[0]
+	if neighbor["imported"] != int64(710) || neighbor["preferred"] != int64(376688) {
+		t.Error("Unexpected route counts:", neighbor)
+	}
+	if neighbor["uptime_seconds"] != int64(3600) {
+		t.Error("Expected an uptime of 3600s, got:", neighbor["uptime_seconds"])
+	}
+}
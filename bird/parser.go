@@ -7,11 +7,9 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
-// WorkerPoolSize is the number of go routines used to parse routing tables concurrently
-var WorkerPoolSize = 8
-
 var (
 	ParserConf ParserConfig
 	regex      struct {
@@ -28,6 +26,8 @@ var (
 			numericValue *regexp.Regexp
 			routes       *regexp.Regexp
 			stringValue  *regexp.Regexp
+			capabilities *regexp.Regexp
+			role         *regexp.Regexp
 			routeChanges *regexp.Regexp
 			short        *regexp.Regexp
 		}
@@ -38,17 +38,18 @@ var (
 			countRx *regexp.Regexp
 		}
 		routes struct {
-			startDefinition        *regexp.Regexp
-			second                 *regexp.Regexp
-			routeType              *regexp.Regexp
-			bgp                    *regexp.Regexp
-			community              *regexp.Regexp
-			largeCommunity         *regexp.Regexp
-			extendedCommunity      *regexp.Regexp
-			origin                 *regexp.Regexp
-			prefixBird2            *regexp.Regexp
-			unreachablePrefixBird2 *regexp.Regexp
-			gatewayBird2           *regexp.Regexp
+			startDefinition    *regexp.Regexp
+			second             *regexp.Regexp
+			routeType          *regexp.Regexp
+			bgp                *regexp.Regexp
+			community          *regexp.Regexp
+			largeCommunity     *regexp.Regexp
+			extendedCommunity  *regexp.Regexp
+			origin             *regexp.Regexp
+			prefixBird2        *regexp.Regexp
+			gatewayBird2       *regexp.Regexp
+			routeDistinguisher *regexp.Regexp
+			mplsLabel          *regexp.Regexp
 		}
 	}
 )
@@ -72,6 +73,8 @@ func init() {
 	regex.protocol.numericValue = regexp.MustCompile(`^\s+([^:]+):\s+([\d]+)\s*$`)
 	regex.protocol.routes = regexp.MustCompile(`^\s+Routes:\s+(.*)`)
 	regex.protocol.stringValue = regexp.MustCompile(`^\s+([^:]+):\s+(.+)\s*$`)
+	regex.protocol.capabilities = regexp.MustCompile(`^\s+(Local|Neighbor) capabilities\s*$`)
+	regex.protocol.role = regexp.MustCompile(`^\s+(Local )?[Rr]ole:\s+(\S+)\s*$`)
 	regex.protocol.routeChanges = regexp.MustCompile(`(Import|Export) (updates|withdraws):\s+(\d+|---)\s+(\d+|---)\s+(\d+|---)\s+(\d+|---)\s+(\d+|---)\s*$`)
 
 	regex.routes.startDefinition = regexp.MustCompile(`^([0-9a-f\.\:\/]+)\s+via\s+([0-9a-f\.\:]+)\s+on\s+([\w\.]+)\s+\[([\w\.:]+)\s+([0-9\-\:\s]+)(?:\s+from\s+([0-9a-f\.\:\/]+)){0,1}\]\s+(?:(\*)\s+){0,1}\((\d+)(?:\/\d+){0,1}\).*`)
@@ -83,9 +86,10 @@ func init() {
 	regex.routes.largeCommunity = regexp.MustCompile(`^\((\d+),\s*(\d+),\s*(\d+)\)`)
 	regex.routes.extendedCommunity = regexp.MustCompile(`^\(([^,]+),\s*([^,]+),\s*([^,]+)\)`)
 	regex.routes.origin = regexp.MustCompile(`\([^\(]*\)\s*`)
-	regex.routes.prefixBird2 = regexp.MustCompile(`^([0-9a-f\.\:\/]+)?\s+unicast\s+\[([\w\.:]+)\s+([0-9\-\:\s]+)(?:\s+from\s+([0-9a-f\.\:\/]+))?\]\s+(?:(\*)\s+)?\((\d+)(?:\/\d+)?(?:\/[^\)]*)?\).*$`)
-	regex.routes.unreachablePrefixBird2 = regexp.MustCompile(`^([0-9a-f\.\:\/]+)?\s+unreachable\s+\[([\w\.:]+)\s+([0-9\-\:\s]+)(?:\s+from\s+([0-9a-f\.\:\/]+))?\]\s+(?:(\*)\s+)?\((\d+)(?:\/\d+)?(?:\/[^\)]*)?\).*$`)
-	regex.routes.gatewayBird2 = regexp.MustCompile(`^\s+via\s+([0-9a-f\.\:]+)\s+on\s+([\w\.]+)\s*$`)
+	regex.routes.prefixBird2 = regexp.MustCompile(`^(flow[46]\s*\{[^\}]*\}|(?:[0-9\.]+:\d+\s+)?[0-9a-f\.\:\/]+)?\s+(unicast|blackhole|unreachable|prohibited)\s+\[([\w\.:]+)\s+([0-9\-\:\s]+)(?:\s+from\s+([0-9a-f\.\:\/]+))?\]\s+(?:(\*)\s+)?\((\d+)(?:\/\d+)?(?:\/[^\)]*)?\).*$`)
+	regex.routes.gatewayBird2 = regexp.MustCompile(`^\s+via\s+([0-9a-f\.\:]+)\s+on\s+([\w\.]+)(?:\s+mpls\s+([\d\/]+))?\s*$`)
+	regex.routes.routeDistinguisher = regexp.MustCompile(`^([0-9\.]+:\d+)\s+(.+)$`)
+	regex.routes.mplsLabel = regexp.MustCompile(`^\d+$`)
 }
 
 func dirtyContains(l []string, e string) bool {
@@ -166,27 +170,46 @@ func parseProtocolsShort(reader io.Reader) Parsed {
 	return Parsed{"protocols": res}
 }
 
+// The protocol blocks are parsed by the worker pool
+// while reading, as route servers have thousands of them.
 func parseProtocols(reader io.Reader) Parsed {
 	res := Parsed{}
+	mu := &sync.Mutex{}
+	jobs := &jobGroup{}
+
+	submit := func(block string) {
+		jobs.Submit(func() {
+			parsed := parseProtocol(block)
+			name, ok := parsed["protocol"].(string)
+			if !ok {
+				return
+			}
+			mu.Lock()
+			res[name] = parsed
+			mu.Unlock()
+		})
+	}
 
-	proto := ""
+	proto := &strings.Builder{}
 
 	lines := newLineIterator(reader, false)
 	for lines.next() {
 		line := lines.string()
 
 		if emptyString(line) {
-			if !emptyString(proto) {
-				parsed := parseProtocol(proto)
-
-				res[parsed["protocol"].(string)] = parsed
+			if !emptyString(proto.String()) {
+				submit(proto.String())
 			}
-			proto = ""
+			proto = &strings.Builder{}
 		} else {
-			proto += (line + "\n")
+			proto.WriteString(line)
+			proto.WriteString("\n")
 		}
 	}
 
+	if err := jobs.Wait(); err != nil {
+		return ParserError
+	}
 	return Parsed{"protocols": res}
 }
 
@@ -227,7 +250,8 @@ type blockParsed struct {
 
 func parseRoutes(reader io.Reader) Parsed {
 	jobs := make(chan blockJob)
-	out := startRouteWorkers(jobs)
+	group := &jobGroup{}
+	out := startRouteWorkers(jobs, group)
 
 	res := startRouteConsumer(out)
 	defer close(res)
@@ -254,19 +278,24 @@ func parseRoutes(reader io.Reader) Parsed {
 
 	close(jobs)
 
-	return <-res
+	routes := <-res
+	if err := group.Wait(); err != nil {
+		return ParserError
+	}
+	return routes
 }
 
-func startRouteWorkers(jobs chan blockJob) chan blockParsed {
+func startRouteWorkers(jobs chan blockJob, group *jobGroup) chan blockParsed {
 	out := make(chan blockParsed)
 
-	wg := &sync.WaitGroup{}
-	wg.Add(WorkerPoolSize)
 	go func() {
-		for i := 0; i < WorkerPoolSize; i++ {
-			go workerForRouteBlockParsing(jobs, out, wg)
+		for j := range jobs {
+			j := j
+			group.Submit(func() {
+				parseRouteLines(j.lines, j.position, out)
+			})
 		}
-		wg.Wait()
+		group.Wait()
 		close(out)
 	}()
 
@@ -280,10 +309,16 @@ func startRouteConsumer(out <-chan blockParsed) chan Parsed {
 		byBlock := map[int][]Parsed{}
 		count := 0
 		for r := range out {
-			count++
+			// Blocks are missing when a parser job failed, the
+			// result is discarded then
+			if r.position >= count {
+				count = r.position + 1
+			}
 			byBlock[r.position] = r.items
 		}
-		res <- Parsed{"routes": sortedSliceForRouteBlocks(byBlock, count)}
+		routes := sortedSliceForRouteBlocks(byBlock, count)
+		assignPathIds(routes)
+		res <- Parsed{"routes": routes}
 	}()
 
 	return res
@@ -304,13 +339,6 @@ func sortedSliceForRouteBlocks(byBlock map[int][]Parsed, numBlocks int) []Parsed
 	return res
 }
 
-func workerForRouteBlockParsing(jobs <-chan blockJob, out chan<- blockParsed, wg *sync.WaitGroup) {
-	for j := range jobs {
-		parseRouteLines(j.lines, j.position, out)
-	}
-	wg.Done()
-}
-
 func parseRouteLines(lines []string, position int, ch chan<- blockParsed) {
 	route := Parsed{}
 	routes := []Parsed{}
@@ -323,26 +351,16 @@ func parseRouteLines(lines []string, position int, ch chan<- blockParsed) {
 			continue
 		}
 
-		if regex.routes.unreachablePrefixBird2.MatchString(line) {
-			formerPrefix := ""
+		if regex.routes.prefixBird2.MatchString(line) {
+			former := Parsed{}
 			if len(route) > 0 {
 				routes = append(routes, route)
 
-				formerPrefix = route["network"].(string)
+				former = route
 				route = Parsed{}
 			}
 
-			parseMainRouteDetailBird2(regex.routes.unreachablePrefixBird2.FindStringSubmatch(line), route, formerPrefix)
-		} else if regex.routes.prefixBird2.MatchString(line) {
-			formerPrefix := ""
-			if len(route) > 0 {
-				routes = append(routes, route)
-
-				formerPrefix = route["network"].(string)
-				route = Parsed{}
-			}
-
-			parseMainRouteDetailBird2(regex.routes.prefixBird2.FindStringSubmatch(line), route, formerPrefix)
+			parseMainRouteDetailBird2(regex.routes.prefixBird2.FindStringSubmatch(line), route, former)
 		} else if regex.routes.startDefinition.MatchString(line) {
 			if len(route) > 0 {
 				routes = append(routes, route)
@@ -391,6 +409,10 @@ func parseRouteLines(lines []string, position int, ch chan<- blockParsed) {
 
 			parseRoutesBgp(line, bgp)
 			route["bgp"] = bgp
+
+			if _, ok := route["flow"]; ok {
+				route["flow_actions"] = flowActions(bgp)
+			}
 		}
 
 		i++
@@ -404,7 +426,8 @@ func parseRouteLines(lines []string, position int, ch chan<- blockParsed) {
 }
 
 func parseMainRouteDetail(groups []string, route Parsed) {
-	route["network"] = groups[1]
+	setNetwork(route, groups[1])
+	route["route_type"] = "unicast"
 	route["gateway"] = groups[2]
 	route["interface"] = groups[3]
 	route["from_protocol"] = groups[4]
@@ -412,6 +435,7 @@ func parseMainRouteDetail(groups []string, route Parsed) {
 	route["learnt_from"] = groups[6]
 	route["primary"] = groups[7] == "*"
 	route["metric"] = parseInt(groups[8])
+	parseRouteSelection(route)
 
 	for k := range route {
 		if dirtyContains(ParserConf.FilterFields, k) {
@@ -420,18 +444,30 @@ func parseMainRouteDetail(groups []string, route Parsed) {
 	}
 }
 
-func parseMainRouteDetailBird2(groups []string, route Parsed, formerPrefix string) {
+func parseMainRouteDetailBird2(groups []string, route Parsed, former Parsed) {
 	if len(groups[1]) > 0 {
-		route["network"] = groups[1]
+		parseRouteNetworkBird2(groups[1], route)
 	} else {
-		route["network"] = formerPrefix
+		// Continuation of the former network
+		for _, key := range []string{"network", "rd", "mpls_label", "flow"} {
+			if value, ok := former[key]; ok {
+				route[key] = value
+			}
+		}
+		if _, ok := route["network"]; !ok {
+			route["network"] = ""
+		}
 	}
 
-	route["from_protocol"] = groups[2]
-	route["age"] = groups[3]
-	route["learnt_from"] = groups[4]
-	route["primary"] = groups[5] == "*"
-	route["metric"] = parseInt(groups[6])
+	// The route type is one of unicast, blackhole,
+	// unreachable or prohibited
+	route["route_type"] = groups[2]
+	route["from_protocol"] = groups[3]
+	route["age"] = groups[4]
+	route["learnt_from"] = groups[5]
+	route["primary"] = groups[6] == "*"
+	route["metric"] = parseInt(groups[7])
+	parseRouteSelection(route)
 
 	for k := range route {
 		if dirtyContains(ParserConf.FilterFields, k) {
@@ -440,9 +476,51 @@ func parseMainRouteDetailBird2(groups []string, route Parsed, formerPrefix strin
 	}
 }
 
+// Expose the BIRD route preference, the protocol instance
+// the route was learned from and whether the route is the
+// selected (best) route under explicit names.
+func parseRouteSelection(route Parsed) {
+	route["preference"] = route["metric"]
+	route["source_protocol"] = route["from_protocol"]
+	route["selected"] = route["primary"]
+	setTimestamp(route, "age", time.Now())
+}
+
+// Networks of vpn4 and vpn6 tables are prefixed with the route
+// distinguisher, e.g. `65000:1 10.0.0.0/24`. The networks of
+// mpls tables are MPLS labels, the networks of flow4 and
+// flow6 tables contain the flowspec components.
+func parseRouteNetworkBird2(network string, route Parsed) {
+	if isFlowNetwork(network) {
+		route["flow"] = parseFlowComponents(network)
+	} else if groups := regex.routes.routeDistinguisher.FindStringSubmatch(network); groups != nil {
+		route["rd"] = groups[1]
+		setNetwork(route, groups[2])
+		return
+	} else if regex.routes.mplsLabel.MatchString(network) {
+		route["mpls_label"] = parseInt(network)
+	} else {
+		setNetwork(route, network)
+		return
+	}
+	route["network"] = network
+}
+
 func parseRoutesGatewayBird2(groups []string, route Parsed) {
 	route["gateway"] = groups[1]
 	route["interface"] = groups[2]
+	if groups[3] != "" {
+		route["mpls_labels"] = parseMplsLabels(groups[3])
+	}
+}
+
+// Parse a label stack, e.g. `100/200`
+func parseMplsLabels(stack string) []int64 {
+	labels := []int64{}
+	for _, label := range strings.Split(stack, "/") {
+		labels = append(labels, parseInt(label))
+	}
+	return labels
 }
 
 func parseRoutesSecond(line string, route Parsed) Parsed {
@@ -471,12 +549,19 @@ func parseRoutesBgp(line string, bgp Parsed) {
 
 	if groups[1] == "community" {
 		parseRoutesCommunities(groups, bgp)
+		labelCommunities(bgp)
 	} else if groups[1] == "large_community" {
 		parseRoutesLargeCommunities(groups, bgp)
+		labelCommunities(bgp)
 	} else if groups[1] == "ext_community" {
 		parseRoutesExtendedCommunities(groups, bgp)
+		labelCommunities(bgp)
 	} else if groups[1] == "as_path" {
 		bgp["as_path"] = strings.Split(groups[2], " ")
+		setAsPathFields(bgp)
+	} else if groups[1] == "otc" {
+		// Only to Customer (RFC 9234)
+		bgp["otc"] = parseInt(groups[2])
 	} else {
 		bgp[groups[1]] = groups[2]
 	}
@@ -554,11 +639,14 @@ func isCorrectChannel(currentIPVersion string) bool {
 func parseProtocol(lines string) Parsed {
 	res := Parsed{}
 	routeChanges := Parsed{}
+	roles := Parsed{}
+	capabilities := ""
 
 	handlers := []func(string) bool{
 		func(l string) bool { return parseProtocolHeader(l, res) },
 		func(l string) bool { return parseProtocolRouteLine(l, res) },
 		func(l string) bool { return parseProtocolRouteChanges(l, routeChanges) },
+		func(l string) bool { return parseProtocolRole(l, &capabilities, roles) },
 		func(l string) bool { return parseProtocolNumberValuesRx(l, res) },
 		func(l string) bool { return parseProtocolStringValuesRx(l, res) },
 	}
@@ -581,6 +669,18 @@ func parseProtocol(lines string) Parsed {
 
 	res["route_changes"] = routeChanges
 
+	if res["bird_protocol"] == "BGP" {
+		res["security"] = parseProtocolSecurity(res)
+		if len(roles) > 0 {
+			res["roles"] = protocolRoles(roles)
+		}
+		neighborAs, _ := res["neighbor_as"].(int64)
+		localAs, _ := res["local_as"].(int64)
+		if IsAsTrans(neighborAs) || IsAsTrans(localAs) {
+			res["as_trans"] = true
+		}
+	}
+
 	if _, ok := res["routes"]; !ok {
 		routes := Parsed{}
 		routes["accepted"] = int64(0)
@@ -614,9 +714,10 @@ func parseProtocolHeader(line string, res Parsed) bool {
 	res["table"] = groups[3]
 	res["state"] = groups[4]
 	res["state_changed"] = groups[5]
+	setTimestamp(res, "state_changed", time.Now())
 	res["connection"] = groups[6] // TODO eliminate
 	if groups[2] == "Pipe" {
-		res["peer_table"] = groups[6][3:]
+		res["peer_table"] = strings.TrimSpace(strings.TrimPrefix(groups[6], "=>"))
 	}
 	return true
 }
@@ -679,6 +780,82 @@ func parseProtocolStringValuesRx(line string, res Parsed) bool {
 	return true
 }
 
+// Parse the BGP roles (RFC 9234) of the local and the neighbor
+// capabilities, the role of the capabilities section applies.
+func parseProtocolRole(line string, section *string, roles Parsed) bool {
+	if groups := regex.protocol.capabilities.FindStringSubmatch(line); groups != nil {
+		*section = strings.ToLower(groups[1])
+		return true
+	}
+
+	groups := regex.protocol.role.FindStringSubmatch(line)
+	if groups == nil {
+		return false
+	}
+	switch {
+	case groups[1] != "":
+		roles["local"] = groups[2]
+	case *section != "":
+		roles[*section] = groups[2]
+	default:
+		return false
+	}
+	return true
+}
+
+// Roles of the neighbor matching the local role
+var bgpRolePairs = map[string]string{
+	"provider":  "customer",
+	"customer":  "provider",
+	"rs_server": "rs_client",
+	"rs_client": "rs_server",
+	"peer":      "peer",
+}
+
+// protocolRoles checks if the roles of the session match,
+// a mismatch is a role mismatch error in RFC 9234.
+func protocolRoles(roles Parsed) Parsed {
+	local, _ := roles["local"].(string)
+	neighbor, _ := roles["neighbor"].(string)
+	if local != "" && neighbor != "" {
+		roles["valid"] = bgpRolePairs[local] == neighbor
+	}
+	return roles
+}
+
+// Derive the session security settings (MD5 / TCP-AO authentication
+// and TTL security) from the BGP protocol details.
+// The password itself is never exposed.
+func parseProtocolSecurity(res Parsed) Parsed {
+	security := Parsed{}
+
+	// Not every version of bird reports the authentication
+	// method and the TTL security of the session
+	authentication := "unknown"
+	if method, ok := res["authentication"].(string); ok {
+		authentication = strings.ToLower(method)
+	} else if _, ok := res["password"]; ok {
+		authentication = "md5"
+	}
+	delete(res, "password")
+	security["authentication"] = authentication
+
+	if value, ok := res["ttl_security"].(string); ok {
+		switch strings.ToLower(value) {
+		case "on", "yes", "enabled":
+			security["ttl_security"] = true
+		default:
+			security["ttl_security"] = false
+		}
+	}
+
+	if session, ok := res["session"].(string); ok {
+		security["multihop"] = dirtyContains(strings.Fields(session), "multihop")
+	}
+
+	return security
+}
+
 // Will snake_case a value like that:
 // I am a Weird stRiNg -> i_am_a_weird_string
 func treatKey(key string) string {
@@ -704,6 +881,9 @@ func parseProtocolRoutes(input string) Parsed {
 	for _, token := range tokens {
 		token = strings.TrimSpace(token)
 		content := strings.Split(token, " ")
+		if len(content) < 2 {
+			continue
+		}
 		routes[content[1]] = parseInt(content[0])
 	}
 
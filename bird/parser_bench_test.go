@@ -0,0 +1,63 @@
+package bird
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// Benchmarks replaying captured birdc output through the parsers.
+// Run with: go test -run=NONE -bench=. ./bird/
+
+func readSample(b *testing.B, filename string, repeat int) []byte {
+	f, err := openFile(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	sample, err := ioutil.ReadAll(f)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return bytes.Repeat(sample, repeat)
+}
+
+func benchmarkParser(b *testing.B, parser func(io.Reader) Parsed, filename string, repeat int) {
+	input := readSample(b, filename, repeat)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		parser(bytes.NewReader(input))
+	}
+}
+
+func BenchmarkParseRoutesBird1IPv4(b *testing.B) {
+	benchmarkParser(b, parseRoutes, "routes_bird1_ipv4.sample", 1)
+}
+
+func BenchmarkParseRoutesBird2IPv4(b *testing.B) {
+	benchmarkParser(b, parseRoutes, "routes_bird2_ipv4.sample", 1)
+}
+
+func BenchmarkParseRoutesBird2IPv6(b *testing.B) {
+	benchmarkParser(b, parseRoutes, "routes_bird2_ipv6.sample", 1)
+}
+
+// A large table, simulating the dump of a route server
+func BenchmarkParseRoutesLargeTable(b *testing.B) {
+	benchmarkParser(b, parseRoutes, "routes_bird2_ipv4.sample", 1000)
+}
+
+func BenchmarkParseProtocols(b *testing.B) {
+	benchmarkParser(b, parseProtocols, "protocols_bgp_pipe.sample", 1)
+}
+
+func BenchmarkParseProtocolsShort(b *testing.B) {
+	benchmarkParser(b, parseProtocolsShort, "protocols_short.sample", 1)
+}
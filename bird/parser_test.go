@@ -63,6 +63,104 @@ func TestParseProtocolBgp(t *testing.T) {
 	fmt.Println(protocols)
 }
 
+func TestParseProtocolBgpSecurity(t *testing.T) {
+	f, err := openFile("protocols_bgp_security.sample")
+	if err != nil {
+		t.Error(err)
+	}
+	defer f.Close()
+
+	p := parseProtocols(f)
+	protocols := p["protocols"].(Parsed)
+
+	expected := map[string]Parsed{
+		"R192_1": Parsed{
+			"authentication": "unknown",
+			"multihop":       false,
+		},
+		"R192_2": Parsed{
+			"authentication": "unknown",
+			"multihop":       true,
+		},
+		// The session is not reported while not established
+		"R192_3": Parsed{
+			"authentication": "unknown",
+		},
+	}
+
+	for name, security := range expected {
+		protocol, ok := protocols[name].(Parsed)
+		if !ok {
+			t.Fatal("Protocol not found:", name)
+		}
+
+		if !reflect.DeepEqual(protocol["security"], security) {
+			t.Error(name, ": Expected security to be:", security, "not", protocol["security"])
+		}
+	}
+}
+
+func TestProtocolSecurityReported(t *testing.T) {
+	res := Parsed{
+		"authentication": "TCP-AO",
+		"ttl_security":   "on",
+		"session":        "external AS4",
+	}
+	expected := Parsed{
+		"authentication": "tcp-ao",
+		"ttl_security":   true,
+		"multihop":       false,
+	}
+	if security := parseProtocolSecurity(res); !reflect.DeepEqual(security, expected) {
+		t.Error("Expected security to be:", expected, "not", security)
+	}
+
+	security := parseProtocolSecurity(Parsed{"password": "secret"})
+	if security["authentication"] != "md5" {
+		t.Error("Expected md5 authentication with a password, got:", security)
+	}
+}
+
+func TestParseProtocolBgpRoles(t *testing.T) {
+	f, err := openFile("protocols_bgp_roles.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := parseProtocols(f)
+	protocols := p["protocols"].(Parsed)
+
+	expected := map[string]interface{}{
+		"R192_10": Parsed{"local": "provider", "neighbor": "customer", "valid": true},
+		"R192_11": Parsed{"local": "peer", "neighbor": "customer", "valid": false},
+		"R192_12": nil,
+	}
+	for name, roles := range expected {
+		protocol, ok := protocols[name].(Parsed)
+		if !ok {
+			t.Fatal("Protocol not found:", name)
+		}
+		if roles == nil {
+			if _, ok := protocol["roles"]; ok {
+				t.Error(name, ": Expected no roles, got:", protocol["roles"])
+			}
+			continue
+		}
+		if !reflect.DeepEqual(protocol["roles"], roles) {
+			t.Error(name, ": Expected roles to be:", roles, "not", protocol["roles"])
+		}
+	}
+}
+
+func TestParseRoutesOtc(t *testing.T) {
+	bgp := Parsed{}
+	parseRoutesBgp("\tBGP.otc: 64510", bgp)
+	if bgp["otc"] != int64(64510) {
+		t.Error("Expected the OTC attribute as ASN, got:", bgp["otc"])
+	}
+}
+
 func TestParseProtocolShort(t *testing.T) {
 	f, err := openFile("protocols_short.sample")
 	if err != nil {
@@ -90,6 +188,82 @@ func TestParseRoutesAllIpv4Bird2(t *testing.T) {
 	runTestForIpv4WithFile("routes_bird2_ipv4.sample", 5, t)
 }
 
+func TestParseRoutesTypesBird2(t *testing.T) {
+	f, err := openFile("routes_bird2_types.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	routes := parseRoutes(f)["routes"].([]Parsed)
+	if len(routes) != 4 {
+		t.Fatalf("Expected 4 routes but got %v", len(routes))
+	}
+
+	expected := []struct {
+		network   string
+		routeType string
+	}{
+		{"192.0.2.0/24", "unicast"},
+		{"198.51.100.1/32", "blackhole"},
+		{"203.0.113.0/24", "unreachable"},
+		{"198.51.100.0/24", "prohibited"},
+	}
+	for i, e := range expected {
+		if routes[i]["network"] != e.network {
+			t.Error("Expected network", e.network, "not", routes[i]["network"])
+		}
+		if routes[i]["route_type"] != e.routeType {
+			t.Error(e.network, ": Expected route_type", e.routeType, "not", routes[i]["route_type"])
+		}
+	}
+
+	// The blackhole route keeps its BGP attributes
+	bgp := routes[1]["bgp"].(Parsed)
+	if communities := bgp["communities"].([][]int64); len(communities) != 1 || communities[0][1] != 666 {
+		t.Error("Expected blackhole community, got:", bgp["communities"])
+	}
+	if _, ok := routes[1]["gateway"]; ok {
+		t.Error("Blackhole route should not have a gateway")
+	}
+}
+
+func TestParseRoutesVpnBird2(t *testing.T) {
+	f, err := openFile("routes_bird2_vpn4.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	routes := parseRoutes(f)["routes"].([]Parsed)
+	if len(routes) != 3 {
+		t.Fatalf("Expected 3 routes but got %v", len(routes))
+	}
+
+	expected := []struct {
+		network string
+		rd      string
+		labels  []int64
+		gateway string
+	}{
+		{"10.0.0.0/24", "65000:1", []int64{100, 200}, "192.0.2.1"},
+		{"10.0.0.0/24", "65000:1", []int64{300}, "192.0.2.2"},
+		{"10.1.0.0/24", "192.0.2.1:7", []int64{101}, "192.0.2.1"},
+	}
+	for i, e := range expected {
+		route := routes[i]
+		if route["network"] != e.network || route["rd"] != e.rd {
+			t.Error("Expected", e.rd, e.network, "not", route["rd"], route["network"])
+		}
+		if !reflect.DeepEqual(route["mpls_labels"], e.labels) {
+			t.Error("Expected labels", e.labels, "not", route["mpls_labels"])
+		}
+		if route["gateway"] != e.gateway {
+			t.Error("Expected gateway", e.gateway, "not", route["gateway"])
+		}
+	}
+}
+
 func runTestForIpv4WithFile(file string, numRoutes int, t *testing.T) {
 	f, err := openFile(file)
 	if err != nil {
@@ -406,6 +580,18 @@ func assertRouteIsEqual(expected expectedRoute, actual Parsed, name string, t *t
 		t.Fatal(name, ": Expected protocol to be:", expected.protocol, "not", protocol)
 	}
 
+	if preference := value(actual, "preference", name, t).(int64); preference != expected.metric {
+		t.Fatal(name, ": Expected preference to be:", expected.metric, "not", preference)
+	}
+
+	if source := value(actual, "source_protocol", name, t).(string); source != expected.protocol {
+		t.Fatal(name, ": Expected source_protocol to be:", expected.protocol, "not", source)
+	}
+
+	if selected := value(actual, "selected", name, t).(bool); selected != expected.primary {
+		t.Fatal(name, ": Expected selected to be:", expected.primary, "not", selected)
+	}
+
 	if iface := value(actual, "interface", name, t).(string); iface != expected.iface {
 		t.Fatal(name, ": Expected interface to be:", expected.iface, "not", iface)
 	}
@@ -0,0 +1,173 @@
+package bird
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Persistence of the memory cache across restarts
+//
+// With persist_file, the cached results are written to the file
+// on shutdown and loaded on startup, so a restart is not followed
+// by the full table queries of all clients. The entries keep
+// their TTL and the time they were cached at: expired entries
+// and entries older than persist_max_age are not loaded.
+
+type persistedEntry struct {
+	Cache string // "results" or "counts"
+	Key   string
+	Value []byte
+}
+
+// memoryCacheOf returns the memory cache of the backend,
+// the redis cache is persisted by redis.
+func memoryCacheOf(c Cache) *MemoryCache {
+	switch backend := c.(type) {
+	case *MemoryCache:
+		return backend
+	case *SpillCache:
+		return backend.MemoryCache
+	}
+	return nil
+}
+
+func (c *MemoryCache) entries(now time.Time) map[string]Parsed {
+	c.RLock()
+	defer c.RUnlock()
+	res := make(map[string]Parsed, len(c.m))
+	for key, val := range c.m {
+		if ttl, ok := val["ttl"].(time.Time); ok && ttl.After(now) {
+			res[key] = val
+		}
+	}
+	return res
+}
+
+// SaveCache writes the unexpired entries of the result and
+// count caches to the file and returns their number. Entries
+// which can not be encoded are skipped.
+func SaveCache(filename string) (int, error) {
+	now := time.Now()
+	caches := map[string]*MemoryCache{
+		"results": memoryCacheOf(cache),
+		"counts":  countCache,
+	}
+
+	// Encode into a temporary file replacing the former one,
+	// so a failed save keeps the former file
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".cache")
+	if err != nil {
+		return 0, err
+	}
+	fail := func(err error) (int, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+
+	out := bufio.NewWriter(tmp)
+	enc := gob.NewEncoder(out)
+	count := 0
+	for name, c := range caches {
+		if c == nil {
+			continue
+		}
+		for key, val := range c.entries(now) {
+			// Entries which can not be encoded are skipped,
+			// so each value is encoded on its own
+			value := &bytes.Buffer{}
+			if err := gob.NewEncoder(value).Encode(val); err != nil {
+				continue
+			}
+			entry := persistedEntry{Cache: name, Key: key, Value: value.Bytes()}
+			if err := enc.Encode(entry); err != nil {
+				return fail(err)
+			}
+			count++
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return count, nil
+}
+
+// removeSpillOrphans removes the spill files which are not
+// referenced by the restored entries
+func removeSpillOrphans() {
+	if spill, ok := cache.(*SpillCache); ok {
+		spill.RemoveOrphans()
+	}
+}
+
+// LoadCache restores the entries from the file and returns
+// their number. A missing file is not an error.
+func LoadCache(filename string, maxAge time.Duration) (int, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		removeSpillOrphans()
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	caches := map[string]*MemoryCache{
+		"results": memoryCacheOf(cache),
+		"counts":  countCache,
+	}
+
+	now := time.Now()
+	dec := gob.NewDecoder(f)
+	count := 0
+	for {
+		entry := persistedEntry{}
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				removeSpillOrphans()
+				return count, nil
+			}
+			return count, err
+		}
+
+		c := caches[entry.Cache]
+		if c == nil {
+			continue
+		}
+		val := Parsed{}
+		if err := gob.NewDecoder(bytes.NewReader(entry.Value)).Decode(&val); err != nil {
+			continue
+		}
+		ttl, ok := val["ttl"].(time.Time)
+		if !ok || ttl.Before(now) {
+			continue
+		}
+		cachedAt, ok := val["cached_at"].(time.Time)
+		if !ok || (maxAge > 0 && now.Sub(cachedAt) > maxAge) {
+			continue
+		}
+
+		c.Lock()
+		if _, ok := c.m[entry.Key]; !ok {
+			c.m[entry.Key] = val
+			count++
+		}
+		c.Unlock()
+	}
+}
@@ -0,0 +1,78 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher_persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cache.gob")
+
+	formerCache, formerCountCache := cache, countCache
+	defer func() { cache, countCache = formerCache, formerCountCache }()
+	memory, _ := NewMemoryCache()
+	cache = memory
+	countCache, _ = NewMemoryCache()
+
+	memory.SetTTL("routes", Parsed{"routes": []Parsed{{"network": "192.0.2.0/24"}}}, time.Hour)
+	memory.SetTTL("expired", Parsed{"routes": []Parsed{}}, -time.Second)
+	countCache.SetTTL("count", Parsed{"routes": int64(42)}, time.Hour)
+
+	count, err := SaveCache(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Error("Expected the unexpired entries to be saved, got:", count)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || files[0] != filename {
+		t.Error("Expected only the cache file, got:", files)
+	}
+
+	memory, _ = NewMemoryCache()
+	cache = memory
+	countCache, _ = NewMemoryCache()
+	count, err = LoadCache(filename, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Error("Expected two restored entries, got:", count)
+	}
+
+	res, err := memory.Get("routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, _ := res["routes"].([]Parsed)
+	if len(routes) != 1 || routes[0]["network"] != "192.0.2.0/24" {
+		t.Error("Unexpected restored routes:", res)
+	}
+	if _, ok := res["cached_at"].(time.Time); !ok {
+		t.Error("Expected the age of the entry to be kept")
+	}
+	if res, err := countCache.Get("count"); err != nil || res["routes"] != int64(42) {
+		t.Error("Unexpected restored count:", res, err)
+	}
+
+	// Entries older than the max age are dropped
+	memory, _ = NewMemoryCache()
+	cache = memory
+	time.Sleep(10 * time.Millisecond)
+	if count, _ := LoadCache(filename, time.Millisecond); count != 0 {
+		t.Error("Expected old entries to be dropped, got:", count)
+	}
+
+	if count, err := LoadCache(filepath.Join(dir, "missing"), 0); count != 0 || err != nil {
+		t.Error("Expected a missing file to be ignored:", err)
+	}
+}
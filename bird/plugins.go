@@ -0,0 +1,127 @@
+package bird
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Parser plugins
+//
+// Parsers for `show` commands not supported by birdwatcher
+// (e.g. babel, rip or the output of custom filters) can be
+// registered from a custom main package:
+//
+//    bird.RegisterParser("babel_neighbors", parseBabelNeighbors)
+//
+// and mapped to endpoints in the [plugins] section of the config.
+
+type ParserFunc func(io.Reader) Parsed
+
+var parserRegistry = struct {
+	sync.RWMutex
+	parsers map[string]ParserFunc
+}{
+	parsers: map[string]ParserFunc{},
+}
+
+var PluginsConf map[string]PluginConfig
+
+func init() {
+	RegisterParser("status", parseStatus)
+	RegisterParser("protocols", parseProtocols)
+	RegisterParser("protocols_short", parseProtocolsShort)
+	RegisterParser("symbols", parseSymbols)
+	RegisterParser("routes", parseRoutes)
+	RegisterParser("routes_count", parseRoutesCount)
+	RegisterParser("lines", parseLines)
+	RegisterParser("raw", parseRaw)
+	RegisterParser("babel_neighbors", parseBabelNeighbors)
+	RegisterParser("babel_entries", parseBabelEntries)
+}
+
+// RegisterParser makes a parser available for plugin endpoints
+func RegisterParser(name string, parser ParserFunc) error {
+	if name == "" || parser == nil {
+		return fmt.Errorf("Parser name and function are required")
+	}
+
+	parserRegistry.Lock()
+	defer parserRegistry.Unlock()
+
+	if _, ok := parserRegistry.parsers[name]; ok {
+		return fmt.Errorf("Parser already registered: %s", name)
+	}
+	parserRegistry.parsers[name] = parser
+	return nil
+}
+
+// LookupParser gets a registered parser by name
+func LookupParser(name string) (ParserFunc, bool) {
+	parserRegistry.RLock()
+	defer parserRegistry.RUnlock()
+
+	parser, ok := parserRegistry.parsers[name]
+	return parser, ok
+}
+
+// Split the output into lines, without the birdc status lines
+func parseLines(reader io.Reader) Parsed {
+	res := []string{}
+
+	lines := newLineIterator(reader, false)
+	for lines.next() {
+		line := lines.string()
+		if specialLine(line) {
+			continue
+		}
+		res = append(res, line)
+	}
+
+	return Parsed{"lines": res}
+}
+
+// Return the output as is, without the birdc status lines
+func parseRaw(reader io.Reader) Parsed {
+	lines := parseLines(reader)["lines"].([]string)
+	return Parsed{"output": strings.Join(lines, "\n")}
+}
+
+// Get the birdc command of a plugin, without the leading `show`
+// as every command is run as `show` command.
+func pluginCommand(command string) string {
+	command = strings.TrimSpace(command)
+	if strings.HasPrefix(command, "show ") {
+		command = strings.TrimSpace(strings.TrimPrefix(command, "show "))
+	}
+	return command
+}
+
+// RunPlugin runs the command of a configured plugin
+// and parses the output with the registered parser.
+func RunPlugin(useCache bool, name string) (Parsed, bool) {
+	conf, ok := PluginsConf[name]
+	if !ok {
+		return Parsed{"error": "unknown plugin: " + name}, false
+	}
+
+	return RunCommand(useCache, conf.Command, conf.Parser)
+}
+
+// RunCommand runs a `show` command and parses the
+// output with a registered parser.
+func RunCommand(useCache bool, command string, parserName string) (Parsed, bool) {
+	parser, ok := LookupParser(parserName)
+	if !ok {
+		return Parsed{"error": "unknown parser: " + parserName}, false
+	}
+
+	cmd := pluginCommand(command)
+	return RunAndParse(
+		useCache,
+		GetCacheKey("RunCommand", cmd, parserName),
+		cmd,
+		parser,
+		nil)
+}
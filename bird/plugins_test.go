@@ -0,0 +1,39 @@
+package bird
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterParser(t *testing.T) {
+	err := RegisterParser("test_custom", parseLines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LookupParser("test_custom"); !ok {
+		t.Error("Expected registered parser")
+	}
+	if err := RegisterParser("test_custom", parseLines); err == nil {
+		t.Error("Expected an error when registering a parser twice")
+	}
+	if err := RegisterParser("", parseLines); err == nil {
+		t.Error("Expected an error for a parser without a name")
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	out := "BIRD 2.0.7 ready.\nbabel1:\nInterface eth0\n\n"
+	lines := parseLines(strings.NewReader(out))["lines"].([]string)
+	if len(lines) != 3 || lines[0] != "babel1:" {
+		t.Error("Unexpected lines:", lines)
+	}
+}
+
+func TestPluginCommand(t *testing.T) {
+	if cmd := pluginCommand(" show babel neighbors"); cmd != "babel neighbors" {
+		t.Error("Unexpected command:", cmd)
+	}
+	if cmd := pluginCommand("babel neighbors"); cmd != "babel neighbors" {
+		t.Error("Unexpected command:", cmd)
+	}
+}
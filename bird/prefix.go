@@ -0,0 +1,72 @@
+package bird
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Prefix canonicalization
+//
+// Networks are emitted in canonical form: IPv6 addresses in
+// lowercase and compressed, host bits stripped and the
+// prefix length always present. The address family of the
+// input is kept: IPv4-mapped IPv6 prefixes like ::ffff:0:0/96
+// stay IPv6, where net.IPNet.String() would print 0.0.0.0/0.
+
+// formatIP prints the address in the family of the input
+func formatIP(ip net.IP, v6 bool) string {
+	if !v6 {
+		return ip.To4().String()
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return "::ffff:" + ip4.String()
+	}
+	return ip.To16().String()
+}
+
+// CanonicalAddress returns the address in canonical form
+func CanonicalAddress(address string) (string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address, fmt.Errorf("invalid address: %s", address)
+	}
+	return formatIP(ip, strings.Contains(address, ":")), nil
+}
+
+// CanonicalPrefix returns the prefix in canonical form. An
+// address is returned as host prefix, e.g. 192.0.2.1/32.
+func CanonicalPrefix(network string) (string, error) {
+	v6 := strings.Contains(network, ":")
+	prefix := network
+	if !strings.Contains(prefix, "/") {
+		if net.ParseIP(prefix) == nil {
+			return network, fmt.Errorf("invalid network: %s", network)
+		}
+		if v6 {
+			prefix += "/128"
+		} else {
+			prefix += "/32"
+		}
+	}
+
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return network, fmt.Errorf("invalid network: %s", network)
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	return formatIP(ipNet.IP, v6) + "/" + strconv.Itoa(ones), nil
+}
+
+// setNetwork sets the normalized network of the route.
+// Malformed networks are kept as they are and the route
+// is flagged with a parse error.
+func setNetwork(route Parsed, network string) {
+	normalized, err := CanonicalPrefix(network)
+	if err != nil {
+		route["parse_error"] = err.Error()
+	}
+	route["network"] = normalized
+}
@@ -0,0 +1,68 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestCanonicalPrefix(t *testing.T) {
+	prefixes := map[string]string{
+		"10.0.0.0/24":       "10.0.0.0/24",
+		"10.0.0.1/24":       "10.0.0.0/24",
+		"192.0.2.1":         "192.0.2.1/32",
+		"2001:DB8:0::/32":   "2001:db8::/32",
+		"2001:db8::1":       "2001:db8::1/128",
+		"2001:db8:1:2::/48": "2001:db8:1::/48",
+	}
+
+	for network, expected := range prefixes {
+		normalized, err := CanonicalPrefix(network)
+		if err != nil {
+			t.Error(err)
+		}
+		if normalized != expected {
+			t.Error("Expected", expected, "for", network, "got:", normalized)
+		}
+	}
+
+	for _, network := range []string{"10.0.0.256/24", "10.0.0.0/33", "foo"} {
+		if _, err := CanonicalPrefix(network); err == nil {
+			t.Error("Expected an error for", network)
+		}
+	}
+}
+
+func TestCanonicalPrefixMapped(t *testing.T) {
+	prefixes := map[string]string{
+		"::ffff:0:0/96":            "::ffff:0.0.0.0/96",
+		"::ffff:10.0.0.0/104":      "::ffff:10.0.0.0/104",
+		"::FFFF:10.1.2.3/104":      "::ffff:10.0.0.0/104",
+		"::ffff:192.0.2.1":         "::ffff:192.0.2.1/128",
+		"0:0:0:0:0:ffff:a00:0/104": "::ffff:10.0.0.0/104",
+		"::/0":                     "::/0",
+	}
+	for network, expected := range prefixes {
+		normalized, err := CanonicalPrefix(network)
+		if err != nil {
+			t.Error(err)
+		}
+		if normalized != expected {
+			t.Error("Expected", expected, "for", network, "got:", normalized)
+		}
+	}
+
+	address, err := CanonicalAddress("::FFFF:192.0.2.1")
+	if err != nil || address != "::ffff:192.0.2.1" {
+		t.Error("Expected the mapped address to stay IPv6, got:", address, err)
+	}
+}
+
+func TestSetNetworkMalformed(t *testing.T) {
+	route := Parsed{}
+	setNetwork(route, "10.0.0.0/33")
+	if route["network"] != "10.0.0.0/33" {
+		t.Error("Expected the malformed network to be kept, got:", route["network"])
+	}
+	if _, ok := route["parse_error"]; !ok {
+		t.Error("Expected a parse error for the malformed network")
+	}
+}
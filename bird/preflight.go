@@ -0,0 +1,183 @@
+package bird
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Preflight report
+//
+// A summary of what the birdwatcher found on startup: the BIRD
+// version, the tables, the protocols, if the BGP protocols use
+// peer tables and which parser modes apply. It is logged once
+// BIRD answers and served by /status/preflight, to diagnose
+// misconfigured deployments.
+
+// Preflight checks BIRD and the configuration
+func Preflight(useCache bool) Parsed {
+	warnings := []string{}
+	report := Parsed{
+		"generated_at": time.Now().UTC(),
+		"ip_version":   IPVersion,
+	}
+
+	status, _ := Status(useCache)
+	if IsSpecial(status) {
+		report["bird"] = Parsed{"reachable": false}
+		// The command line is not part of the served report
+		log.Println("Preflight: BIRD is not reachable with", ClientConf.BirdCmd)
+		report["warnings"] = []string{"BIRD is not reachable"}
+		return report
+	}
+	birdStatus, _ := status["status"].(Parsed)
+	report["bird"] = Parsed{
+		"reachable":     true,
+		"version":       birdStatus["version"],
+		"major_version": MajorVersion(),
+		"router_id":     birdStatus["router_id"],
+	}
+	report["capabilities"] = Capabilities()["capabilities"]
+
+	symbols, _ := Symbols(useCache)
+	tables := []string{}
+	if !IsSpecial(symbols) {
+		tables = symbolNames(symbols, "routing table")
+	}
+	report["tables"] = tables
+	if len(tables) == 0 {
+		warnings = append(warnings, "No routing tables found")
+	}
+
+	protocolsRes, _ := Protocols(useCache)
+	protocols, _ := protocolsRes["protocols"].(Parsed)
+	if IsSpecial(protocolsRes) || protocols == nil {
+		warnings = append(warnings, "Could not retrieve the protocols")
+		protocols = Parsed{}
+	}
+
+	byType := Parsed{}
+	up := 0
+	bgp := Parsed{}
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+		kind, _ := protocol["bird_protocol"].(string)
+		count, _ := byType[kind].(int)
+		byType[kind] = count + 1
+		if protocol["state"] == "up" {
+			up++
+		}
+		if kind == "BGP" {
+			bgp[name] = protocol
+		}
+	}
+	report["protocols"] = Parsed{
+		"total":   len(protocols),
+		"up":      up,
+		"by_type": byType,
+	}
+
+	peerTableReport, peerTablesUsed := preflightPeerTables(bgp, protocols)
+	report["peer_tables"] = peerTableReport
+	if len(bgp) == 0 {
+		warnings = append(warnings, "No BGP protocols found")
+	}
+
+	strategy := filteredStrategy()
+	effective := strategy
+	if strategy == FilteredStrategyAuto {
+		switch {
+		case HasCapability("filtered_keep"):
+			effective = FilteredStrategyBird2
+		case peerTablesUsed:
+			effective = FilteredStrategyPipeDiff
+		default:
+			effective = FilteredStrategyDirect
+		}
+	}
+	if effective == FilteredStrategyPipeDiff && !peerTablesUsed {
+		warnings = append(warnings, "filtered_strategy pipe-diff requires peer tables with pipes")
+	}
+	if effective == FilteredStrategyBird2 && !HasCapability("filtered_keep") {
+		warnings = append(warnings, "filtered_strategy "+FilteredStrategyBird2+" requires BIRD 2")
+	}
+
+	receivedSource := RoutesConf.ReceivedSource
+	if receivedSource == "" {
+		receivedSource = "keep_filtered"
+	}
+	if receivedSource == "peer_table" && !peerTablesUsed {
+		warnings = append(warnings, "received_source peer_table requires peer tables")
+	}
+
+	report["parser"] = Parsed{
+		"filtered_strategy":           strategy,
+		"filtered_strategy_effective": effective,
+		"received_source":             receivedSource,
+		"bird_timezone":               ParserConf.BirdTimezone,
+		"timezone":                    ParserConf.Timezone,
+	}
+
+	sort.Strings(warnings)
+	report["warnings"] = warnings
+	return report
+}
+
+// preflightPeerTables reports the table of each BGP protocol
+// and the pipes exporting its routes to the master table.
+func preflightPeerTables(bgp Parsed, protocols Parsed) (Parsed, bool) {
+	master := remapTable("master")
+	mode := AnnouncersModeMaster
+	withPipes := false
+
+	details := Parsed{}
+	for name, p := range bgp {
+		protocol := p.(Parsed)
+		table, _ := protocol["table"].(string)
+		peerTable := table != "" && table != "master" && table != master
+		res := Parsed{
+			"table":      table,
+			"peer_table": peerTable,
+		}
+		if peerTable {
+			mode = AnnouncersModePeerTables
+			pipes := peerTablePipes(protocols, table)
+			res["pipes"] = pipes
+			if len(pipes) > 0 {
+				withPipes = true
+			}
+		}
+		details[name] = res
+	}
+
+	return Parsed{
+		"mode":      mode,
+		"tables":    peerTables(bgp),
+		"protocols": details,
+	}, withPipes
+}
+
+// LogPreflight logs a summary and the warnings of the report
+func LogPreflight(report Parsed) {
+	birdInfo, _ := report["bird"].(Parsed)
+	protocols, _ := report["protocols"].(Parsed)
+	tables, _ := report["tables"].([]string)
+	peerTables, _ := report["peer_tables"].(Parsed)
+	parser, _ := report["parser"].(Parsed)
+
+	if reachable, _ := birdInfo["reachable"].(bool); reachable {
+		log.Printf("Preflight: BIRD %v, %d tables (%s), %v protocols (%v up), %v mode, filtered strategy %v",
+			birdInfo["version"], len(tables), strings.Join(tables, ", "),
+			protocols["total"], protocols["up"], peerTables["mode"],
+			parser["filtered_strategy_effective"])
+	}
+
+	warnings, _ := report["warnings"].([]string)
+	for _, warning := range warnings {
+		log.Println("Preflight warning:", warning)
+	}
+}
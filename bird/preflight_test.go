@@ -0,0 +1,105 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	status, err := ioutil.ReadFile("../test/status_bird1.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols, err := ioutil.ReadFile("../test/protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures := map[string][]byte{
+		"status":        status,
+		"protocols all": protocols,
+		"symbols":       []byte("master\trouting table\nT65001_nada_co_ripe\trouting table\n"),
+	}
+	for cmd, out := range fixtures {
+		err := ioutil.WriteFile(filepath.Join(dir, FixtureName(cmd)), out, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	MockDir = dir
+	formerCache, formerRoutesConf := cache, RoutesConf
+	cache, _ = NewMemoryCache()
+	RoutesConf.FilteredStrategy = FilteredStrategyPipeDiff
+	RoutesConf.ReceivedSource = "peer_table"
+	defer func() {
+		MockDir = ""
+		cache, RoutesConf = formerCache, formerRoutesConf
+	}()
+
+	report := Preflight(false)
+	birdInfo, _ := report["bird"].(Parsed)
+	if birdInfo["reachable"] != true || birdInfo["version"] == nil {
+		t.Error("Unexpected BIRD info:", birdInfo)
+	}
+	if tables, _ := report["tables"].([]string); len(tables) != 2 {
+		t.Error("Unexpected tables:", report["tables"])
+	}
+
+	peerTables, _ := report["peer_tables"].(Parsed)
+	if peerTables["mode"] != AnnouncersModePeerTables {
+		t.Error("Expected peer tables to be detected, got:", peerTables)
+	}
+	details, _ := peerTables["protocols"].(Parsed)
+	r194, _ := details["R194_42"].(Parsed)
+	if r194["peer_table"] != true {
+		t.Error("Expected the peer table of R194_42, got:", r194)
+	}
+
+	parser, _ := report["parser"].(Parsed)
+	if parser["filtered_strategy_effective"] != FilteredStrategyPipeDiff {
+		t.Error("Unexpected parser modes:", parser)
+	}
+	if warnings, _ := report["warnings"].([]string); len(warnings) != 0 {
+		t.Error("Expected no warnings, got:", warnings)
+	}
+
+	// Without peer tables the strategies can not work
+	os.Remove(filepath.Join(dir, FixtureName("protocols all")))
+	cache, _ = NewMemoryCache()
+	report = Preflight(false)
+	if warnings, _ := report["warnings"].([]string); len(warnings) == 0 {
+		t.Error("Expected warnings without protocols")
+	}
+}
+
+func TestPreflightUnreachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	MockDir = dir
+	formerCache, formerConf := cache, ClientConf
+	cache, _ = NewMemoryCache()
+	ClientConf.BirdCmd = "birdc -s /run/bird/secret.ctl"
+	defer func() {
+		MockDir = ""
+		cache, ClientConf = formerCache, formerConf
+	}()
+
+	report := Preflight(false)
+	warnings, _ := report["warnings"].([]string)
+	if len(warnings) != 1 || strings.Contains(warnings[0], "secret.ctl") {
+		t.Error("Expected a warning without the command, got:", warnings)
+	}
+}
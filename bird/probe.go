@@ -0,0 +1,96 @@
+package bird
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Startup probe of BIRD
+//
+// BIRD might not be up yet when the birdwatcher starts, e.g. at
+// boot. BIRD is probed with backoff until it answers; after
+// the probe period the birdwatcher is degraded, but continues
+// probing in the background.
+
+type StartupConfig struct {
+	// Period of the probe in seconds before the
+	// birdwatcher is degraded
+	ProbePeriod int `toml:"probe_period"`
+}
+
+const (
+	ReadinessStarting = "starting"
+	ReadinessReady    = "ready"
+	ReadinessDegraded = "degraded"
+
+	probeMinBackoff = time.Second
+	probeMaxBackoff = 30 * time.Second
+)
+
+var readiness = struct {
+	sync.RWMutex
+	state string
+	since time.Time
+}{state: ReadinessStarting, since: time.Now().UTC()}
+
+func setReadiness(state string) {
+	readiness.Lock()
+	defer readiness.Unlock()
+	if readiness.state != state {
+		readiness.state = state
+		readiness.since = time.Now().UTC()
+	}
+}
+
+// Readiness returns the readiness state and its start
+func Readiness() (string, time.Time) {
+	readiness.RLock()
+	defer readiness.RUnlock()
+	return readiness.state, readiness.since
+}
+
+// probeBackoff doubles the backoff up to the maximum
+func probeBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > probeMaxBackoff {
+		return probeMaxBackoff
+	}
+	return backoff
+}
+
+// ProbeBird probes BIRD until it answers. The capabilities
+// are detected by the probe.
+func ProbeBird(conf StartupConfig, probe func() bool, sleep func(time.Duration)) {
+	period := time.Duration(conf.ProbePeriod) * time.Second
+	if period <= 0 {
+		period = 5 * time.Minute
+	}
+
+	waited := time.Duration(0)
+	backoff := probeMinBackoff
+	for !probe() {
+		state, _ := Readiness()
+		if state == ReadinessStarting && waited >= period {
+			log.Println("BIRD is not available after", period, "- serving degraded")
+			setReadiness(ReadinessDegraded)
+		}
+		sleep(backoff)
+		waited += backoff
+		backoff = probeBackoff(backoff)
+	}
+
+	if state, _ := Readiness(); state == ReadinessDegraded {
+		log.Println("BIRD is available")
+	}
+	setReadiness(ReadinessReady)
+}
+
+// InstallStartupProbe probes BIRD in the background and
+// logs the preflight report once BIRD answers
+func InstallStartupProbe(conf StartupConfig) {
+	go func() {
+		ProbeBird(conf, DetectCapabilities, time.Sleep)
+		LogPreflight(Preflight(true))
+	}()
+}
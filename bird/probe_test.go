@@ -0,0 +1,69 @@
+package bird
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProbeBird(t *testing.T) {
+	defer setReadiness(ReadinessStarting)
+
+	attempts := 0
+	probe := func() bool {
+		attempts++
+		return attempts == 4
+	}
+
+	sleeps := []time.Duration{}
+	sleep := func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		if state, _ := Readiness(); state != ReadinessStarting {
+			t.Error("Expected starting while probing, got:", state)
+		}
+	}
+
+	ProbeBird(StartupConfig{ProbePeriod: 300}, probe, sleep)
+
+	if state, _ := Readiness(); state != ReadinessReady {
+		t.Error("Expected ready, got:", state)
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if len(sleeps) != len(expected) {
+		t.Fatal("Unexpected backoff:", sleeps)
+	}
+	for i := range expected {
+		if sleeps[i] != expected[i] {
+			t.Error("Unexpected backoff:", sleeps)
+		}
+	}
+}
+
+func TestProbeBirdDegraded(t *testing.T) {
+	defer setReadiness(ReadinessStarting)
+
+	attempts := 0
+	probe := func() bool {
+		attempts++
+		return attempts == 10
+	}
+
+	states := []string{}
+	sleep := func(d time.Duration) {
+		state, _ := Readiness()
+		states = append(states, state)
+	}
+
+	// Probing for 1+2+4 seconds passes the period
+	ProbeBird(StartupConfig{ProbePeriod: 5}, probe, sleep)
+
+	if states[2] != ReadinessStarting || states[3] != ReadinessDegraded {
+		t.Error("Expected to be degraded after the probe period, got:", states)
+	}
+	if state, _ := Readiness(); state != ReadinessReady {
+		t.Error("Expected ready after BIRD answered, got:", state)
+	}
+
+	if probeBackoff(20*time.Second) != probeMaxBackoff {
+		t.Error("Expected the backoff to be limited")
+	}
+}
@@ -0,0 +1,52 @@
+package bird
+
+// Channel statistics of a protocol: the route counters and
+// the route change stats flattened into numeric fields, e.g.
+// import_updates_filtered or export_withdraws_accepted.
+// Counters not available for the protocol (---) are omitted.
+
+func ProtocolStats(useCache bool, protocol string) (Parsed, bool) {
+	protocols, from_cache := Protocols(useCache)
+	if IsSpecial(protocols) {
+		return protocols, from_cache
+	}
+
+	details, ok := protocols["protocols"].(Parsed)[protocol].(Parsed)
+	if !ok {
+		return Parsed{"error": "unknown protocol: " + protocol}, from_cache
+	}
+
+	return Parsed{
+		"protocol":  protocol,
+		"state":     details["state"],
+		"stats":     protocolStats(details),
+		"ttl":       protocols["ttl"],
+		"cached_at": protocols["cached_at"],
+	}, from_cache
+}
+
+func protocolStats(protocol Parsed) Parsed {
+	res := Parsed{}
+
+	routes, _ := protocol["routes"].(Parsed)
+	for key, value := range routes {
+		if n, ok := value.(int64); ok {
+			res["routes_"+key] = n
+		}
+	}
+
+	changes, _ := protocol["route_changes"].(Parsed)
+	for kind, c := range changes {
+		counters, ok := c.(Parsed)
+		if !ok {
+			continue
+		}
+		for key, value := range counters {
+			if n, ok := value.(int64); ok {
+				res[kind+"_"+key] = n
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,36 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestProtocolStats(t *testing.T) {
+	f, err := openFile("protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	protocols := parseProtocols(f)["protocols"].(Parsed)
+	stats := protocolStats(protocols["R194_42"].(Parsed))
+
+	expected := map[string]int64{
+		"routes_imported":         710,
+		"routes_filtered":         0,
+		"routes_exported":         154998,
+		"routes_preferred":        376688,
+		"import_updates_received": 710,
+		"export_updates_rejected": 710,
+		"export_updates_accepted": 171390,
+	}
+	for key, value := range expected {
+		if stats[key] != value {
+			t.Error("Expected", key, "to be", value, "got:", stats[key])
+		}
+	}
+
+	// Not available for import withdraws
+	if _, ok := stats["import_withdraws_filtered"]; ok {
+		t.Error("Expected import_withdraws_filtered to be omitted")
+	}
+}
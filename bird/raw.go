@@ -0,0 +1,74 @@
+package bird
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Raw birdc output for debugging. birdc is run in verbose
+// mode (-v) to get the numeric reply codes of the lines.
+
+var rawReplyCode = regexp.MustCompile(`^(\d{4})[ -](.*)$`)
+
+// Split the verbose birdc output into the reply code
+// of the last line with a code and the output text.
+func parseRawReply(out string) (int64, string) {
+	code := int64(-1)
+	lines := []string{}
+
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		groups := rawReplyCode.FindStringSubmatch(line)
+		if groups == nil {
+			// Continuation lines start with a space
+			lines = append(lines, strings.TrimPrefix(line, " "))
+			continue
+		}
+
+		code = parseInt(groups[1])
+		if groups[2] != "" {
+			lines = append(lines, groups[2])
+		}
+	}
+
+	return code, strings.Join(lines, "\n")
+}
+
+// RunRaw runs a `show` command and returns the raw
+// output together with the reply code.
+func RunRaw(command string) (Parsed, bool) {
+	args := strings.TrimSpace(command)
+	if !strings.HasPrefix(args, "show ") {
+		return Parsed{"error": "only show commands are allowed"}, false
+	}
+	args = strings.TrimSpace(strings.TrimPrefix(args, "show "))
+
+	if !checkRateLimit() {
+		return NilParse, false
+	}
+
+	if MockDir != "" {
+		out, err := runMock(args)
+		if err != nil {
+			return Parsed{"error": fmt.Sprintf("%s", err)}, false
+		}
+		return Parsed{"command": command, "output": parseRaw(out)["output"]}, false
+	}
+
+	out, err := runBirdc(context.Background(), "-v "+"show "+args, 0)
+	if err != nil && err != ErrOutputTruncated {
+		return BirdError, false
+	}
+
+	code, output := parseRawReply(string(out))
+	res := Parsed{
+		"command": command,
+		"code":    code,
+		"output":  output,
+	}
+	if err == ErrOutputTruncated {
+		res["output_truncated"] = true
+	}
+	return res, false
+}
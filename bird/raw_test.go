@@ -0,0 +1,31 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestParseRawReply(t *testing.T) {
+	out := "0001 BIRD 2.0.7 ready.\n" +
+		"2002-Name       Proto      Table      State  Since         Info\n" +
+		"1002-device1    Device     ---        up     2019-01-02\n" +
+		" kernel1    Kernel     master4    up     2019-01-02\n" +
+		"0000 \n"
+
+	code, output := parseRawReply(out)
+	if code != 0 {
+		t.Error("Expected reply code 0, got:", code)
+	}
+
+	expected := "BIRD 2.0.7 ready.\n" +
+		"Name       Proto      Table      State  Since         Info\n" +
+		"device1    Device     ---        up     2019-01-02\n" +
+		"kernel1    Kernel     master4    up     2019-01-02"
+	if output != expected {
+		t.Error("Unexpected output:", output)
+	}
+
+	code, _ = parseRawReply("0001 BIRD 2.0.7 ready.\n8003 No such table\n")
+	if code != 8003 {
+		t.Error("Expected reply code 8003, got:", code)
+	}
+}
@@ -0,0 +1,137 @@
+package bird
+
+import (
+	"sort"
+)
+
+// Peers are tagged with the region and location from the
+// peers config, allowing to slice the protocols by POP.
+
+const untaggedRegion = "untagged"
+
+// The protocol is shared with the cache, so the
+// tags are added to a copy.
+func tagRegion(name string, protocol Parsed) Parsed {
+	peer, ok := PeersConf[name]
+	if !ok {
+		return protocol
+	}
+
+	res := make(Parsed, len(protocol)+2)
+	for k, v := range protocol {
+		res[k] = v
+	}
+	res["region"] = peer.Region
+	res["location"] = peer.Location
+	return res
+}
+
+func protocolRegion(protocol Parsed) string {
+	region, _ := protocol["region"].(string)
+	if region == "" {
+		return untaggedRegion
+	}
+	return region
+}
+
+func filterRegion(protocols Parsed, region string) Parsed {
+	res := Parsed{}
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+		if protocolRegion(protocol) == region {
+			res[name] = protocol
+		}
+	}
+	return res
+}
+
+// ProtocolsBgpRegion returns the BGP protocols of a region
+func ProtocolsBgpRegion(useCache bool, region string) (Parsed, bool) {
+	res, from_cache := ProtocolsBgp(useCache)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	protocols, _ := res["protocols"].(Parsed)
+	return Parsed{
+		"protocols": filterRegion(protocols, region),
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}, from_cache
+}
+
+// ProtocolsBgpRegions summarizes the BGP sessions per region
+func ProtocolsBgpRegions(useCache bool) (Parsed, bool) {
+	res, from_cache := ProtocolsBgp(useCache)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	protocols, _ := res["protocols"].(Parsed)
+	return Parsed{
+		"regions":   summarizeRegions(protocols),
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}, from_cache
+}
+
+func summarizeRegions(protocols Parsed) Parsed {
+	res := Parsed{}
+	for name, p := range protocols {
+		protocol, ok := p.(Parsed)
+		if !ok {
+			continue
+		}
+
+		region := protocolRegion(protocol)
+		summary, ok := res[region].(Parsed)
+		if !ok {
+			summary = Parsed{
+				"protocols":       []string{},
+				"locations":       []string{},
+				"up":              int64(0),
+				"down":            int64(0),
+				"routes_imported": int64(0),
+				"routes_exported": int64(0),
+			}
+			res[region] = summary
+		}
+
+		summary["protocols"] = append(summary["protocols"].([]string), name)
+		if location, _ := protocol["location"].(string); location != "" {
+			summary["locations"] = appendUnique(summary["locations"].([]string), location)
+		}
+
+		if protocol["state"] == "up" {
+			summary["up"] = summary["up"].(int64) + 1
+		} else {
+			summary["down"] = summary["down"].(int64) + 1
+		}
+
+		routes, _ := protocol["routes"].(Parsed)
+		imported, _ := routes["imported"].(int64)
+		exported, _ := routes["exported"].(int64)
+		summary["routes_imported"] = summary["routes_imported"].(int64) + imported
+		summary["routes_exported"] = summary["routes_exported"].(int64) + exported
+	}
+
+	for _, s := range res {
+		summary := s.(Parsed)
+		sort.Strings(summary["protocols"].([]string))
+		sort.Strings(summary["locations"].([]string))
+	}
+
+	return res
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
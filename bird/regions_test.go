@@ -0,0 +1,65 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeRegions(t *testing.T) {
+	PeersConf = map[string]PeerConfig{
+		"R1": PeerConfig{Region: "fra", Location: "FR5"},
+		"R2": PeerConfig{Region: "fra", Location: "FR7"},
+		"R3": PeerConfig{Region: "ams", Location: "AM7"},
+	}
+	defer func() { PeersConf = nil }()
+
+	protocol := func(name, state string, imported int64) Parsed {
+		return tagRegion(name, Parsed{
+			"state":  state,
+			"routes": Parsed{"imported": imported, "exported": int64(1)},
+		})
+	}
+
+	protocols := Parsed{
+		"R1": protocol("R1", "up", 10),
+		"R2": protocol("R2", "down", 0),
+		"R3": protocol("R3", "up", 5),
+		"R4": protocol("R4", "up", 1),
+	}
+
+	fra := filterRegion(protocols, "fra")
+	if len(fra) != 2 || fra["R1"] == nil || fra["R2"] == nil {
+		t.Error("Unexpected protocols in region fra:", fra)
+	}
+
+	regions := summarizeRegions(protocols)
+	summary := regions["fra"].(Parsed)
+	if summary["up"] != int64(1) || summary["down"] != int64(1) {
+		t.Error("Unexpected session counts:", summary)
+	}
+	if summary["routes_imported"] != int64(10) {
+		t.Error("Expected 10 imported routes, got:", summary["routes_imported"])
+	}
+	if !reflect.DeepEqual(summary["locations"], []string{"FR5", "FR7"}) {
+		t.Error("Unexpected locations:", summary["locations"])
+	}
+
+	untagged := regions[untaggedRegion].(Parsed)
+	if !reflect.DeepEqual(untagged["protocols"], []string{"R4"}) {
+		t.Error("Unexpected untagged protocols:", untagged["protocols"])
+	}
+}
+
+func TestTagRegionCopies(t *testing.T) {
+	PeersConf = map[string]PeerConfig{"R1": PeerConfig{Region: "fra"}}
+	defer func() { PeersConf = nil }()
+
+	cached := Parsed{"state": "up"}
+	tagged := tagRegion("R1", cached)
+	if tagged["region"] != "fra" {
+		t.Error("Expected region fra, got:", tagged["region"])
+	}
+	if _, ok := cached["region"]; ok {
+		t.Error("Expected the cached protocol not to be modified")
+	}
+}
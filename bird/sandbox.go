@@ -0,0 +1,109 @@
+package bird
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Sandboxed execution of birdc
+//
+// birdc is started through the birdwatcher binary, which applies
+// the resource limits, joins the cgroup and replaces itself with
+// birdc. The limits are inherited by birdc, so a runaway query
+// can not use up the resources of the router.
+
+// SandboxExecArg is the first argument of the birdwatcher
+// when running a command in the sandbox.
+const SandboxExecArg = "-sandbox-exec"
+
+type SandboxConfig struct {
+	Enabled bool `toml:"enabled"`
+
+	// Resource limits of birdc, 0 disables a limit
+	CpuSeconds   int `toml:"cpu_seconds"`
+	AddressSpace int `toml:"address_space"` // MiB
+	Nofile       int `toml:"nofile"`
+
+	// Directory of the cgroup (v2) birdc is moved into
+	Cgroup string `toml:"cgroup"`
+}
+
+var SandboxConf SandboxConfig
+
+// The command line running the command in the sandbox
+func sandboxCommand(conf SandboxConfig, executable string, cmd []string) []string {
+	sandbox := []string{
+		executable,
+		SandboxExecArg,
+		"cpu=" + strconv.Itoa(conf.CpuSeconds),
+		"as=" + strconv.Itoa(conf.AddressSpace),
+		"nofile=" + strconv.Itoa(conf.Nofile),
+	}
+	if conf.Cgroup != "" {
+		sandbox = append(sandbox, "cgroup="+conf.Cgroup)
+	}
+	sandbox = append(sandbox, "--")
+	return append(sandbox, cmd...)
+}
+
+func sandboxExecutable() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return os.Args[0]
+	}
+	return executable
+}
+
+// Parse the limits and the command from the arguments
+// following SandboxExecArg.
+func parseSandboxArgs(args []string) (SandboxConfig, []string, error) {
+	conf := SandboxConfig{Enabled: true}
+	for i, arg := range args {
+		if arg == "--" {
+			if i+1 == len(args) {
+				return conf, nil, fmt.Errorf("missing command")
+			}
+			return conf, args[i+1:], nil
+		}
+
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return conf, nil, fmt.Errorf("invalid argument: %s", arg)
+		}
+		if kv[0] == "cgroup" {
+			conf.Cgroup = kv[1]
+			continue
+		}
+
+		value, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return conf, nil, fmt.Errorf("invalid limit: %s", arg)
+		}
+		switch kv[0] {
+		case "cpu":
+			conf.CpuSeconds = value
+		case "as":
+			conf.AddressSpace = value
+		case "nofile":
+			conf.Nofile = value
+		default:
+			return conf, nil, fmt.Errorf("unknown limit: %s", kv[0])
+		}
+	}
+	return conf, nil, fmt.Errorf("missing command")
+}
+
+// SandboxExec runs the command within the limits,
+// it does not return.
+func SandboxExec(args []string) {
+	conf, cmd, err := parseSandboxArgs(args)
+	if err != nil {
+		log.Fatal("Invalid sandbox arguments: ", err)
+	}
+	if err := sandboxExec(conf, cmd); err != nil {
+		log.Fatal("Could not run sandboxed: ", err)
+	}
+}
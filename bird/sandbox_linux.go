@@ -0,0 +1,48 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+func setRlimit(resource int, value uint64) error {
+	limit := &syscall.Rlimit{Cur: value, Max: value}
+	return syscall.Setrlimit(resource, limit)
+}
+
+// Apply the limits and replace the process with the command
+func sandboxExec(conf SandboxConfig, cmd []string) error {
+	if conf.Cgroup != "" {
+		procs := filepath.Join(conf.Cgroup, "cgroup.procs")
+		pid := []byte(strconv.Itoa(os.Getpid()))
+		if err := ioutil.WriteFile(procs, pid, 0644); err != nil {
+			return err
+		}
+	}
+
+	if conf.CpuSeconds > 0 {
+		if err := setRlimit(syscall.RLIMIT_CPU, uint64(conf.CpuSeconds)); err != nil {
+			return err
+		}
+	}
+	if conf.AddressSpace > 0 {
+		if err := setRlimit(syscall.RLIMIT_AS, uint64(conf.AddressSpace)<<20); err != nil {
+			return err
+		}
+	}
+	if conf.Nofile > 0 {
+		if err := setRlimit(syscall.RLIMIT_NOFILE, uint64(conf.Nofile)); err != nil {
+			return err
+		}
+	}
+
+	path, err := exec.LookPath(cmd[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, cmd, os.Environ())
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package bird
+
+import (
+	"fmt"
+)
+
+func sandboxExec(conf SandboxConfig, cmd []string) error {
+	return fmt.Errorf("the sandbox is only supported on linux")
+}
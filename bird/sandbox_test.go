@@ -0,0 +1,47 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	conf := SandboxConfig{
+		Enabled:      true,
+		CpuSeconds:   60,
+		AddressSpace: 1024,
+		Nofile:       64,
+		Cgroup:       "/sys/fs/cgroup/birdwatcher",
+	}
+	birdc := []string{"birdc", "-r", "show", "status"}
+
+	cmd := sandboxCommand(conf, "/usr/bin/birdwatcher", birdc)
+	if cmd[0] != "/usr/bin/birdwatcher" || cmd[1] != SandboxExecArg {
+		t.Fatal("Unexpected sandbox command:", cmd)
+	}
+
+	parsed, args, err := parseSandboxArgs(cmd[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != conf {
+		t.Error("Expected:", conf, "got:", parsed)
+	}
+	if !reflect.DeepEqual(args, birdc) {
+		t.Error("Unexpected command:", args)
+	}
+}
+
+func TestParseSandboxArgsInvalid(t *testing.T) {
+	invalid := [][]string{
+		{"cpu=10"},
+		{"cpu=10", "--"},
+		{"cpu=ten", "--", "birdc"},
+		{"mem=10", "--", "birdc"},
+	}
+	for _, args := range invalid {
+		if _, _, err := parseSandboxArgs(args); err == nil {
+			t.Error("Expected an error for:", args)
+		}
+	}
+}
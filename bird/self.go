@@ -0,0 +1,62 @@
+package bird
+
+import (
+	"sync"
+	"time"
+)
+
+// Health of the birdwatcher itself, served by /status/self
+
+var lastBirdcError = struct {
+	sync.Mutex
+	err     string
+	command string
+	at      time.Time
+}{}
+
+func recordBirdcError(command string, err error) {
+	lastBirdcError.Lock()
+	lastBirdcError.err = err.Error()
+	lastBirdcError.command = command
+	lastBirdcError.at = time.Now().UTC()
+	lastBirdcError.Unlock()
+}
+
+// cacheLen returns the number of cached entries if
+// the cache backend supports counting them.
+func cacheLen() (int, bool) {
+	counted, ok := cache.(interface{ Len() int })
+	if !ok {
+		return 0, false
+	}
+	return counted.Len(), true
+}
+
+// SelfStatus returns the state of the cache, the worker
+// pool and the last error running birdc.
+func SelfStatus() Parsed {
+	res := Parsed{}
+
+	if entries, ok := cacheLen(); ok {
+		res["cache_entries"] = entries
+	}
+
+	workers, busy, queued := pool.stats()
+	res["worker_pool"] = Parsed{
+		"workers":     workers,
+		"busy":        busy,
+		"queue_depth": queued,
+	}
+
+	lastBirdcError.Lock()
+	if lastBirdcError.err != "" {
+		res["last_birdc_error"] = Parsed{
+			"error":   lastBirdcError.err,
+			"command": lastBirdcError.command,
+			"at":      lastBirdcError.at,
+		}
+	}
+	lastBirdcError.Unlock()
+
+	return res
+}
@@ -0,0 +1,27 @@
+package bird
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelfStatus(t *testing.T) {
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() { cache = formerCache }()
+
+	cache.Set("key", Parsed{}, 5)
+	recordBirdcError("show status", errors.New("exit status 1"))
+
+	self := SelfStatus()
+	if self["cache_entries"] != 1 {
+		t.Error("Expected one cache entry, got:", self["cache_entries"])
+	}
+	if _, ok := self["worker_pool"].(Parsed)["queue_depth"]; !ok {
+		t.Error("Expected the queue depth of the worker pool")
+	}
+	lastError, ok := self["last_birdc_error"].(Parsed)
+	if !ok || lastError["error"] != "exit status 1" || lastError["command"] != "show status" {
+		t.Error("Unexpected last birdc error:", self["last_birdc_error"])
+	}
+}
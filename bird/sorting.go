@@ -0,0 +1,181 @@
+package bird
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sorting of routes and protocols
+//
+// The sort keys are derived from the typed parser output once
+// per route, so numbers are ordered numerically and networks by
+// address and prefix length.
+
+// A sortKey is compared by number, then by address and
+// prefix length, then by string.
+type sortKey struct {
+	n    int64
+	ip   []byte
+	ones int
+	s    string
+}
+
+func (a sortKey) less(b sortKey) bool {
+	if a.n != b.n {
+		return a.n < b.n
+	}
+	if c := bytes.Compare(a.ip, b.ip); c != 0 {
+		return c < 0
+	}
+	if a.ones != b.ones {
+		return a.ones < b.ones
+	}
+	return a.s < b.s
+}
+
+func networkKey(network string) sortKey {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return sortKey{s: network}
+	}
+	ones, _ := ipNet.Mask.Size()
+	return sortKey{ip: ipNet.IP.To16(), ones: ones}
+}
+
+// PrefixLess orders networks by address and prefix length
+func PrefixLess(x, y string) bool {
+	return networkKey(x).less(networkKey(y))
+}
+
+func routeAge(route Parsed, now time.Time) (time.Time, bool) {
+	if ts, ok := route["age_timestamp"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t, true
+		}
+	}
+	age, _ := route["age"].(string)
+	return parseBirdTime(age, now.In(birdLocation()))
+}
+
+func prefixLength(route Parsed) int64 {
+	network, _ := route["network"].(string)
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return -1
+	}
+	ones, _ := ipNet.Mask.Size()
+	return int64(ones)
+}
+
+// Sort keys of routes. Routes are ordered by age like a duration,
+// so the most recently changed routes come first.
+var routeSortKeys = map[string]func(Parsed, time.Time) sortKey{
+	"network": func(route Parsed, now time.Time) sortKey {
+		network, _ := route["network"].(string)
+		return networkKey(network)
+	},
+	"age": func(route Parsed, now time.Time) sortKey {
+		t, ok := routeAge(route, now)
+		if !ok {
+			return sortKey{}
+		}
+		return sortKey{n: -t.UnixNano()}
+	},
+	"as_path_length": func(route Parsed, now time.Time) sortKey {
+		return sortKey{n: int64(len(routeAsPath(route)))}
+	},
+	"local_pref": func(route Parsed, now time.Time) sortKey {
+		return sortKey{n: routeBgpInt(route, "local_pref", 0)}
+	},
+	"prefix_length": func(route Parsed, now time.Time) sortKey {
+		return sortKey{n: prefixLength(route)}
+	},
+}
+
+// SortRoutes returns the routes ordered by the sort key.
+// The routes slice shared with the cache is not modified.
+func SortRoutes(routes []Parsed, by string, desc bool) ([]Parsed, error) {
+	keyFunc, ok := routeSortKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("Invalid sort key: %s", by)
+	}
+
+	now := time.Now()
+	type keyed struct {
+		key   sortKey
+		route Parsed
+	}
+	entries := make([]keyed, len(routes))
+	for i, route := range routes {
+		entries[i] = keyed{keyFunc(route, now), route}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if desc {
+			return entries[j].key.less(entries[i].key)
+		}
+		return entries[i].key.less(entries[j].key)
+	})
+
+	res := make([]Parsed, len(entries))
+	for i, e := range entries {
+		res[i] = e.route
+	}
+	return res, nil
+}
+
+var protocolSortKeys = map[string]func(string, Parsed) sortKey{
+	"name": func(name string, protocol Parsed) sortKey {
+		return sortKey{s: name}
+	},
+	"state": func(name string, protocol Parsed) sortKey {
+		state, _ := protocol["state"].(string)
+		return sortKey{s: strings.ToLower(state)}
+	},
+	"state_changed": func(name string, protocol Parsed) sortKey {
+		ts, _ := protocol["state_changed_timestamp"].(string)
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return sortKey{n: t.UnixNano()}
+		}
+		return sortKey{}
+	},
+	"neighbor_as": func(name string, protocol Parsed) sortKey {
+		asn, _ := protocol["neighbor_as"].(int64)
+		return sortKey{n: asn}
+	},
+	"routes_imported": func(name string, protocol Parsed) sortKey {
+		routes, _ := protocol["routes"].(Parsed)
+		imported, _ := routes["imported"].(int64)
+		return sortKey{n: imported}
+	},
+}
+
+// SortProtocols returns the names of the protocols ordered
+// by the sort key, ties are ordered by name.
+func SortProtocols(protocols Parsed, by string, desc bool) ([]string, error) {
+	keyFunc, ok := protocolSortKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("Invalid sort key: %s", by)
+	}
+
+	names := make([]string, 0, len(protocols))
+	keys := map[string]sortKey{}
+	for name, p := range protocols {
+		protocol, _ := p.(Parsed)
+		names = append(names, name)
+		keys[name] = keyFunc(name, protocol)
+	}
+	sort.Strings(names)
+
+	sort.SliceStable(names, func(i, j int) bool {
+		if desc {
+			return keys[names[j]].less(keys[names[i]])
+		}
+		return keys[names[i]].less(keys[names[j]])
+	})
+	return names, nil
+}
@@ -0,0 +1,77 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortRoutes(t *testing.T) {
+	route := func(network, age, localPref string, path ...string) Parsed {
+		return Parsed{
+			"network": network,
+			"age":     age,
+			"bgp":     Parsed{"as_path": path, "local_pref": localPref},
+		}
+	}
+
+	routes := []Parsed{
+		route("10.0.0.0/8", "2018-05-30 10:00:00", "100", "1", "2"),
+		route("9.0.0.0/8", "2018-05-31 10:00:00", "90", "1", "2", "3"),
+		route("10.0.0.0/16", "2018-05-29 10:00:00", "1000", "1"),
+	}
+
+	networks := func(routes []Parsed) []string {
+		res := []string{}
+		for _, r := range routes {
+			res = append(res, r["network"].(string))
+		}
+		return res
+	}
+
+	tests := []struct {
+		by       string
+		desc     bool
+		expected []string
+	}{
+		{"network", false, []string{"9.0.0.0/8", "10.0.0.0/8", "10.0.0.0/16"}},
+		{"age", false, []string{"9.0.0.0/8", "10.0.0.0/8", "10.0.0.0/16"}},
+		{"as_path_length", true, []string{"9.0.0.0/8", "10.0.0.0/8", "10.0.0.0/16"}},
+		{"local_pref", true, []string{"10.0.0.0/16", "10.0.0.0/8", "9.0.0.0/8"}},
+	}
+
+	for _, test := range tests {
+		sorted, err := SortRoutes(routes, test.by, test.desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := networks(sorted); !reflect.DeepEqual(res, test.expected) {
+			t.Error("Unexpected order by", test.by, ":", res)
+		}
+	}
+
+	if networks(routes)[0] != "10.0.0.0/8" {
+		t.Error("Expected the routes not to be modified")
+	}
+
+	if _, err := SortRoutes(routes, "foo", false); err == nil {
+		t.Error("Expected an error for an invalid sort key")
+	}
+}
+
+func TestSortProtocols(t *testing.T) {
+	protocols := Parsed{
+		"R1": Parsed{"neighbor_as": int64(3), "routes": Parsed{"imported": int64(10)}},
+		"R2": Parsed{"neighbor_as": int64(1), "routes": Parsed{"imported": int64(30)}},
+		"R3": Parsed{"neighbor_as": int64(1), "routes": Parsed{"imported": int64(20)}},
+	}
+
+	names, _ := SortProtocols(protocols, "neighbor_as", false)
+	if !reflect.DeepEqual(names, []string{"R2", "R3", "R1"}) {
+		t.Error("Unexpected order by neighbor_as:", names)
+	}
+
+	names, _ = SortProtocols(protocols, "routes_imported", true)
+	if !reflect.DeepEqual(names, []string{"R2", "R3", "R1"}) {
+		t.Error("Unexpected order by routes_imported:", names)
+	}
+}
@@ -0,0 +1,392 @@
+package bird
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Spilling of oversized results to disk
+//
+// Results estimated larger than the spill threshold are written
+// to the spill directory and only a stub is kept in the memory
+// cache. On a cache hit the file is memory mapped and decoded.
+// Concurrent hits share a single decoded result, which is kept
+// for a short time only, so a burst of requests does not decode
+// the file once per request. The estimated size of the decoded
+// results held or in decoding is limited by the hold limit.
+
+const (
+	spillFileField = "spill_file"
+	spillSizeField = "spill_size"
+)
+
+// Time a decoded result is shared after decoding
+const spillHoldTime = 5 * time.Second
+
+// Default limit of the decoded results (in bytes)
+const spillHoldLimit = 256 << 20
+
+func init() {
+	// Types of values in parsed results
+	gob.Register(Parsed{})
+	gob.Register([]Parsed{})
+	gob.Register([]string{})
+	gob.Register([]int64{})
+	gob.Register([][]int64{})
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+	gob.Register(map[string]int64{})
+	gob.Register(time.Time{})
+}
+
+// SpillCache is a MemoryCache keeping results larger
+// than the threshold (in bytes) on disk.
+type SpillCache struct {
+	*MemoryCache
+	dir       string
+	threshold int
+
+	// HoldLimit is the maximum estimated size of the decoded
+	// results shared by requests. A single result larger than
+	// the limit is decoded, but not shared.
+	HoldLimit int
+
+	decodeLock sync.Mutex
+	released   *sync.Cond
+	decoded    map[string]*spillDecode
+	holding    int
+}
+
+// spillDecode is the decoding of a spill file shared by
+// all requests for the key. The result is available when
+// done is closed.
+type spillDecode struct {
+	done     chan struct{}
+	cachedAt interface{}
+	expires  time.Time
+	size     int
+	held     bool
+	val      Parsed
+	err      error
+}
+
+// finished is true when the decoding is done
+func (d *spillDecode) finished() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// current is true while the decoding is in progress or
+// the decoded result of the cache entry can be shared.
+func (d *spillDecode) current(cachedAt interface{}, now time.Time) bool {
+	if d.cachedAt != cachedAt {
+		return false
+	}
+	select {
+	case <-d.done:
+		return d.err == nil && now.Before(d.expires)
+	default:
+		return true
+	}
+}
+
+func NewSpillCache(dir string, threshold int) (*SpillCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	memory, err := NewMemoryCache()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SpillCache{
+		MemoryCache: memory,
+		dir:         dir,
+		threshold:   threshold,
+		HoldLimit:   spillHoldLimit,
+		decoded:     map[string]*spillDecode{},
+	}
+	c.released = sync.NewCond(&c.decodeLock)
+	return c, nil
+}
+
+// estimateSize approximates the memory used by a value
+func estimateSize(v interface{}) int {
+	switch value := v.(type) {
+	case string:
+		return 16 + len(value)
+	case Parsed:
+		size := 48
+		for k, e := range value {
+			size += 16 + len(k) + estimateSize(e)
+		}
+		return size
+	case map[string]interface{}:
+		return estimateSize(Parsed(value))
+	case []Parsed:
+		size := 24
+		for _, e := range value {
+			size += estimateSize(e)
+		}
+		return size
+	case []interface{}:
+		size := 24
+		for _, e := range value {
+			size += 16 + estimateSize(e)
+		}
+		return size
+	case []string:
+		size := 24
+		for _, e := range value {
+			size += 16 + len(e)
+		}
+		return size
+	}
+	return 16
+}
+
+func (c *SpillCache) spillFile(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".gob")
+}
+
+func (c *SpillCache) spill(key string, val Parsed) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(val); err != nil {
+		return "", err
+	}
+
+	// Replace the file atomically, it might be mapped
+	// by a concurrent request
+	filename := c.spillFile(key)
+	tmp, err := ioutil.TempFile(c.dir, "spill")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	tmp.Close()
+
+	return filename, os.Rename(tmp.Name(), filename)
+}
+
+func (c *SpillCache) Set(key string, val Parsed, ttl int) error {
+	size := estimateSize(val)
+	if ttl <= 0 || size < c.threshold {
+		c.removeSpillFile(key)
+		return c.MemoryCache.Set(key, val, ttl)
+	}
+
+	filename, err := c.spill(key, val)
+	if err != nil {
+		log.Println("Could not spill", key, "to disk:", err)
+		c.removeSpillFile(key)
+		return c.MemoryCache.Set(key, val, ttl)
+	}
+
+	stub := Parsed{spillFileField: filename, spillSizeField: size}
+	if err := c.MemoryCache.Set(key, stub, ttl); err != nil {
+		return err
+	}
+
+	// Like the memory cache, annotate the cached value
+	val["ttl"] = stub["ttl"]
+	val["cached_at"] = stub["cached_at"]
+	return nil
+}
+
+func (c *SpillCache) Get(key string) (Parsed, error) {
+	stub, err := c.MemoryCache.Get(key)
+	if err != nil {
+		return stub, err
+	}
+
+	filename, ok := stub[spillFileField].(string)
+	if !ok {
+		return stub, nil
+	}
+
+	size, _ := stub[spillSizeField].(int)
+
+	c.decodeLock.Lock()
+	prev, ok := c.decoded[key]
+	if ok && prev.current(stub["cached_at"], time.Now()) {
+		c.decodeLock.Unlock()
+		<-prev.done
+		return prev.val, prev.err
+	}
+	if ok && prev.finished() {
+		c.release(prev)
+	}
+	d := &spillDecode{
+		done:     make(chan struct{}),
+		cachedAt: stub["cached_at"],
+		size:     size,
+	}
+	c.decoded[key] = d
+	c.reserve(d)
+	c.decodeLock.Unlock()
+
+	d.val, d.err = c.decode(key, filename, stub)
+
+	c.decodeLock.Lock()
+	d.expires = time.Now().Add(spillHoldTime)
+	close(d.done)
+	c.released.Broadcast()
+	if d.err != nil || c.decoded[key] != d {
+		if c.decoded[key] == d {
+			delete(c.decoded, key)
+		}
+		c.release(d)
+	}
+	c.decodeLock.Unlock()
+
+	return d.val, d.err
+}
+
+// reserve accounts the size of the decoding to the held
+// results. The oldest shared results are released to stay
+// within the hold limit, or the decoding waits for results
+// in decoding. Must be called with the decode lock held.
+func (c *SpillCache) reserve(d *spillDecode) {
+	for c.holding > 0 && c.holding+d.size > c.HoldLimit {
+		if !c.releaseOldest(d) {
+			c.released.Wait()
+		}
+	}
+	c.holding += d.size
+	d.held = true
+}
+
+// releaseOldest releases the decoded result shared for the
+// shortest remaining time.
+func (c *SpillCache) releaseOldest(current *spillDecode) bool {
+	oldestKey := ""
+	var oldest *spillDecode
+	for key, d := range c.decoded {
+		if d == current || !d.finished() {
+			continue
+		}
+		if oldest == nil || d.expires.Before(oldest.expires) {
+			oldestKey, oldest = key, d
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	delete(c.decoded, oldestKey)
+	c.release(oldest)
+	return true
+}
+
+func (c *SpillCache) release(d *spillDecode) {
+	if !d.held {
+		return
+	}
+	d.held = false
+	c.holding -= d.size
+	c.released.Broadcast()
+}
+
+// decode reads the spilled result. The result is shared
+// by concurrent requests and must not be modified.
+func (c *SpillCache) decode(key, filename string, stub Parsed) (Parsed, error) {
+	val := Parsed{}
+	err := readMapped(filename, func(data []byte) error {
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&val)
+	})
+	if err != nil {
+		return NilParse, errors.New("Failed to read spilled key '" + key + "': " + err.Error())
+	}
+
+	val["ttl"] = stub["ttl"]
+	val["cached_at"] = stub["cached_at"]
+	return val, nil
+}
+
+// Expire removes the spill files of expired entries
+func (c *SpillCache) Expire() int {
+	count := c.MemoryCache.Expire()
+	c.RemoveOrphans()
+
+	// Release the shared decoded results
+	now := time.Now()
+	c.decodeLock.Lock()
+	for key, d := range c.decoded {
+		if d.finished() && !now.Before(d.expires) {
+			delete(c.decoded, key)
+			c.release(d)
+		}
+	}
+	c.decodeLock.Unlock()
+
+	return count
+}
+
+// removeSpillFile removes the spill file of the key when
+// the result is no longer spilled.
+func (c *SpillCache) removeSpillFile(key string) {
+	c.RLock()
+	_, ok := c.m[key][spillFileField]
+	c.RUnlock()
+	if ok {
+		os.Remove(c.spillFile(key))
+	}
+}
+
+// RemoveOrphans removes the files in the spill directory
+// which are not referenced by a cache entry, like the files
+// of evicted entries or of a previous run. Temporary files
+// are kept for a while, they might be written concurrently.
+func (c *SpillCache) RemoveOrphans() int {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		log.Println("Could not read the spill directory:", err)
+		return 0
+	}
+
+	c.RLock()
+	referenced := map[string]bool{}
+	for _, val := range c.m {
+		if filename, ok := val[spillFileField].(string); ok {
+			referenced[filepath.Base(filename)] = true
+		}
+	}
+	c.RUnlock()
+
+	count := 0
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || referenced[name] {
+			continue
+		}
+		if filepath.Ext(name) != ".gob" {
+			if !strings.HasPrefix(name, "spill") ||
+				time.Since(f.ModTime()) < time.Hour {
+				continue
+			}
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err == nil {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,173 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSpillCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewSpillCache(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	small := Parsed{"foo": "bar"}
+	if err := cache.Set("small", small, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []Parsed{}
+	for i := 0; i < 100; i++ {
+		routes = append(routes, Parsed{
+			"network": "10.0.0.0/24",
+			"bgp": Parsed{
+				"as_path":     []string{"64496", "64497"},
+				"communities": [][]int64{{64496, 1}},
+			},
+		})
+	}
+	large := Parsed{"routes": routes}
+	if err := cache.Set("large", large, 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := large["ttl"]; !ok {
+		t.Error("Expected the spilled value to be annotated with the ttl")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.gob"))
+	if len(files) != 1 {
+		t.Fatal("Expected one spill file, got:", files)
+	}
+
+	val, err := cache.Get("small")
+	if err != nil || val["foo"] != "bar" {
+		t.Error("Unexpected small value:", val, err)
+	}
+
+	val, err = cache.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spilled, ok := val["routes"].([]Parsed)
+	if !ok || len(spilled) != 100 {
+		t.Fatal("Unexpected spilled routes:", val["routes"])
+	}
+	bgp := spilled[0]["bgp"].(Parsed)
+	if bgp["communities"].([][]int64)[0][1] != 1 {
+		t.Error("Unexpected communities:", bgp)
+	}
+	if _, ok := val["ttl"]; !ok {
+		t.Error("Expected ttl in spilled value")
+	}
+
+	// Hits within the hold time share the decoded result
+	again, err := cache.Get("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(again).Pointer() != reflect.ValueOf(val).Pointer() {
+		t.Error("Expected the decoded result to be shared")
+	}
+
+	// Expire the entry and remove the spill file
+	cache.m["large"]["ttl"] = cache.m["large"]["cached_at"]
+	cache.Expire()
+	files, _ = filepath.Glob(filepath.Join(dir, "*.gob"))
+	if len(files) != 0 {
+		t.Error("Expected spill file to be removed, got:", files)
+	}
+}
+
+func TestSpillCacheHoldLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewSpillCache(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []Parsed{}
+	for i := 0; i < 100; i++ {
+		routes = append(routes, Parsed{"network": "10.0.0.0/24"})
+	}
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Set(key, Parsed{"routes": routes}, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only one decoded result fits
+	size := cache.m["a"][spillSizeField].(int)
+	cache.HoldLimit = size + size/2
+
+	if _, err := cache.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.decoded["a"]; ok {
+		t.Error("Expected the oldest decoded result to be released")
+	}
+	if _, ok := cache.decoded["b"]; !ok {
+		t.Error("Expected the decoded result to be shared")
+	}
+	if cache.holding != size {
+		t.Error("Expected", size, "bytes held, got:", cache.holding)
+	}
+}
+
+func TestSpillCacheRemoveOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orphan := filepath.Join(dir, "orphan.gob")
+	if err := ioutil.WriteFile(orphan, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewSpillCache(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := []Parsed{}
+	for i := 0; i < 100; i++ {
+		routes = append(routes, Parsed{"network": "10.0.0.0/24"})
+	}
+	if err := cache.Set("large", Parsed{"routes": routes}, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := cache.RemoveOrphans(); count != 1 {
+		t.Error("Expected one orphan to be removed, got:", count)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.gob"))
+	if len(files) != 1 || files[0] != cache.spillFile("large") {
+		t.Error("Unexpected spill files:", files)
+	}
+
+	// Replacing the spilled result removes the file
+	if err := cache.Set("large", Parsed{"foo": "bar"}, 5); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "*.gob"))
+	if len(files) != 0 {
+		t.Error("Expected the spill file to be removed, got:", files)
+	}
+}
@@ -0,0 +1,32 @@
+package bird
+
+import (
+	"os"
+	"syscall"
+)
+
+// readMapped memory maps the file while reading it
+func readMapped(filename string, read func([]byte) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return read(nil)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()),
+		syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	defer syscall.Munmap(data)
+
+	return read(data)
+}
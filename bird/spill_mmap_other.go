@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package bird
+
+import (
+	"io/ioutil"
+)
+
+func readMapped(filename string, read func([]byte) error) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return read(data)
+}
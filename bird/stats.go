@@ -0,0 +1,115 @@
+package bird
+
+import (
+	"sort"
+)
+
+// AS path statistics over a routing table
+
+type asnCount struct {
+	asn   string
+	count int64
+}
+
+func RoutesAsPathStats(useCache bool, table string, limit int) (Parsed, bool) {
+	res, from_cache := RoutesTable(useCache, table)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	routes, _ := res["routes"].([]Parsed)
+
+	return Parsed{
+		"as_path_stats": asPathStats(routes, limit),
+		"ttl":           res["ttl"],
+		"cached_at":     res["cached_at"],
+	}, from_cache
+}
+
+func asPathStats(routes []Parsed, limit int) Parsed {
+	count := int64(0)
+	totalLength := int64(0)
+	totalUniqueLength := int64(0)
+	maxLength := 0
+	prepended := int64(0)
+
+	transit := map[string]int64{}
+	prepending := map[string]int64{}
+
+	for _, route := range routes {
+		path := routeAsPath(route)
+		if len(path) == 0 {
+			continue
+		}
+
+		// An AS_SET counts as a single hop
+		length := len(asPathHops(path))
+		count++
+		totalLength += int64(length)
+		if length > maxLength {
+			maxLength = length
+		}
+
+		unique := uniqueAsns(path)
+		totalUniqueLength += int64(len(unique))
+
+		// Every ASN except the origin provides transit
+		for _, asn := range unique[:len(unique)-1] {
+			transit[asn]++
+		}
+
+		prepends := []string{}
+		for i := 1; i < len(path); i++ {
+			if path[i] == path[i-1] {
+				prepends = append(prepends, path[i])
+			}
+		}
+		for _, asn := range uniqueAsns(prepends) {
+			prepending[asn]++
+		}
+		if len(prepends) > 0 {
+			prepended++
+		}
+	}
+
+	averageLength := 0.0
+	averageUniqueLength := 0.0
+	if count > 0 {
+		averageLength = float64(totalLength) / float64(count)
+		averageUniqueLength = float64(totalUniqueLength) / float64(count)
+	}
+
+	return Parsed{
+		"routes":                     count,
+		"average_path_length":        averageLength,
+		"average_unique_path_length": averageUniqueLength,
+		"max_path_length":            maxLength,
+		"prepended_routes":           prepended,
+		"top_transit_asns":           topAsns(transit, limit),
+		"top_prepending_asns":        topAsns(prepending, limit),
+	}
+}
+
+func topAsns(counts map[string]int64, limit int) []Parsed {
+	sorted := []asnCount{}
+	for asn, count := range counts {
+		sorted = append(sorted, asnCount{asn, count})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count == sorted[j].count {
+			return parseInt(sorted[i].asn) < parseInt(sorted[j].asn)
+		}
+		return sorted[i].count > sorted[j].count
+	})
+
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	res := []Parsed{}
+	for _, c := range sorted {
+		res = append(res, Parsed{"asn": parseInt(c.asn), "routes": c.count})
+	}
+	return res
+}
@@ -0,0 +1,54 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAsPathStats(t *testing.T) {
+	route := func(path ...string) Parsed {
+		return Parsed{"bgp": Parsed{"as_path": path}}
+	}
+
+	routes := []Parsed{
+		route("64500", "64510"),
+		route("64500", "64500", "64500", "64520"),
+		route("64501", "64510"),
+		Parsed{}, // Route without BGP attributes
+	}
+
+	stats := asPathStats(routes, 1)
+
+	if stats["routes"] != int64(3) {
+		t.Error("Expected 3 routes, got:", stats["routes"])
+	}
+	if stats["max_path_length"] != 4 {
+		t.Error("Expected max path length 4, got:", stats["max_path_length"])
+	}
+	if stats["average_unique_path_length"] != 2.0 {
+		t.Error("Expected average unique path length 2, got:", stats["average_unique_path_length"])
+	}
+	if stats["prepended_routes"] != int64(1) {
+		t.Error("Expected 1 prepended route, got:", stats["prepended_routes"])
+	}
+
+	expected := []Parsed{Parsed{"asn": int64(64500), "routes": int64(2)}}
+	if !reflect.DeepEqual(stats["top_transit_asns"], expected) {
+		t.Error("Expected top transit ASNs:", expected, "not", stats["top_transit_asns"])
+	}
+}
+
+func TestAsPathStatsAsSet(t *testing.T) {
+	routes := []Parsed{
+		Parsed{"bgp": Parsed{"as_path": []string{"64500", "{64510", "64511", "64512}"}}},
+		Parsed{"bgp": Parsed{"as_path": []string{"64500", "64520"}}},
+	}
+
+	stats := asPathStats(routes, 1)
+	if stats["max_path_length"] != 2 {
+		t.Error("Expected max path length 2, got:", stats["max_path_length"])
+	}
+	if stats["average_path_length"] != 2.0 {
+		t.Error("Expected average path length 2, got:", stats["average_path_length"])
+	}
+}
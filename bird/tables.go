@@ -0,0 +1,127 @@
+package bird
+
+import (
+	"sort"
+)
+
+// Overview of all routing tables: the number of routes and
+// the protocols importing into and exporting from each table.
+
+func symbolNames(symbols Parsed, kind string) []string {
+	res := []string{}
+
+	all, ok := symbols["symbols"].(Parsed)
+	if !ok {
+		return res
+	}
+
+	switch names := all[kind].(type) {
+	case []string:
+		res = append(res, names...)
+	case []interface{}: // Decoded from the redis cache
+		for _, name := range names {
+			if s, ok := name.(string); ok {
+				res = append(res, s)
+			}
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+func Tables(useCache bool) (Parsed, bool) {
+	symbols, from_cache := Symbols(useCache)
+	if IsSpecial(symbols) {
+		return symbols, from_cache
+	}
+
+	protocols, _ := Protocols(useCache)
+	if IsSpecial(protocols) {
+		return protocols, from_cache
+	}
+
+	tables := symbolNames(symbols, "routing table")
+
+	counts := map[string]interface{}{}
+	for _, table := range tables {
+		count, _ := RoutesTableCount(useCache, table)
+		if IsSpecial(count) {
+			continue
+		}
+		counts[table] = count["routes"]
+	}
+
+	allProtocols, _ := protocols["protocols"].(Parsed)
+
+	return Parsed{
+		"tables":    tablesOverview(tables, allProtocols, counts),
+		"ttl":       symbols["ttl"],
+		"cached_at": symbols["cached_at"],
+	}, from_cache
+}
+
+func tablesOverview(tables []string, protocols Parsed, counts map[string]interface{}) Parsed {
+	res := Parsed{}
+	for _, table := range tables {
+		res[table] = Parsed{
+			"routes":    counts[table],
+			"protocols": []Parsed{},
+		}
+	}
+
+	attach := func(table string, protocol Parsed) {
+		t, ok := res[table].(Parsed)
+		if !ok {
+			return
+		}
+		t["protocols"] = append(t["protocols"].([]Parsed), protocol)
+
+		// Keep track of the latest protocol state change
+		changed, _ := protocol["state_changed"].(string)
+		if last, _ := t["last_change"].(string); changed > last {
+			t["last_change"] = changed
+		}
+	}
+
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		protocol, ok := protocols[name].(Parsed)
+		if !ok {
+			continue
+		}
+
+		birdProtocol, _ := protocol["bird_protocol"].(string)
+		table, _ := protocol["table"].(string)
+
+		// Routes are imported into the table through the
+		// import (input) filter and exported through the
+		// export (output) filter of the protocol.
+		attach(table, Parsed{
+			"protocol":      name,
+			"bird_protocol": birdProtocol,
+			"state_changed": protocol["state_changed"],
+			"import_filter": protocol["input_filter"],
+			"export_filter": protocol["output_filter"],
+		})
+
+		// Pipes export into their peer table
+		if peerTable, ok := protocol["peer_table"].(string); ok {
+			attach(peerTable, Parsed{
+				"protocol":      name,
+				"bird_protocol": birdProtocol,
+				"state_changed": protocol["state_changed"],
+				"import_filter": protocol["output_filter"],
+				"export_filter": protocol["input_filter"],
+				"peer_table":    true,
+			})
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,39 @@
+package bird
+
+import (
+	"testing"
+)
+
+func TestTablesOverview(t *testing.T) {
+	f, err := openFile("protocols_bgp_pipe.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	protocols := parseProtocols(f)["protocols"].(Parsed)
+	tables := []string{"master", "T65001_nada_co_ripe"}
+	counts := map[string]interface{}{
+		"master":              int64(23),
+		"T65001_nada_co_ripe": int64(42),
+	}
+
+	overview := tablesOverview(tables, protocols, counts)
+
+	master := overview["master"].(Parsed)
+	if master["routes"] != int64(23) {
+		t.Error("Expected 23 routes in master, got:", master["routes"])
+	}
+	if n := len(master["protocols"].([]Parsed)); n != 1 {
+		t.Error("Expected 1 protocol attached to master, got:", n)
+	}
+
+	peerTable := overview["T65001_nada_co_ripe"].(Parsed)
+	attached := peerTable["protocols"].([]Parsed)
+	if len(attached) != 2 {
+		t.Fatal("Expected the BGP protocol and the pipe in the peer table, got:", attached)
+	}
+	if peerTable["last_change"] != "2018-05-31 15:38:58" {
+		t.Error("Unexpected last change:", peerTable["last_change"])
+	}
+}
@@ -0,0 +1,65 @@
+package bird
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timestamps: BIRD reports times in the local time of the router,
+// omitting the date for today. Each time field is accompanied by
+// an RFC3339 timestamp in the configured timezone (UTC by default),
+// e.g. age_timestamp or state_changed_timestamp.
+
+var locations sync.Map
+
+func loadLocation(name string, fallback *time.Location) *time.Location {
+	if name == "" {
+		return fallback
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fallback
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+func birdLocation() *time.Location {
+	return loadLocation(ParserConf.BirdTimezone, time.Local)
+}
+
+func outputLocation() *time.Location {
+	return loadLocation(ParserConf.Timezone, time.UTC)
+}
+
+// birdTimestamp converts a time reported by BIRD (or a JSON
+// encoded time, see last_reconfig) to an RFC3339 timestamp.
+func birdTimestamp(value string, now time.Time) (string, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		var ok bool
+		t, ok = parseBirdTime(value, now.In(birdLocation()))
+		if !ok {
+			return "", false
+		}
+	}
+
+	return t.In(outputLocation()).Format(time.RFC3339), true
+}
+
+func setTimestamp(res Parsed, field string, now time.Time) {
+	value, ok := res[field].(string)
+	if !ok {
+		return
+	}
+	if ts, ok := birdTimestamp(value, now); ok {
+		res[field+"_timestamp"] = ts
+	}
+}
@@ -0,0 +1,48 @@
+package bird
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBirdTimestamp(t *testing.T) {
+	ParserConf.BirdTimezone = "Europe/Berlin"
+	defer func() { ParserConf = ParserConfig{} }()
+
+	now := time.Date(2018, 5, 31, 18, 0, 0, 0, time.UTC)
+
+	tests := map[string]string{
+		"2018-05-31 15:38:58":       "2018-05-31T13:38:58Z",
+		"15:38:58":                  "2018-05-31T13:38:58Z",
+		"2018-05-30":                "2018-05-29T22:00:00Z",
+		`"2018-05-31T10:00:00Z"`:    "2018-05-31T10:00:00Z",
+		"2018-05-31 15:38:58.12345": "2018-05-31T13:38:58Z",
+	}
+	for value, expected := range tests {
+		ts, ok := birdTimestamp(value, now)
+		if !ok || ts != expected {
+			t.Error("Expected", expected, "for", value, "got:", ts)
+		}
+	}
+
+	if _, ok := birdTimestamp("Could not fetch file", now); ok {
+		t.Error("Expected an error for an invalid time")
+	}
+
+	ParserConf.Timezone = "America/New_York"
+	ts, _ := birdTimestamp("2018-05-31 15:38:58", now)
+	if ts != "2018-05-31T09:38:58-04:00" {
+		t.Error("Unexpected timestamp in output timezone:", ts)
+	}
+}
+
+func TestSetTimestamp(t *testing.T) {
+	ParserConf.BirdTimezone = "UTC"
+	defer func() { ParserConf = ParserConfig{} }()
+
+	protocol := Parsed{"state_changed": "2018-05-31 15:38:58"}
+	setTimestamp(protocol, "state_changed", time.Now())
+	if protocol["state_changed_timestamp"] != "2018-05-31T15:38:58Z" {
+		t.Error("Unexpected timestamp:", protocol["state_changed_timestamp"])
+	}
+}
@@ -0,0 +1,95 @@
+package bird
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Top-N routes of a table by a sort key, e.g. the longest
+// AS paths. A heap of n routes is kept while scanning the
+// cached table instead of sorting all routes.
+
+type keyedRoute struct {
+	key   sortKey
+	route Parsed
+}
+
+// routeHeap keeps the least route on top, so it is
+// replaced when a greater route is found.
+type routeHeap struct {
+	routes []keyedRoute
+	less   func(a, b sortKey) bool
+}
+
+func (h *routeHeap) Len() int           { return len(h.routes) }
+func (h *routeHeap) Less(i, j int) bool { return h.less(h.routes[i].key, h.routes[j].key) }
+func (h *routeHeap) Swap(i, j int)      { h.routes[i], h.routes[j] = h.routes[j], h.routes[i] }
+
+func (h *routeHeap) Push(x interface{}) {
+	h.routes = append(h.routes, x.(keyedRoute))
+}
+
+func (h *routeHeap) Pop() interface{} {
+	last := h.routes[len(h.routes)-1]
+	h.routes = h.routes[:len(h.routes)-1]
+	return last
+}
+
+// topRoutes selects the n routes with the greatest keys,
+// or the least keys if asc is set, ordered from the top.
+func topRoutes(routes []Parsed, by string, n int, asc bool) ([]Parsed, error) {
+	keyFunc, ok := routeSortKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("Invalid sort key: %s", by)
+	}
+
+	less := func(a, b sortKey) bool { return a.less(b) }
+	if asc {
+		less = func(a, b sortKey) bool { return b.less(a) }
+	}
+
+	h := &routeHeap{less: less}
+	now := time.Now()
+	for _, route := range routes {
+		entry := keyedRoute{keyFunc(route, now), route}
+		if h.Len() < n {
+			heap.Push(h, entry)
+		} else if n > 0 && less(h.routes[0].key, entry.key) {
+			h.routes[0] = entry
+			heap.Fix(h, 0)
+		}
+	}
+
+	sort.SliceStable(h.routes, func(i, j int) bool {
+		return less(h.routes[j].key, h.routes[i].key)
+	})
+
+	res := make([]Parsed, len(h.routes))
+	for i, e := range h.routes {
+		res[i] = e.route
+	}
+	return res, nil
+}
+
+func RoutesTop(useCache bool, table string, by string, n int, asc bool) (Parsed, bool) {
+	res, from_cache := RoutesTable(useCache, table)
+	if IsSpecial(res) {
+		return res, from_cache
+	}
+
+	routes, _ := res["routes"].([]Parsed)
+	top, err := topRoutes(routes, by, n, asc)
+	if err != nil {
+		return Parsed{"error": err.Error()}, from_cache
+	}
+
+	return Parsed{
+		"routes":    top,
+		"table":     table,
+		"by":        by,
+		"ttl":       res["ttl"],
+		"cached_at": res["cached_at"],
+	}, from_cache
+}
@@ -0,0 +1,48 @@
+package bird
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopRoutes(t *testing.T) {
+	routes := []Parsed{}
+	for _, network := range []string{
+		"10.0.0.0/8", "10.1.0.0/16", "10.1.1.0/24", "10.1.1.1/32", "192.168.0.0/22",
+	} {
+		routes = append(routes, Parsed{"network": network})
+	}
+
+	networks := func(routes []Parsed) []string {
+		res := []string{}
+		for _, r := range routes {
+			res = append(res, r["network"].(string))
+		}
+		return res
+	}
+
+	top, err := topRoutes(routes, "prefix_length", 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"10.1.1.1/32", "10.1.1.0/24", "192.168.0.0/22"}
+	if res := networks(top); !reflect.DeepEqual(res, expected) {
+		t.Error("Unexpected top routes:", res)
+	}
+
+	top, _ = topRoutes(routes, "prefix_length", 2, true)
+	expected = []string{"10.0.0.0/8", "10.1.0.0/16"}
+	if res := networks(top); !reflect.DeepEqual(res, expected) {
+		t.Error("Unexpected bottom routes:", res)
+	}
+
+	top, _ = topRoutes(routes, "prefix_length", 10, false)
+	if len(top) != len(routes) {
+		t.Error("Expected all routes, got:", len(top))
+	}
+
+	top, _ = topRoutes(routes, "prefix_length", 0, false)
+	if len(top) != 0 {
+		t.Error("Expected no routes, got:", len(top))
+	}
+}
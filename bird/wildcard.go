@@ -0,0 +1,96 @@
+package bird
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Routes of all protocols matching a pattern like R_AS650*
+
+// IsProtocolPattern tests if the protocol contains a wildcard
+func IsProtocolPattern(protocol string) bool {
+	return strings.Contains(protocol, "*")
+}
+
+// matchProtocols returns the sorted names of the protocols
+// matching the pattern.
+func matchProtocols(pattern string, protocols Parsed) []string {
+	res := []string{}
+	for name := range protocols {
+		if ok, _ := path.Match(pattern, name); ok {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// RoutesProtoPattern queries the routes of every protocol
+// matching the pattern and merges them. Each route is annotated
+// with the protocol it was queried for.
+func RoutesProtoPattern(useCache bool, pattern string) (Parsed, bool) {
+	protocols, fromCache := Protocols(useCache)
+	if IsSpecial(protocols) {
+		return protocols, fromCache
+	}
+
+	all, ok := protocols["protocols"].(Parsed)
+	if !ok {
+		return protocols, fromCache
+	}
+
+	names := matchProtocols(pattern, all)
+	if len(names) == 0 {
+		return Parsed{"error": "no protocol matches " + pattern}, fromCache
+	}
+
+	results := make([]Parsed, len(names))
+	cached := make([]bool, len(names))
+
+	err := runConcurrently(len(names), func(i int) {
+		results[i], cached[i] = RoutesProto(useCache, names[i])
+	})
+	if err != nil {
+		return Parsed{"error": err.Error()}, false
+	}
+
+	routes := []Parsed{}
+	errors := Parsed{}
+	allCached := fromCache
+	for i, name := range names {
+		res := results[i]
+		allCached = allCached && cached[i]
+		if IsLimitExceeded(res) {
+			return res, false
+		}
+		if IsSpecial(res) {
+			errors[name] = "no result"
+			continue
+		}
+		if err, ok := res["error"]; ok {
+			errors[name] = err
+			continue
+		}
+
+		protoRoutes, _ := res["routes"].([]Parsed)
+		for _, route := range protoRoutes {
+			// Cached routes are shared and must not be modified
+			annotated := make(Parsed, len(route)+1)
+			for k, v := range route {
+				annotated[k] = v
+			}
+			annotated["protocol"] = name
+			routes = append(routes, annotated)
+		}
+	}
+
+	ret := Parsed{
+		"routes":    routes,
+		"protocols": names,
+	}
+	if len(errors) > 0 {
+		ret["protocol_errors"] = errors
+	}
+	return ret, allCached
+}
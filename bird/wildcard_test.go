@@ -0,0 +1,82 @@
+package bird
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoutesProtoPattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wildcard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixtures := map[string]string{
+		"protocols all":                          "../test/protocols_bgp_pipe.sample",
+		"route all protocol M65001_nada_co_ripe": "../test/routes_bird1_ipv4.sample",
+	}
+	for cmd, sample := range fixtures {
+		data, err := ioutil.ReadFile(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, FixtureName(cmd)), data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	MockDir = dir
+	BirdVersion = 1
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() {
+		MockDir = ""
+		BirdVersion = 0
+		cache = formerCache
+	}()
+
+	res, _ := RoutesProtoPattern(false, "*_co_ripe")
+	protocols, _ := res["protocols"].([]string)
+	if len(protocols) != 2 ||
+		protocols[0] != "C65003_nada2_co_ripe" ||
+		protocols[1] != "M65001_nada_co_ripe" {
+		t.Error("Unexpected matching protocols:", res["protocols"])
+	}
+
+	routes, _ := res["routes"].([]Parsed)
+	if len(routes) == 0 {
+		t.Fatal("Expected routes, got:", res)
+	}
+	for _, route := range routes {
+		if route["protocol"] != "M65001_nada_co_ripe" {
+			t.Error("Expected routes annotated with the protocol:", route)
+		}
+	}
+
+	// There is no fixture for the second protocol
+	errors, _ := res["protocol_errors"].(Parsed)
+	if _, ok := errors["C65003_nada2_co_ripe"]; !ok {
+		t.Error("Expected an error for C65003_nada2_co_ripe, got:", res["protocol_errors"])
+	}
+
+	res, _ = RoutesProtoPattern(false, "X*")
+	if _, ok := res["error"]; !ok {
+		t.Error("Expected an error without matching protocols")
+	}
+}
+
+func TestRoutesProtoPatternWithoutProtocols(t *testing.T) {
+	formerCache := cache
+	cache, _ = NewMemoryCache()
+	defer func() { cache = formerCache }()
+
+	cache.Set(CacheKeyPrefix+"protocols all", Parsed{"error": "no protocols"}, 5)
+	res, _ := RoutesProtoPattern(true, "*")
+	if res["error"] != "no protocols" {
+		t.Error("Expected the protocols result, got:", res)
+	}
+}
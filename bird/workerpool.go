@@ -0,0 +1,219 @@
+package bird
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/metrics"
+)
+
+// Adaptive worker pool for parsing routing tables
+//
+// The pool keeps at least WorkerPoolMin workers running and
+// starts new workers while jobs are queued, up to WorkerPoolMax
+// or the number of available CPUs. Idle workers above the
+// minimum exit after workerIdleTimeout.
+
+var (
+	WorkerPoolMin = 2
+	WorkerPoolMax = 8
+)
+
+const (
+	workerQueueSize   = 1024
+	workerIdleTimeout = 30 * time.Second
+)
+
+type workerPool struct {
+	sync.Mutex
+	jobs    chan func()
+	workers int
+	busy    int
+}
+
+var parserPanicsTotal = metrics.NewCounter(
+	"birdwatcher_parser_panics_total",
+	"Parser jobs aborted by a panic")
+
+var pool = &workerPool{
+	jobs: make(chan func(), workerQueueSize),
+}
+
+func init() {
+	metrics.NewGaugeFunc("birdwatcher_worker_pool_workers",
+		"Number of running parser workers",
+		func() float64 {
+			workers, _, _ := pool.stats()
+			return float64(workers)
+		})
+	metrics.NewGaugeFunc("birdwatcher_worker_pool_queue_depth",
+		"Number of queued parser jobs",
+		func() float64 {
+			_, _, queued := pool.stats()
+			return float64(queued)
+		})
+	metrics.NewGaugeFunc("birdwatcher_worker_pool_utilization",
+		"Ratio of busy to running parser workers",
+		func() float64 {
+			workers, busy, _ := pool.stats()
+			if workers == 0 {
+				return 0
+			}
+			return float64(busy) / float64(workers)
+		})
+}
+
+// maxWorkers limits the pool to the available CPUs,
+// as parsing is bound by CPU.
+func maxWorkers() int {
+	max := WorkerPoolMax
+	if cpus := runtime.GOMAXPROCS(0); cpus < max {
+		max = cpus
+	}
+	if max < WorkerPoolMin {
+		max = WorkerPoolMin
+	}
+	if max < 1 {
+		max = 1
+	}
+	return max
+}
+
+func (p *workerPool) stats() (int, int, int) {
+	p.Lock()
+	defer p.Unlock()
+	return p.workers, p.busy, len(p.jobs)
+}
+
+// Submit queues a job and scales up the pool if
+// all workers are busy.
+func (p *workerPool) Submit(job func()) {
+	p.jobs <- job
+
+	p.Lock()
+	defer p.Unlock()
+	for p.workers < WorkerPoolMin ||
+		(p.busy+len(p.jobs) > p.workers && p.workers < maxWorkers()) {
+		p.workers++
+		go p.work()
+	}
+}
+
+func (p *workerPool) work() {
+	idle := time.NewTimer(workerIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case job := <-p.jobs:
+			p.Lock()
+			p.busy++
+			p.Unlock()
+
+			p.run(job)
+
+			p.Lock()
+			p.busy--
+			p.Unlock()
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(workerIdleTimeout)
+		case <-idle.C:
+			p.Lock()
+			if p.workers > WorkerPoolMin {
+				p.workers--
+				p.Unlock()
+				return
+			}
+			p.Unlock()
+			idle.Reset(workerIdleTimeout)
+		}
+	}
+}
+
+// run executes a job. A panic in a parser job would take
+// down the daemon, as it is not running in the goroutine
+// of the request; it is logged, counted and returned instead.
+func (p *workerPool) run(job func()) (failure error) {
+	defer func() {
+		if err := recover(); err != nil {
+			parserPanicsTotal.Inc()
+			log.Printf("Panic in parser job: %v\n%s", err, debug.Stack())
+			failure = fmt.Errorf("parser job failed: %v", err)
+		}
+	}()
+	job()
+	return nil
+}
+
+// jobGroup submits jobs to the pool and collects the
+// failure of any of them, so a partial result is not
+// mistaken for a complete one.
+type jobGroup struct {
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+	failure error
+}
+
+func (g *jobGroup) Submit(job func()) {
+	g.wg.Add(1)
+	pool.Submit(func() {
+		defer g.wg.Done()
+		if err := pool.run(job); err != nil {
+			g.lock.Lock()
+			if g.failure == nil {
+				g.failure = err
+			}
+			g.lock.Unlock()
+		}
+	})
+}
+
+// Wait waits for all jobs and returns the first failure
+func (g *jobGroup) Wait() error {
+	g.wg.Wait()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.failure
+}
+
+// runConcurrently calls fn for 0 <= i < n with at most as many
+// calls in parallel as the pool has workers. The calls must not
+// run as pool jobs if they wait for parser jobs themselves:
+// with all workers blocked, the pool would deadlock.
+// A panic of a call is recovered and returned as error.
+func runConcurrently(n int, fn func(i int)) error {
+	wg := &sync.WaitGroup{}
+	slots := make(chan bool, maxWorkers())
+
+	var failure error
+	failureLock := &sync.Mutex{}
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- true
+			defer func() { <-slots }()
+			defer func() {
+				if err := recover(); err != nil {
+					log.Printf("Panic in concurrent query: %v\n%s", err, debug.Stack())
+					failureLock.Lock()
+					if failure == nil {
+						failure = fmt.Errorf("query failed: %v", err)
+					}
+					failureLock.Unlock()
+				}
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return failure
+}
@@ -0,0 +1,91 @@
+package bird
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWorkerPoolScaling(t *testing.T) {
+	p := &workerPool{jobs: make(chan func(), workerQueueSize)}
+
+	release := make(chan bool)
+	started := &sync.WaitGroup{}
+	done := &sync.WaitGroup{}
+
+	jobs := maxWorkers() + 2
+	started.Add(maxWorkers())
+	done.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		p.Submit(func() {
+			started.Done()
+			<-release
+			done.Done()
+		})
+	}
+
+	// All workers are busy, the remaining jobs are queued
+	started.Wait()
+	workers, busy, queued := p.stats()
+	if workers != maxWorkers() {
+		t.Error("Expected", maxWorkers(), "workers, got:", workers)
+	}
+	if busy != workers {
+		t.Error("Expected all workers to be busy, got:", busy)
+	}
+	if queued != 2 {
+		t.Error("Expected 2 queued jobs, got:", queued)
+	}
+
+	started.Add(2)
+	close(release)
+	done.Wait()
+}
+
+func TestWorkerPoolRecover(t *testing.T) {
+	p := &workerPool{jobs: make(chan func(), workerQueueSize)}
+	before := parserPanicsTotal.Value()
+
+	err := p.run(func() {
+		var routes []Parsed
+		_ = routes[1]
+	})
+
+	if err == nil {
+		t.Error("Expected the panic to be returned")
+	}
+	if parserPanicsTotal.Value() != before+1 {
+		t.Error("Expected the panic to be counted")
+	}
+}
+
+func TestJobGroupFailure(t *testing.T) {
+	jobs := &jobGroup{}
+	for i := 0; i < 3; i++ {
+		i := i
+		jobs.Submit(func() {
+			if i == 1 {
+				panic("broken block")
+			}
+		})
+	}
+	if err := jobs.Wait(); err == nil {
+		t.Error("Expected the failed job to be reported")
+	}
+}
+
+func TestRunConcurrentlyRecover(t *testing.T) {
+	done := make([]bool, 3)
+	err := runConcurrently(3, func(i int) {
+		if i == 1 {
+			var routes []Parsed
+			_ = routes[1]
+		}
+		done[i] = true
+	})
+	if err == nil {
+		t.Error("Expected the panic to be returned")
+	}
+	if !done[0] || !done[2] {
+		t.Error("Expected the other calls to complete:", done)
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sort"
 
 	"strings"
+	"time"
 
 	"github.com/alice-lg/birdwatcher/bird"
 	"github.com/alice-lg/birdwatcher/endpoints"
-	"github.com/gorilla/handlers"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -32,70 +36,83 @@ func makeRouter(config endpoints.ServerConfig) *httprouter.Router {
 	whitelist := config.ModulesEnabled
 
 	r := httprouter.New()
-	if isModuleEnabled("status", whitelist) {
-		r.GET("/version", endpoints.Version(VERSION))
-		r.GET("/status", endpoints.Endpoint(endpoints.Status))
-	}
-	if isModuleEnabled("protocols", whitelist) {
-		r.GET("/protocols", endpoints.Endpoint(endpoints.Protocols))
-	}
-	if isModuleEnabled("protocols_bgp", whitelist) {
-		r.GET("/protocols/bgp", endpoints.Endpoint(endpoints.Bgp))
-	}
-	if isModuleEnabled("protocols_short", whitelist) {
-		r.GET("/protocols/short", endpoints.Endpoint(endpoints.ProtocolsShort))
-	}
-	if isModuleEnabled("symbols", whitelist) {
-		r.GET("/symbols", endpoints.Endpoint(endpoints.Symbols))
-	}
-	if isModuleEnabled("symbols_tables", whitelist) {
-		r.GET("/symbols/tables", endpoints.Endpoint(endpoints.SymbolTables))
-	}
-	if isModuleEnabled("symbols_protocols", whitelist) {
-		r.GET("/symbols/protocols", endpoints.Endpoint(endpoints.SymbolProtocols))
-	}
-	if isModuleEnabled("routes_protocol", whitelist) {
-		r.GET("/routes/protocol/:protocol", endpoints.Endpoint(endpoints.ProtoRoutes))
-	}
-	if isModuleEnabled("routes_peer", whitelist) {
-		r.GET("/routes/peer/:peer", endpoints.Endpoint(endpoints.PeerRoutes))
-	}
-	if isModuleEnabled("routes_table", whitelist) {
-		r.GET("/routes/table/:table", endpoints.Endpoint(endpoints.TableRoutes))
-	}
-	if isModuleEnabled("routes_table_filtered", whitelist) {
-		r.GET("/routes/table/:table/filtered", endpoints.Endpoint(endpoints.TableRoutesFiltered))
-	}
-	if isModuleEnabled("routes_table_peer", whitelist) {
-		r.GET("/routes/table/:table/peer/:peer", endpoints.Endpoint(endpoints.TableAndPeerRoutes))
-	}
-	if isModuleEnabled("routes_count_protocol", whitelist) {
-		r.GET("/routes/count/protocol/:protocol", endpoints.Endpoint(endpoints.ProtoCount))
-	}
-	if isModuleEnabled("routes_count_table", whitelist) {
-		r.GET("/routes/count/table/:table", endpoints.Endpoint(endpoints.TableCount))
-	}
-	if isModuleEnabled("routes_count_primary", whitelist) {
-		r.GET("/routes/count/primary/:protocol", endpoints.Endpoint(endpoints.ProtoPrimaryCount))
-	}
-	if isModuleEnabled("routes_filtered", whitelist) {
-		r.GET("/routes/filtered/:protocol", endpoints.Endpoint(endpoints.RoutesFiltered))
-	}
-	if isModuleEnabled("routes_noexport", whitelist) {
-		r.GET("/routes/noexport/:protocol", endpoints.Endpoint(endpoints.RoutesNoExport))
-	}
-	if isModuleEnabled("routes_prefixed", whitelist) {
-		r.GET("/routes/prefix", endpoints.Endpoint(endpoints.RoutesPrefixed))
-	}
-	if isModuleEnabled("route_net", whitelist) {
-		r.GET("/route/net/:net", endpoints.Endpoint(endpoints.RouteNet))
-		r.GET("/route/net/:net/table/:table", endpoints.Endpoint(endpoints.RouteNetTable))
-	}
-	if isModuleEnabled("routes_pipe_filtered_count", whitelist) {
-		r.GET("/routes/pipe/filtered/count", endpoints.Endpoint(endpoints.PipeRoutesFilteredCount))
+	m := newModuleRouter(r, whitelist, config.DisabledModuleStatus)
+
+	m.GET("status", "/ready", endpoints.Ready)
+	m.GET("status", "/version", endpoints.Version(VERSION))
+	m.GET("status", "/status", endpoints.Endpoint(endpoints.Status))
+	m.GET("status_self", "/status/self", endpoints.Endpoint(endpoints.StatusSelf))
+	m.GET("status_preflight", "/status/preflight", endpoints.Endpoint(endpoints.Preflight))
+	m.GET("capabilities", "/capabilities", endpoints.Endpoint(endpoints.Capabilities))
+	m.GET("protocols", "/protocols", endpoints.ProtocolsLongPoll)
+	m.GET("protocols_bgp", "/protocols/bgp", endpoints.Endpoint(endpoints.Bgp))
+	m.GET("protocols_bgp_regions", "/protocols/bgp/regions", endpoints.Endpoint(endpoints.BgpRegions))
+	// httprouter does not allow a wildcard next to
+	// the static /protocols/bgp and /protocols/short
+	m.GET("protocols_stats", "/protocols/stats/:protocol", endpoints.Endpoint(endpoints.ProtocolStats))
+	m.GET("neighbors_stats", "/neighbors/stats", endpoints.Endpoint(endpoints.NeighborsStats))
+	m.GET("protocols_short", "/protocols/short", endpoints.Endpoint(endpoints.ProtocolsShort))
+	m.POST("protocols_query", "/protocols/query", endpoints.Endpoint(endpoints.ProtocolsQuery))
+	m.GET("symbols", "/symbols", endpoints.Endpoint(endpoints.Symbols))
+	m.GET("symbols_tables", "/symbols/tables", endpoints.Endpoint(endpoints.SymbolTables))
+	m.GET("symbols_protocols", "/symbols/protocols", endpoints.Endpoint(endpoints.SymbolProtocols))
+	m.GET("filters", "/filters", endpoints.Endpoint(endpoints.Filters))
+	m.GET("tables", "/tables", endpoints.Endpoint(endpoints.Tables))
+	m.GET("routes_protocol", "/routes/protocol/:protocol", endpoints.Endpoint(endpoints.ProtoRoutes))
+	m.GET("routes_peer", "/routes/peer/:peer", endpoints.Endpoint(endpoints.PeerRoutes))
+	m.GET("routes_table", "/routes/table/:table", endpoints.Endpoint(endpoints.TableRoutes))
+	m.GET("routes_table_filtered", "/routes/table/:table/filtered", endpoints.Endpoint(
+		endpoints.RequireCapability("keep_filtered", endpoints.TableRoutesFiltered)))
+	m.GET("routes_table_peer", "/routes/table/:table/peer/:peer", endpoints.Endpoint(endpoints.TableAndPeerRoutes))
+	m.GET("routes_count_protocol", "/routes/count/protocol/:protocol", endpoints.Endpoint(endpoints.ProtoCount))
+	m.GET("routes_count_table", "/routes/count/table/:table", endpoints.Endpoint(endpoints.TableCount))
+	m.GET("routes_count_all", "/routes/count/all", endpoints.Endpoint(endpoints.CountAll))
+	m.GET("routes_count_primary", "/routes/count/primary/:protocol", endpoints.Endpoint(endpoints.ProtoPrimaryCount))
+	m.GET("routes_filtered", "/routes/filtered/:protocol", endpoints.Endpoint(endpoints.RoutesFiltered))
+	m.GET("routes_noexport", "/routes/noexport/:protocol", endpoints.Endpoint(endpoints.RoutesNoExport))
+	m.GET("routes_exported", "/routes/exported/:protocol", endpoints.Endpoint(endpoints.RoutesExported))
+	m.GET("routes_received", "/routes/received/:protocol", endpoints.Endpoint(endpoints.RoutesReceived))
+	m.GET("routes_top", "/routes/top", endpoints.Endpoint(endpoints.RoutesTop))
+	m.GET("routes_prefixed", "/routes/prefix", endpoints.Endpoint(endpoints.RoutesPrefixed))
+	m.GET("lookup_announcers", "/lookup/announcers", endpoints.Endpoint(endpoints.Announcers))
+	m.GET("route_net", "/route/net/:net", endpoints.Endpoint(endpoints.RouteNet))
+	m.GET("route_net", "/route/net/:net/table/:table", endpoints.Endpoint(endpoints.RouteNetTable))
+	m.GET("route_net_explain", "/route/net/:net/explain", endpoints.Endpoint(endpoints.RouteNetExplain))
+	m.GET("route_net_explain", "/route/net/:net/table/:table/explain", endpoints.Endpoint(endpoints.RouteNetExplain))
+	m.GET("routes_pipe_filtered_count", "/routes/pipe/filtered/count", endpoints.Endpoint(endpoints.PipeRoutesFilteredCount))
+	m.GET("routes_pipe_filtered", "/routes/pipe/filtered", endpoints.Endpoint(endpoints.PipeRoutesFiltered))
+	m.GET("routes_changes", "/routes/changes/table/:table", endpoints.Endpoint(endpoints.TableRoutesChanges))
+	m.GET("routes_stats_aspath", "/routes/stats/aspath/:table", endpoints.Endpoint(endpoints.RoutesAsPathStats))
+	m.GET("babel", "/babel/neighbors", endpoints.Endpoint(endpoints.BabelNeighbors))
+	m.GET("babel", "/babel/entries", endpoints.Endpoint(endpoints.BabelEntries))
+	m.GET("plugins", "/plugins/:plugin", endpoints.Endpoint(endpoints.Plugin))
+	m.GET("raw", "/raw", endpoints.AdminEndpoint(endpoints.Raw))
+	m.GET("querylog_ws", "/ws/querylog", endpoints.QueryLogTail)
+	m.GET("analysis_leaks", "/analysis/leaks", endpoints.Endpoint(endpoints.RouteLeaks))
+	m.GET("analysis_nexthops", "/analysis/nexthops/:table", endpoints.Endpoint(endpoints.NextHopReachability))
+	m.GET("communities", "/communities", endpoints.Endpoint(endpoints.Communities))
+	m.GET("export", "/export/prefix-list/:protocol", endpoints.PrefixListExport)
+	m.GET("export", "/export/rpsl/table/:table", endpoints.RPSLExportTable)
+	m.GET("export", "/export/rpsl/protocol/:protocol", endpoints.RPSLExportProtocol)
+	m.GET("metrics", "/metrics", endpoints.Metrics)
+	m.GET("ui", "/ui", endpoints.UI)
+
+	// Custom paths are registered last and only with the module,
+	// paths conflicting with the other routes are skipped
+	custom := m.customEndpoints()
+	paths := make([]string, 0, len(custom))
+	for path := range custom {
+		paths = append(paths, path)
 	}
-	if isModuleEnabled("routes_pipe_filtered", whitelist) {
-		r.GET("/routes/pipe/filtered", endpoints.Endpoint(endpoints.PipeRoutesFiltered))
+	sort.Strings(paths)
+	for _, path := range paths {
+		registered := append([]string{routerProxyPath}, m.paths...)
+		handler, err := endpoints.CustomEndpoint(path, custom[path], registered)
+		if err != nil {
+			log.Println("Skipping custom endpoint", path, "-", err)
+			continue
+		}
+		m.GET("custom_endpoints", path, endpoints.Endpoint(handler))
 	}
 
 	return r
@@ -107,8 +124,17 @@ func PrintServiceInfo(conf *Config, birdConf bird.BirdConfig) {
 	// General Info
 	log.Println("Starting Birdwatcher")
 	log.Println("            Using:", birdConf.BirdCmd)
+	if birdConf.Netns != "" {
+		log.Println("  Network namespace:", birdConf.Netns)
+	}
 	log.Println("           Listen:", birdConf.Listen)
 	log.Println("        Cache TTL:", birdConf.CacheTtl)
+	if bird.MockDir != "" {
+		log.Println("   Using fixtures:", bird.MockDir)
+	}
+	if bird.RecordDir != "" {
+		log.Println("   Recording into:", bird.RecordDir)
+	}
 
 	// Endpoint Info
 	if len(conf.Server.AllowFrom) == 0 {
@@ -116,6 +142,9 @@ func PrintServiceInfo(conf *Config, birdConf bird.BirdConfig) {
 	} else {
 		log.Println("        AllowFrom:", strings.Join(conf.Server.AllowFrom, ", "))
 	}
+	if len(conf.Server.DenyFrom) > 0 {
+		log.Println("         DenyFrom:", strings.Join(conf.Server.DenyFrom, ", "))
+	}
 
 	if conf.Cache.UseRedis {
 		log.Println("    Caching backend: REDIS")
@@ -144,19 +173,50 @@ func (m *MyLogger) Write(p []byte) (n int, err error) {
 func main() {
 	// Disable timestamps for the default logger, as they are generated by the syslog implementation
 	log.SetFlags(log.Flags() &^ (log.Ldate | log.Ltime))
+
+	// Run birdc with resource limits
+	if len(os.Args) > 1 && os.Args[1] == bird.SandboxExecArg {
+		bird.SandboxExec(os.Args[2:])
+	}
+
+	// Command line client
+	if isClientCommand(os.Args[1:]) {
+		os.Exit(runClient(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	bird6 := flag.Bool("6", false, "Use bird6 instead of bird")
-	workerPoolSize := flag.Int("worker-pool-size", 8, "Number of go routines used to parse routing tables concurrently")
+	workerPoolMin := flag.Int("worker-pool-min", 2, "Minimum number of go routines used to parse routing tables concurrently")
+	workerPoolMax := flag.Int("worker-pool-max", 8, "Maximum number of go routines used to parse routing tables concurrently, limited by the available CPUs")
+	workerPoolSize := flag.Int("worker-pool-size", 0, "Deprecated, use -worker-pool-max")
 	configfile := flag.String("config", "/etc/birdwatcher/birdwatcher.conf", "Configuration file location")
+	mockDir := flag.String("mock-dir", "", "Serve recorded birdc output from this directory instead of running birdc")
+	recordDir := flag.String("record-dir", "", "Record the output of every birdc command to this directory, replay with -mock-dir")
+	modules := flag.String("modules", "", "Comma separated list of enabled modules, overrides modules_enabled")
+	router := flag.String("router", "", "Serve the router with this name from the [routers] config (used by multi-router mode)")
+	routerSocket := flag.String("router-socket", "", "Unix socket of the router process (used by multi-router mode)")
 	flag.Parse()
 
-	bird.WorkerPoolSize = *workerPoolSize
+	bird.WorkerPoolMin = *workerPoolMin
+	bird.WorkerPoolMax = *workerPoolMax
+	if *workerPoolSize > 0 {
+		log.Println("The flag -worker-pool-size is deprecated, use -worker-pool-max instead")
+		bird.WorkerPoolMax = *workerPoolSize
+	}
+	bird.MockDir = *mockDir
+	bird.RecordDir = *recordDir
 
 	conf, err := LoadConfigs([]string{*configfile})
-	if err != nil {
-		log.Fatal("Loading birdwatcher configuration failed:", err)
+	if err == ErrNoConfig {
+		log.Println("Loading birdwatcher configuration failed:", err)
+		log.Println("Using built-in defaults")
+		conf = DefaultConfig()
+	} else if err != nil {
+		log.Fatalln("Loading birdwatcher configuration failed:", err)
 	}
 
-	if conf.Server.EnableTLS {
+	conf.Server.ModulesEnabled = selectModules(*modules, conf.Server.ModulesEnabled)
+
+	if conf.Server.EnableTLS && !conf.Acme.Enabled {
 		if len(conf.Server.Crt) == 0 || len(conf.Server.Key) == 0 {
 			log.Fatalln("You have enabled TLS support. Please specify 'crt' and 'key' in birdwatcher config file.")
 		}
@@ -172,6 +232,42 @@ func main() {
 		bird.IPVersion = "6"
 	}
 
+	// Serve a router of the multi-router mode
+	if *router != "" {
+		routerConf, ok := conf.Routers[*router]
+		if !ok {
+			log.Fatal("Unknown router: ", *router)
+		}
+		birdConf = routerConf
+		birdConf.Listen = *routerSocket
+		bird.CacheKeyPrefix = "router_" + *router + "_"
+
+		// Access control is done by the parent process
+		conf.Server.AllowFrom = nil
+		conf.Server.DenyFrom = nil
+
+		go watchParent()
+	}
+
+	// The IP based access control does not apply to
+	// clients connecting through a unix socket
+	if isUnixListen(birdConf.Listen) {
+		conf.Server.AllowFrom = nil
+		conf.Server.DenyFrom = nil
+	}
+
+	if err := bird.ValidateNetns(birdConf.Netns); err != nil {
+		log.Fatal(err)
+	}
+	if err := bird.ValidateRestrictedMode(birdConf); err != nil {
+		log.Fatal(err)
+	}
+
+	logOutput := SetupLogging(conf.Logging, map[string]string{
+		"BIRDWATCHER_IP_VERSION": bird.IPVersion,
+		"BIRDWATCHER_LISTEN":     birdConf.Listen,
+	})
+
 	PrintServiceInfo(conf, birdConf)
 
 	// Configuration
@@ -182,27 +278,209 @@ func main() {
 	bird.RateLimitConf.Unlock()
 	bird.ParserConf = conf.Parser
 	bird.CacheConf = conf.Cache
+	bird.RoutesConf = conf.Routes
+	bird.PeersConf = conf.Peers
+	bird.AnalysisConf = conf.Analysis
+	bird.LimitsConf = conf.Limits
+	bird.SandboxConf = conf.Sandbox
+	bird.PluginsConf = conf.Plugins
+	if err := bird.LoadCommunities(conf.Communities); err != nil {
+		log.Println("Could not load communities:", err)
+	}
 	bird.InitializeCache()
+	if bird.CacheConf.PersistFile != "" && !bird.CacheConf.UseRedis {
+		persistFile := bird.CacheConf.PersistFile
+		if *router != "" {
+			persistFile += "." + *router
+		}
+		RestoreCache(persistFile, bird.CacheConf.PersistMaxAge)
+		go SaveCacheOnShutdown(persistFile)
+	}
+	bird.InstallStartupProbe(conf.Startup)
+	bird.InstallCapabilityDetection(conf.Capabilities)
 
 	endpoints.Conf = conf.Server
+	endpoints.ConfigHash = ConfigHash(conf)
+	endpoints.CustomEndpointsConf = conf.CustomEndpoints
+	if err := endpoints.InitAccessControl(); err != nil {
+		log.Fatal("Invalid access control configuration: ", err)
+	}
+	endpoints.InstallAccessControlRefresh()
+	endpoints.InstallAsNamesRefresh(conf.AsNames)
+	endpoints.RdnsConf = conf.Rdns
+	endpoints.RedactConf = conf.Redact
+	if err := endpoints.ValidateProfiles(conf.Profiles, conf.Server.Profile); err != nil {
+		log.Fatal("Invalid profiles: ", err)
+	}
+	endpoints.Profiles = conf.Profiles
+	if err := endpoints.ValidateTenants(conf.Tenants); err != nil {
+		log.Fatal("Invalid tenants: ", err)
+	}
+	endpoints.Tenants = conf.Tenants
+	if conf.Rdns.Enabled {
+		endpoints.InstallRdnsCacheExpiry()
+	}
 
 	// Make server
 	r := makeRouter(conf.Server)
+	if *router == "" {
+		registerRouters(r, StartRouters(conf.Routers))
+	}
+
+	// The access log can be written to a separate file
+	accessLogOutput := logOutput
+	if conf.Logging.AccessLog != "" {
+		accessLogFile, err := os.OpenFile(conf.Logging.AccessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal("Could not open access log:", err)
+		}
+		accessLogOutput = accessLogFile
+	}
 
 	// Set up our own custom log.Logger without a prefix
-	myquerylog := log.New(os.Stdout, "", 0)
+	myquerylog := log.New(accessLogOutput, "", 0)
 	// Disable timestamps, as they are contained in the query log
 	myquerylog.SetFlags(myquerylog.Flags() &^ (log.Ldate | log.Ltime))
-	mylogger := &MyLogger{myquerylog}
+	mylogger := io.MultiWriter(&MyLogger{myquerylog}, endpoints.QueryLog)
+	profileHandler := func(profile string) http.Handler {
+		var h http.Handler = EncodedSlashHandler(r)
+		if profile != "" {
+			h = endpoints.ProfileHandler(profile, h)
+		}
+		return AccessLogHandler(conf.Logging.AccessLogFormat, mylogger, RecoverHandler(h))
+	}
+	server := NewServer(birdConf.Listen, conf.Server, profileHandler(conf.Server.Profile))
+
+	go Housekeeping(conf.Housekeeping, !(bird.CacheConf.UseRedis)) // redis expires its entries itself
+
+	if conf.Events.Enabled && *router == "" {
+		publisher, err := NewPublisher(conf.Events)
+		if err != nil {
+			log.Fatal("Invalid events configuration: ", err)
+		}
+		go NewEventWatcher(conf.Events, publisher).Run()
+	}
 
-	go Housekeeping(conf.Housekeeping, !(bird.CacheConf.UseRedis)) // expire caches only for MemoryCache
+	if conf.Alerts.Enabled && *router == "" {
+		if conf.Alerts.WebhookURL == "" {
+			log.Fatal("Alerts are enabled, please specify a webhook_url")
+		}
+		go NewAlerter(conf.Alerts).Run()
+	}
+
+	if conf.Snmp.Enabled && *router == "" {
+		go RunSnmpAgent(conf.Snmp)
+	}
+
+	if conf.Prefetch.Enabled && *router == "" {
+		prefetcher, err := NewPrefetcher(conf.Prefetch, EncodedSlashHandler(r))
+		if err != nil {
+			log.Fatal("Invalid prefetch configuration: ", err)
+		}
+		go prefetcher.Run()
+	}
+
+	if conf.MetricsPush.Enabled && *router == "" {
+		pusher, err := NewMetricsPusher(conf.MetricsPush)
+		if err != nil {
+			log.Fatal("Invalid metrics_push configuration: ", err)
+		}
+		go pusher.Run()
+	}
+
+	creds, err := lookupCredentials(conf.Server.User, conf.Server.Group)
+	if err != nil {
+		log.Fatal("Invalid user or group: ", err)
+	}
 
-	if conf.Server.EnableTLS {
+	// Bind the listener and load the certificates
+	// before dropping privileges
+	var listener net.Listener
+	if *router != "" {
+		listener, err = listenRouterSocket(*routerSocket)
+		if err == nil && creds != nil {
+			// The parent process needs to connect to the socket
+			err = os.Chown(*routerSocket, creds.uid, creds.gid)
+		}
+	} else if isUnixListen(birdConf.Listen) {
+		listener, err = ListenUnix(birdConf.Listen, conf.Server)
+	} else {
+		listener, err = net.Listen("tcp", birdConf.Listen)
+	}
+	if err != nil {
+		log.Fatal("Could not listen: ", err)
+	}
+
+	var profileListeners map[string]net.Listener
+	if *router == "" {
+		profileListeners, err = listenProfiles(conf.Profiles, conf.Server)
+		if err != nil {
+			log.Fatal("Could not listen for profiles: ", err)
+		}
+	}
+
+	useTLS := (conf.Server.EnableTLS || conf.Acme.Enabled) && *router == ""
+	if useTLS {
+		server.TLSConfig, err = NewTLSConfig(conf.Server)
+		if err != nil {
+			log.Fatal("Invalid TLS configuration: ", err)
+		}
+	}
+	if useTLS && conf.Acme.Enabled {
+		acme, err := NewAcmeManager(conf.Acme)
+		if err != nil {
+			log.Fatal("Could not set up ACME: ", err)
+		}
+		if creds != nil {
+			// Certificates are renewed after dropping privileges
+			if err := os.Chown(acme.config.CacheDir, creds.uid, creds.gid); err != nil {
+				log.Fatal("Could not set up ACME: ", err)
+			}
+		}
+
+		// Serve the HTTP-01 challenges
+		if acme.config.Challenge == acmeChallengeHTTP {
+			challengeListener, err := net.Listen("tcp", acme.config.HTTPListen)
+			if err != nil {
+				log.Fatal("Could not listen for ACME challenges: ", err)
+			}
+			go http.Serve(challengeListener, acme)
+		}
+		go acme.Run()
+
+		server.TLSConfig.GetCertificate = acme.GetCertificate
+	} else if useTLS {
 		if len(conf.Server.Crt) == 0 || len(conf.Server.Key) == 0 {
 			log.Fatalln("You have enabled TLS support but not specified both a .crt and a .key file in the config.")
 		}
-		log.Fatal(http.ListenAndServeTLS(birdConf.Listen, conf.Server.Crt, conf.Server.Key, handlers.LoggingHandler(mylogger, r)))
+		cert, err := LoadKeyPair(conf.Server.Crt, conf.Server.Key, conf.Server.KeyPassphrase)
+		if err != nil {
+			log.Fatal("Could not load TLS certificate: ", err)
+		}
+		if conf.Server.OCSPStapleFile == "" {
+			server.TLSConfig.Certificates = []tls.Certificate{cert}
+		} else {
+			stapled := &stapledCertificate{cert: cert, ocspFile: conf.Server.OCSPStapleFile}
+			if err := stapled.reload(); err != nil {
+				log.Fatal("Could not load OCSP response: ", err)
+			}
+			go stapled.refresh(time.Hour)
+			server.TLSConfig.GetCertificate = stapled.GetCertificate
+		}
+	}
+
+	if creds != nil {
+		if err := creds.drop(); err != nil {
+			log.Fatal("Could not drop privileges: ", err)
+		}
+		log.Println("Running as user:", conf.Server.User)
+	}
+
+	serveProfiles(profileListeners, conf.Server, profileHandler)
+
+	if useTLS {
+		log.Fatal(server.ServeTLS(listener, "", ""))
 	} else {
-		log.Fatal(http.ListenAndServe(birdConf.Listen, handlers.LoggingHandler(mylogger, r)))
+		log.Fatal(server.Serve(listener))
 	}
 }
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+// Command line client
+//
+//   birdwatcher query /protocols/bgp
+//   birdwatcher get routes -protocol R1
+//
+// The request is sent to a running birdwatcher, or with
+// -local handled in the process by running birdc. Results
+// are printed as a table or as JSON.
+
+const defaultClientURL = "http://localhost:29184"
+
+type clientOptions struct {
+	url    string
+	token  string
+	format string
+
+	local      bool
+	configfile string
+	bird6      bool
+	mockDir    string
+}
+
+func clientFlags(name string, opts *clientOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.url, "url", defaultClientURL, "URL of the birdwatcher, or unix:/path/to/socket")
+	fs.StringVar(&opts.token, "token", os.Getenv("BIRDWATCHER_TOKEN"), "Bearer token sent with the request (default $BIRDWATCHER_TOKEN)")
+	fs.StringVar(&opts.format, "format", "table", "Output format: table or json")
+	fs.BoolVar(&opts.local, "local", false, "Handle the request in this process instead of calling a running birdwatcher")
+	fs.StringVar(&opts.configfile, "config", "/etc/birdwatcher/birdwatcher.conf", "Configuration file location, used with -local")
+	fs.BoolVar(&opts.bird6, "6", false, "Use bird6 instead of bird, used with -local")
+	fs.StringVar(&opts.mockDir, "mock-dir", "", "Use recorded birdc output from this directory, used with -local")
+	return fs
+}
+
+// isClientCommand checks if the arguments select the client
+func isClientCommand(args []string) bool {
+	return len(args) > 0 && (args[0] == "query" || args[0] == "get")
+}
+
+// runClient runs the query or get subcommand and
+// returns the exit status
+func runClient(args []string, stdout, stderr io.Writer) int {
+	opts := &clientOptions{}
+	var path string
+	var err error
+
+	switch args[0] {
+	case "query":
+		fs := clientFlags("query", opts)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintln(stderr, "Usage: birdwatcher query [flags] <endpoint>")
+			fs.PrintDefaults()
+		}
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		path = fs.Arg(0)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	case "get":
+		path, err = getPath(args[1:], opts, stderr)
+		if err == flag.ErrHelp {
+			return 2
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if opts.format != "table" && opts.format != "json" {
+		fmt.Fprintln(stderr, "Unknown format:", opts.format)
+		return 2
+	}
+
+	var status int
+	var body []byte
+	if opts.local {
+		status, body, err = queryLocal(opts, path)
+	} else {
+		status, body, err = queryRemote(opts, path)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Request failed:", err)
+		return 1
+	}
+
+	if err := printResult(stdout, opts.format, body); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if status >= 400 {
+		return 1
+	}
+	return 0
+}
+
+// Resources of the get subcommand
+var clientResources = []string{"status", "protocols", "neighbors", "tables", "routes"}
+
+// getPath maps the resource and its flags to the endpoint
+func getPath(args []string, opts *clientOptions, stderr io.Writer) (string, error) {
+	fs := clientFlags("get", opts)
+	fs.SetOutput(stderr)
+	protocol := fs.String("protocol", "", "Routes of the protocol")
+	table := fs.String("table", "", "Routes of the table")
+	network := fs.String("net", "", "Routes for the address")
+	filtered := fs.Bool("filtered", false, "Filtered routes of the protocol")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: birdwatcher get <resource> [flags]")
+		fmt.Fprintln(stderr, "Resources:", strings.Join(clientResources, ", "))
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		return "", flag.ErrHelp
+	}
+	resource := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return "", flag.ErrHelp
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("Unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	switch resource {
+	case "status":
+		return "/status", nil
+	case "protocols":
+		return "/protocols", nil
+	case "neighbors":
+		return "/protocols/bgp", nil
+	case "tables":
+		return "/tables", nil
+	case "routes":
+		switch {
+		case *filtered && *protocol != "":
+			return "/routes/filtered/" + url.PathEscape(*protocol), nil
+		case *filtered:
+			return "", fmt.Errorf("-filtered requires -protocol")
+		case *network != "" && *table != "":
+			return "/route/net/" + url.PathEscape(*network) + "/table/" + url.PathEscape(*table), nil
+		case *network != "":
+			return "/route/net/" + url.PathEscape(*network), nil
+		case *protocol != "":
+			return "/routes/protocol/" + url.PathEscape(*protocol), nil
+		case *table != "":
+			return "/routes/table/" + url.PathEscape(*table), nil
+		}
+		return "", fmt.Errorf("routes requires -protocol, -table or -net")
+	}
+	return "", fmt.Errorf("Unknown resource: %s (%s)", resource, strings.Join(clientResources, ", "))
+}
+
+func queryRemote(opts *clientOptions, path string) (int, []byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	base := strings.TrimSuffix(opts.url, "/")
+	if isUnixListen(base) {
+		socket := strings.TrimPrefix(base, unixListenPrefix)
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		base = "http://unix"
+	}
+
+	req, err := http.NewRequest("GET", base+path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if opts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.token)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, body, err
+}
+
+// Response of a request handled in the process
+type localResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *localResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *localResponse) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+func (r *localResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// queryLocal handles the request with the router of the
+// configuration, without access control.
+func queryLocal(opts *clientOptions, path string) (int, []byte, error) {
+	conf, err := LoadConfigs([]string{opts.configfile})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	birdConf := conf.Bird
+	if opts.bird6 {
+		birdConf = conf.Bird6
+		bird.IPVersion = "6"
+	}
+	bird.MockDir = opts.mockDir
+	bird.ClientConf = birdConf
+	bird.StatusConf = conf.Status
+	bird.ParserConf = conf.Parser
+	bird.RoutesConf = conf.Routes
+	bird.PeersConf = conf.Peers
+	bird.LimitsConf = conf.Limits
+	bird.SandboxConf = conf.Sandbox
+	bird.CacheConf = conf.Cache
+	bird.CacheConf.UseRedis = false
+	bird.InitializeCache()
+
+	conf.Server.ModulesEnabled = selectModules("", conf.Server.ModulesEnabled)
+	conf.Server.AllowFrom = nil
+	conf.Server.DenyFrom = nil
+	endpoints.Conf = conf.Server
+	endpoints.VERSION = VERSION
+
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("Accept", "application/json")
+	if opts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.token)
+	}
+
+	res := &localResponse{header: http.Header{}}
+	EncodedSlashHandler(makeRouter(conf.Server)).ServeHTTP(res, req)
+	if res.status == 0 {
+		res.status = http.StatusOK
+	}
+	return res.status, res.body.Bytes(), nil
+}
+
+// printResult prints the response as indented JSON, or the
+// protocols, routes or status as a table. Other responses
+// are printed as JSON.
+func printResult(w io.Writer, format string, body []byte) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		// Not JSON, e.g. an error of the server
+		_, err := w.Write(body)
+		return err
+	}
+
+	if format == "table" {
+		if msg, ok := doc["error"]; ok {
+			fmt.Fprintln(w, "Error:", clientText(msg))
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		if printTable(tw, doc) {
+			return tw.Flush()
+		}
+	}
+
+	js, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(js))
+	return err
+}
+
+func printTable(w io.Writer, doc map[string]interface{}) bool {
+	if protocols, ok := doc["protocols"].(map[string]interface{}); ok {
+		names := make([]string, 0, len(protocols))
+		for name := range protocols {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(w, "PROTOCOL\tTYPE\tTABLE\tSTATE\tSINCE\tNEIGHBOR\tASN\tIMPORTED\tEXPORTED\tFILTERED\tDESCRIPTION")
+		for _, name := range names {
+			p, _ := protocols[name].(map[string]interface{})
+			routes, _ := p["routes"].(map[string]interface{})
+			fmt.Fprintln(w, strings.Join([]string{
+				name,
+				clientText(p["bird_protocol"]),
+				clientText(p["table"]),
+				clientText(p["state"]),
+				clientText(p["state_changed"]),
+				clientText(p["neighbor_address"]),
+				clientText(p["neighbor_as"]),
+				clientText(routes["imported"]),
+				clientText(routes["exported"]),
+				clientText(routes["filtered"]),
+				clientText(p["description"]),
+			}, "\t"))
+		}
+		return true
+	}
+
+	if routes, ok := doc["routes"].([]interface{}); ok {
+		fmt.Fprintln(w, "NETWORK\tGATEWAY\tINTERFACE\tPROTOCOL\tBEST\tAS PATH\tLOCAL PREF\tMED")
+		for _, r := range routes {
+			route, _ := r.(map[string]interface{})
+			bgp, _ := route["bgp"].(map[string]interface{})
+			best := ""
+			if primary, _ := route["primary"].(bool); primary {
+				best = "*"
+			}
+			fmt.Fprintln(w, strings.Join([]string{
+				clientText(route["network"]),
+				clientText(route["gateway"]),
+				clientText(route["interface"]),
+				clientText(route["from_protocol"]),
+				best,
+				clientText(bgp["as_path"]),
+				clientText(bgp["local_pref"]),
+				clientText(bgp["med"]),
+			}, "\t"))
+		}
+		return true
+	}
+
+	if status, ok := doc["status"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(status))
+		for k := range status {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\n", k, clientText(status[k]))
+		}
+		return true
+	}
+
+	return false
+}
+
+// clientText formats a value of the response
+func clientText(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		// Numbers are decoded as float64
+		if value == float64(int64(value)) {
+			return fmt.Sprint(int64(value))
+		}
+	case []interface{}:
+		parts := make([]string, len(value))
+		for i, e := range value {
+			parts[i] = clientText(e)
+		}
+		return strings.Join(parts, " ")
+	case map[string]interface{}:
+		js, _ := json.Marshal(value)
+		return string(js)
+	}
+	return fmt.Sprint(v)
+}
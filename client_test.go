@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientGetPath(t *testing.T) {
+	tests := []struct {
+		args []string
+		path string
+	}{
+		{[]string{"status"}, "/status"},
+		{[]string{"neighbors"}, "/protocols/bgp"},
+		{[]string{"routes", "--protocol", "R1"}, "/routes/protocol/R1"},
+		{[]string{"routes", "-protocol", "R1", "-filtered"}, "/routes/filtered/R1"},
+		{[]string{"routes", "-net", "192.0.2.1", "-table", "T1"}, "/route/net/192.0.2.1/table/T1"},
+		{[]string{"routes", "-table", "master"}, "/routes/table/master"},
+	}
+	for _, test := range tests {
+		path, err := getPath(test.args, &clientOptions{}, &bytes.Buffer{})
+		if err != nil || path != test.path {
+			t.Error("Unexpected path for", test.args, "-", path, err)
+		}
+	}
+
+	for _, args := range [][]string{{"routes"}, {"routes", "-filtered"}, {"unknown"}} {
+		if _, err := getPath(args, &clientOptions{}, &bytes.Buffer{}); err == nil {
+			t.Error("Expected an error for", args)
+		}
+	}
+}
+
+func TestClientQuery(t *testing.T) {
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		if r.URL.Path != "/routes/protocol/R1" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"routes":[{"network":"192.0.2.0/24","gateway":"198.51.100.1",
+			"from_protocol":"R1","primary":true,"bgp":{"as_path":["65001","65002"],"local_pref":100}}]}`))
+	}))
+	defer server.Close()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	status := runClient([]string{"get", "routes", "-url", server.URL, "-token", "secret", "--protocol", "R1"}, stdout, stderr)
+	if status != 0 {
+		t.Fatal("Unexpected exit status:", status, stderr.String())
+	}
+	if token != "Bearer secret" {
+		t.Error("Expected the token to be sent, got:", token)
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "NETWORK") || !strings.Contains(out, "65001 65002") ||
+		!strings.Contains(out, "100") {
+		t.Error("Unexpected table:", out)
+	}
+
+	stdout.Reset()
+	status = runClient([]string{"query", "-url", server.URL, "-format", "json", "/unknown"}, stdout, stderr)
+	if status != 1 {
+		t.Error("Expected a failure for an error response, got:", status)
+	}
+	if !strings.Contains(stdout.String(), `"error": "not found"`) {
+		t.Error("Expected the error as JSON, got:", stdout.String())
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+// birdwatcher-bench replays captured birdc output through the
+// parsers and generates HTTP load against a running birdwatcher.
+//
+// Replay recordings (e.g. from birdwatcher -record-dir):
+//
+//    birdwatcher-bench parse -parser routes -n 20 route_table_master4_all.txt
+//
+// Load a birdwatcher instance with 16 concurrent clients:
+//
+//    birdwatcher-bench load -c 16 -d 30s http://localhost:29184/routes/table/master
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: birdwatcher-bench parse [flags] FILE...")
+	fmt.Fprintln(os.Stderr, "       birdwatcher-bench load [flags] URL...")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "parse":
+		err = benchParse(os.Args[2:])
+	case "load":
+		err = benchLoad(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// Latency statistics
+type results struct {
+	sync.Mutex
+	durations []time.Duration
+	bytes     int64
+	errors    int
+}
+
+func (r *results) add(d time.Duration, n int64, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.durations = append(r.durations, d)
+	r.bytes += n
+}
+
+func (r *results) percentile(p float64) time.Duration {
+	if len(r.durations) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.durations)-1) * p)
+	return r.durations[i]
+}
+
+func (r *results) print(name string, elapsed time.Duration) {
+	sort.Slice(r.durations, func(i, j int) bool {
+		return r.durations[i] < r.durations[j]
+	})
+
+	count := len(r.durations)
+	fmt.Printf("%s\n", name)
+	fmt.Printf("  runs:       %d (%d errors)\n", count, r.errors)
+	fmt.Printf("  throughput: %.1f/s, %.1f MiB/s\n",
+		float64(count)/elapsed.Seconds(),
+		float64(r.bytes)/elapsed.Seconds()/(1<<20))
+	fmt.Printf("  latency:    p50 %v, p90 %v, p99 %v, max %v\n",
+		r.percentile(0.5), r.percentile(0.9),
+		r.percentile(0.99), r.percentile(1))
+}
+
+func benchParse(args []string) error {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	parserName := flags.String("parser", "routes", "Name of the parser (e.g. routes, protocols, status)")
+	runs := flags.Int("n", 10, "Number of runs per file")
+	workers := flags.Int("workers", 8, "Maximum number of route parser workers")
+	flags.Parse(args)
+
+	parser, ok := bird.LookupParser(*parserName)
+	if !ok {
+		return fmt.Errorf("unknown parser: %s", *parserName)
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no input files")
+	}
+	bird.WorkerPoolMax = *workers
+
+	for _, filename := range flags.Args() {
+		input, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		res := &results{}
+		start := time.Now()
+		for i := 0; i < *runs; i++ {
+			t := time.Now()
+			parser(bytes.NewReader(input))
+			res.add(time.Since(t), int64(len(input)), nil)
+		}
+		res.print(filename, time.Since(start))
+	}
+
+	return nil
+}
+
+func benchLoad(args []string) error {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	concurrency := flags.Int("c", 4, "Number of concurrent clients")
+	duration := flags.Duration("d", 10*time.Second, "Duration of the test")
+	uncached := flags.Bool("uncached", false, "Bypass the cache (requires allow_uncached)")
+	timeout := flags.Duration("timeout", 60*time.Second, "Request timeout")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no URLs")
+	}
+
+	client := &http.Client{Timeout: *timeout}
+
+	for _, url := range flags.Args() {
+		if *uncached {
+			url += "?uncached=true"
+		}
+
+		res := &results{}
+		wg := &sync.WaitGroup{}
+		start := time.Now()
+		deadline := start.Add(*duration)
+
+		for i := 0; i < *concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(deadline) {
+					t := time.Now()
+					n, err := fetch(client, url)
+					res.add(time.Since(t), n, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		res.print(url, time.Since(start))
+	}
+
+	return nil
+}
+
+func fetch(client *http.Client, url string) (int64, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		return n, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return n, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return n, nil
+}
@@ -3,11 +3,14 @@ package main
 // Birdwatcher Configuration
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
-	"github.com/BurntSushi/toml"
 	"github.com/imdario/mergo"
 
 	"github.com/alice-lg/birdwatcher/bird"
@@ -21,9 +24,33 @@ type Config struct {
 	Status       bird.StatusConfig
 	Bird         bird.BirdConfig
 	Bird6        bird.BirdConfig
+	Routers      map[string]bird.BirdConfig
 	Parser       bird.ParserConfig
 	Cache        bird.CacheConfig
+	Routes       bird.RoutesConfig
+	Analysis     bird.AnalysisConfig
+	Limits       bird.LimitsConfig
+	Capabilities bird.CapabilitiesConfig
+	Startup      bird.StartupConfig
+	Sandbox      bird.SandboxConfig
+	Peers        map[string]bird.PeerConfig
+	Plugins      map[string]bird.PluginConfig
+	Communities  bird.CommunitiesConfig
 	Housekeeping HousekeepingConfig
+	Logging      LoggingConfig
+	Acme         AcmeConfig
+	Events       EventsConfig
+	Alerts       AlertsConfig
+	Snmp         SnmpConfig
+	MetricsPush  MetricsPushConfig       `toml:"metrics_push"`
+	AsNames      endpoints.AsNamesConfig `toml:"asnames"`
+	Rdns         endpoints.RdnsConfig
+	Redact       endpoints.RedactConfig
+	Profiles     map[string]endpoints.ProfileConfig
+	Tenants      map[string]endpoints.TenantConfig
+	Prefetch     PrefetchConfig
+
+	CustomEndpoints map[string]endpoints.CustomEndpointConfig `toml:"custom_endpoints"`
 }
 
 // Try to load configfiles as specified in the files
@@ -34,18 +61,25 @@ type Config struct {
 //    ./etc/birdwatcher/birdwatcher.local.conf
 //
 //
+// ErrNoConfig is returned if none of the config files exists
+var ErrNoConfig = fmt.Errorf("Could not load any config file")
+
 func LoadConfigs(configFiles []string) (*Config, error) {
 	config := &Config{}
 	hasConfig := false
 	var confError error
 
 	for _, filename := range configFiles {
-		tmp := &Config{}
-		_, err := toml.DecodeFile(filename, tmp)
-		if err != nil {
+		tmp, conflicts, err := loadConfigFile(filename)
+		if os.IsNotExist(err) {
 			continue
+		} else if err != nil {
+			// A broken config must not start the server
+			// with the defaults
+			return nil, fmt.Errorf("Invalid config file %s: %s", filename, err)
 		} else {
 			log.Println("Using config file:", filename)
+			logConfigConflicts(conflicts)
 			hasConfig = true
 			// Merge configs
 			if err := mergo.Merge(config, tmp); err != nil {
@@ -55,12 +89,23 @@ func LoadConfigs(configFiles []string) (*Config, error) {
 	}
 
 	if !hasConfig {
-		confError = fmt.Errorf("Could not load any config file")
+		confError = ErrNoConfig
 	}
 
 	return config, confError
 }
 
+// ConfigHash identifies the loaded configuration,
+// e.g. to check if all instances run the same config.
+func ConfigHash(config *Config) string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func ConfigOptions(filename string) []string {
 	return []string{
 		strings.Join([]string{"/", filename}, ""),
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,3 +19,108 @@ func TestLoadConfigs(t *testing.T) {
 	t.Log(res)
 	t.Log(err)
 }
+
+func TestConfigHash(t *testing.T) {
+	conf := DefaultConfig()
+	hash := ConfigHash(conf)
+	if len(hash) != 64 {
+		t.Fatal("Unexpected config hash:", hash)
+	}
+	if ConfigHash(conf) != hash {
+		t.Error("Expected the hash to be stable")
+	}
+
+	conf.Bird.CacheTtl++
+	if ConfigHash(conf) == hash {
+		t.Error("Expected the hash to change with the config")
+	}
+}
+
+func TestLoadConfigInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"birdwatcher.conf": `include = ["conf.d/*.conf"]
+
+[server]
+modules_enabled = ["status"]
+allow_from = ["127.0.0.1"]
+
+[bird]
+listen = "0.0.0.0:29184"
+`,
+		"conf.d/20-peers.conf": `[peers.R1]
+relationship = "customer"
+
+[server]
+modules_enabled = ["status", "protocols", "routes_protocol"]
+`,
+		"conf.d/10-modules.conf": `[server]
+modules_enabled = ["status", "protocols"]
+`,
+		"conf.d/ignored.txt": `[server]
+modules_enabled = []
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf, conflicts, err := loadConfigFile(filepath.Join(dir, "birdwatcher.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Bird.Listen != "0.0.0.0:29184" || len(conf.Server.AllowFrom) != 1 {
+		t.Error("Expected the values of the main file, got:", conf.Bird, conf.Server.AllowFrom)
+	}
+	if conf.Peers["R1"].Relationship != "customer" {
+		t.Error("Expected the peers of the fragment, got:", conf.Peers)
+	}
+	// Fragments are merged in lexical order
+	if len(conf.Server.ModulesEnabled) != 3 {
+		t.Error("Expected the modules of the last fragment, got:", conf.Server.ModulesEnabled)
+	}
+
+	if len(conflicts) != 2 {
+		t.Fatal("Expected two conflicts, got:", conflicts)
+	}
+	if !strings.Contains(conflicts[0], "birdwatcher.conf is overridden by") ||
+		!strings.HasSuffix(conflicts[0], "10-modules.conf") ||
+		!strings.HasSuffix(conflicts[1], "20-peers.conf") {
+		t.Error("Unexpected conflicts:", conflicts)
+	}
+
+	// Fragments can not include other files
+	ioutil.WriteFile(filepath.Join(dir, "conf.d/30-include.conf"), []byte(`include = "*.conf"`), 0644)
+	if _, _, err := loadConfigFile(filepath.Join(dir, "birdwatcher.conf")); err == nil {
+		t.Error("Expected an error for an include in a fragment")
+	}
+}
+
+func TestLoadConfigsInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "birdwatcher.conf")
+	if err := ioutil.WriteFile(filename, []byte("[server\nallow_from = []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigs([]string{filename}); err == nil || err == ErrNoConfig {
+		t.Error("Expected an error for an invalid config, got:", err)
+	}
+
+	if _, err := LoadConfigs([]string{filepath.Join(dir, "missing.conf")}); err != ErrNoConfig {
+		t.Error("Expected ErrNoConfig for a missing config, got:", err)
+	}
+}
@@ -0,0 +1,264 @@
+package endpoints
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access control lists are parsed once on startup into
+// networks; addresses without a prefix length are treated
+// as host prefixes. Hostnames are resolved periodically.
+
+type ACL []*net.IPNet
+
+// accessList holds the networks of an access control list and
+// the addresses of hostnames, which are resolved periodically.
+type accessList struct {
+	sync.RWMutex
+	networks  ACL
+	hostnames []string
+	resolved  map[string]ACL
+}
+
+var accessControl struct {
+	sync.RWMutex
+	allow *accessList
+	deny  *accessList
+}
+
+var hostnameRx = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9\-]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9\-]*[a-zA-Z0-9])?\.?$`)
+
+func parseNetwork(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		return network, err
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid address: %s", entry)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// ParseACL parses a list of addresses and CIDR prefixes
+func ParseACL(entries []string) (ACL, error) {
+	acl := ACL{}
+	for _, entry := range entries {
+		network, err := parseNetwork(entry)
+		if err != nil {
+			return nil, err
+		}
+		acl = append(acl, network)
+	}
+	return acl, nil
+}
+
+// Contains checks if the ip is in any of the networks
+func (acl ACL) Contains(ip net.IP) bool {
+	for _, network := range acl {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var numericLabelRx = regexp.MustCompile(`^[0-9]+$`)
+
+// Entries with a numeric top level label look like
+// addresses, e.g. 10.0.0.256, and are no hostnames
+func isHostname(entry string) bool {
+	labels := strings.Split(strings.TrimSuffix(entry, "."), ".")
+	return !strings.Contains(entry, "/") &&
+		net.ParseIP(entry) == nil &&
+		hostnameRx.MatchString(entry) &&
+		!numericLabelRx.MatchString(labels[len(labels)-1])
+}
+
+// Split the entries into networks and hostnames
+func parseAccessList(entries []string) (*accessList, error) {
+	list := &accessList{
+		networks: ACL{},
+		resolved: map[string]ACL{},
+	}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if isHostname(entry) {
+			list.hostnames = append(list.hostnames, entry)
+			continue
+		}
+
+		network, err := parseNetwork(entry)
+		if err != nil {
+			return nil, err
+		}
+		list.networks = append(list.networks, network)
+	}
+
+	return list, nil
+}
+
+func (list *accessList) Empty() bool {
+	return len(list.networks) == 0 && len(list.hostnames) == 0
+}
+
+func (list *accessList) Contains(ip net.IP) bool {
+	if list.networks.Contains(ip) {
+		return true
+	}
+
+	list.RLock()
+	defer list.RUnlock()
+	for _, acl := range list.resolved {
+		if acl.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve the hostnames. If a lookup fails, the previously
+// resolved addresses of the hostname are kept.
+func (list *accessList) resolve() {
+	for _, hostname := range list.hostnames {
+		ips, err := net.LookupIP(hostname)
+		if err != nil {
+			log.Println("Could not resolve", hostname, "for access control:", err)
+			continue
+		}
+
+		acl := ACL{}
+		for _, ip := range ips {
+			network, err := parseNetwork(ip.String())
+			if err != nil {
+				continue
+			}
+			acl = append(acl, network)
+		}
+
+		list.Lock()
+		list.resolved[hostname] = acl
+		list.Unlock()
+	}
+}
+
+// InitAccessControl parses the allow and deny lists
+// from the server config and resolves all hostnames.
+func InitAccessControl() error {
+	allow, err := parseAccessList(Conf.AllowFrom)
+	if err != nil {
+		return fmt.Errorf("allow_from: %s", err)
+	}
+
+	deny, err := parseAccessList(Conf.DenyFrom)
+	if err != nil {
+		return fmt.Errorf("deny_from: %s", err)
+	}
+
+	allow.resolve()
+	deny.resolve()
+
+	accessControl.Lock()
+	accessControl.allow = allow
+	accessControl.deny = deny
+	accessControl.Unlock()
+
+	return nil
+}
+
+// InstallAccessControlRefresh periodically resolves the
+// hostnames in the access control lists.
+func InstallAccessControlRefresh() {
+	accessControl.RLock()
+	hasHostnames := len(accessControl.allow.hostnames) > 0 ||
+		len(accessControl.deny.hostnames) > 0
+	accessControl.RUnlock()
+	if !hasHostnames {
+		return
+	}
+
+	interval := time.Duration(Conf.ResolveInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	go func() {
+		c := time.Tick(interval)
+		for range c {
+			accessControl.RLock()
+			allow, deny := accessControl.allow, accessControl.deny
+			accessControl.RUnlock()
+
+			allow.resolve()
+			deny.resolve()
+		}
+	}()
+}
+
+// Extract the client IP from the remote address
+func remoteIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	// Remove IPv6 zone
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+
+	return net.ParseIP(host)
+}
+
+// accessLists returns the allow and deny lists. If access
+// control was not initialized, e.g. by the client or in tests,
+// the lists are initialized from the config.
+func accessLists() (*accessList, *accessList, error) {
+	accessControl.RLock()
+	allow, deny := accessControl.allow, accessControl.deny
+	accessControl.RUnlock()
+	if allow != nil && deny != nil {
+		return allow, deny, nil
+	}
+
+	if err := InitAccessControl(); err != nil {
+		return nil, nil, err
+	}
+	accessControl.RLock()
+	defer accessControl.RUnlock()
+	return accessControl.allow, accessControl.deny, nil
+}
+
+func isAccessAllowed(ip net.IP) bool {
+	allow, deny, err := accessLists()
+	if err != nil {
+		log.Println("Access denied, invalid access control:", err)
+		return false
+	}
+
+	if ip == nil {
+		return allow.Empty() && deny.Empty()
+	}
+
+	if deny.Contains(ip) {
+		return false
+	}
+
+	if allow.Empty() {
+		return true // AllowFrom ALL
+	}
+
+	return allow.Contains(ip)
+}
@@ -0,0 +1,137 @@
+package endpoints
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestParseACL(t *testing.T) {
+	acl, err := ParseACL([]string{
+		"10.0.0.0/8",
+		"2001:db8::/32",
+		"192.0.2.1",
+		"fe80::1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allowed := []string{"10.23.42.1", "2001:db8:1::1", "192.0.2.1", "fe80::1", "::ffff:10.1.1.1"}
+	for _, ip := range allowed {
+		if !acl.Contains(net.ParseIP(ip)) {
+			t.Error(ip, "should be in the ACL")
+		}
+	}
+
+	denied := []string{"11.0.0.1", "2001:db9::1", "192.0.2.2", "fe80::2"}
+	for _, ip := range denied {
+		if acl.Contains(net.ParseIP(ip)) {
+			t.Error(ip, "should not be in the ACL")
+		}
+	}
+
+	if _, err := ParseACL([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected an error for an invalid entry")
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	Conf.AllowFrom = []string{"10.0.0.0/8", "2001:db8::/32"}
+	Conf.DenyFrom = []string{"10.0.0.66"}
+	defer func() {
+		Conf.AllowFrom = nil
+		Conf.DenyFrom = nil
+		InitAccessControl()
+	}()
+
+	if err := InitAccessControl(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := map[string]bool{
+		"10.0.0.1:4242":        true,
+		"10.0.0.66:4242":       false,
+		"172.16.0.1:4242":      false,
+		"[2001:db8::1]:4242":   true,
+		"[2001:db9::1]:4242":   false,
+		"[fe80::1%eth0]:4242":  false,
+		"[::ffff:10.0.0.1]:42": true,
+	}
+
+	for addr, allowed := range requests {
+		req, _ := http.NewRequest("GET", "/status", nil)
+		req.RemoteAddr = addr
+
+		err := CheckAccess(req)
+		if allowed && err != nil {
+			t.Error(addr, "should be allowed:", err)
+		}
+		if !allowed && err == nil {
+			t.Error(addr, "should be rejected")
+		}
+	}
+}
+
+func TestParseAccessListHostnames(t *testing.T) {
+	list, err := parseAccessList([]string{"10.0.0.0/8", "monitoring.example.net", "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list.networks) != 1 {
+		t.Error("Expected 1 network, got:", len(list.networks))
+	}
+	if len(list.hostnames) != 2 {
+		t.Error("Expected 2 hostnames, got:", list.hostnames)
+	}
+
+	list.resolved["monitoring.example.net"] = ACL{
+		&net.IPNet{IP: net.ParseIP("192.0.2.10").To4(), Mask: net.CIDRMask(32, 32)},
+	}
+	if !list.Contains(net.ParseIP("192.0.2.10")) {
+		t.Error("Resolved address of hostname should be in the access list")
+	}
+
+	if _, err := parseAccessList([]string{"in valid"}); err == nil {
+		t.Error("Expected an error for an invalid entry")
+	}
+
+	// Malformed addresses are no hostnames
+	for _, entry := range []string{"10.0.0.256", "10.0.0.1/33", "192.0.2", "2001:db8::g"} {
+		if _, err := parseAccessList([]string{entry}); err == nil {
+			t.Error("Expected an error for", entry)
+		}
+	}
+}
+
+func TestCheckAccessUninitialized(t *testing.T) {
+	reset := func() {
+		accessControl.Lock()
+		accessControl.allow, accessControl.deny = nil, nil
+		accessControl.Unlock()
+	}
+	defer func() {
+		Conf.AllowFrom = nil
+		Conf.DenyFrom = nil
+		InitAccessControl()
+	}()
+
+	req, _ := http.NewRequest("GET", "/status", nil)
+	req.RemoteAddr = "172.16.0.1:4242"
+
+	// Initialized from the config on first use
+	reset()
+	Conf.AllowFrom = []string{"10.0.0.0/8"}
+	if err := CheckAccess(req); err == nil {
+		t.Error("Expected the allow list of the config to apply")
+	}
+
+	// An invalid config fails closed
+	reset()
+	Conf.AllowFrom = nil
+	Conf.DenyFrom = []string{"10.0.0.0/33"}
+	if err := CheckAccess(req); err == nil {
+		t.Error("Expected access to be denied with an invalid config")
+	}
+}
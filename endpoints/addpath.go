@@ -0,0 +1,36 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// With add-path, only the best path of each prefix and
+// protocol is returned, unless ?all_paths=true is set.
+// The number of omitted paths is set as hidden_paths.
+
+func pathsResult(qs url.Values, res bird.Parsed) bird.Parsed {
+	allPaths := qs.Get("all_paths")
+	if allPaths != "" && allPaths != "true" && allPaths != "false" {
+		return bird.Parsed{"error": fmt.Sprintf("Invalid all_paths: %s", allPaths)}
+	}
+	if allPaths == "true" || bird.IsSpecial(res) {
+		return res
+	}
+
+	routes, ok := res["routes"].([]bird.Parsed)
+	if !ok || !bird.HasAddPaths(routes) {
+		return res
+	}
+
+	best := bird.BestPaths(routes)
+	ret := make(bird.Parsed, len(res)+1)
+	for k, v := range res {
+		ret[k] = v
+	}
+	ret["routes"] = best
+	ret["hidden_paths"] = len(routes) - len(best)
+	return ret
+}
@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+func RouteLeaks(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.RouteLeaks(useCache)
+}
+
+func RoutesAsPathStats(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	table, err := ValidateProtocolParam(ps.ByName("table"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return bird.Parsed{"error": "limit must be a positive number"}, false
+		}
+	}
+
+	return bird.RoutesAsPathStats(useCache, table, limit)
+}
+
+func NextHopReachability(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	table, err := ValidateProtocolParam(ps.ByName("table"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	igpTable := "master"
+	if t := r.URL.Query().Get("igp_table"); t != "" {
+		igpTable, err = ValidateProtocolParam(t)
+		if err != nil {
+			return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		}
+	}
+
+	return bird.NextHopReachability(useCache, table, igpTable)
+}
+
+func RoutesTop(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	qs := r.URL.Query()
+
+	table := "master"
+	if t := qs.Get("table"); t != "" {
+		var err error
+		table, err = ValidateProtocolParam(t)
+		if err != nil {
+			return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		}
+	}
+
+	by := qs.Get("by")
+	if by == "" {
+		return bird.Parsed{"error": "by is required, e.g. age, as_path_length or prefix_length"}, false
+	}
+
+	n := 100
+	if value := qs.Get("n"); value != "" {
+		var err error
+		n, err = strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return bird.Parsed{"error": "n must be a positive number"}, false
+		}
+	}
+
+	return bird.RoutesTop(useCache, table, by, n, qs.Get("order") == "asc")
+}
@@ -0,0 +1,190 @@
+package endpoints
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// AS name resolution
+//
+// The names are loaded from a file with one AS per line,
+// e.g. the asn.txt published by the RIPE NCC:
+//
+//    3333 RIPE-NCC-AS Reseaux IP Europeens Network Coordination Centre (RIPE NCC), NL
+//
+// With ?resolve_asnames=true responses include the names of
+// all ASNs in AS paths and neighbor ASNs as as_names.
+
+type AsNamesConfig struct {
+	File string `toml:"file"`
+	// Download the file from this url every refresh_interval hours
+	URL             string `toml:"url"`
+	RefreshInterval int    `toml:"refresh_interval"`
+}
+
+var asNames = struct {
+	sync.RWMutex
+	names map[int64]string
+}{
+	names: map[int64]string{},
+}
+
+func parseAsNames(reader io.Reader) map[int64]string {
+	names := map[int64]string{}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		asn, err := bird.ParseAsn(fields[0])
+		if err != nil {
+			continue
+		}
+		names[asn] = strings.TrimSpace(fields[1])
+	}
+
+	return names
+}
+
+// LoadAsNames reads the AS names from the file
+func LoadAsNames(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	names := parseAsNames(f)
+
+	asNames.Lock()
+	asNames.names = names
+	asNames.Unlock()
+
+	log.Println("Loaded", len(names), "AS names from", filename)
+	return nil
+}
+
+func downloadAsNames(url string, filename string) error {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Could not download AS names: %s", resp.Status)
+	}
+
+	// Replace the file atomically
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".asnames")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// InstallAsNamesRefresh loads the AS names and
+// downloads updates if an url is configured.
+func InstallAsNamesRefresh(config AsNamesConfig) {
+	if config.File == "" {
+		return
+	}
+
+	if err := LoadAsNames(config.File); err != nil {
+		log.Println("Could not load AS names:", err)
+	}
+	if config.URL == "" {
+		return
+	}
+
+	interval := time.Duration(config.RefreshInterval) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	go func() {
+		for {
+			if err := downloadAsNames(config.URL, config.File); err != nil {
+				log.Println("Could not refresh AS names:", err)
+			} else if err := LoadAsNames(config.File); err != nil {
+				log.Println("Could not load AS names:", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Collect the ASNs of AS paths and neighbors in the result
+func collectAsns(value interface{}, asns map[int64]bool) {
+	switch v := value.(type) {
+	case bird.Parsed:
+		for key, item := range v {
+			switch key {
+			case "as_path":
+				if path, ok := item.([]string); ok {
+					for _, asn := range parseAsns(path) {
+						asns[asn] = true
+					}
+				}
+			case "neighbor_as", "local_as":
+				if asn, ok := item.(int64); ok {
+					asns[asn] = true
+				}
+			default:
+				collectAsns(item, asns)
+			}
+		}
+	case *bird.Parsed:
+		collectAsns(*v, asns)
+	case []bird.Parsed:
+		for _, item := range v {
+			collectAsns(item, asns)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectAsns(item, asns)
+		}
+	}
+}
+
+// resolveAsNames gets the names of the ASNs in the result
+func resolveAsNames(ret bird.Parsed) map[string]string {
+	asns := map[int64]bool{}
+	collectAsns(ret, asns)
+
+	asNames.RLock()
+	defer asNames.RUnlock()
+
+	res := map[string]string{}
+	for asn := range asns {
+		if name, ok := asNames.names[asn]; ok {
+			res[strconv.FormatInt(asn, 10)] = name
+		} else if bird.IsAsTrans(asn) {
+			res[strconv.FormatInt(asn, 10)] = "AS_TRANS"
+		}
+	}
+	return res
+}
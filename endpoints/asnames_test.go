@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestParseAsNames(t *testing.T) {
+	names := parseAsNames(strings.NewReader(
+		"3333 RIPE-NCC-AS Reseaux IP Europeens Network Coordination Centre (RIPE NCC), NL\n" +
+			"AS65001 Example Org\n" +
+			"invalid\n"))
+
+	if names[3333] != "RIPE-NCC-AS Reseaux IP Europeens Network Coordination Centre (RIPE NCC), NL" {
+		t.Error("Unexpected name:", names[3333])
+	}
+	if names[65001] != "Example Org" {
+		t.Error("Unexpected name:", names[65001])
+	}
+	if len(names) != 2 {
+		t.Error("Expected 2 names, got:", names)
+	}
+}
+
+func TestResolveAsNames(t *testing.T) {
+	asNames.names = map[int64]string{
+		65001: "Peer Org",
+		65002: "Origin Org",
+		65003: "Neighbor Org",
+	}
+	defer func() { asNames.names = map[int64]string{} }()
+
+	ret := bird.Parsed{
+		"routes": []bird.Parsed{
+			bird.Parsed{"bgp": bird.Parsed{"as_path": []string{"65001", "65002", "65099"}}},
+			bird.Parsed{"bgp": bird.Parsed{"as_path": []string{"65001", "23456"}}},
+		},
+		"protocols": bird.Parsed{
+			"R1": bird.Parsed{"neighbor_as": int64(65003)},
+		},
+	}
+
+	names := resolveAsNames(ret)
+	if len(names) != 4 || names["65002"] != "Origin Org" || names["65003"] != "Neighbor Org" {
+		t.Error("Unexpected AS names:", names)
+	}
+	if names["23456"] != "AS_TRANS" {
+		t.Error("Expected AS_TRANS to be labeled, got:", names["23456"])
+	}
+}
@@ -0,0 +1,60 @@
+package endpoints
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Get the bearer token from the authorization header
+func requestToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+}
+
+func isAdminToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	for _, adminToken := range Conf.AdminTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAdminAuth validates the bearer token of the request
+// against the configured admin tokens.
+func CheckAdminAuth(req *http.Request) error {
+	if len(Conf.AdminTokens) == 0 {
+		return fmt.Errorf("Admin endpoints are disabled: no admin_tokens configured.")
+	}
+
+	if !isAdminToken(requestToken(req)) {
+		log.Println("Rejecting unauthorized admin request from:", req.RemoteAddr)
+		return fmt.Errorf("Unauthorized.")
+	}
+
+	return nil
+}
+
+// AdminEndpoint wraps an endpoint requiring an admin token
+func AdminEndpoint(wrapped endpoint) httprouter.Handle {
+	handle := Endpoint(wrapped)
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if err := CheckAdminAuth(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		handle(w, r, ps)
+	}
+}
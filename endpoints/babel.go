@@ -0,0 +1,16 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+func BabelNeighbors(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.BabelNeighbors(useCache)
+}
+
+func BabelEntries(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.BabelEntries(useCache)
+}
@@ -0,0 +1,106 @@
+package endpoints
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Query cost accounting
+//
+// Every query answered by bird is charged to the client
+// with a cost of one unit per millisecond spent querying bird
+// and parsing the result, and one unit per KiB of parsed data.
+// Results served from the cache are free. A client exceeding
+// the hourly query_budget is rejected until the next hour.
+
+const budgetWindow = time.Hour
+
+type clientBudgets struct {
+	sync.Mutex
+	used  map[string]int
+	start time.Time
+}
+
+var budgets = &clientBudgets{
+	used: make(map[string]int),
+}
+
+func queryCost(elapsed time.Duration, bytes int) int {
+	return int(elapsed/time.Millisecond) + bytes/1024
+}
+
+// chargeQuery charges the query of a result not served from
+// the cache, including failed and aborted queries. The size
+// is the parsed birdc output, or the response if unknown.
+func chargeQuery(client string, ret bird.Parsed, elapsed time.Duration, responseBytes func() int) {
+	size, ok := bird.ParsedBytes(ret)
+	if !ok {
+		size = responseBytes()
+	}
+	budgets.Charge(client, queryCost(elapsed, size), time.Now())
+}
+
+// resetExpired starts a new accounting window
+// if the current one is over. The lock must be held.
+func (b *clientBudgets) resetExpired(now time.Time) {
+	if now.Sub(b.start) >= budgetWindow {
+		b.used = make(map[string]int)
+		b.start = now
+	}
+}
+
+// Exhausted checks if the client has used up its budget and
+// returns the time until the budget is renewed.
+func (b *clientBudgets) Exhausted(client string, budget int, now time.Time) (bool, time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	b.resetExpired(now)
+
+	if b.used[client] < budget {
+		return false, 0
+	}
+	return true, b.start.Add(budgetWindow).Sub(now)
+}
+
+// Charge adds the cost of a query to the used budget of the client
+func (b *clientBudgets) Charge(client string, cost int, now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+	b.resetExpired(now)
+
+	b.used[client] += cost
+}
+
+// checkBudget rejects the request with 429 Too Many Requests
+// if the client exceeded the query budget.
+func checkBudget(w http.ResponseWriter, client string) bool {
+	if Conf.QueryBudget <= 0 {
+		return true
+	}
+
+	exhausted, retry := budgets.Exhausted(client, Conf.QueryBudget, time.Now())
+	if !exhausted {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retry/time.Second)+1))
+	http.Error(w, "Query budget exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// countingWriter counts the bytes of the response
+type countingWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += n
+	return n, err
+}
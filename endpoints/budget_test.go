@@ -0,0 +1,78 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestQueryCost(t *testing.T) {
+	if cost := queryCost(250*time.Millisecond, 10*1024); cost != 260 {
+		t.Error("Expected cost 260, got:", cost)
+	}
+}
+
+func TestClientBudgets(t *testing.T) {
+	b := &clientBudgets{used: make(map[string]int)}
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if exhausted, _ := b.Exhausted("192.0.2.1", 100, now); exhausted {
+		t.Error("Expected a fresh budget")
+	}
+
+	b.Charge("192.0.2.1", 150, now)
+	exhausted, retry := b.Exhausted("192.0.2.1", 100, now.Add(10*time.Minute))
+	if !exhausted {
+		t.Error("Expected the budget to be exhausted")
+	}
+	if retry != 50*time.Minute {
+		t.Error("Unexpected retry duration:", retry)
+	}
+
+	// Other clients are not affected
+	if exhausted, _ := b.Exhausted("192.0.2.2", 100, now); exhausted {
+		t.Error("Expected budget of other client to be available")
+	}
+
+	// The budget is renewed after the window
+	if exhausted, _ := b.Exhausted("192.0.2.1", 100, now.Add(time.Hour)); exhausted {
+		t.Error("Expected the budget to be renewed")
+	}
+}
+
+func TestEndpointCharges(t *testing.T) {
+	Conf.QueryBudget = 1000000
+	bird.LimitsConf.MaxParseBytes = 100 * 1024
+	defer func() {
+		Conf.QueryBudget = 0
+		bird.LimitsConf.MaxParseBytes = 0
+		budgets = &clientBudgets{used: make(map[string]int)}
+	}()
+
+	results := []bird.Parsed{
+		bird.ParseSizeExceeded,
+		bird.Parsed{"routes": []bird.Parsed{}, bird.ParsedBytesField: 50 * 1024},
+	}
+	urls := []string{"/routes/table/master", "/routes/table/master?format=pb"}
+
+	for i, ret := range results {
+		budgets = &clientBudgets{used: make(map[string]int)}
+		ret := ret
+		handle := Endpoint(func(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+			return ret, false
+		})
+
+		req := httptest.NewRequest("GET", urls[i], nil)
+		req.RemoteAddr = "192.0.2.1:4242"
+		handle(httptest.NewRecorder(), req, nil)
+
+		expected := []int{100, 50}[i]
+		if used := budgets.used["192.0.2.1"]; used < expected {
+			t.Error("Expected at least", expected, "units to be charged, got:", used)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Caching headers
+
+// setCacheHeaders derives the Cache-Control, Expires and Age
+// headers from the TTL of the cached result, so a proxy in
+// front of birdwatcher expires the response together with
+// the internal cache. Responses depending on the caller are
+// only cacheable by the client itself.
+func setCacheHeaders(w http.ResponseWriter, ret bird.Parsed, useCache bool, now time.Time) {
+	if !Conf.CacheHeaders {
+		return
+	}
+
+	ttl, ok := ret["ttl"].(time.Time)
+	if !useCache || !ok || !ttl.After(now) {
+		w.Header().Set("Cache-Control", "no-cache")
+		return
+	}
+
+	cachedAt, ok := ret["cached_at"].(time.Time)
+	if !ok || cachedAt.After(now) {
+		cachedAt = now
+	}
+
+	maxAge := int(ttl.Sub(cachedAt) / time.Second)
+	age := int(now.Sub(cachedAt) / time.Second)
+
+	scope := "public"
+	if dependsOnCaller() {
+		scope = "private"
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, maxAge))
+	w.Header().Set("Age", strconv.Itoa(age))
+	w.Header().Set("Expires", ttl.UTC().Format(http.TimeFormat))
+	w.Header().Set("Last-Modified", cachedAt.UTC().Format(http.TimeFormat))
+}
+
+// dependsOnCaller is true if the access lists, admin tokens,
+// redaction, profiles or tenants make the response specific
+// to the client.
+func dependsOnCaller() bool {
+	return len(Conf.AllowFrom) > 0 || len(Conf.DenyFrom) > 0 ||
+		dependsOnAuthorization()
+}
+
+// dependsOnAuthorization is true if the response depends
+// on the token of the request.
+func dependsOnAuthorization() bool {
+	return len(Conf.AdminTokens) > 0 || RedactConf.Enabled ||
+		len(Profiles) > 0 || len(Tenants) > 0
+}
@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestSetCacheHeaders(t *testing.T) {
+	Conf.CacheHeaders = true
+	defer func() { Conf.CacheHeaders = false }()
+
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	ret := bird.Parsed{
+		"cached_at": now.Add(-2 * time.Minute),
+		"ttl":       now.Add(3 * time.Minute),
+	}
+
+	rec := httptest.NewRecorder()
+	setCacheHeaders(rec, ret, true, now)
+	if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		t.Error("Unexpected Cache-Control:", cc)
+	}
+	if age := rec.Header().Get("Age"); age != "120" {
+		t.Error("Unexpected Age:", age)
+	}
+	if exp := rec.Header().Get("Expires"); exp != now.Add(3*time.Minute).Format(http.TimeFormat) {
+		t.Error("Unexpected Expires:", exp)
+	}
+
+	// Uncached and expired results must not be cached
+	rec = httptest.NewRecorder()
+	setCacheHeaders(rec, ret, false, now)
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Error("Expected no-cache for uncached request, got:", cc)
+	}
+
+	rec = httptest.NewRecorder()
+	setCacheHeaders(rec, ret, true, now.Add(time.Hour))
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Error("Expected no-cache for expired result, got:", cc)
+	}
+}
+
+func TestSetCacheHeadersPrivate(t *testing.T) {
+	Conf.CacheHeaders = true
+	Conf.AdminTokens = []string{"secret"}
+	defer func() {
+		Conf.CacheHeaders = false
+		Conf.AdminTokens = nil
+	}()
+
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	ret := bird.Parsed{
+		"cached_at": now,
+		"ttl":       now.Add(5 * time.Minute),
+	}
+
+	rec := httptest.NewRecorder()
+	setCacheHeaders(rec, ret, true, now)
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, max-age=300" {
+		t.Error("Unexpected Cache-Control:", cc)
+	}
+}
@@ -0,0 +1,27 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Capabilities returns the detected BIRD version and capabilities
+func Capabilities(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.Capabilities(), false
+}
+
+// RequireCapability makes the endpoint available only if
+// the BIRD version has the capability.
+func RequireCapability(capability string, wrapped endpoint) endpoint {
+	return func(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+		if !bird.HasCapability(capability) {
+			return bird.Parsed{
+				"error":      "not supported by the BIRD version",
+				"capability": capability,
+			}, false
+		}
+		return wrapped(r, ps, useCache)
+	}
+}
@@ -0,0 +1,12 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+func Communities(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.Parsed{"communities": bird.Communities()}, false
+}
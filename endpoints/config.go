@@ -3,10 +3,102 @@ package endpoints
 // Endpoints / Server configuration
 type ServerConfig struct {
 	AllowFrom      []string `toml:"allow_from"`
+	DenyFrom       []string `toml:"deny_from"`
 	ModulesEnabled []string `toml:"modules_enabled"`
 	AllowUncached  bool     `toml:"allow_uncached"`
+	AdminTokens    []string `toml:"admin_tokens"`
+	RawCommands    []string `toml:"raw_commands"`
 
-	EnableTLS bool   `toml:"enable_tls"`
-	Crt       string `toml:"crt"`
-	Key       string `toml:"key"`
+	// Requests per minute and client with ?cache=bypass
+	// and an admin token (default 10)
+	CacheBypassLimit int `toml:"cache_bypass_limit"`
+
+	// Permission profile of requests on the listen address
+	// without a profile token
+	Profile string `toml:"profile"`
+
+	// Reject requests without a tenant or admin token
+	// if tenants are configured
+	RequireTenant bool `toml:"require_tenant"`
+
+	// Status of responses for paths of disabled
+	// modules: 404 (default) or 403
+	DisabledModuleStatus int `toml:"disabled_module_status"`
+
+	// Interval in seconds for resolving hostnames in
+	// allow_from and deny_from
+	ResolveInterval int `toml:"resolve_interval"`
+
+	// Timeouts in seconds, a negative value disables the timeout
+	ReadTimeout       int  `toml:"read_timeout"`
+	WriteTimeout      int  `toml:"write_timeout"`
+	IdleTimeout       int  `toml:"idle_timeout"`
+	MaxHeaderBytes    int  `toml:"max_header_bytes"`
+	DisableKeepAlives bool `toml:"disable_keep_alives"`
+
+	// Size of the response buffer and number of bytes
+	// after which the response is flushed to the client
+	ResponseBufferSize int `toml:"response_buffer_size"`
+	ResponseFlushBytes int `toml:"response_flush_bytes"`
+
+	// Emit Cache-Control and Expires headers derived
+	// from the cache TTL
+	CacheHeaders bool `toml:"cache_headers"`
+
+	// Hourly budget of query cost units per client,
+	// 0 disables the accounting
+	QueryBudget int `toml:"query_budget"`
+
+	// Maximum time in seconds a long polling request
+	// on /protocols is held
+	LongPollMaxWait int `toml:"long_poll_max_wait"`
+
+	// Number of response snapshots kept for answering
+	// ?diff_against=<etag> with a JSON Patch, 0 disables
+	DeltaSnapshots int `toml:"delta_snapshots"`
+
+	// Size of all snapshots in bytes (default 64 MiB)
+	DeltaSnapshotBytes int `toml:"delta_snapshot_bytes"`
+
+	// Naming of the response fields: snake (default) or
+	// camel, overridden by ?key_style=
+	KeyStyle string `toml:"key_style"`
+
+	// Render protocols, status and routes as HTML
+	// for clients accepting text/html
+	HTMLViews bool `toml:"html_views"`
+
+	EnableTLS    bool   `toml:"enable_tls"`
+	Crt          string `toml:"crt"`
+	Key          string `toml:"key"`
+	DisableHTTP2 bool   `toml:"disable_http2"`
+
+	// Passphrase of an encrypted PEM key
+	KeyPassphrase string `toml:"key_passphrase"`
+
+	// Minimum TLS version (1.0 - 1.3) and cipher suites for TLS 1.2
+	// and older. An OCSP response is stapled from the file.
+	TLSMinVersion   string   `toml:"tls_min_version"`
+	TLSCipherSuites []string `toml:"tls_cipher_suites"`
+	OCSPStapleFile  string   `toml:"ocsp_staple_file"`
+
+	// Unix socket listener (listen = "unix:/path"). Clients
+	// are authorized by UID or GID if allow_uids or allow_gids
+	// are set.
+	SocketMode  string `toml:"socket_mode"`
+	SocketOwner string `toml:"socket_owner"`
+	SocketGroup string `toml:"socket_group"`
+	AllowUids   []int  `toml:"allow_uids"`
+	AllowGids   []int  `toml:"allow_gids"`
+
+	// Drop privileges to this user and group after
+	// binding the listener and loading the certificates
+	User  string `toml:"user"`
+	Group string `toml:"group"`
+}
+
+// Custom endpoint configuration, keyed by path
+type CustomEndpointConfig struct {
+	Command string `toml:"command"`
+	Output  string `toml:"output"`
 }
@@ -0,0 +1,115 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Custom endpoints run a fixed birdc command configured
+// for the path. Path parameters are validated and
+// substituted for the {name} placeholders in the command.
+
+var CustomEndpointsConf map[string]CustomEndpointConfig
+
+var customCommandPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+func ValidateCustomParam(value string) (string, error) {
+	return ValidateLengthAndCharset(value, 80, "ABCDEFGHIJKLMNOPQRSTUVWXYZ_:./-abcdefghijklmnopqrstuvwxyz1234567890")
+}
+
+// Get the names of the parameters in a path, e.g. `protocol`
+// for `/ospf/neighbors/:protocol`
+func pathParams(path string) map[string]bool {
+	params := map[string]bool{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = true
+		}
+	}
+	return params
+}
+
+// Substitute the placeholders in the command
+func customCommand(command string, ps httprouter.Params) (string, error) {
+	var err error
+	cmd := customCommandPlaceholder.ReplaceAllStringFunc(command, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, verr := ValidateCustomParam(ps.ByName(name))
+		if verr != nil && err == nil {
+			err = fmt.Errorf("%s: %s", name, verr)
+		}
+		if value == "" && err == nil {
+			err = fmt.Errorf("%s: missing value", name)
+		}
+		return value
+	})
+	return cmd, err
+}
+
+func isWildcard(segment string) bool {
+	return strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")
+}
+
+// pathsConflict checks if the router can not register both
+// paths: a wildcard segment can not share its position with
+// a different segment, and paths can not be registered twice.
+func pathsConflict(a, b string) bool {
+	as := strings.Split(a, "/")
+	bs := strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if isWildcard(as[i]) || isWildcard(bs[i]) {
+			if as[i] != bs[i] || strings.HasPrefix(as[i], "*") {
+				return true
+			}
+			continue
+		}
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return len(as) == len(bs)
+}
+
+// CustomEndpoint creates the endpoint for a configured path.
+// The path must not conflict with the registered paths.
+func CustomEndpoint(path string, conf CustomEndpointConfig, registered []string) (endpoint, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+	for _, other := range registered {
+		if pathsConflict(path, other) {
+			return nil, fmt.Errorf("path conflicts with %s", other)
+		}
+	}
+	if strings.TrimSpace(conf.Command) == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+
+	output := conf.Output
+	if output == "" {
+		output = "lines"
+	}
+	if output != "lines" && output != "raw" {
+		return nil, fmt.Errorf("unknown output: %s", output)
+	}
+
+	params := pathParams(path)
+	for _, match := range customCommandPlaceholder.FindAllStringSubmatch(conf.Command, -1) {
+		if !params[match[1]] {
+			return nil, fmt.Errorf("unknown parameter in command: %s", match[1])
+		}
+	}
+
+	return func(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+		cmd, err := customCommand(conf.Command, ps)
+		if err != nil {
+			return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		}
+		return bird.RunCommand(useCache, cmd, output)
+	}, nil
+}
@@ -0,0 +1,79 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestCustomCommand(t *testing.T) {
+	ps := httprouter.Params{
+		httprouter.Param{Key: "protocol", Value: "ospf1"},
+	}
+
+	cmd, err := customCommand("show ospf neighbors {protocol}", ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "show ospf neighbors ospf1" {
+		t.Error("Unexpected command:", cmd)
+	}
+
+	ps[0].Value = "ospf1 all"
+	if _, err := customCommand("show ospf neighbors {protocol}", ps); err == nil {
+		t.Error("Expected an error for an invalid parameter")
+	}
+
+	if _, err := customCommand("show ospf neighbors {other}", ps); err == nil {
+		t.Error("Expected an error for a missing parameter")
+	}
+}
+
+func TestCustomEndpointValidation(t *testing.T) {
+	conf := CustomEndpointConfig{Command: "show ospf neighbors {protocol}"}
+	if _, err := CustomEndpoint("/ospf/neighbors/:protocol", conf, nil); err != nil {
+		t.Error("Expected a valid custom endpoint, got:", err)
+	}
+	if _, err := CustomEndpoint("/ospf/neighbors", conf, nil); err == nil {
+		t.Error("Expected an error for a placeholder without path parameter")
+	}
+
+	conf.Output = "json"
+	if _, err := CustomEndpoint("/ospf/neighbors/:protocol", conf, nil); err == nil {
+		t.Error("Expected an error for an unknown output")
+	}
+}
+
+func TestCustomEndpointConflicts(t *testing.T) {
+	conf := CustomEndpointConfig{Command: "show ospf neighbors {protocol}"}
+	registered := []string{
+		"/protocols/bgp",
+		"/routes/protocol/:protocol",
+		"/r/:router/*path",
+		"/ospf/neighbors/:protocol",
+	}
+
+	conflicts := []string{
+		"/protocols/:protocol",
+		"/routes/protocol/:name",
+		"/r/blue/:protocol",
+		"/ospf/neighbors/:protocol",
+		"/ospf/:protocol",
+	}
+	for _, path := range conflicts {
+		if _, err := CustomEndpoint(path, conf, registered); err == nil {
+			t.Error("Expected a conflict for", path)
+		}
+	}
+
+	valid := []string{
+		"/ospf/state/:protocol",
+		"/routes/protocol/:protocol/ospf",
+		"/protocols/bgp/:protocol",
+	}
+	for _, path := range valid {
+		if _, err := CustomEndpoint(path, conf, registered); err != nil {
+			t.Error("Expected no conflict for", path, "got:", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Deduplication of routes with ?dedupe=best, collapsing the
+// routes of a prefix to the best path, or ?group_by=prefix,
+// returning the paths of each prefix as prefixes.
+
+func dedupeResult(qs url.Values, res bird.Parsed) bird.Parsed {
+	dedupe := qs.Get("dedupe")
+	groupBy := qs.Get("group_by")
+	if (dedupe == "" && groupBy == "") || bird.IsSpecial(res) {
+		return res
+	}
+
+	if dedupe != "" && dedupe != "best" {
+		return bird.Parsed{"error": fmt.Sprintf("Invalid dedupe: %s", dedupe)}
+	}
+	if groupBy != "" && groupBy != "prefix" {
+		return bird.Parsed{"error": fmt.Sprintf("Invalid group_by: %s", groupBy)}
+	}
+
+	routes, ok := res["routes"].([]bird.Parsed)
+	if !ok {
+		return bird.Parsed{"error": "dedupe and group_by are only available for routes"}
+	}
+
+	ret := bird.Parsed{}
+	for k, v := range res {
+		ret[k] = v
+	}
+
+	if dedupe == "best" {
+		routes = bird.DedupeRoutes(routes)
+		ret["routes"] = routes
+	}
+	if groupBy == "prefix" {
+		delete(ret, "routes")
+		ret["prefixes"] = bird.GroupRoutesByPrefix(routes)
+	}
+	return ret
+}
@@ -0,0 +1,293 @@
+package endpoints
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Delta compression of repeated dumps
+//
+// Each response carries the ETag of its body. A client passing
+// `?diff_against=<etag>` of a response still held in the snapshot
+// store gets an RFC 6902 JSON Patch turning the former body into
+// the current one. The api block is not part of the body.
+//
+// The snapshots are kept per tenant (or profile), so a client
+// can only diff against responses of its own scope. The patch
+// is computed from the filtered and redacted responses.
+
+type patchOp struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// The value is omitted for remove operations only,
+// as null is a valid value to add.
+func (op patchOp) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(map[string]string{"op": op.Op, "path": op.Path})
+	}
+	return json.Marshal(map[string]interface{}{
+		"op":    op.Op,
+		"path":  op.Path,
+		"value": op.Value,
+	})
+}
+
+// Default size of all snapshots in bytes of their JSON encoding
+const defaultDeltaSnapshotBytes = 64 << 20
+
+func deltaSnapshotBytes() int {
+	if Conf.DeltaSnapshotBytes > 0 {
+		return Conf.DeltaSnapshotBytes
+	}
+	return defaultDeltaSnapshotBytes
+}
+
+type snapshot struct {
+	doc  interface{}
+	size int
+}
+
+// Snapshots of the latest responses, keyed by scope and ETag.
+// The oldest snapshot is evicted first.
+type snapshotStore struct {
+	sync.Mutex
+	order []string
+	docs  map[string]snapshot
+	size  int
+}
+
+var snapshots = newSnapshotStore()
+
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{docs: map[string]snapshot{}}
+}
+
+func (s *snapshotStore) Get(key string) (interface{}, bool) {
+	s.Lock()
+	defer s.Unlock()
+	snap, ok := s.docs[key]
+	return snap.doc, ok
+}
+
+// Add keeps at most max snapshots with a size of
+// at most maxBytes. Larger documents are not kept.
+func (s *snapshotStore) Add(key string, doc interface{}, size, max, maxBytes int) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.docs[key]; ok || size > maxBytes {
+		return
+	}
+
+	s.docs[key] = snapshot{doc: doc, size: size}
+	s.order = append(s.order, key)
+	s.size += size
+	for len(s.order) > max || s.size > maxBytes {
+		s.size -= s.docs[s.order[0]].size
+		delete(s.docs, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// snapshotScope separates the snapshots of tenants, profiles
+// and clients seeing unredacted responses.
+func snapshotScope(r *http.Request) string {
+	if name, tenant := requestTenant(r); tenant != nil {
+		return "tenant:" + name
+	}
+	if name, profile := requestProfile(r); profile != nil {
+		return "profile:" + name
+	}
+	if RedactConf.Enabled && isRedactToken(requestToken(r)) {
+		return "unredacted"
+	}
+	return ""
+}
+
+// snapshotWriter hashes the encoded response and keeps
+// the encoding only up to the size limit of the snapshots.
+type snapshotWriter struct {
+	hash     hash.Hash
+	size     int
+	buf      *bytes.Buffer
+	maxBytes int
+}
+
+func (s *snapshotWriter) Write(p []byte) (int, error) {
+	s.hash.Write(p)
+	s.size += len(p)
+	if s.buf != nil {
+		if s.size > s.maxBytes {
+			s.buf = nil // Too large for a snapshot
+		} else {
+			s.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// snapshotDocument encodes the response without the api block
+// and decodes it into a generic document. Responses larger than
+// maxBytes are only hashed, their document is nil.
+func snapshotDocument(res map[string]interface{}, maxBytes int) (string, interface{}, int, error) {
+	body := make(map[string]interface{}, len(res))
+	for k, v := range res {
+		if k != "api" {
+			body[k] = v
+		}
+	}
+
+	out := &snapshotWriter{
+		hash:     sha1.New(),
+		buf:      &bytes.Buffer{},
+		maxBytes: maxBytes,
+	}
+	if err := encodeJSONStream(out, body, 2); err != nil {
+		return "", nil, 0, err
+	}
+	tag := `"` + hex.EncodeToString(out.hash.Sum(nil)) + `"`
+	if out.buf == nil {
+		return tag, nil, out.size, nil
+	}
+
+	// Keep large numbers exact
+	var doc interface{}
+	dec := json.NewDecoder(out.buf)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return "", nil, 0, err
+	}
+	return tag, doc, out.size, nil
+}
+
+// deltaResponse stores the snapshot of the response and returns
+// either the response or the patch against the requested snapshot.
+// Responses exceeding the snapshot size are neither kept nor diffed.
+func deltaResponse(w http.ResponseWriter, r *http.Request, res map[string]interface{}) interface{} {
+	if Conf.DeltaSnapshots <= 0 {
+		return res
+	}
+
+	tag, doc, size, err := snapshotDocument(res, deltaSnapshotBytes())
+	if err != nil {
+		return res
+	}
+	scope := snapshotScope(r)
+	if doc != nil {
+		snapshots.Add(scope+tag, doc, size, Conf.DeltaSnapshots, deltaSnapshotBytes())
+	}
+
+	// The long polling of /protocols uses the ETag for the
+	// protocol state.
+	if w.Header().Get("ETag") == "" {
+		w.Header().Set("ETag", tag)
+	} else {
+		w.Header().Set("X-Snapshot-ETag", tag)
+	}
+
+	base := r.URL.Query().Get("diff_against")
+	if base == "" || doc == nil {
+		return res
+	}
+	if !strings.HasPrefix(base, `"`) {
+		base = `"` + base + `"`
+	}
+
+	former, ok := snapshots.Get(scope + base)
+	if !ok {
+		return res
+	}
+
+	if w.Header().Get("Content-Type") == "application/json" {
+		w.Header().Set("Content-Type", "application/json-patch+json")
+	}
+	return diffDocuments(former, doc, "", []patchOp{})
+}
+
+// Escape a key as JSON pointer reference token
+func pointerToken(key string) string {
+	key = strings.Replace(key, "~", "~0", -1)
+	return strings.Replace(key, "/", "~1", -1)
+}
+
+func diffDocuments(from, to interface{}, path string, ops []patchOp) []patchOp {
+	if reflect.DeepEqual(from, to) {
+		return ops
+	}
+
+	switch toValue := to.(type) {
+	case map[string]interface{}:
+		fromValue, ok := from.(map[string]interface{})
+		if !ok {
+			break
+		}
+		return diffObjects(fromValue, toValue, path, ops)
+	case []interface{}:
+		fromValue, ok := from.([]interface{})
+		if !ok {
+			break
+		}
+		return diffArrays(fromValue, toValue, path, ops)
+	}
+
+	return append(ops, patchOp{Op: "replace", Path: path, Value: to})
+}
+
+func diffObjects(from, to map[string]interface{}, path string, ops []patchOp) []patchOp {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + pointerToken(k)
+		fromValue, inFrom := from[k]
+		toValue, inTo := to[k]
+		switch {
+		case !inTo:
+			ops = append(ops, patchOp{Op: "remove", Path: p})
+		case !inFrom:
+			ops = append(ops, patchOp{Op: "add", Path: p, Value: toValue})
+		default:
+			ops = diffDocuments(fromValue, toValue, p, ops)
+		}
+	}
+	return ops
+}
+
+// Arrays are compared by position. Surplus elements are
+// removed from the end, so the indices stay valid.
+func diffArrays(from, to []interface{}, path string, ops []patchOp) []patchOp {
+	common := len(from)
+	if len(to) < common {
+		common = len(to)
+	}
+
+	for i := 0; i < common; i++ {
+		ops = diffDocuments(from[i], to[i], path+"/"+strconv.Itoa(i), ops)
+	}
+	for i := len(from) - 1; i >= common; i-- {
+		ops = append(ops, patchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+	for i := common; i < len(to); i++ {
+		ops = append(ops, patchOp{Op: "add", Path: path + "/-", Value: to[i]})
+	}
+	return ops
+}
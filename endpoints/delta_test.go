@@ -0,0 +1,145 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	decode := func(s string) interface{} {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(s), &doc); err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+
+	from := decode(`{"a": 1, "b": {"c/d": [1, 2, 3]}, "gone": true}`)
+	to := decode(`{"a": 2, "b": {"c/d": [1, 5]}, "new": null}`)
+
+	ops := diffDocuments(from, to, "", []patchOp{})
+	data, _ := json.Marshal(ops)
+
+	expected := `[{"op":"replace","path":"/a","value":2},` +
+		`{"op":"replace","path":"/b/c~1d/1","value":5},` +
+		`{"op":"remove","path":"/b/c~1d/2"},` +
+		`{"op":"remove","path":"/gone"},` +
+		`{"op":"add","path":"/new","value":null}]`
+	if string(data) != expected {
+		t.Error("Unexpected patch:", string(data))
+	}
+}
+
+func TestDeltaResponse(t *testing.T) {
+	Conf.DeltaSnapshots = 2
+	defer func() {
+		Conf.DeltaSnapshots = 0
+		snapshots = newSnapshotStore()
+	}()
+
+	first := map[string]interface{}{"api": "x", "routes": []string{"a"}}
+	rec := httptest.NewRecorder()
+	deltaResponse(rec, httptest.NewRequest("GET", "/routes", nil), first)
+	tag := rec.Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	second := map[string]interface{}{"api": "y", "routes": []string{"a", "b"}}
+	rec = httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	req := httptest.NewRequest("GET", "/routes?diff_against="+tag, nil)
+	body := deltaResponse(rec, req, second)
+
+	ops, ok := body.([]patchOp)
+	if !ok {
+		t.Fatal("Expected a patch, got:", body)
+	}
+	if len(ops) != 1 || ops[0].Op != "add" || ops[0].Path != "/routes/-" {
+		t.Error("Unexpected patch:", ops)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json-patch+json" {
+		t.Error("Unexpected content type:", ct)
+	}
+
+	// Unknown snapshots are answered with the full body
+	req = httptest.NewRequest("GET", "/routes?diff_against=unknown", nil)
+	body = deltaResponse(httptest.NewRecorder(), req, second)
+	if _, ok := body.(map[string]interface{}); !ok {
+		t.Error("Expected the full response, got:", body)
+	}
+}
+
+func TestDeltaResponseSnapshotBytes(t *testing.T) {
+	Conf.DeltaSnapshots = 2
+	Conf.DeltaSnapshotBytes = 32
+	defer func() {
+		Conf.DeltaSnapshots = 0
+		Conf.DeltaSnapshotBytes = 0
+		snapshots = newSnapshotStore()
+	}()
+
+	small := map[string]interface{}{"routes": []string{"a"}}
+	rec := httptest.NewRecorder()
+	deltaResponse(rec, httptest.NewRequest("GET", "/routes", nil), small)
+	tag := rec.Header().Get("ETag")
+
+	// Larger responses get an ETag, but are not diffed
+	large := map[string]interface{}{"routes": []string{"a", "b", "c", "d", "e", "f"}}
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/routes?diff_against="+tag, nil)
+	body := deltaResponse(rec, req, large)
+	if _, ok := body.(map[string]interface{}); !ok {
+		t.Error("Expected the full response, got:", body)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Error("Expected an ETag")
+	}
+	if len(snapshots.order) != 1 {
+		t.Error("Expected the large response not to be kept:", snapshots.order)
+	}
+}
+
+func TestSnapshotStoreBytes(t *testing.T) {
+	store := newSnapshotStore()
+	store.Add("a", "a", 40, 10, 100)
+	store.Add("b", "b", 40, 10, 100)
+	store.Add("c", "c", 40, 10, 100)
+	if _, ok := store.Get("a"); ok {
+		t.Error("Expected the oldest snapshot to be evicted")
+	}
+	if _, ok := store.Get("c"); !ok || store.size != 80 {
+		t.Error("Unexpected snapshots:", store.order, store.size)
+	}
+
+	store.Add("d", "d", 200, 10, 100)
+	if _, ok := store.Get("d"); ok {
+		t.Error("Expected an oversized snapshot not to be kept")
+	}
+}
+
+func TestDeltaResponseTenants(t *testing.T) {
+	Conf.DeltaSnapshots = 2
+	Tenants = map[string]TenantConfig{
+		"blue": TenantConfig{Tokens: []string{"blue-token"}},
+	}
+	defer func() {
+		Conf.DeltaSnapshots = 0
+		Tenants = nil
+		snapshots = newSnapshotStore()
+	}()
+
+	rec := httptest.NewRecorder()
+	res := map[string]interface{}{"routes": []string{"a", "b"}}
+	deltaResponse(rec, httptest.NewRequest("GET", "/routes", nil), res)
+	tag := rec.Header().Get("ETag")
+
+	// A tenant can not diff against the snapshots of others
+	req := httptest.NewRequest("GET", "/routes?diff_against="+tag, nil)
+	req.Header.Set("Authorization", "Bearer blue-token")
+	body := deltaResponse(httptest.NewRecorder(), req, map[string]interface{}{})
+	if _, ok := body.(map[string]interface{}); !ok {
+		t.Error("Expected the full response, got:", body)
+	}
+}
@@ -0,0 +1,548 @@
+package endpoints
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Response encodings
+//
+// Besides JSON, responses are encoded as MessagePack or CBOR
+// when requested with the Accept header. The binary encodings
+// follow the JSON representation, so all encodings share the
+// field names and formats. Browsers get HTML
+// views if html_views is enabled.
+
+type responseEncoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+// Encode streams the response: the entries of the top level
+// fields (e.g. each protocol or route) are encoded one by one,
+// so a large response is never held as a whole.
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	if err := encodeJSONStream(w, v, 2); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte("\n"))
+	return err
+}
+
+func encodeJSONStream(w io.Writer, v interface{}, depth int) error {
+	if depth > 0 {
+		switch value := v.(type) {
+		case bird.Parsed:
+			if value != nil {
+				return encodeJSONObject(w, value, depth)
+			}
+		case map[string]interface{}:
+			if value != nil {
+				return encodeJSONObject(w, value, depth)
+			}
+		case []bird.Parsed:
+			if value != nil {
+				items := make([]interface{}, len(value))
+				for i, item := range value {
+					items[i] = item
+				}
+				return encodeJSONArray(w, items, depth)
+			}
+		case []interface{}:
+			if value != nil {
+				return encodeJSONArray(w, value, depth)
+			}
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func encodeJSONObject(w io.Writer, m map[string]interface{}, depth int) error {
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i, k := range sortedDocumentKeys(m) {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		key, _ := json.Marshal(k)
+		w.Write(key)
+		io.WriteString(w, ":")
+		if err := encodeJSONStream(w, m[k], depth-1); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}")
+	return err
+}
+
+func encodeJSONArray(w io.Writer, items []interface{}, depth int) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, item := range items {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		if err := encodeJSONStream(w, item, depth-1); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// A binaryEncoder writes the values of the response as
+// their JSON representation would be decoded, directly to
+// the response writer.
+type binaryEncoder struct {
+	contentType string
+	writer      func(buf *bufio.Writer) documentWriter
+}
+
+func (e binaryEncoder) ContentType() string {
+	return e.contentType
+}
+
+func (e binaryEncoder) Encode(w io.Writer, v interface{}) error {
+	buf := bufio.NewWriter(w)
+	if err := writeDocument(e.writer(buf), v); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+var (
+	msgpackEncoder = binaryEncoder{"application/msgpack", newMsgpackWriter}
+	cborEncoder    = binaryEncoder{"application/cbor", newCborWriter}
+)
+
+// negotiateEncoder selects the encoder from the Accept header,
+// falling back to JSON.
+func negotiateEncoder(r *http.Request) responseEncoder {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accept, ";", 2)[0])
+		switch mediaType {
+		case "application/msgpack", "application/x-msgpack":
+			return msgpackEncoder
+		case "application/cbor":
+			return cborEncoder
+		case "application/json":
+			return jsonEncoder{}
+		case "text/html":
+			if Conf.HTMLViews {
+				return htmlEncoder{}
+			}
+		}
+	}
+	return jsonEncoder{}
+}
+
+func genericDocument(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&doc)
+	return doc, err
+}
+
+// Numbers are encoded as integers if possible
+func parseNumber(n json.Number) interface{} {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u
+	}
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return f
+}
+
+func sortedDocumentKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// documentWriter writes the values of a document
+type documentWriter interface {
+	Nil()
+	Bool(b bool)
+	Int(i int64)
+	Uint(u uint64)
+	Float(f float64)
+	String(s string)
+	// Array and Map start a container of n
+	// values or key value pairs
+	Array(n int)
+	Map(n int)
+}
+
+// writeDocument walks the value like encoding/json would
+// encode it. Values with a custom JSON representation,
+// e.g. times, are converted through JSON.
+func writeDocument(dw documentWriter, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		dw.Nil()
+		return nil
+	case bool:
+		dw.Bool(value)
+		return nil
+	case string:
+		dw.String(value)
+		return nil
+	case int:
+		dw.Int(int64(value))
+		return nil
+	case int64:
+		dw.Int(value)
+		return nil
+	case uint64:
+		dw.Uint(value)
+		return nil
+	case float64:
+		writeFloat(dw, value)
+		return nil
+	case json.Number:
+		switch n := parseNumber(value).(type) {
+		case int64:
+			dw.Int(n)
+		case uint64:
+			dw.Uint(n)
+		case float64:
+			writeFloat(dw, n)
+		}
+		return nil
+	case bird.Parsed:
+		if value == nil {
+			dw.Nil()
+			return nil
+		}
+		return writeDocumentMap(dw, value)
+	case map[string]interface{}:
+		if value == nil {
+			dw.Nil()
+			return nil
+		}
+		return writeDocumentMap(dw, value)
+	case []bird.Parsed:
+		if value == nil {
+			dw.Nil()
+			return nil
+		}
+		dw.Array(len(value))
+		for _, e := range value {
+			if err := writeDocument(dw, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if value == nil {
+			dw.Nil()
+			return nil
+		}
+		dw.Array(len(value))
+		for _, e := range value {
+			if err := writeDocument(dw, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.Marshaler, encoding.TextMarshaler:
+		return writeConverted(dw, v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		dw.Bool(rv.Bool())
+	case reflect.String:
+		dw.String(rv.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dw.Int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dw.Uint(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(dw, rv.Float())
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			dw.Nil()
+			return nil
+		}
+		return writeDocument(dw, rv.Elem().Interface())
+	case reflect.Slice:
+		if rv.IsNil() {
+			dw.Nil()
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return writeConverted(dw, v)
+		}
+		dw.Array(rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := writeDocument(dw, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		dw.Array(rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := writeDocument(dw, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return writeConverted(dw, v)
+		}
+		if rv.IsNil() {
+			dw.Nil()
+			return nil
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		dw.Map(len(keys))
+		for _, k := range keys {
+			dw.String(k)
+			value := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()))
+			if err := writeDocument(dw, value.Interface()); err != nil {
+				return err
+			}
+		}
+	default:
+		return writeConverted(dw, v)
+	}
+	return nil
+}
+
+func writeDocumentMap(dw documentWriter, m map[string]interface{}) error {
+	dw.Map(len(m))
+	for _, k := range sortedDocumentKeys(m) {
+		dw.String(k)
+		if err := writeDocument(dw, m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeConverted writes the generic document of the
+// JSON representation of the value
+func writeConverted(dw documentWriter, v interface{}) error {
+	doc, err := genericDocument(v)
+	if err != nil {
+		return err
+	}
+	return writeDocument(dw, doc)
+}
+
+// Floats are encoded as integers if possible,
+// like numbers decoded from JSON
+func writeFloat(dw documentWriter, f float64) {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		switch n := parseNumber(json.Number(strconv.FormatFloat(f, 'f', -1, 64))).(type) {
+		case int64:
+			dw.Int(n)
+			return
+		case uint64:
+			dw.Uint(n)
+			return
+		}
+	}
+	dw.Float(f)
+}
+
+func writeUint(buf *bufio.Writer, prefix byte, n uint64, size int) {
+	buf.WriteByte(prefix)
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	buf.Write(b[8-size:])
+}
+
+// MessagePack
+
+type msgpackWriter struct {
+	buf *bufio.Writer
+}
+
+func newMsgpackWriter(buf *bufio.Writer) documentWriter {
+	return msgpackWriter{buf}
+}
+
+func (m msgpackWriter) header(n int, fix, fixMax byte, b8, b16, b32 byte) {
+	switch {
+	case n <= int(fixMax):
+		m.buf.WriteByte(fix | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		writeUint(m.buf, b8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		writeUint(m.buf, b16, uint64(n), 2)
+	default:
+		writeUint(m.buf, b32, uint64(n), 4)
+	}
+}
+
+func (m msgpackWriter) Nil() {
+	m.buf.WriteByte(0xc0)
+}
+
+func (m msgpackWriter) Bool(b bool) {
+	if b {
+		m.buf.WriteByte(0xc3)
+	} else {
+		m.buf.WriteByte(0xc2)
+	}
+}
+
+func (m msgpackWriter) Int(i int64) {
+	switch {
+	case i >= 0:
+		m.Uint(uint64(i))
+	case i >= -32:
+		m.buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		writeUint(m.buf, 0xd0, uint64(i), 1)
+	case i >= math.MinInt16:
+		writeUint(m.buf, 0xd1, uint64(i), 2)
+	case i >= math.MinInt32:
+		writeUint(m.buf, 0xd2, uint64(i), 4)
+	default:
+		writeUint(m.buf, 0xd3, uint64(i), 8)
+	}
+}
+
+func (m msgpackWriter) Uint(u uint64) {
+	switch {
+	case u < 128:
+		m.buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		writeUint(m.buf, 0xcc, u, 1)
+	case u <= math.MaxUint16:
+		writeUint(m.buf, 0xcd, u, 2)
+	case u <= math.MaxUint32:
+		writeUint(m.buf, 0xce, u, 4)
+	default:
+		writeUint(m.buf, 0xcf, u, 8)
+	}
+}
+
+func (m msgpackWriter) Float(f float64) {
+	writeUint(m.buf, 0xcb, math.Float64bits(f), 8)
+}
+
+func (m msgpackWriter) String(s string) {
+	m.header(len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	m.buf.WriteString(s)
+}
+
+func (m msgpackWriter) Array(n int) {
+	m.header(n, 0x90, 15, 0, 0xdc, 0xdd)
+}
+
+func (m msgpackWriter) Map(n int) {
+	m.header(n, 0x80, 15, 0, 0xde, 0xdf)
+}
+
+// CBOR (RFC 7049)
+
+type cborWriter struct {
+	buf *bufio.Writer
+}
+
+func newCborWriter(buf *bufio.Writer) documentWriter {
+	return cborWriter{buf}
+}
+
+func (c cborWriter) header(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		c.buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		writeUint(c.buf, major|24, n, 1)
+	case n <= math.MaxUint16:
+		writeUint(c.buf, major|25, n, 2)
+	case n <= math.MaxUint32:
+		writeUint(c.buf, major|26, n, 4)
+	default:
+		writeUint(c.buf, major|27, n, 8)
+	}
+}
+
+func (c cborWriter) Nil() {
+	c.buf.WriteByte(0xf6)
+}
+
+func (c cborWriter) Bool(b bool) {
+	if b {
+		c.buf.WriteByte(0xf5)
+	} else {
+		c.buf.WriteByte(0xf4)
+	}
+}
+
+func (c cborWriter) Int(i int64) {
+	if i >= 0 {
+		c.header(0, uint64(i))
+	} else {
+		c.header(1, uint64(-1-i))
+	}
+}
+
+func (c cborWriter) Uint(u uint64) {
+	c.header(0, u)
+}
+
+func (c cborWriter) Float(f float64) {
+	writeUint(c.buf, 0xfb, math.Float64bits(f), 8)
+}
+
+func (c cborWriter) String(s string) {
+	c.header(3, uint64(len(s)))
+	c.buf.WriteString(s)
+}
+
+func (c cborWriter) Array(n int) {
+	c.header(4, uint64(n))
+}
+
+func (c cborWriter) Map(n int) {
+	c.header(5, uint64(n))
+}
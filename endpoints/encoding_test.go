@@ -0,0 +1,124 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestNegotiateEncoder(t *testing.T) {
+	tests := map[string]string{
+		"":                                   "application/json",
+		"application/msgpack":                "application/msgpack",
+		"text/html, application/cbor;q=0.9":  "application/cbor",
+		"application/json, application/cbor": "application/json",
+	}
+	for accept, expected := range tests {
+		req := httptest.NewRequest("GET", "/status", nil)
+		req.Header.Set("Accept", accept)
+		if ct := negotiateEncoder(req).ContentType(); ct != expected {
+			t.Error("Expected", expected, "for", accept, "got:", ct)
+		}
+	}
+}
+
+func TestBinaryEncoders(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": []interface{}{int64(1), int64(-200), 1.5, true, nil},
+		"b": "bird",
+	}
+
+	tests := []struct {
+		encoder  responseEncoder
+		expected []byte
+	}{
+		{msgpackEncoder, []byte{
+			0x82,
+			0xa1, 'a', 0x95, 0x01, 0xd1, 0xff, 0x38,
+			0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xc3, 0xc0,
+			0xa1, 'b', 0xa4, 'b', 'i', 'r', 'd',
+		}},
+		{cborEncoder, []byte{
+			0xa2,
+			0x61, 'a', 0x85, 0x01, 0x38, 0xc7,
+			0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xf5, 0xf6,
+			0x61, 'b', 0x64, 'b', 'i', 'r', 'd',
+		}},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		if err := test.encoder.Encode(buf, doc); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), test.expected) {
+			t.Errorf("Unexpected %s encoding: % x",
+				test.encoder.ContentType(), buf.Bytes())
+		}
+	}
+}
+
+func TestBinaryEncodersParsed(t *testing.T) {
+	res := bird.Parsed{
+		"routes": []bird.Parsed{{
+			"network": "10.0.0.0/8",
+			"metric":  100,
+			"bgp": bird.Parsed{
+				"as_path":     []string{"64496", "64497"},
+				"communities": [][]int64{{64496, 1}},
+				"local_pref":  float64(100),
+			},
+		}, nil},
+		"cached_at": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"counts":    map[string]int64{"imported": 1},
+		"empty":     bird.Parsed(nil),
+		"ratio":     0.5,
+	}
+
+	// The values are encoded like their JSON representation
+	doc, err := genericDocument(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, encoder := range []responseEncoder{msgpackEncoder, cborEncoder} {
+		direct := &bytes.Buffer{}
+		if err := encoder.Encode(direct, res); err != nil {
+			t.Fatal(err)
+		}
+		converted := &bytes.Buffer{}
+		if err := encoder.Encode(converted, doc); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(direct.Bytes(), converted.Bytes()) {
+			t.Errorf("Unexpected %s encoding: % x",
+				encoder.ContentType(), direct.Bytes())
+		}
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	res := map[string]interface{}{
+		"protocols": bird.Parsed{
+			"R1": bird.Parsed{"state": "up", "routes": bird.Parsed{"imported": 1}},
+			"R2": bird.Parsed{"state": "<down>"},
+		},
+		"routes":  []bird.Parsed{{"network": "10.0.0.0/8"}, nil},
+		"empty":   bird.Parsed(nil),
+		"ttl":     1,
+		"symbols": []interface{}{"a", 1},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := (jsonEncoder{}).Encode(buf, res); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := json.Marshal(res)
+	if buf.String() != string(expected)+"\n" {
+		t.Error("Unexpected stream:", buf.String())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"time"
 
 	"compress/gzip"
 	"encoding/json"
@@ -19,30 +20,26 @@ type endpoint func(*http.Request, httprouter.Params, bool) (bird.Parsed, bool)
 var Conf ServerConfig
 
 func CheckAccess(req *http.Request) error {
-	if len(Conf.AllowFrom) == 0 {
-		return nil // AllowFrom ALL
+	if isInternalRequest(req) {
+		return nil
 	}
 
-	// Extract IP
-	tokens := strings.Split(req.RemoteAddr, ":")
-	ip := strings.Join(tokens[:len(tokens)-1], ":")
-	ip = strings.Replace(ip, "[", "", -1)
-	ip = strings.Replace(ip, "]", "", -1)
-
-	// Check Access
-	for _, allowed := range Conf.AllowFrom {
-		if ip == allowed {
-			return nil
-		}
+	ip := remoteIP(req)
+	if isAccessAllowed(ip) {
+		return nil
 	}
 
 	// Log this request
-	log.Println("Rejecting access from:", ip)
+	log.Println("Rejecting access from:", req.RemoteAddr)
 
 	return fmt.Errorf("%s is not allowed to access this service.", ip)
 }
 
 func CheckUseCache(req *http.Request) bool {
+	if isInternalRequest(req) {
+		return false
+	}
+
 	qs := req.URL.Query()
 
 	if Conf.AllowUncached &&
@@ -50,9 +47,31 @@ func CheckUseCache(req *http.Request) bool {
 		return false
 	}
 
+	// Operators bypass the cache with an admin token
+	if qs.Get("cache") == "bypass" && isAdminToken(requestToken(req)) {
+		client := remoteIP(req).String()
+		if !cacheBypasses.allow("cache_bypass", client, cacheBypassLimit(), time.Now()) {
+			log.Println("Cache bypass limit exceeded by:", client)
+			return true
+		}
+		return false
+	}
+
 	return true
 }
 
+const defaultCacheBypassLimit = 10
+
+// Requests bypassing the cache per client in the current minute
+var cacheBypasses = &profileCounter{counts: map[string]int{}}
+
+func cacheBypassLimit() int {
+	if Conf.CacheBypassLimit > 0 {
+		return Conf.CacheBypassLimit
+	}
+	return defaultCacheBypassLimit
+}
+
 func Endpoint(wrapped endpoint) httprouter.Handle {
 	return func(w http.ResponseWriter,
 		r *http.Request,
@@ -64,45 +83,123 @@ func Endpoint(wrapped endpoint) httprouter.Handle {
 			return
 		}
 
+		client := remoteIP(r).String()
+		if !isInternalRequest(r) && !checkBudget(w, client) {
+			return
+		}
+
 		res := make(map[string]interface{})
 
 		useCache := CheckUseCache(r)
+		queryStart := time.Now()
 		ret, from_cache := wrapped(r, ps, useCache)
+		queryTime := time.Since(queryStart)
+
+		// Charge every query, also if it failed or exceeded a limit
+		counter := &countingWriter{}
+		if !from_cache && Conf.QueryBudget > 0 {
+			parsed := ret
+			defer chargeQuery(client, parsed, queryTime, func() int { return counter.count })
+		}
 
 		if reflect.DeepEqual(ret, bird.NilParse) {
 			w.WriteHeader(http.StatusTooManyRequests)
 			return
 		}
-		if reflect.DeepEqual(ret, bird.BirdError) {
+		if reflect.DeepEqual(ret, bird.BirdError) || reflect.DeepEqual(ret, bird.ParserError) {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Header().Set("Content-Type", "application/json")
 			js, _ := json.Marshal(ret)
 			w.Write(js)
 			return
 		}
+		if bird.IsLimitExceeded(ret) {
+			writeLimitExceeded(w, ret)
+			return
+		}
+		if isInvalidNet(ret) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			js, _ := json.Marshal(ret)
+			w.Write(js)
+			return
+		}
+		ret = tenantResult(r, ps, ret)
+		ret = pathsResult(r.URL.Query(), ret)
+		ret = sortResult(r.URL.Query(), ret)
+		ret = dedupeResult(r.URL.Query(), ret)
+		ret = redactResult(r, ret)
+
+		switch r.URL.Query().Get("format") {
+		case "exabgp":
+			writeExabgp(w, ret)
+			return
+		case "pb":
+			writeProtobuf(w, ret)
+			return
+		}
+
 		res["api"] = GetApiInfo(&ret, from_cache)
 
 		for k, v := range ret {
-			res[k] = v
+			if k != bird.ParsedBytesField {
+				res[k] = v
+			}
 		}
+		if r.URL.Query().Get("resolve_asnames") == "true" {
+			res["as_names"] = resolveAsNames(ret)
+		}
+		if RdnsConf.Enabled {
+			res["hostnames"] = resolveHostnames(ret)
+		}
+
+		encoder := negotiateEncoder(r)
+		w.Header().Set("Content-Type", encoder.ContentType())
+		w.Header().Set("Vary", "Accept")
+		w.Header().Add("Vary", "Accept-Encoding")
+		if dependsOnAuthorization() {
+			w.Header().Add("Vary", "Authorization")
+		}
+		setCacheHeaders(w, ret, useCache, time.Now())
+		// The HTML views use the field names of the parser
+		styled := res
+		if _, html := encoder.(htmlEncoder); !html {
+			styled = styleKeys(r, res)
+		}
+		body := deltaResponse(w, r, styled)
 
-		w.Header().Set("Content-Type", "application/json")
+		out := bufferedResponse(w)
+		defer out.Flush()
+
+		counter.w = out
 
 		// Check if compression is supported
 		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 			// Compress response
 			w.Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(w)
+			gz := gzip.NewWriter(out)
 			defer gz.Close()
-			json := json.NewEncoder(gz)
-			json.Encode(res)
+			counter.w = gz
+			encoder.Encode(counter, body)
 		} else {
-			json := json.NewEncoder(w)
-			json.Encode(res) // Fall back to uncompressed response
+			encoder.Encode(counter, body) // Fall back to uncompressed response
 		}
 	}
 }
 
+// Queries exceeding the parse size are rejected with 413,
+// queries exceeding the wall time with 504.
+func writeLimitExceeded(w http.ResponseWriter, ret bird.Parsed) {
+	status := http.StatusGatewayTimeout
+	if reflect.DeepEqual(ret, bird.ParseSizeExceeded) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	js, _ := json.Marshal(ret)
+	w.Write(js)
+}
+
 func Version(version string) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		w.Header().Set("Content-Type", "text/plain")
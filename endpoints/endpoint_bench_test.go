@@ -0,0 +1,76 @@
+package endpoints
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Benchmarks of requests through the endpoints, answered
+// from captured birdc output.
+// Run with: go test -run=NONE -bench=. ./endpoints/
+
+const benchRoutesCommand = "route table master4 all" +
+	" where net.type = NET_IP4 || net.type = NET_VPN4" +
+	" || net.type = NET_FLOW4 || net.type = NET_MPLS"
+
+func setupEndpointBenchmark(b *testing.B) func() {
+	dir, err := ioutil.TempDir("", "birdwatcher-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	sample, err := ioutil.ReadFile("../test/routes_bird2_ipv4.sample")
+	if err != nil {
+		b.Fatal(err)
+	}
+	fixture := filepath.Join(dir, bird.FixtureName(benchRoutesCommand))
+	if err := ioutil.WriteFile(fixture, sample, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	bird.MockDir = dir
+	bird.BirdVersion = 2
+	bird.IPVersion = "4"
+	bird.InitializeCache()
+	Conf.AllowUncached = true
+
+	return func() {
+		bird.MockDir = ""
+		Conf.AllowUncached = false
+		os.RemoveAll(dir)
+	}
+}
+
+func benchmarkEndpoint(b *testing.B, url string) {
+	defer setupEndpointBenchmark(b)()
+
+	handler := Endpoint(TableRoutes)
+	params := httprouter.Params{{Key: "table", Value: "master"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest("GET", url, nil), params)
+			if rec.Code != 200 {
+				b.Fatal("Unexpected status:", rec.Code, rec.Body.String())
+			}
+		}
+	})
+}
+
+func BenchmarkEndpointRoutesTableCached(b *testing.B) {
+	benchmarkEndpoint(b, "/routes/table/master")
+}
+
+func BenchmarkEndpointRoutesTableUncached(b *testing.B) {
+	benchmarkEndpoint(b, "/routes/table/master?uncached=true")
+}
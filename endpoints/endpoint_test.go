@@ -0,0 +1,88 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestCheckUseCacheBypass(t *testing.T) {
+	formerConf := Conf
+	defer func() { Conf = formerConf }()
+	Conf.AdminTokens = []string{"admin-secret"}
+	Conf.CacheBypassLimit = 2
+	cacheBypasses = &profileCounter{counts: map[string]int{}}
+
+	request := func(token string) bool {
+		req := httptest.NewRequest("GET", "/routes/table/master?cache=bypass", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return CheckUseCache(req)
+	}
+
+	if !request("") || !request("other") {
+		t.Error("Expected the cache for clients without an admin token")
+	}
+	if request("admin-secret") || request("admin-secret") {
+		t.Error("Expected the cache to be bypassed with an admin token")
+	}
+	if !request("admin-secret") {
+		t.Error("Expected the cache after the bypass limit")
+	}
+}
+
+func TestPathsResult(t *testing.T) {
+	res := bird.Parsed{"routes": []bird.Parsed{
+		{"network": "10.0.0.0/8", "from_protocol": "R1", "path_id": int64(1)},
+		{"network": "10.0.0.0/8", "from_protocol": "R1", "path_id": int64(2)},
+	}}
+
+	best := pathsResult(url.Values{}, res)
+	if routes := best["routes"].([]bird.Parsed); len(routes) != 1 {
+		t.Error("Expected the best path only, got:", routes)
+	}
+	if best["hidden_paths"] != 1 {
+		t.Error("Expected 1 hidden path, got:", best["hidden_paths"])
+	}
+	if routes := res["routes"].([]bird.Parsed); len(routes) != 2 {
+		t.Error("Expected the result not to be modified")
+	}
+
+	all := pathsResult(url.Values{"all_paths": {"true"}}, res)
+	if routes := all["routes"].([]bird.Parsed); len(routes) != 2 {
+		t.Error("Expected all paths, got:", routes)
+	}
+
+	invalid := pathsResult(url.Values{"all_paths": {"yes"}}, res)
+	if _, ok := invalid["error"]; !ok {
+		t.Error("Expected an error for an invalid all_paths")
+	}
+}
+
+func TestEndpointInvalidNet(t *testing.T) {
+	handle := Endpoint(RouteNet)
+
+	req := httptest.NewRequest("GET", "/route/net/192.0.2", nil)
+	rec := httptest.NewRecorder()
+	handle(rec, req, httprouter.Params{{Key: "net", Value: "192.0.2"}})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Error("Expected status 400, got:", rec.Code)
+	}
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["error"] != "Invalid prefix or address: 192.0.2" {
+		t.Error("Unexpected error:", res["error"])
+	}
+	if formats, _ := res["accepted_formats"].([]interface{}); len(formats) != len(netFormats) {
+		t.Error("Expected the accepted formats, got:", res["accepted_formats"])
+	}
+}
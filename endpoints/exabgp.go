@@ -0,0 +1,171 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Rendering of routes as exabgp JSON update messages,
+// one message per line.
+
+type exabgpMessage struct {
+	Exabgp   string         `json:"exabgp"`
+	Time     float64        `json:"time"`
+	Host     string         `json:"host"`
+	Counter  int            `json:"counter"`
+	Type     string         `json:"type"`
+	Neighbor exabgpNeighbor `json:"neighbor"`
+}
+
+type exabgpNeighbor struct {
+	Address   map[string]string      `json:"address"`
+	Asn       map[string]int64       `json:"asn"`
+	Direction string                 `json:"direction"`
+	Message   map[string]interface{} `json:"message"`
+}
+
+type exabgpNlri struct {
+	Nlri string `json:"nlri"`
+}
+
+func exabgpFamily(network string) string {
+	if strings.Contains(network, ":") {
+		return "ipv6 unicast"
+	}
+	return "ipv4 unicast"
+}
+
+func parseAsns(path []string) []int64 {
+	res := []int64{}
+	for _, asn := range path {
+		if value, err := bird.ParseAsn(asn); err == nil {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+func exabgpAttributes(bgp bird.Parsed) map[string]interface{} {
+	attributes := map[string]interface{}{}
+
+	if origin, ok := bgp["origin"].(string); ok {
+		attributes["origin"] = strings.ToLower(origin)
+	}
+	if path, ok := bgp["as_path"].([]string); ok {
+		attributes["as-path"] = parseAsns(path)
+	}
+	if med, ok := bgp["med"].(string); ok {
+		if value, err := strconv.ParseInt(med, 10, 64); err == nil {
+			attributes["med"] = value
+		}
+	}
+	if localPref, ok := bgp["local_pref"].(string); ok {
+		if value, err := strconv.ParseInt(localPref, 10, 64); err == nil {
+			attributes["local-preference"] = value
+		}
+	}
+	if communities, ok := bgp["communities"]; ok {
+		attributes["community"] = communities
+	}
+	if communities, ok := bgp["large_communities"]; ok {
+		attributes["large-community"] = communities
+	}
+	if communities, ok := bgp["ext_communities"].([]interface{}); ok {
+		extended := []map[string]string{}
+		for _, c := range communities {
+			parts, ok := c.([]interface{})
+			if !ok || len(parts) != 3 {
+				continue
+			}
+			extended = append(extended, map[string]string{
+				"string": strings.Join([]string{
+					parts[0].(string), parts[1].(string), parts[2].(string),
+				}, ":"),
+			})
+		}
+		attributes["extended-community"] = extended
+	}
+
+	return attributes
+}
+
+// exabgpUpdates converts parsed routes into exabgp update messages
+func exabgpUpdates(routes []bird.Parsed, now time.Time) []exabgpMessage {
+	messages := []exabgpMessage{}
+
+	for i, route := range routes {
+		network, _ := route["network"].(string)
+		bgp, ok := route["bgp"].(bird.Parsed)
+		if !ok || network == "" {
+			continue // Only BGP routes can be announced
+		}
+
+		nextHop := ""
+		if nh, ok := bgp["next_hop"].(string); ok {
+			if fields := strings.Fields(nh); len(fields) > 0 {
+				nextHop = fields[0]
+			}
+		}
+
+		peer, _ := route["learnt_from"].(string)
+		if peer == "" {
+			peer, _ = route["gateway"].(string)
+		}
+
+		peerAsn := int64(0)
+		if path, ok := bgp["as_path"].([]string); ok {
+			if asns := parseAsns(path); len(asns) > 0 {
+				peerAsn = asns[0]
+			}
+		}
+
+		update := map[string]interface{}{
+			"attribute": exabgpAttributes(bgp),
+			"announce": map[string]interface{}{
+				exabgpFamily(network): map[string][]exabgpNlri{
+					nextHop: []exabgpNlri{{Nlri: network}},
+				},
+			},
+		}
+
+		messages = append(messages, exabgpMessage{
+			Exabgp:  "4.0.1",
+			Time:    float64(now.UnixNano()) / float64(time.Second),
+			Host:    "birdwatcher",
+			Counter: i + 1,
+			Type:    "update",
+			Neighbor: exabgpNeighbor{
+				Address:   map[string]string{"peer": peer},
+				Asn:       map[string]int64{"peer": peerAsn},
+				Direction: "receive",
+				Message:   map[string]interface{}{"update": update},
+			},
+		})
+	}
+
+	return messages
+}
+
+// Write the routes of the result as exabgp messages
+func writeExabgp(w http.ResponseWriter, res bird.Parsed) {
+	routes, ok := res["routes"].([]bird.Parsed)
+	if !ok {
+		http.Error(w, "exabgp format is only available for routes", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	out := bufferedResponse(w)
+	defer out.Flush()
+
+	encoder := json.NewEncoder(out)
+	for _, message := range exabgpUpdates(routes, time.Now()) {
+		encoder.Encode(message)
+	}
+}
@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestExabgpUpdates(t *testing.T) {
+	routes := []bird.Parsed{
+		bird.Parsed{
+			"network": "192.0.2.0/24",
+			"gateway": "10.0.0.1",
+			"bgp": bird.Parsed{
+				"origin":      "IGP",
+				"as_path":     []string{"64500", "64510"},
+				"next_hop":    "10.0.0.1",
+				"local_pref":  "100",
+				"communities": [][]int64{{64500, 1}},
+			},
+		},
+		bird.Parsed{
+			"network": "2001:db8::/32",
+			"gateway": "fe80::1",
+			"bgp": bird.Parsed{
+				"as_path":  []string{"64501"},
+				"next_hop": "2001:db8:ffff::1 fe80::1",
+			},
+		},
+		bird.Parsed{
+			"network": "198.51.100.0/24", // Not a BGP route
+		},
+	}
+
+	messages := exabgpUpdates(routes, time.Unix(0, 0))
+	if len(messages) != 2 {
+		t.Fatal("Expected 2 messages, got:", len(messages))
+	}
+
+	buf, _ := json.Marshal(messages[0])
+	expected := `{"exabgp":"4.0.1","time":0,"host":"birdwatcher","counter":1,"type":"update","neighbor":{"address":{"peer":"10.0.0.1"},"asn":{"peer":64500},"direction":"receive","message":{"update":{"announce":{"ipv4 unicast":{"10.0.0.1":[{"nlri":"192.0.2.0/24"}]}},"attribute":{"as-path":[64500,64510],"community":[[64500,1]],"local-preference":100,"origin":"igp"}}}}}`
+	if string(buf) != expected {
+		t.Error("Unexpected exabgp message:", string(buf))
+	}
+
+	update := messages[1].Neighbor.Message["update"].(map[string]interface{})
+	announce := update["announce"].(map[string]interface{})
+	if _, ok := announce["ipv6 unicast"]; !ok {
+		t.Error("Expected an ipv6 unicast announcement:", announce)
+	}
+}
@@ -0,0 +1,188 @@
+package endpoints
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Export of the accepted routes of a peer
+// as router configuration snippets.
+
+type routesRenderer func(w io.Writer, name string, routes []bird.Parsed)
+
+var prefixListRenderers = map[string]routesRenderer{
+	"ios":   renderPrefixListIOS,
+	"junos": renderPrefixListJunos,
+	"bird":  renderPrefixListBird,
+}
+
+// Characters not allowed in names of all formats
+var configNameSeparator = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Get the prefixes of the routes, sorted and
+// without duplicates. Routes without an IP
+// network (e.g. flowspec) are skipped.
+func routePrefixes(routes []bird.Parsed) ([]string, []string) {
+	seen := map[string]bool{}
+	ipv4 := []string{}
+	ipv6 := []string{}
+
+	for _, route := range routes {
+		network, _ := route["network"].(string)
+		if seen[network] {
+			continue
+		}
+		seen[network] = true
+
+		ip, _, err := net.ParseCIDR(network)
+		if err != nil {
+			continue
+		}
+		if ip.To4() != nil {
+			ipv4 = append(ipv4, network)
+		} else {
+			ipv6 = append(ipv6, network)
+		}
+	}
+
+	sortPrefixes(ipv4)
+	sortPrefixes(ipv6)
+	return ipv4, ipv6
+}
+
+// Order prefixes by address and prefix length
+func sortPrefixes(prefixes []string) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		return bird.PrefixLess(prefixes[i], prefixes[j])
+	})
+}
+
+func renderPrefixListIOS(w io.Writer, name string, routes []bird.Parsed) {
+	ipv4, ipv6 := routePrefixes(routes)
+	for i, prefix := range ipv4 {
+		fmt.Fprintf(w, "ip prefix-list %s seq %d permit %s\n", name, (i+1)*5, prefix)
+	}
+	for i, prefix := range ipv6 {
+		fmt.Fprintf(w, "ipv6 prefix-list %s-v6 seq %d permit %s\n", name, (i+1)*5, prefix)
+	}
+}
+
+func renderPrefixListJunos(w io.Writer, name string, routes []bird.Parsed) {
+	ipv4, ipv6 := routePrefixes(routes)
+	fmt.Fprintf(w, "policy-options {\n")
+	fmt.Fprintf(w, "    prefix-list %s {\n", name)
+	for _, prefix := range append(ipv4, ipv6...) {
+		fmt.Fprintf(w, "        %s;\n", prefix)
+	}
+	fmt.Fprintf(w, "    }\n")
+	fmt.Fprintf(w, "}\n")
+}
+
+func renderPrefixListBird(w io.Writer, name string, routes []bird.Parsed) {
+	ipv4, ipv6 := routePrefixes(routes)
+	sets := []struct {
+		suffix   string
+		prefixes []string
+	}{
+		{"_v4", ipv4},
+		{"_v6", ipv6},
+	}
+
+	for _, set := range sets {
+		if len(set.prefixes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "define %s%s = [\n", name, set.suffix)
+		fmt.Fprintf(w, "    %s\n", strings.Join(set.prefixes, ",\n    "))
+		fmt.Fprintf(w, "];\n")
+	}
+}
+
+// exportProtocolRoutes renders the routes of the protocol
+// with the renderer selected by the format parameter.
+func exportProtocolRoutes(renderers map[string]routesRenderer, defaultFormat string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		protocol, err := ValidateProtocolParam(ps.ByName("protocol"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = defaultFormat
+		}
+		render, ok := renderers[format]
+		if !ok {
+			http.Error(w, "Unknown format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = protocol
+		}
+		name = configNameSeparator.ReplaceAllString(name, "_")
+
+		fetch := func(useCache bool) (bird.Parsed, bool) {
+			return bird.RoutesProto(useCache, protocol)
+		}
+		writeRoutesExport(w, r, ps, fetch, func(out io.Writer, routes []bird.Parsed) {
+			render(out, name, routes)
+		})
+	}
+}
+
+// writeRoutesExport renders the routes of the result as text.
+// Like Endpoint, the query is charged to the budget of the
+// client and the routes are filtered for the tenant and redacted.
+func writeRoutesExport(w http.ResponseWriter, r *http.Request, ps httprouter.Params,
+	fetch func(useCache bool) (bird.Parsed, bool), render func(io.Writer, []bird.Parsed)) {
+	if err := CheckAccess(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	client := remoteIP(r).String()
+	if !isInternalRequest(r) && !checkBudget(w, client) {
+		return
+	}
+
+	queryStart := time.Now()
+	res, from_cache := fetch(CheckUseCache(r))
+	queryTime := time.Since(queryStart)
+
+	counter := &countingWriter{}
+	if !from_cache && Conf.QueryBudget > 0 {
+		parsed := res
+		defer chargeQuery(client, parsed, queryTime, func() int { return counter.count })
+	}
+
+	if bird.IsSpecial(res) {
+		http.Error(w, "Could not get the routes", http.StatusServiceUnavailable)
+		return
+	}
+	res = tenantResult(r, ps, res)
+	res = redactResult(r, res)
+	routes, _ := res["routes"].([]bird.Parsed)
+
+	w.Header().Set("Content-Type", "text/plain")
+	out := bufferedResponse(w)
+	defer out.Flush()
+
+	counter.w = out
+	render(counter, routes)
+}
+
+// PrefixListExport renders the accepted prefixes of a
+// peer as ios, junos or bird prefix list.
+var PrefixListExport = exportProtocolRoutes(prefixListRenderers, "bird")
@@ -0,0 +1,97 @@
+package endpoints
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+var exportRoutes = []bird.Parsed{
+	bird.Parsed{"network": "10.0.10.0/24"},
+	bird.Parsed{"network": "10.0.1.0/24"},
+	bird.Parsed{"network": "10.0.0.0/24"},
+	bird.Parsed{"network": "10.0.0.0/24"},
+	bird.Parsed{"network": "2001:db8::/32"},
+	bird.Parsed{"network": "flow4 { dst 10.0.0.0/24; }"},
+}
+
+func TestRenderPrefixListIOS(t *testing.T) {
+	buf := &bytes.Buffer{}
+	renderPrefixListIOS(buf, "R1", exportRoutes)
+
+	expected := "ip prefix-list R1 seq 5 permit 10.0.0.0/24\n" +
+		"ip prefix-list R1 seq 10 permit 10.0.1.0/24\n" +
+		"ip prefix-list R1 seq 15 permit 10.0.10.0/24\n" +
+		"ipv6 prefix-list R1-v6 seq 5 permit 2001:db8::/32\n"
+	if buf.String() != expected {
+		t.Error("Unexpected ios prefix list:", buf.String())
+	}
+}
+
+func TestRenderPrefixListJunos(t *testing.T) {
+	buf := &bytes.Buffer{}
+	renderPrefixListJunos(buf, "R1", exportRoutes)
+
+	expected := "policy-options {\n" +
+		"    prefix-list R1 {\n" +
+		"        10.0.0.0/24;\n" +
+		"        10.0.1.0/24;\n" +
+		"        10.0.10.0/24;\n" +
+		"        2001:db8::/32;\n" +
+		"    }\n" +
+		"}\n"
+	if buf.String() != expected {
+		t.Error("Unexpected junos prefix list:", buf.String())
+	}
+}
+
+func TestRenderPrefixListBird(t *testing.T) {
+	buf := &bytes.Buffer{}
+	renderPrefixListBird(buf, "R1", exportRoutes)
+
+	expected := "define R1_v4 = [\n" +
+		"    10.0.0.0/24,\n" +
+		"    10.0.1.0/24,\n" +
+		"    10.0.10.0/24\n" +
+		"];\n" +
+		"define R1_v6 = [\n" +
+		"    2001:db8::/32\n" +
+		"];\n"
+	if buf.String() != expected {
+		t.Error("Unexpected bird prefix set:", buf.String())
+	}
+}
+
+func TestWriteRoutesExportTenant(t *testing.T) {
+	Tenants = map[string]TenantConfig{
+		"blue": TenantConfig{Tokens: []string{"blue-token"}, Protocols: []string{"R1"}},
+	}
+	defer func() { Tenants = nil }()
+
+	fetch := func(useCache bool) (bird.Parsed, bool) {
+		return bird.Parsed{"routes": []bird.Parsed{
+			bird.Parsed{"network": "10.0.0.0/24", "from_protocol": "R1"},
+			bird.Parsed{"network": "10.0.1.0/24", "from_protocol": "R2"},
+		}}, false
+	}
+
+	req := httptest.NewRequest("GET", "/export/prefix-list/R1", nil)
+	req.Header.Set("Authorization", "Bearer blue-token")
+	rendered := []bird.Parsed{}
+	writeRoutesExport(httptest.NewRecorder(), req, nil, fetch, func(out io.Writer, routes []bird.Parsed) {
+		rendered = routes
+	})
+	if len(rendered) != 1 || rendered[0]["from_protocol"] != "R1" {
+		t.Error("Expected only the routes of the tenant, got:", rendered)
+	}
+}
+
+func TestConfigNameSeparator(t *testing.T) {
+	name := configNameSeparator.ReplaceAllString("AS64500-peer 1", "_")
+	if name != "AS64500_peer_1" {
+		t.Error("Unexpected name:", name)
+	}
+}
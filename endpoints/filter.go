@@ -2,6 +2,10 @@ package endpoints
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
 )
 
 /*
@@ -50,6 +54,79 @@ func ValidateProtocolParam(value string) (string, error) {
 	return ValidateLengthAndCharset(value, 80, "ABCDEFGHIJKLMNOPQRSTUVWXYZ_:.abcdefghijklmnopqrstuvwxyz1234567890")
 }
 
+// ValidateProtocolPatternParam validates a protocol name
+// which may contain * wildcards
+func ValidateProtocolPatternParam(value string) (string, error) {
+	return ValidateLengthAndCharset(value, 80, "ABCDEFGHIJKLMNOPQRSTUVWXYZ_:.abcdefghijklmnopqrstuvwxyz1234567890*")
+}
+
+// ValidatePrefixParam validates a prefix or address and returns
+// it in canonical form, so equivalent queries share a cache key,
+// e.g. 2001:DB8:0::/32 becomes 2001:db8::/32.
 func ValidatePrefixParam(value string) (string, error) {
-	return ValidateLengthAndCharset(value, 80, "1234567890abcdef.:/")
+	value, err := ValidateLengthAndCharset(strings.ToLower(value), 80, "1234567890abcdef.:/")
+	if err != nil {
+		return "", err
+	}
+	return canonicalPrefix(value), nil
+}
+
+// Host bits of a prefix are cleared. Values which are
+// neither prefix nor address are kept as they are.
+func canonicalPrefix(value string) string {
+	canonical := bird.CanonicalAddress
+	if strings.Contains(value, "/") {
+		canonical = bird.CanonicalPrefix
+	}
+	if res, err := canonical(value); err == nil {
+		return res
+	}
+	return value
+}
+
+// Examples of the formats accepted by ValidateNetParam
+var netFormats = []string{
+	"192.0.2.0/24",
+	"192.0.2.1",
+	"2001:db8::/32",
+	"2001:db8::1",
+}
+
+// ValidateNetParam validates a prefix or an address and returns
+// the canonical prefix. An address is looked up as host prefix,
+// e.g. 192.0.2.1 becomes 192.0.2.1/32 and 2001:db8::1 becomes
+// 2001:db8::1/128, IPv4-mapped addresses stay IPv6. The
+// prefix may be path escaped, as in
+// /route/net/192.0.2.0%2F24.
+func ValidateNetParam(value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("A prefix or address is required")
+	}
+	if err := ValidateLength(value, 80); err != nil {
+		return "", err
+	}
+
+	unescaped, err := url.PathUnescape(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("Invalid prefix or address: %s", value)
+	}
+	prefix, err := bird.CanonicalPrefix(unescaped)
+	if err != nil {
+		return "", fmt.Errorf("Invalid prefix or address: %s", value)
+	}
+	return prefix, nil
+}
+
+// invalidNet is the result for an invalid prefix parameter,
+// it is returned with status 400 and the accepted formats.
+func invalidNet(err error) bird.Parsed {
+	return bird.Parsed{
+		"error":            err.Error(),
+		"accepted_formats": netFormats,
+	}
+}
+
+func isInvalidNet(ret bird.Parsed) bool {
+	_, ok := ret["accepted_formats"]
+	return ok && ret["error"] != nil
 }
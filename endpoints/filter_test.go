@@ -36,3 +36,59 @@ func TestValidateProtocol(t *testing.T) {
 	}
 
 }
+
+func TestValidatePrefix(t *testing.T) {
+	tests := map[string]string{
+		"2001:DB8::/32":       "2001:db8::/32",
+		"2001:db8:0:0::/32":   "2001:db8::/32",
+		"10.1.2.3/8":          "10.0.0.0/8",
+		"2001:0db8::0001":     "2001:db8::1",
+		"192.168.1.1":         "192.168.1.1",
+		"10.0.0.0/8":          "10.0.0.0/8",
+		"::ffff:192.0.2.128":  "::ffff:192.0.2.128",
+		"::ffff:10.0.0.0/104": "::ffff:10.0.0.0/104",
+	}
+	for param, expected := range tests {
+		res, err := ValidatePrefixParam(param)
+		if err != nil {
+			t.Error(param, "should be a valid prefix param:", err)
+		}
+		if res != expected {
+			t.Error("Expected", expected, "for", param, "got:", res)
+		}
+	}
+
+	if _, err := ValidatePrefixParam("10.0.0.0/8 all"); err == nil {
+		t.Error("Expected an error for an invalid prefix param")
+	}
+}
+
+func TestValidateNetParam(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.0/24":          "192.0.2.0/24",
+		"192.0.2.1/24":          "192.0.2.0/24",
+		"192.0.2.1":             "192.0.2.1/32",
+		"192.0.2.0%2F24":        "192.0.2.0/24",
+		" 192.0.2.1 ":           "192.0.2.1/32",
+		"2001:DB8::/32":         "2001:db8::/32",
+		"2001:0db8::0001":       "2001:db8::1/128",
+		"2001:db8::%2f48":       "2001:db8::/48",
+		"::ffff:192.0.2.1":      "::ffff:192.0.2.1/128",
+		"::ffff:10.0.0.0%2F104": "::ffff:10.0.0.0/104",
+	}
+	for param, expected := range tests {
+		res, err := ValidateNetParam(param)
+		if err != nil {
+			t.Error(param, "should be a valid net param:", err)
+		}
+		if res != expected {
+			t.Error("Expected", expected, "for", param, "got:", res)
+		}
+	}
+
+	for _, param := range []string{"", "192.0.2", "192.0.2.0/33", "2001:db8::/129", "10.0.0.0/8 all", "example.com", "%zz"} {
+		if res, err := ValidateNetParam(param); err == nil {
+			t.Error("Expected an error for", param, "got:", res)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Minimal HTML views of the responses for browsers, enabled
+// with html_views. Protocols, the status and routes are shown
+// as tables, other responses as indented JSON.
+
+type htmlEncoder struct{}
+
+func (htmlEncoder) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+type htmlView struct {
+	Title     string
+	Status    [][2]string
+	Protocols []htmlProtocol
+	Routes    []htmlRoute
+	JSON      string
+}
+
+type htmlProtocol struct {
+	Name, Proto, Table, State, Since string
+	Neighbor, ASN, Description       string
+	Imported, Exported, Filtered     string
+}
+
+type htmlRoute struct {
+	Network, Gateway, Interface, Protocol string
+	Primary                               bool
+	ASPath, LocalPref, MED, Communities   string
+}
+
+var htmlTemplate = template.Must(template.New("view").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>birdwatcher{{if .Title}} - {{.Title}}{{end}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em 2em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 2px 8px; border-bottom: 1px solid #ddd; }
+th { background: #f4f4f4; }
+tr.down td { color: #a00; }
+pre { background: #f8f8f8; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Status}}
+<table>
+{{range .Status}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Protocols}}
+<table>
+<tr><th>Protocol</th><th>Type</th><th>Table</th><th>State</th><th>Since</th><th>Neighbor</th><th>ASN</th><th>Description</th><th>Imported</th><th>Exported</th><th>Filtered</th></tr>
+{{range .Protocols}}<tr{{if ne .State "up"}} class="down"{{end}}><td>{{.Name}}</td><td>{{.Proto}}</td><td>{{.Table}}</td><td>{{.State}}</td><td>{{.Since}}</td><td>{{.Neighbor}}</td><td>{{.ASN}}</td><td>{{.Description}}</td><td>{{.Imported}}</td><td>{{.Exported}}</td><td>{{.Filtered}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Routes}}
+<table>
+<tr><th>Network</th><th>Gateway</th><th>Interface</th><th>Protocol</th><th>Best</th><th>AS path</th><th>Local pref</th><th>MED</th><th>Communities</th></tr>
+{{range .Routes}}<tr><td>{{.Network}}</td><td>{{.Gateway}}</td><td>{{.Interface}}</td><td>{{.Protocol}}</td><td>{{if .Primary}}*{{end}}</td><td>{{.ASPath}}</td><td>{{.LocalPref}}</td><td>{{.MED}}</td><td>{{.Communities}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .JSON}}<pre>{{.JSON}}</pre>{{end}}
+</body>
+</html>
+`))
+
+func (htmlEncoder) Encode(w io.Writer, v interface{}) error {
+	doc, err := genericDocument(v)
+	if err != nil {
+		return err
+	}
+	return htmlTemplate.Execute(w, newHTMLView(doc))
+}
+
+func newHTMLView(doc interface{}) htmlView {
+	view := htmlView{}
+	m, _ := doc.(map[string]interface{})
+
+	switch {
+	case m["status"] != nil:
+		view.Title = "Status"
+		status, _ := m["status"].(map[string]interface{})
+		for _, k := range sortedDocumentKeys(status) {
+			view.Status = append(view.Status, [2]string{k, htmlText(status[k])})
+		}
+	case m["protocols"] != nil:
+		view.Title = "Protocols"
+		protocols, _ := m["protocols"].(map[string]interface{})
+		view.Protocols = htmlProtocols(protocols)
+	case m["routes"] != nil:
+		view.Title = "Routes"
+		routes, _ := m["routes"].([]interface{})
+		view.Routes = htmlRoutes(routes)
+	}
+
+	if view.Status == nil && view.Protocols == nil && view.Routes == nil {
+		js, _ := json.MarshalIndent(doc, "", "  ")
+		view.JSON = string(js)
+	}
+	return view
+}
+
+func htmlProtocols(protocols map[string]interface{}) []htmlProtocol {
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []htmlProtocol{}
+	for _, name := range names {
+		p, ok := protocols[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		routes, _ := p["routes"].(map[string]interface{})
+		res = append(res, htmlProtocol{
+			Name:        name,
+			Proto:       htmlText(p["bird_protocol"]),
+			Table:       htmlText(p["table"]),
+			State:       htmlText(p["state"]),
+			Since:       htmlText(p["state_changed"]),
+			Neighbor:    htmlText(p["neighbor_address"]),
+			ASN:         htmlText(p["neighbor_as"]),
+			Description: htmlText(p["description"]),
+			Imported:    htmlText(routes["imported"]),
+			Exported:    htmlText(routes["exported"]),
+			Filtered:    htmlText(routes["filtered"]),
+		})
+	}
+	return res
+}
+
+func htmlRoutes(routes []interface{}) []htmlRoute {
+	res := []htmlRoute{}
+	for _, r := range routes {
+		route, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		bgp, _ := route["bgp"].(map[string]interface{})
+		primary, _ := route["primary"].(bool)
+
+		communities := []string{}
+		for _, key := range []string{"communities", "large_communities"} {
+			list, _ := bgp[key].([]interface{})
+			for _, c := range list {
+				parts := []string{}
+				values, _ := c.([]interface{})
+				for _, v := range values {
+					parts = append(parts, htmlText(v))
+				}
+				communities = append(communities, "("+strings.Join(parts, ",")+")")
+			}
+		}
+
+		res = append(res, htmlRoute{
+			Network:     htmlText(route["network"]),
+			Gateway:     htmlText(route["gateway"]),
+			Interface:   htmlText(route["interface"]),
+			Protocol:    htmlText(route["from_protocol"]),
+			Primary:     primary,
+			ASPath:      htmlText(bgp["as_path"]),
+			LocalPref:   htmlText(bgp["local_pref"]),
+			MED:         htmlText(bgp["med"]),
+			Communities: strings.Join(communities, " "),
+		})
+	}
+	return res
+}
+
+// htmlText formats a value of the generic document
+func htmlText(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []interface{}:
+		parts := make([]string, len(value))
+		for i, e := range value {
+			parts[i] = htmlText(e)
+		}
+		return strings.Join(parts, " ")
+	case map[string]interface{}:
+		js, _ := json.Marshal(value)
+		return string(js)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,57 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestNegotiateHTML(t *testing.T) {
+	formerConf := Conf
+	defer func() { Conf = formerConf }()
+
+	req := httptest.NewRequest("GET", "/protocols", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	if _, ok := negotiateEncoder(req).(jsonEncoder); !ok {
+		t.Error("Expected JSON without html_views")
+	}
+
+	Conf.HTMLViews = true
+	if _, ok := negotiateEncoder(req).(htmlEncoder); !ok {
+		t.Error("Expected HTML for a browser")
+	}
+}
+
+func TestHTMLEncoder(t *testing.T) {
+	res := map[string]interface{}{
+		"protocols": bird.Parsed{
+			"R1": bird.Parsed{
+				"bird_protocol":    "BGP",
+				"state":            "up",
+				"neighbor_address": "192.0.2.1",
+				"description":      "<script>",
+				"routes":           bird.Parsed{"imported": int64(42)},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := (htmlEncoder{}).Encode(buf, res); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{"<td>R1</td>", "<td>192.0.2.1</td>", "<td>42</td>", "&lt;script&gt;"} {
+		if !strings.Contains(out, expected) {
+			t.Error("Expected", expected, "in:", out)
+		}
+	}
+
+	buf.Reset()
+	(htmlEncoder{}).Encode(buf, map[string]interface{}{"symbols": bird.Parsed{"T1": "routing table"}})
+	if !strings.Contains(buf.String(), "<pre>") {
+		t.Error("Expected a JSON fallback:", buf.String())
+	}
+}
@@ -0,0 +1,22 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+)
+
+// Internal requests are issued by birdwatcher itself, e.g. for
+// prefetching. They are not subject to the access control or
+// the query budget and always bypass the cache.
+
+type internalRequestKey struct{}
+
+func InternalRequest(req *http.Request) *http.Request {
+	ctx := context.WithValue(req.Context(), internalRequestKey{}, true)
+	return req.WithContext(ctx)
+}
+
+func isInternalRequest(req *http.Request) bool {
+	internal, _ := req.Context().Value(internalRequestKey{}).(bool)
+	return internal
+}
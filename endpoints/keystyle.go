@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Naming of the response fields
+//
+// Fields are named in snake_case. With key_style = "camel" in
+// the config or ?key_style=camel, the keys are converted to
+// camelCase for all encodings. Maps keyed by names from the
+// BIRD config (protocols, tables, ...) keep their keys.
+
+var snakeCaseKey = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)+$`)
+
+var nameKeyedFields = map[string]bool{
+	"protocols":   true,
+	"tables":      true,
+	"filters":     true,
+	"regions":     true,
+	"peers":       true,
+	"neighbors":   true,
+	"entries":     true,
+	"as_names":    true,
+	"hostnames":   true,
+	"communities": true,
+}
+
+func camelCase(key string) string {
+	if !snakeCaseKey.MatchString(key) {
+		return key
+	}
+
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func camelCaseKeys(v interface{}, keepKeys bool) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			key := k
+			if !keepKeys {
+				key = camelCase(k)
+			}
+			res[key] = camelCaseKeys(e, !keepKeys && nameKeyedFields[k])
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(value))
+		for i, e := range value {
+			res[i] = camelCaseKeys(e, false)
+		}
+		return res
+	}
+	return v
+}
+
+func keyStyle(r *http.Request) string {
+	if style := r.URL.Query().Get("key_style"); style != "" {
+		return style
+	}
+	return Conf.KeyStyle
+}
+
+// styleKeys applies the requested key style to the response
+func styleKeys(r *http.Request, res map[string]interface{}) map[string]interface{} {
+	if keyStyle(r) != "camel" {
+		return res
+	}
+
+	doc, err := genericDocument(res)
+	if err != nil {
+		return res
+	}
+	styled, ok := camelCaseKeys(doc, false).(map[string]interface{})
+	if !ok {
+		return res
+	}
+	return styled
+}
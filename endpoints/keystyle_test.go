@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"neighbor_as":      "neighborAs",
+		"import_updates":   "importUpdates",
+		"ttl":              "ttl",
+		"R194_42":          "R194_42",
+		"routing table":    "routing table",
+		"as_path_stats_v2": "asPathStatsV2",
+	}
+	for key, expected := range tests {
+		if res := camelCase(key); res != expected {
+			t.Error("Expected", expected, "for", key, "got:", res)
+		}
+	}
+}
+
+func TestStyleKeys(t *testing.T) {
+	res := map[string]interface{}{
+		"protocols": map[string]interface{}{
+			"bgp_peer_1": map[string]interface{}{"neighbor_as": 64500},
+		},
+		"cached_at": "now",
+	}
+
+	req := httptest.NewRequest("GET", "/protocols/bgp?key_style=camel", nil)
+	styled := styleKeys(req, res)
+
+	protocols := styled["protocols"].(map[string]interface{})
+	peer, ok := protocols["bgp_peer_1"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected the protocol name to be kept:", protocols)
+	}
+	if _, ok := peer["neighborAs"]; !ok {
+		t.Error("Expected neighborAs, got:", peer)
+	}
+	if _, ok := styled["cachedAt"]; !ok {
+		t.Error("Expected cachedAt, got:", styled)
+	}
+
+	req = httptest.NewRequest("GET", "/protocols/bgp", nil)
+	if !reflect.DeepEqual(styleKeys(req, res), res) {
+		t.Error("Expected snake case keys to be kept by default")
+	}
+}
@@ -0,0 +1,234 @@
+package endpoints
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Long polling of the protocol state
+//
+// A client passes the ETag of the last response with
+// `?wait=30s&if_changed_since=<etag>` (or If-None-Match).
+// The request is held until the state of a protocol changes
+// or the wait time is over, which is answered with
+// 304 Not Modified. A single poller checks the cached
+// protocols for all waiting clients.
+
+const (
+	defaultLongPollMaxWait = 60 * time.Second
+	longPollInterval       = 2 * time.Second
+)
+
+// protocolsStateTag hashes the state of the protocols,
+// ignoring counters which change all the time.
+func protocolsStateTag(states bird.Parsed) string {
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha1.New()
+	for _, name := range names {
+		state, _ := states[name].(bird.Parsed)
+		fmt.Fprintf(h, "%s\x00%v\x00%v\x00%v\x00%v\x00%v\n",
+			name, state["state"], state["state_changed"], state["connection"],
+			state["bgp_state"], state["last_error"])
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:10]) + `"`
+}
+
+func allProtocolsStateTag(protocols bird.Parsed) string {
+	states, _ := protocols["protocols"].(bird.Parsed)
+	return protocolsStateTag(states)
+}
+
+// requestStateTag is the tag of the protocols visible to
+// the client, so tenants do not see changes of others.
+func requestStateTag(r *http.Request, protocols bird.Parsed) string {
+	states, _ := protocols["protocols"].(bird.Parsed)
+	if _, tenant := requestTenant(r); tenant != nil {
+		states, _ = tenantProtocols(tenant, states).(bird.Parsed)
+	}
+	return protocolsStateTag(states)
+}
+
+func cachedProtocols() (bird.Parsed, error) {
+	protocols, _ := bird.Protocols(true)
+	if bird.IsSpecial(protocols) {
+		return nil, fmt.Errorf("Could not get the protocol state")
+	}
+	return protocols, nil
+}
+
+func longPollMaxWait() time.Duration {
+	if Conf.LongPollMaxWait > 0 {
+		return time.Duration(Conf.LongPollMaxWait) * time.Second
+	}
+	return defaultLongPollMaxWait
+}
+
+// protocolsPoller checks the cached protocols for all waiting
+// clients, so they do not query BIRD themselves. It runs while
+// clients are waiting and closes changed on every change.
+type protocolsPoller struct {
+	sync.Mutex
+	protocols bird.Parsed
+	tag       string
+	changed   chan struct{}
+	waiters   int
+	running   bool
+}
+
+var longPollPoller = &protocolsPoller{changed: make(chan struct{})}
+
+// subscribe returns the latest protocols and a channel which
+// is closed when they change
+func (p *protocolsPoller) subscribe() (bird.Parsed, <-chan struct{}, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.protocols == nil {
+		protocols, err := cachedProtocols()
+		if err != nil {
+			return nil, nil, err
+		}
+		p.protocols = protocols
+		p.tag = allProtocolsStateTag(protocols)
+	}
+
+	p.waiters++
+	if !p.running {
+		p.running = true
+		go p.run()
+	}
+	return p.protocols, p.changed, nil
+}
+
+func (p *protocolsPoller) unsubscribe() {
+	p.Lock()
+	p.waiters--
+	p.Unlock()
+}
+
+func (p *protocolsPoller) latest() (bird.Parsed, <-chan struct{}) {
+	p.Lock()
+	defer p.Unlock()
+	return p.protocols, p.changed
+}
+
+func (p *protocolsPoller) run() {
+	for {
+		time.Sleep(longPollInterval)
+
+		p.Lock()
+		if p.waiters == 0 {
+			p.running = false
+			p.protocols = nil
+			p.Unlock()
+			return
+		}
+		p.Unlock()
+
+		protocols, err := cachedProtocols()
+		if err != nil {
+			continue
+		}
+		tag := allProtocolsStateTag(protocols)
+
+		p.Lock()
+		p.protocols = protocols
+		if tag != p.tag {
+			p.tag = tag
+			close(p.changed)
+			p.changed = make(chan struct{})
+		}
+		p.Unlock()
+	}
+}
+
+// waitForStateChange waits until the state visible to the
+// client differs from the tag, the deadline or the client
+// is gone. The current tag is returned with true if it changed.
+func waitForStateChange(r *http.Request, tag string, wait time.Duration) (string, bool, error) {
+	protocols, changed, err := longPollPoller.subscribe()
+	if err != nil {
+		return "", false, err
+	}
+	defer longPollPoller.unsubscribe()
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	for {
+		current := requestStateTag(r, protocols)
+		if current != tag {
+			return current, true, nil
+		}
+
+		select {
+		case <-changed:
+			protocols, changed = longPollPoller.latest()
+		case <-timeout.C:
+			return current, false, nil
+		case <-r.Context().Done():
+			return current, false, r.Context().Err()
+		}
+	}
+}
+
+// ProtocolsLongPoll serves the protocols with long polling
+func ProtocolsLongPoll(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	qs := r.URL.Query()
+	waitParam := qs.Get("wait")
+	tag := qs.Get("if_changed_since")
+	if tag == "" {
+		tag = r.Header.Get("If-None-Match")
+	}
+
+	if err := CheckAccess(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if waitParam == "" || tag == "" {
+		// The tag of the cached protocols, which are also
+		// used for the response
+		if protocols, err := cachedProtocols(); err == nil {
+			w.Header().Set("ETag", requestStateTag(r, protocols))
+		}
+		Endpoint(Protocols)(w, r, ps)
+		return
+	}
+
+	wait, err := time.ParseDuration(waitParam)
+	if err != nil || wait < 0 {
+		http.Error(w, "wait must be a duration, e.g. 30s", http.StatusBadRequest)
+		return
+	}
+	if max := longPollMaxWait(); wait > max {
+		wait = max
+	}
+
+	current, changed, err := waitForStateChange(r, tag, wait)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("ETag", current)
+	if !changed {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	Endpoint(Protocols)(w, r, ps)
+}
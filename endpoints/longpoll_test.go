@@ -0,0 +1,107 @@
+package endpoints
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestProtocolsStateTag(t *testing.T) {
+	protocols := bird.Parsed{
+		"R1": bird.Parsed{"state": "up", "state_changed": "2019-02-15", "connection": "Established"},
+		"R2": bird.Parsed{"state": "start", "state_changed": "2019-02-15", "connection": "Active"},
+	}
+
+	tag := protocolsStateTag(protocols)
+	if tag != protocolsStateTag(protocols) {
+		t.Error("Expected a stable tag")
+	}
+
+	protocols["R2"] = bird.Parsed{
+		"state": "up", "state_changed": "10:00:00", "connection": "Established",
+	}
+	if tag == protocolsStateTag(protocols) {
+		t.Error("Expected the tag to change with the state")
+	}
+}
+
+func TestRequestStateTagTenant(t *testing.T) {
+	formerTenants := Tenants
+	defer func() { Tenants = formerTenants }()
+	Tenants = map[string]TenantConfig{
+		"customer1": {Tokens: []string{"c1-secret"}, Protocols: []string{"R1"}},
+	}
+
+	protocols := bird.Parsed{"protocols": bird.Parsed{
+		"R1": bird.Parsed{"state": "up"},
+		"R2": bird.Parsed{"state": "up"},
+	}}
+	req := httptest.NewRequest("GET", "/protocols", nil)
+	req.Header.Set("Authorization", "Bearer c1-secret")
+	tag := requestStateTag(req, protocols)
+
+	// Changes of other tenants are not visible
+	protocols["protocols"].(bird.Parsed)["R2"] = bird.Parsed{"state": "down"}
+	if requestStateTag(req, protocols) != tag {
+		t.Error("Expected the tenant tag to ignore other protocols")
+	}
+	protocols["protocols"].(bird.Parsed)["R1"] = bird.Parsed{"state": "down"}
+	if requestStateTag(req, protocols) == tag {
+		t.Error("Expected the tenant tag to change with its protocols")
+	}
+}
+
+func TestProtocolsLongPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-longpoll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixtures := map[string]string{
+		"protocols":     "../test/protocols_short.sample",
+		"protocols all": "../test/protocols_bgp_pipe.sample",
+	}
+	for cmd, sample := range fixtures {
+		out, err := ioutil.ReadFile(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, bird.FixtureName(cmd)), out, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bird.MockDir = dir
+	defer func() { bird.MockDir = "" }()
+	bird.InitializeCache()
+
+	rec := httptest.NewRecorder()
+	ProtocolsLongPoll(rec, httptest.NewRequest("GET", "/protocols", nil), nil)
+	tag := rec.Header().Get("ETag")
+	if rec.Code != 200 || tag == "" {
+		t.Fatal("Expected a response with an ETag, got:", rec.Code, tag)
+	}
+
+	// Unchanged state
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/protocols?wait=10ms", nil)
+	req.Header.Set("If-None-Match", tag)
+	ProtocolsLongPoll(rec, req, nil)
+	if rec.Code != 304 {
+		t.Error("Expected 304 Not Modified, got:", rec.Code)
+	}
+
+	// Changed state
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/protocols?wait=10ms&if_changed_since=outdated", nil)
+	ProtocolsLongPoll(rec, req, nil)
+	if rec.Code != 200 || rec.Header().Get("ETag") != tag {
+		t.Error("Expected the protocols with the current tag, got:", rec.Code)
+	}
+}
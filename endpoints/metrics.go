@@ -0,0 +1,19 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/alice-lg/birdwatcher/metrics"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Metrics exports the metrics in the Prometheus text format
+func Metrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := CheckAccess(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.Default.Write(w)
+}
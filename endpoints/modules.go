@@ -0,0 +1,38 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type moduleDisabledResponse struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"`
+	Module string `json:"module"`
+}
+
+// ModuleDisabled responds to requests for the endpoints of
+// a module missing in modules_enabled. Clients without access
+// are rejected first, the enabled modules are not disclosed.
+func ModuleDisabled(module string, status int) httprouter.Handle {
+	if status != http.StatusForbidden {
+		status = http.StatusNotFound
+	}
+	js, _ := json.Marshal(moduleDisabledResponse{
+		Error:  "module " + module + " is not enabled",
+		Code:   "module_disabled",
+		Module: module,
+	})
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if err := CheckAccess(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(js)
+	}
+}
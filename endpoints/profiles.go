@@ -0,0 +1,166 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Permission profiles, e.g. public, noc and admin, bind a
+// set of modules, a rate limit and redaction rules. A request
+// gets the profile of its bearer token or the profile of the
+// listener it was received on. Requests without a profile use
+// the global modules_enabled and [redact] settings.
+
+type ProfileConfig struct {
+	Modules []string `toml:"modules"`
+
+	// Requests per minute and client, 0 disables the limit
+	RateLimit int `toml:"rate_limit"`
+
+	RedactFields []string `toml:"redact_fields"`
+	RedactMask   bool     `toml:"redact_mask"`
+
+	// Bearer tokens selecting the profile and an additional
+	// plain HTTP listener serving the profile
+	Tokens []string `toml:"tokens"`
+	Listen string   `toml:"listen"`
+}
+
+var Profiles map[string]ProfileConfig
+
+type profileContextKey struct{}
+
+// ValidateProfiles checks the profile of the main listener
+// and that no token selects more than one profile.
+func ValidateProfiles(profiles map[string]ProfileConfig, serverProfile string) error {
+	if _, ok := profiles[serverProfile]; serverProfile != "" && !ok {
+		return fmt.Errorf("Unknown profile of the server: %s", serverProfile)
+	}
+
+	tokens := map[string]string{}
+	for name, profile := range profiles {
+		for _, token := range profile.Tokens {
+			if token == "" {
+				return fmt.Errorf("Empty token in profile %s", name)
+			}
+			if other, ok := tokens[token]; ok {
+				return fmt.Errorf("Token of profile %s is also used by %s", name, other)
+			}
+			tokens[token] = name
+		}
+	}
+	return nil
+}
+
+// ProfileHandler assigns the profile to all requests
+// received by the handler, e.g. on a listener.
+func ProfileHandler(profile string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), profileContextKey{}, profile)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestProfile returns the profile of the request, the
+// profile of a token takes precedence over the listener.
+func requestProfile(r *http.Request) (string, *ProfileConfig) {
+	if len(Profiles) == 0 {
+		return "", nil
+	}
+
+	if token := requestToken(r); token != "" {
+		for name, profile := range Profiles {
+			for _, t := range profile.Tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					profile := profile
+					return name, &profile
+				}
+			}
+		}
+	}
+
+	name, _ := r.Context().Value(profileContextKey{}).(string)
+	profile, ok := Profiles[name]
+	if !ok {
+		return "", nil
+	}
+	return name, &profile
+}
+
+func containsModule(modules []string, module string) bool {
+	for _, m := range modules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileEndpoint checks the module and the rate limit of
+// the profile of the request. Without a profile the module
+// must be in the global modules.
+func ProfileEndpoint(module string, modules []string, status int, handle httprouter.Handle) httprouter.Handle {
+	disabled := ModuleDisabled(module, status)
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		name, profile := requestProfile(r)
+		if profile == nil {
+			if !containsModule(modules, module) {
+				disabled(w, r, ps)
+				return
+			}
+			handle(w, r, ps)
+			return
+		}
+
+		if !containsModule(profile.Modules, module) {
+			ModuleDisabled(module, status)(w, r, ps)
+			return
+		}
+		if profile.RateLimit > 0 &&
+			!profileRequests.allow(name, remoteIP(r).String(), profile.RateLimit, time.Now()) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			js, _ := json.Marshal(map[string]string{
+				"error":   "rate limit of profile " + name + " exceeded",
+				"profile": name,
+			})
+			w.Write(js)
+			return
+		}
+		handle(w, r, ps)
+	}
+}
+
+// Requests per profile and client in the current minute
+type profileCounter struct {
+	sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+var profileRequests = &profileCounter{counts: map[string]int{}}
+
+func (c *profileCounter) allow(profile, client string, limit int, now time.Time) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if window := now.Truncate(time.Minute); !window.Equal(c.window) {
+		c.window = window
+		c.counts = map[string]int{}
+	}
+
+	key := profile + " " + client
+	if c.counts[key] >= limit {
+		return false
+	}
+	c.counts[key]++
+	return true
+}
@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestProfileEndpoint(t *testing.T) {
+	formerProfiles := Profiles
+	defer func() { Profiles = formerProfiles }()
+	Profiles = map[string]ProfileConfig{
+		"public": {Modules: []string{"status"}, RateLimit: 1},
+		"noc":    {Modules: []string{"status", "raw"}, Tokens: []string{"noc-secret"}},
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Write([]byte("ok"))
+	}
+	raw := ProfileEndpoint("raw", []string{"status"}, http.StatusForbidden, ok)
+	status := ProfileEndpoint("status", []string{"status"}, http.StatusForbidden, ok)
+
+	serve := func(h httprouter.Handle, profile, token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h(w, r, nil)
+		})
+		ProfileHandler(profile, handler).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(raw, "public", ""); code != http.StatusForbidden {
+		t.Error("Expected raw to be disabled for public, got:", code)
+	}
+	if code := serve(raw, "public", "noc-secret"); code != http.StatusOK {
+		t.Error("Expected the noc token to enable raw, got:", code)
+	}
+	if code := serve(raw, "", ""); code != http.StatusForbidden {
+		t.Error("Expected the global modules without a profile, got:", code)
+	}
+
+	// The rate limit of public is one request per minute
+	profileRequests = &profileCounter{counts: map[string]int{}}
+	if code := serve(status, "public", ""); code != http.StatusOK {
+		t.Error("Expected the first request to pass, got:", code)
+	}
+	if code := serve(status, "public", ""); code != http.StatusTooManyRequests {
+		t.Error("Expected the rate limit, got:", code)
+	}
+}
+
+func TestProfileCounter(t *testing.T) {
+	c := &profileCounter{counts: map[string]int{}}
+	now := time.Date(2026, 1, 1, 12, 0, 10, 0, time.UTC)
+	if !c.allow("public", "192.0.2.1", 1, now) {
+		t.Error("Expected the first request to be allowed")
+	}
+	if c.allow("public", "192.0.2.1", 1, now.Add(10*time.Second)) {
+		t.Error("Expected the second request to be rejected")
+	}
+	if !c.allow("public", "192.0.2.2", 1, now) {
+		t.Error("Expected the limit to be per client")
+	}
+	if !c.allow("public", "192.0.2.1", 1, now.Add(time.Minute)) {
+		t.Error("Expected the limit to be reset in the next minute")
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	profiles := map[string]ProfileConfig{
+		"public": {},
+		"noc":    {Tokens: []string{"secret"}},
+	}
+	if err := ValidateProfiles(profiles, "public"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateProfiles(profiles, "admin"); err == nil {
+		t.Error("Expected an error for an unknown server profile")
+	}
+	profiles["admin"] = ProfileConfig{Tokens: []string{"secret"}}
+	if err := ValidateProfiles(profiles, ""); err == nil {
+		t.Error("Expected an error for a shared token")
+	}
+}
@@ -0,0 +1,244 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Protocol buffers encoding of routes and protocols as a stream
+// of length-delimited messages. The messages are defined in
+// proto/birdwatcher.proto.
+
+const (
+	pbVarint = 0
+	pbBytes  = 2
+)
+
+type pbMessage struct {
+	bytes.Buffer
+}
+
+func (m *pbMessage) varint(v uint64) {
+	for v >= 0x80 {
+		m.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	m.WriteByte(byte(v))
+}
+
+func (m *pbMessage) key(field int, wireType int) {
+	m.varint(uint64(field<<3 | wireType))
+}
+
+func (m *pbMessage) bytesField(field int, b []byte) {
+	m.key(field, pbBytes)
+	m.varint(uint64(len(b)))
+	m.Write(b)
+}
+
+func (m *pbMessage) String(field int, s string) {
+	if s != "" {
+		m.bytesField(field, []byte(s))
+	}
+}
+
+func (m *pbMessage) Int64(field int, v int64) {
+	if v != 0 {
+		m.key(field, pbVarint)
+		m.varint(uint64(v))
+	}
+}
+
+func (m *pbMessage) Bool(field int, v bool) {
+	if v {
+		m.key(field, pbVarint)
+		m.varint(1)
+	}
+}
+
+func (m *pbMessage) Message(field int, sub *pbMessage) {
+	m.bytesField(field, sub.Bytes())
+}
+
+func (m *pbMessage) PackedUint32(field int, values []int64) {
+	if len(values) == 0 {
+		return
+	}
+	packed := &pbMessage{}
+	for _, v := range values {
+		packed.varint(uint64(uint32(v)))
+	}
+	m.bytesField(field, packed.Bytes())
+}
+
+// BIRD reports some numeric attributes as strings
+func pbInt(v interface{}) int64 {
+	switch value := v.(type) {
+	case int64:
+		return value
+	case string:
+		n, _ := strconv.ParseInt(value, 10, 64)
+		return n
+	}
+	return 0
+}
+
+func pbString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func encodeBgpPb(bgp bird.Parsed) *pbMessage {
+	m := &pbMessage{}
+	m.String(1, pbString(bgp["origin"]))
+	if path, ok := bgp["as_path"].([]string); ok {
+		m.PackedUint32(2, parseAsns(path))
+	}
+	m.String(3, pbString(bgp["next_hop"]))
+	m.Int64(4, pbInt(bgp["med"]))
+	m.Int64(5, pbInt(bgp["local_pref"]))
+
+	if communities, ok := bgp["communities"].([][]int64); ok {
+		for _, c := range communities {
+			community := &pbMessage{}
+			for i, v := range c {
+				community.Int64(i+1, v)
+			}
+			m.Message(6, community)
+		}
+	}
+	if communities, ok := bgp["large_communities"].([][]int64); ok {
+		for _, c := range communities {
+			community := &pbMessage{}
+			for i, v := range c {
+				community.Int64(i+1, v)
+			}
+			m.Message(7, community)
+		}
+	}
+	if communities, ok := bgp["ext_communities"].([]interface{}); ok {
+		for _, c := range communities {
+			parts, ok := c.([]interface{})
+			if !ok {
+				continue
+			}
+			community := &pbMessage{}
+			for i, v := range parts {
+				community.String(i+1, pbString(v))
+			}
+			m.Message(8, community)
+		}
+	}
+
+	return m
+}
+
+func encodeRoutePb(route bird.Parsed) *pbMessage {
+	m := &pbMessage{}
+	m.String(1, pbString(route["network"]))
+	m.String(2, pbString(route["gateway"]))
+	m.String(3, pbString(route["interface"]))
+	m.String(4, pbString(route["from_protocol"]))
+	m.String(5, pbString(route["age"]))
+	m.Int64(6, pbInt(route["metric"]))
+	if primary, ok := route["primary"].(bool); ok {
+		m.Bool(7, primary)
+	}
+	if types, ok := route["type"].([]string); ok {
+		for _, t := range types {
+			m.bytesField(8, []byte(t))
+		}
+	}
+	m.String(9, pbString(route["learnt_from"]))
+	if bgp, ok := route["bgp"].(bird.Parsed); ok {
+		m.Message(10, encodeBgpPb(bgp))
+	}
+	return m
+}
+
+func encodeProtocolPb(name string, protocol bird.Parsed) *pbMessage {
+	m := &pbMessage{}
+	m.String(1, name)
+	m.String(2, pbString(protocol["bird_protocol"]))
+	m.String(3, pbString(protocol["table"]))
+	m.String(4, pbString(protocol["state"]))
+	m.String(5, pbString(protocol["state_changed"]))
+	m.String(6, pbString(protocol["neighbor_address"]))
+	m.Int64(7, pbInt(protocol["neighbor_as"]))
+	m.String(8, pbString(protocol["description"]))
+
+	if routes, ok := protocol["routes"].(bird.Parsed); ok {
+		counters := &pbMessage{}
+		counters.Int64(1, pbInt(routes["imported"]))
+		counters.Int64(2, pbInt(routes["filtered"]))
+		counters.Int64(3, pbInt(routes["exported"]))
+		counters.Int64(4, pbInt(routes["preferred"]))
+		m.Message(9, counters)
+	}
+	return m
+}
+
+// protobufStream returns the message type of the result and
+// a function encoding its messages one by one, so the stream
+// is written without holding all messages.
+func protobufStream(res bird.Parsed) (string, func(emit func(*pbMessage)), bool) {
+	if routes, ok := res["routes"].([]bird.Parsed); ok {
+		return "birdwatcher.Route", func(emit func(*pbMessage)) {
+			for _, route := range routes {
+				emit(encodeRoutePb(route))
+			}
+		}, true
+	}
+
+	if protocols, ok := res["protocols"].(bird.Parsed); ok {
+		names := make([]string, 0, len(protocols))
+		for name := range protocols {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return "birdwatcher.Protocol", func(emit func(*pbMessage)) {
+			for _, name := range names {
+				protocol, ok := protocols[name].(bird.Parsed)
+				if !ok {
+					continue
+				}
+				emit(encodeProtocolPb(name, protocol))
+			}
+		}, true
+	}
+
+	return "", nil, false
+}
+
+// Write the routes or protocols of the result as protobuf
+// stream, each message prefixed with its size
+func writeProtobuf(w http.ResponseWriter, res bird.Parsed) {
+	messageType, messages, ok := protobufStream(res)
+	if !ok {
+		http.Error(w, "pb format is only available for routes and protocols", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", strings.Join([]string{
+		"application/x-protobuf",
+		"delimited=true",
+		"messageType=" + messageType,
+	}, "; "))
+
+	out := bufferedResponse(w)
+	defer out.Flush()
+
+	size := &pbMessage{}
+	messages(func(m *pbMessage) {
+		size.Reset()
+		size.varint(uint64(m.Len()))
+		out.Write(size.Bytes())
+		out.Write(m.Bytes())
+	})
+}
@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestEncodeRoutePb(t *testing.T) {
+	route := bird.Parsed{
+		"network": "10.0.0.0/8",
+		"metric":  int64(300),
+		"primary": true,
+		"bgp": bird.Parsed{
+			"as_path":     []string{"64500", "{64501}"},
+			"communities": [][]int64{{64500, 1}},
+			"local_pref":  "100",
+		},
+	}
+
+	expected := []byte{
+		0x0a, 0x0a, '1', '0', '.', '0', '.', '0', '.', '0', '/', '8',
+		0x30, 0xac, 0x02, // metric 300
+		0x38, 0x01, // primary
+		0x52, 0x0f, // bgp
+		0x12, 0x03, 0xf4, 0xf7, 0x03, // as_path [64500]
+		0x28, 0x64, // local_pref 100
+		0x32, 0x06, 0x08, 0xf4, 0xf7, 0x03, 0x10, 0x01, // community
+	}
+
+	if b := encodeRoutePb(route).Bytes(); !bytes.Equal(b, expected) {
+		t.Errorf("Unexpected encoding: % x", b)
+	}
+}
+
+func TestWriteProtobuf(t *testing.T) {
+	res := bird.Parsed{
+		"protocols": bird.Parsed{
+			"R1": bird.Parsed{"state": "up"},
+			"R2": bird.Parsed{"state": "down"},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	writeProtobuf(rec, res)
+
+	expected := []byte{
+		0x08, 0x0a, 0x02, 'R', '1', 0x22, 0x02, 'u', 'p',
+		0x0a, 0x0a, 0x02, 'R', '2', 0x22, 0x04, 'd', 'o', 'w', 'n',
+	}
+	if !bytes.Equal(rec.Body.Bytes(), expected) {
+		t.Errorf("Unexpected stream: % x", rec.Body.Bytes())
+	}
+	ct := rec.Header().Get("Content-Type")
+	if ct != "application/x-protobuf; delimited=true; messageType=birdwatcher.Protocol" {
+		t.Error("Unexpected content type:", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	writeProtobuf(rec, bird.Parsed{"status": bird.Parsed{}})
+	if rec.Code != 400 {
+		t.Error("Expected 400 for a status result, got:", rec.Code)
+	}
+}
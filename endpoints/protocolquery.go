@@ -0,0 +1,148 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Filtering of protocols by query parameters:
+// ?state=down, ?asn=64500 and ?description_contains=
+// The filters are applied to the cached result.
+//
+// With ?page_size=N the protocols are paginated, ?page=P
+// (starting at 0) selects the page in the order of ?sort=
+// or by name.
+
+type protocolQuery struct {
+	state               string
+	asn                 int64
+	descriptionContains string
+}
+
+func parseProtocolQuery(qs url.Values) (*protocolQuery, error) {
+	q := &protocolQuery{
+		state:               strings.ToLower(qs.Get("state")),
+		descriptionContains: strings.ToLower(qs.Get("description_contains")),
+	}
+
+	if asn := qs.Get("asn"); asn != "" {
+		value, err := bird.ParseAsn(asn)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid asn: %s", asn)
+		}
+		q.asn = value
+	}
+
+	if q.state == "" && q.asn == 0 && q.descriptionContains == "" {
+		return nil, nil
+	}
+	return q, nil
+}
+
+func (q *protocolQuery) Match(protocol bird.Parsed) bool {
+	if q.state != "" {
+		state, _ := protocol["state"].(string)
+		if strings.ToLower(state) != q.state {
+			return false
+		}
+	}
+	if q.asn != 0 {
+		asn, _ := protocol["neighbor_as"].(int64)
+		if asn != q.asn {
+			return false
+		}
+	}
+	if q.descriptionContains != "" {
+		description, _ := protocol["description"].(string)
+		if !strings.Contains(strings.ToLower(description), q.descriptionContains) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterProtocols applies the query parameters to the protocols
+// of the result, the cached result itself is not modified.
+func filterProtocols(qs url.Values, res bird.Parsed) bird.Parsed {
+	if bird.IsSpecial(res) {
+		return res
+	}
+
+	q, err := parseProtocolQuery(qs)
+	if err != nil {
+		return bird.Parsed{"error": err.Error()}
+	}
+	if q == nil {
+		return res
+	}
+
+	protocols, _ := res["protocols"].(bird.Parsed)
+	filtered := bird.Parsed{}
+	for name, p := range protocols {
+		if protocol, ok := p.(bird.Parsed); ok && q.Match(protocol) {
+			filtered[name] = protocol
+		}
+	}
+
+	ret := bird.Parsed{}
+	for k, v := range res {
+		ret[k] = v
+	}
+	ret["protocols"] = filtered
+	return ret
+}
+
+func paginateProtocols(qs url.Values, res bird.Parsed) bird.Parsed {
+	if bird.IsSpecial(res) || qs.Get("page_size") == "" {
+		return res
+	}
+
+	pageSize, err := strconv.Atoi(qs.Get("page_size"))
+	if err != nil || pageSize <= 0 {
+		return bird.Parsed{"error": "page_size must be a positive number"}
+	}
+	page := 0
+	if p := qs.Get("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 0 {
+			return bird.Parsed{"error": "page must be a positive number"}
+		}
+	}
+
+	protocols, ok := res["protocols"].(bird.Parsed)
+	if !ok {
+		return res
+	}
+
+	by := qs.Get("sort")
+	if by == "" {
+		by = "name"
+	}
+	names, err := bird.SortProtocols(protocols, by, qs.Get("order") == "desc")
+	if err != nil {
+		return bird.Parsed{"error": err.Error()}
+	}
+
+	paginated := bird.Parsed{}
+	start := page * pageSize
+	for i := start; i < start+pageSize && i < len(names); i++ {
+		paginated[names[i]] = protocols[names[i]]
+	}
+
+	ret := bird.Parsed{}
+	for k, v := range res {
+		ret[k] = v
+	}
+	ret["protocols"] = paginated
+	ret["pagination"] = bird.Parsed{
+		"page":      page,
+		"page_size": pageSize,
+		"total":     len(names),
+		"pages":     (len(names) + pageSize - 1) / pageSize,
+	}
+	return ret
+}
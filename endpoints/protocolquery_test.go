@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestFilterProtocols(t *testing.T) {
+	res := bird.Parsed{
+		"protocols": bird.Parsed{
+			"R1": bird.Parsed{"state": "up", "neighbor_as": int64(64500), "description": "Nada Co"},
+			"R2": bird.Parsed{"state": "down", "neighbor_as": int64(64500), "description": "Other"},
+			"R3": bird.Parsed{"state": "down", "neighbor_as": int64(64501), "description": "Nada2 Co"},
+			"R4": bird.Parsed{"state": "up", "neighbor_as": int64(4200000000), "description": "Four Byte"},
+		},
+		"ttl": "ttl",
+	}
+
+	tests := map[string][]string{
+		"":                              {"R1", "R2", "R3", "R4"},
+		"state=down":                    {"R2", "R3"},
+		"asn=AS64500":                   {"R1", "R2"},
+		"description_contains=nada":     {"R1", "R3"},
+		"state=down&asn=64501":          {"R3"},
+		"state=up&description_contains": {"R1", "R4"},
+		"asn=4200000000":                {"R4"},
+		"asn=AS64086.59904":             {"R4"},
+	}
+
+	for query, expected := range tests {
+		qs, _ := url.ParseQuery(query)
+		filtered := filterProtocols(qs, res)
+		protocols := filtered["protocols"].(bird.Parsed)
+		if len(protocols) != len(expected) {
+			t.Error("Expected", expected, "for", query, "got:", protocols)
+			continue
+		}
+		for _, name := range expected {
+			if _, ok := protocols[name]; !ok {
+				t.Error("Expected", name, "for", query)
+			}
+		}
+		if filtered["ttl"] != "ttl" {
+			t.Error("Expected the ttl to be kept")
+		}
+	}
+
+	if len(res["protocols"].(bird.Parsed)) != 4 {
+		t.Error("Expected the result not to be modified")
+	}
+
+	for _, query := range []string{"asn=foo", "asn=4294967296"} {
+		qs, _ := url.ParseQuery(query)
+		if _, ok := filterProtocols(qs, res)["error"]; !ok {
+			t.Error("Expected an error for", query)
+		}
+	}
+}
+
+func TestPaginateProtocols(t *testing.T) {
+	protocols := bird.Parsed{}
+	for _, name := range []string{"R1", "R2", "R3", "R4", "R5"} {
+		protocols[name] = bird.Parsed{"state": "up"}
+	}
+	res := bird.Parsed{"protocols": protocols}
+
+	qs, _ := url.ParseQuery("page_size=2&page=2")
+	paginated := paginateProtocols(qs, res)
+	page := paginated["protocols"].(bird.Parsed)
+	if len(page) != 1 || page["R5"] == nil {
+		t.Error("Expected R5 on the last page, got:", page)
+	}
+
+	pagination := paginated["pagination"].(bird.Parsed)
+	if pagination["total"] != 5 || pagination["pages"] != 3 {
+		t.Error("Unexpected pagination:", pagination)
+	}
+
+	qs, _ = url.ParseQuery("page_size=2&sort=name&order=desc")
+	page = paginateProtocols(qs, res)["protocols"].(bird.Parsed)
+	if page["R5"] == nil || page["R4"] == nil {
+		t.Error("Expected R5 and R4 on the first page, got:", page)
+	}
+
+	qs, _ = url.ParseQuery("page_size=0")
+	if _, ok := paginateProtocols(qs, res)["error"]; !ok {
+		t.Error("Expected an error for an invalid page size")
+	}
+}
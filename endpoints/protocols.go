@@ -1,6 +1,9 @@
 package endpoints
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/alice-lg/birdwatcher/bird"
@@ -8,13 +11,82 @@ import (
 )
 
 func Protocols(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
-	return bird.Protocols(useCache)
+	res, from_cache := bird.Protocols(useCache)
+	qs := r.URL.Query()
+	return paginateProtocols(qs, filterProtocols(qs, res)), from_cache
 }
 
 func Bgp(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
-	return bird.ProtocolsBgp(useCache)
+	var res bird.Parsed
+	var from_cache bool
+	if region := r.URL.Query().Get("region"); region != "" {
+		res, from_cache = bird.ProtocolsBgpRegion(useCache, region)
+	} else {
+		res, from_cache = bird.ProtocolsBgp(useCache)
+	}
+	qs := r.URL.Query()
+	return paginateProtocols(qs, filterProtocols(qs, res)), from_cache
+}
+
+func BgpRegions(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.ProtocolsBgpRegions(useCache)
 }
 
 func ProtocolsShort(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
 	return bird.ProtocolsShort(useCache)
 }
+
+func ProtocolStats(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	protocol, err := ValidateProtocolParam(ps.ByName("protocol"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	return bird.ProtocolStats(useCache, protocol)
+}
+
+func NeighborsStats(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.NeighborsStats(useCache)
+}
+
+// Limits of a batch query on POST /protocols/query
+const (
+	maxProtocolsQueryBytes = 1 << 20
+	maxProtocolsQuery      = 1000
+)
+
+type protocolsQueryRequest struct {
+	Protocols []string `json:"protocols"`
+}
+
+// ProtocolsQuery returns the details of the protocols listed
+// in the request body: {"protocols": ["R1", "R2"]}
+func ProtocolsQuery(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	req := protocolsQueryRequest{}
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxProtocolsQueryBytes))
+	if err := dec.Decode(&req); err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("Invalid request: %s", err)}, false
+	}
+	if len(req.Protocols) == 0 {
+		return bird.Parsed{"error": "No protocols requested"}, false
+	}
+	if len(req.Protocols) > maxProtocolsQuery {
+		return bird.Parsed{"error": fmt.Sprintf("Too many protocols, at most %d", maxProtocolsQuery)}, false
+	}
+
+	names := make([]string, 0, len(req.Protocols))
+	seen := map[string]bool{}
+	for _, name := range req.Protocols {
+		protocol, err := ValidateProtocolParam(name)
+		if err != nil {
+			return bird.Parsed{"error": fmt.Sprintf("%s: %s", name, err)}, false
+		}
+		if seen[protocol] {
+			continue
+		}
+		seen[protocol] = true
+		names = append(names, protocol)
+	}
+
+	return bird.ProtocolsBatch(useCache, names)
+}
@@ -0,0 +1,95 @@
+package endpoints
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// QueryLogBroadcaster distributes the lines of the query log
+// to all subscribers. Slow subscribers miss lines instead of
+// blocking the logging of requests.
+type QueryLogBroadcaster struct {
+	sync.RWMutex
+	subscribers map[chan []byte]bool
+}
+
+func NewQueryLogBroadcaster() *QueryLogBroadcaster {
+	return &QueryLogBroadcaster{
+		subscribers: make(map[chan []byte]bool),
+	}
+}
+
+// QueryLog receives all lines written to the query log
+var QueryLog = NewQueryLogBroadcaster()
+
+// Write implements io.Writer
+func (b *QueryLogBroadcaster) Write(p []byte) (int, error) {
+	line := bytes.TrimRight(p, "\n")
+
+	b.RLock()
+	for ch := range b.subscribers {
+		msg := make([]byte, len(line))
+		copy(msg, line)
+		select {
+		case ch <- msg:
+		default: // Drop line for slow subscriber
+		}
+	}
+	b.RUnlock()
+
+	return len(p), nil
+}
+
+func (b *QueryLogBroadcaster) Subscribe() chan []byte {
+	ch := make(chan []byte, 128)
+	b.Lock()
+	b.subscribers[ch] = true
+	b.Unlock()
+	return ch
+}
+
+func (b *QueryLogBroadcaster) Unsubscribe(ch chan []byte) {
+	b.Lock()
+	delete(b.subscribers, ch)
+	b.Unlock()
+}
+
+// QueryLogTail streams the query log to the client
+// over a websocket connection.
+func QueryLogTail(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := CheckAccess(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := CheckAdminAuth(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := upgradeWebsocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer ws.Close()
+
+	log.Println("Streaming query log to:", r.RemoteAddr)
+
+	lines := QueryLog.Subscribe()
+	defer QueryLog.Unsubscribe(lines)
+
+	for {
+		select {
+		case line := <-lines:
+			if err := ws.WriteText(line); err != nil {
+				return
+			}
+		case <-ws.Closed():
+			return
+		}
+	}
+}
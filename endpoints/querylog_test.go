@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWebsocketAccept(t *testing.T) {
+	// Example from RFC 6455
+	accept := websocketAccept("dGhlIHNhbXBsZSBub25jZQ==")
+	if accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Error("Unexpected websocket accept value:", accept)
+	}
+}
+
+func TestQueryLogBroadcaster(t *testing.T) {
+	b := NewQueryLogBroadcaster()
+	lines := b.Subscribe()
+
+	b.Write([]byte("GET /status\n"))
+	if line := <-lines; string(line) != "GET /status" {
+		t.Error("Unexpected query log line:", string(line))
+	}
+
+	b.Unsubscribe(lines)
+	b.Write([]byte("GET /protocols\n"))
+	select {
+	case line := <-lines:
+		t.Error("Unsubscribed channel received:", string(line))
+	default:
+	}
+}
+
+func TestCheckAdminAuth(t *testing.T) {
+	Conf.AdminTokens = []string{"secret"}
+	defer func() { Conf.AdminTokens = nil }()
+
+	req, _ := http.NewRequest("GET", "/ws/querylog", nil)
+	if CheckAdminAuth(req) == nil {
+		t.Error("Request without token should be rejected")
+	}
+
+	req.Header.Set("Authorization", "Bearer wrong")
+	if CheckAdminAuth(req) == nil {
+		t.Error("Request with wrong token should be rejected")
+	}
+
+	req.Header.Set("Authorization", "Bearer secret")
+	if err := CheckAdminAuth(req); err != nil {
+		t.Error("Request with valid token should be accepted:", err)
+	}
+}
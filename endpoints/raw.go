@@ -0,0 +1,38 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Check if the command starts with one of the
+// allowed command prefixes.
+func isRawCommandAllowed(command string) bool {
+	command = strings.Join(strings.Fields(command), " ")
+	for _, prefix := range Conf.RawCommands {
+		prefix = strings.Join(strings.Fields(prefix), " ")
+		if prefix == "" {
+			continue
+		}
+		if command == prefix || strings.HasPrefix(command, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func Raw(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	command := strings.Join(strings.Fields(r.URL.Query().Get("q")), " ")
+	if command == "" {
+		return bird.Parsed{"error": "missing query parameter q"}, false
+	}
+	if !isRawCommandAllowed(command) {
+		return bird.Parsed{"error": fmt.Sprintf("command not allowed: %s", command)}, false
+	}
+
+	return bird.RunRaw(command)
+}
@@ -0,0 +1,32 @@
+package endpoints
+
+import (
+	"testing"
+)
+
+func TestIsRawCommandAllowed(t *testing.T) {
+	Conf.RawCommands = []string{"show route count", "show status"}
+	defer func() { Conf.RawCommands = nil }()
+
+	allowed := []string{
+		"show status",
+		"show  route count",
+		"show route count table master",
+	}
+	for _, command := range allowed {
+		if !isRawCommandAllowed(command) {
+			t.Error("Expected command to be allowed:", command)
+		}
+	}
+
+	denied := []string{
+		"show route",
+		"show statusx",
+		"show protocols all",
+	}
+	for _, command := range denied {
+		if isRawCommandAllowed(command) {
+			t.Error("Expected command to be denied:", command)
+		}
+	}
+}
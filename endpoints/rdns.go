@@ -0,0 +1,177 @@
+package endpoints
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Reverse DNS enrichment
+//
+// The neighbor addresses, gateways and next hops of a
+// response are resolved to hostnames, which are added as
+// hostnames. Lookups are cached, failed lookups as well.
+
+type RdnsConfig struct {
+	Enabled     bool `toml:"enabled"`
+	Concurrency int  `toml:"concurrency"`
+	CacheTtl    int  `toml:"cache_ttl"` // seconds
+	Timeout     int  `toml:"timeout"`   // milliseconds for all lookups
+}
+
+var RdnsConf RdnsConfig
+
+type rdnsEntry struct {
+	hostname string
+	expires  time.Time
+}
+
+type rdnsCache struct {
+	sync.Mutex
+	entries map[string]rdnsEntry
+	lookup  func(ctx context.Context, addr string) ([]string, error)
+}
+
+var hostnames = &rdnsCache{
+	entries: map[string]rdnsEntry{},
+	lookup:  net.DefaultResolver.LookupAddr,
+}
+
+func (c *rdnsCache) get(addr string, now time.Time) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[addr]
+	if !ok || now.After(entry.expires) {
+		return "", false
+	}
+	return entry.hostname, true
+}
+
+func (c *rdnsCache) set(addr, hostname string, expires time.Time) {
+	c.Lock()
+	c.entries[addr] = rdnsEntry{hostname, expires}
+	c.Unlock()
+}
+
+// Expire removes outdated entries
+func (c *rdnsCache) Expire(now time.Time) {
+	c.Lock()
+	for addr, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, addr)
+		}
+	}
+	c.Unlock()
+}
+
+// Resolve the addresses with bounded concurrency. Addresses
+// not resolved within the timeout are left out.
+func (c *rdnsCache) resolve(addrs []string, config RdnsConfig) map[string]string {
+	now := time.Now()
+	ttl := time.Duration(config.CacheTtl) * time.Second
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	timeout := time.Duration(config.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+
+	res := map[string]string{}
+	mu := &sync.Mutex{}
+	pending := []string{}
+
+	for _, addr := range addrs {
+		if hostname, ok := c.get(addr, now); ok {
+			if hostname != "" {
+				res[addr] = hostname
+			}
+			continue
+		}
+		pending = append(pending, addr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sem := make(chan bool, concurrency)
+	wg := &sync.WaitGroup{}
+	for _, addr := range pending {
+		wg.Add(1)
+		sem <- true
+		go func(addr string) {
+			defer func() { <-sem; wg.Done() }()
+
+			names, err := c.lookup(ctx, addr)
+			if ctx.Err() != nil {
+				return // Try again with the next request
+			}
+
+			hostname := ""
+			if err == nil && len(names) > 0 {
+				hostname = strings.TrimSuffix(names[0], ".")
+			}
+			c.set(addr, hostname, now.Add(ttl))
+
+			if hostname != "" {
+				mu.Lock()
+				res[addr] = hostname
+				mu.Unlock()
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	return res
+}
+
+// Collect the neighbor addresses, gateways and next hops
+func collectAddresses(value interface{}, addrs map[string]bool) {
+	switch v := value.(type) {
+	case bird.Parsed:
+		for key, item := range v {
+			switch key {
+			case "neighbor_address", "gateway", "next_hop":
+				if addr, ok := item.(string); ok && net.ParseIP(addr) != nil {
+					addrs[addr] = true
+				}
+			default:
+				collectAddresses(item, addrs)
+			}
+		}
+	case *bird.Parsed:
+		collectAddresses(*v, addrs)
+	case []bird.Parsed:
+		for _, item := range v {
+			collectAddresses(item, addrs)
+		}
+	}
+}
+
+func resolveHostnames(ret bird.Parsed) map[string]string {
+	addrs := map[string]bool{}
+	collectAddresses(ret, addrs)
+
+	list := make([]string, 0, len(addrs))
+	for addr := range addrs {
+		list = append(list, addr)
+	}
+	return hostnames.resolve(list, RdnsConf)
+}
+
+// InstallRdnsCacheExpiry removes expired lookups every hour
+func InstallRdnsCacheExpiry() {
+	go func() {
+		for range time.Tick(time.Hour) {
+			hostnames.Expire(time.Now())
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestResolveHostnames(t *testing.T) {
+	var lookups int32
+	cache := &rdnsCache{
+		entries: map[string]rdnsEntry{},
+		lookup: func(ctx context.Context, addr string) ([]string, error) {
+			atomic.AddInt32(&lookups, 1)
+			if addr == "192.0.2.1" {
+				return []string{"rtr1.example.net."}, nil
+			}
+			return nil, fmt.Errorf("no PTR record")
+		},
+	}
+
+	addrs := map[string]bool{}
+	collectAddresses(bird.Parsed{
+		"routes": []bird.Parsed{
+			bird.Parsed{"gateway": "192.0.2.1", "bgp": bird.Parsed{"next_hop": "192.0.2.2"}},
+		},
+		"protocols": bird.Parsed{"R1": bird.Parsed{"neighbor_address": "192.0.2.1"}},
+	}, addrs)
+	if len(addrs) != 2 {
+		t.Fatal("Expected 2 addresses, got:", addrs)
+	}
+
+	list := []string{"192.0.2.1", "192.0.2.2"}
+	names := cache.resolve(list, RdnsConfig{Concurrency: 1})
+	if len(names) != 1 || names["192.0.2.1"] != "rtr1.example.net" {
+		t.Error("Unexpected hostnames:", names)
+	}
+
+	// Cached, including the failed lookup
+	cache.resolve(list, RdnsConfig{})
+	if lookups != 2 {
+		t.Error("Expected the lookups to be cached, got lookups:", lookups)
+	}
+}
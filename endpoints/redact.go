@@ -0,0 +1,112 @@
+package endpoints
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Redaction of fields for public looking glasses: the
+// configured fields are removed from the responses, or
+// masked, unless the client presents one of the tokens
+// or an admin token.
+
+type RedactConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Fields  []string `toml:"fields"`
+	Mask    bool     `toml:"mask"`
+	Tokens  []string `toml:"tokens"`
+}
+
+var RedactConf RedactConfig
+
+const maskedValue = "redacted"
+
+func isRedactToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, t := range RedactConf.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return isAdminToken(token)
+}
+
+// redactResult applies the redaction rules of the profile
+// of the request, or the global rules unless the client is
+// authenticated. The cached result is not modified.
+func redactResult(r *http.Request, ret bird.Parsed) bird.Parsed {
+	redactFields, mask := RedactConf.Fields, RedactConf.Mask
+	if _, profile := requestProfile(r); profile != nil {
+		redactFields, mask = profile.RedactFields, profile.RedactMask
+	} else if !RedactConf.Enabled || isRedactToken(requestToken(r)) {
+		return ret
+	}
+	if len(redactFields) == 0 {
+		return ret
+	}
+
+	fields := make(map[string]bool, len(redactFields))
+	for _, field := range redactFields {
+		fields[strings.ToLower(field)] = true
+	}
+	return redactValue(ret, fields, mask).(bird.Parsed)
+}
+
+func redactMap(m map[string]interface{}, fields map[string]bool, mask bool) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if fields[k] {
+			if mask {
+				res[k] = maskValue(v)
+			}
+			continue
+		}
+		res[k] = redactValue(v, fields, mask)
+	}
+	return res
+}
+
+func redactValue(v interface{}, fields map[string]bool, mask bool) interface{} {
+	switch t := v.(type) {
+	case bird.Parsed:
+		return bird.Parsed(redactMap(t, fields, mask))
+	case map[string]interface{}:
+		return redactMap(t, fields, mask)
+	case []bird.Parsed:
+		res := make([]bird.Parsed, len(t))
+		for i, p := range t {
+			res[i] = bird.Parsed(redactMap(p, fields, mask))
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, e := range t {
+			res[i] = redactValue(e, fields, mask)
+		}
+		return res
+	}
+	return v
+}
+
+// maskValue keeps the network of addresses (/24 for IPv4,
+// /32 for IPv6), other values are replaced.
+func maskValue(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return maskedValue
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return maskedValue
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+}
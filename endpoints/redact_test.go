@@ -0,0 +1,93 @@
+package endpoints
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestRedactResult(t *testing.T) {
+	formerConf := RedactConf
+	defer func() { RedactConf = formerConf }()
+	RedactConf = RedactConfig{
+		Enabled: true,
+		Fields:  []string{"neighbor_address", "med"},
+		Tokens:  []string{"internal"},
+	}
+
+	ret := bird.Parsed{
+		"protocols": bird.Parsed{
+			"R1": bird.Parsed{"neighbor_address": "192.0.2.17", "state": "up"},
+		},
+		"routes": []bird.Parsed{
+			{"network": "10.0.0.0/8", "bgp": bird.Parsed{"med": int64(10)}},
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/protocols/bgp", nil)
+	res := redactResult(req, ret)
+	r1 := res["protocols"].(bird.Parsed)["R1"].(bird.Parsed)
+	if _, ok := r1["neighbor_address"]; ok || r1["state"] != "up" {
+		t.Error("Expected the neighbor address to be removed:", r1)
+	}
+	bgp := res["routes"].([]bird.Parsed)[0]["bgp"].(bird.Parsed)
+	if _, ok := bgp["med"]; ok {
+		t.Error("Expected the MED to be removed:", bgp)
+	}
+
+	// The original result is not modified
+	if ret["protocols"].(bird.Parsed)["R1"].(bird.Parsed)["neighbor_address"] != "192.0.2.17" {
+		t.Error("The original result was modified")
+	}
+
+	// Authenticated clients get the full result
+	req.Header.Set("Authorization", "Bearer internal")
+	res = redactResult(req, ret)
+	r1 = res["protocols"].(bird.Parsed)["R1"].(bird.Parsed)
+	if r1["neighbor_address"] != "192.0.2.17" {
+		t.Error("Expected the full result with a token:", r1)
+	}
+
+	RedactConf.Mask = true
+	req.Header.Del("Authorization")
+	res = redactResult(req, ret)
+	r1 = res["protocols"].(bird.Parsed)["R1"].(bird.Parsed)
+	if r1["neighbor_address"] != "192.0.2.0/24" {
+		t.Error("Expected a masked address, got:", r1["neighbor_address"])
+	}
+}
+
+func TestRedactResultProfile(t *testing.T) {
+	formerProfiles := Profiles
+	defer func() { Profiles = formerProfiles }()
+	Profiles = map[string]ProfileConfig{
+		"public": {RedactFields: []string{"description"}},
+	}
+
+	ret := bird.Parsed{"description": "Peer", "state": "up"}
+	req := httptest.NewRequest("GET", "/status", nil)
+	res := redactResult(req, ret)
+	if res["description"] != "Peer" {
+		t.Error("Expected no redaction without a profile:", res)
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), profileContextKey{}, "public"))
+	res = redactResult(req, ret)
+	if _, ok := res["description"]; ok || res["state"] != "up" {
+		t.Error("Expected the redaction of the profile:", res)
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	if v := maskValue("2001:db8:1:2::1"); v != "2001:db8::/32" {
+		t.Error("Unexpected masked IPv6 address:", v)
+	}
+	if v := maskValue("peer description"); v != maskedValue {
+		t.Error("Unexpected masked value:", v)
+	}
+	if v := maskValue(int64(10)); v != maskedValue {
+		t.Error("Unexpected masked value:", v)
+	}
+}
@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+)
+
+// Buffering of large responses
+
+const defaultResponseBufferSize = 64 * 1024
+
+// flushWriter passes all writes to the client and flushes
+// the connection whenever flushBytes were written, so
+// large responses are streamed to the client.
+type flushWriter struct {
+	w          io.Writer
+	flusher    http.Flusher
+	flushBytes int
+	written    int
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f.flusher == nil || f.flushBytes <= 0 {
+		return n, err
+	}
+
+	f.written += n
+	if f.written >= f.flushBytes {
+		f.flusher.Flush()
+		f.written = 0
+	}
+	return n, err
+}
+
+// bufferedResponse creates a buffered writer for the
+// response body with the configured buffer size.
+func bufferedResponse(w http.ResponseWriter) *bufio.Writer {
+	size := Conf.ResponseBufferSize
+	if size <= 0 {
+		size = defaultResponseBufferSize
+	}
+
+	flusher, _ := w.(http.Flusher)
+	out := &flushWriter{
+		w:          w,
+		flusher:    flusher,
+		flushBytes: Conf.ResponseFlushBytes,
+	}
+
+	return bufio.NewWriterSize(out, size)
+}
@@ -0,0 +1,34 @@
+package endpoints
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type countingFlusher struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (c *countingFlusher) Flush() {
+	c.flushes++
+}
+
+func TestFlushWriter(t *testing.T) {
+	w := &countingFlusher{ResponseRecorder: httptest.NewRecorder()}
+	f := &flushWriter{w: w, flusher: w, flushBytes: 10}
+
+	f.Write([]byte("12345"))
+	if w.flushes != 0 {
+		t.Error("Expected no flush before 10 bytes were written")
+	}
+
+	f.Write([]byte("123456"))
+	if w.flushes != 1 {
+		t.Error("Expected a flush after 10 bytes were written, got:", w.flushes)
+	}
+
+	if w.Body.String() != "12345123456" {
+		t.Error("Unexpected body:", w.Body.String())
+	}
+}
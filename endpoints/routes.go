@@ -3,17 +3,22 @@ package endpoints
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/alice-lg/birdwatcher/bird"
 	"github.com/julienschmidt/httprouter"
 )
 
 func ProtoRoutes(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
-	protocol, err := ValidateProtocolParam(ps.ByName("protocol"))
+	protocol, err := ValidateProtocolPatternParam(ps.ByName("protocol"))
 	if err != nil {
 		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
 	}
 
+	if bird.IsProtocolPattern(protocol) {
+		return bird.RoutesProtoPattern(useCache, protocol)
+	}
 	return bird.RoutesProto(useCache, protocol)
 }
 
@@ -35,21 +40,52 @@ func RoutesNoExport(r *http.Request, ps httprouter.Params, useCache bool) (bird.
 	return bird.RoutesNoExport(useCache, protocol)
 }
 
+func RoutesExported(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	protocol, err := ValidateProtocolParam(ps.ByName("protocol"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	return bird.RoutesExport(useCache, protocol)
+}
+
+func RoutesReceived(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	protocol, err := ValidateProtocolParam(ps.ByName("protocol"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	return bird.RoutesReceived(useCache, protocol)
+}
+
 func RoutesPrefixed(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
 	qs := r.URL.Query()
 	prefixl := qs["prefix"]
 	if len(prefixl) != 1 {
-		return bird.Parsed{"error": "need a prefix as single query parameter"}, false
+		return invalidNet(fmt.Errorf("need a prefix as single query parameter")), false
 	}
 
-	prefix, err := ValidatePrefixParam(prefixl[0])
+	prefix, err := ValidateNetParam(prefixl[0])
 	if err != nil {
-		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		return invalidNet(err), false
 	}
 
 	return bird.RoutesPrefixed(useCache, prefix)
 }
 
+func Announcers(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	qs := r.URL.Query()
+	if qs.Get("prefix") == "" {
+		return invalidNet(fmt.Errorf("need a prefix as query parameter")), false
+	}
+	prefix, err := ValidateNetParam(qs.Get("prefix"))
+	if err != nil {
+		return invalidNet(err), false
+	}
+
+	return bird.Announcers(useCache, prefix)
+}
+
 func TableRoutes(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
 	table, err := ValidateProtocolParam(ps.ByName("table"))
 	if err != nil {
@@ -108,19 +144,23 @@ func TableCount(r *http.Request, ps httprouter.Params, useCache bool) (bird.Pars
 	return bird.RoutesTableCount(useCache, table)
 }
 
+func CountAll(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.RoutesCountAll(useCache)
+}
+
 func RouteNet(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
-	net, err := ValidatePrefixParam(ps.ByName("net"))
+	net, err := ValidateNetParam(ps.ByName("net"))
 	if err != nil {
-		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		return invalidNet(err), false
 	}
 
 	return bird.RoutesLookupTable(useCache, net, "master")
 }
 
 func RouteNetTable(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
-	net, err := ValidatePrefixParam(ps.ByName("net"))
+	net, err := ValidateNetParam(ps.ByName("net"))
 	if err != nil {
-		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		return invalidNet(err), false
 	}
 
 	table, err := ValidateProtocolParam(ps.ByName("table"))
@@ -131,6 +171,23 @@ func RouteNetTable(r *http.Request, ps httprouter.Params, useCache bool) (bird.P
 	return bird.RoutesLookupTable(useCache, net, table)
 }
 
+func RouteNetExplain(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	net, err := ValidateNetParam(ps.ByName("net"))
+	if err != nil {
+		return invalidNet(err), false
+	}
+
+	table := "master"
+	if ps.ByName("table") != "" {
+		table, err = ValidateProtocolParam(ps.ByName("table"))
+		if err != nil {
+			return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+		}
+	}
+
+	return bird.RoutesLookupTableExplain(useCache, net, table)
+}
+
 func PipeRoutesFiltered(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
 	qs := r.URL.Query()
 
@@ -191,3 +248,32 @@ func PeerRoutes(r *http.Request, ps httprouter.Params, useCache bool) (bird.Pars
 
 	return bird.RoutesPeer(useCache, peer)
 }
+
+func TableRoutesChanges(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	table, err := ValidateProtocolParam(ps.ByName("table"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		return bird.Parsed{"error": fmt.Sprintf("%s", err)}, false
+	}
+
+	return bird.RoutesChanges(useCache, table, since)
+}
+
+// parseSince accepts a RFC 3339 timestamp or unix seconds
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("since is required")
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be a RFC 3339 timestamp or unix time")
+	}
+	return since, nil
+}
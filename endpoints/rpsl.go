@@ -0,0 +1,140 @@
+package endpoints
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Export of routes as RPSL route and route6 objects
+// with the origin AS taken from the AS path.
+
+type rpslRoute struct {
+	prefix   string
+	origin   int64
+	protocol string
+}
+
+var rpslName = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// The origin is the last AS of the path. Routes with an
+// AS set as origin or without AS path are skipped.
+func routeOrigin(route bird.Parsed) (int64, bool) {
+	bgp, _ := route["bgp"].(bird.Parsed)
+	path, _ := bgp["as_path"].([]string)
+	if len(path) == 0 {
+		return 0, false
+	}
+	origin, err := bird.ParseAsn(path[len(path)-1])
+	if err != nil {
+		return 0, false
+	}
+	return origin, true
+}
+
+func rpslRoutes(routes []bird.Parsed) []rpslRoute {
+	seen := map[string]bool{}
+	res := []rpslRoute{}
+
+	for _, route := range routes {
+		network, _ := route["network"].(string)
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			continue
+		}
+		origin, ok := routeOrigin(route)
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%s AS%d", network, origin)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		protocol, _ := route["from_protocol"].(string)
+		res = append(res, rpslRoute{network, origin, protocol})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].prefix != res[j].prefix {
+			return bird.PrefixLess(res[i].prefix, res[j].prefix)
+		}
+		return res[i].origin < res[j].origin
+	})
+	return res
+}
+
+func renderRPSL(w io.Writer, routes []bird.Parsed, source string, mntBy string) {
+	for _, route := range rpslRoutes(routes) {
+		class := "route"
+		if ip, _, _ := net.ParseCIDR(route.prefix); ip.To4() == nil {
+			class = "route6"
+		}
+
+		fmt.Fprintf(w, "%-16s%s\n", class+":", route.prefix)
+		fmt.Fprintf(w, "%-16sAS%d\n", "origin:", route.origin)
+		if route.protocol != "" {
+			fmt.Fprintf(w, "%-16sLearned from %s\n", "descr:", route.protocol)
+		}
+		if mntBy != "" {
+			fmt.Fprintf(w, "%-16s%s\n", "mnt-by:", mntBy)
+		}
+		fmt.Fprintf(w, "%-16s%s\n", "source:", source)
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// rpslExport renders the routes selected by the parameters
+func rpslExport(query func(ps httprouter.Params) (func(useCache bool) (bird.Parsed, bool), error)) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		qs := r.URL.Query()
+		source := qs.Get("source")
+		if source == "" {
+			source = "BIRDWATCHER"
+		}
+		mntBy := qs.Get("mnt_by")
+		if !rpslName.MatchString(source) || !rpslName.MatchString(mntBy) {
+			http.Error(w, "Invalid source or mnt_by", http.StatusBadRequest)
+			return
+		}
+
+		fetch, err := query(ps)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeRoutesExport(w, r, ps, fetch, func(out io.Writer, routes []bird.Parsed) {
+			renderRPSL(out, routes, source, mntBy)
+		})
+	}
+}
+
+// RPSLExportTable renders the routes of a table as RPSL objects
+var RPSLExportTable = rpslExport(func(ps httprouter.Params) (func(bool) (bird.Parsed, bool), error) {
+	table, err := ValidateProtocolParam(ps.ByName("table"))
+	if err != nil {
+		return nil, err
+	}
+	return func(useCache bool) (bird.Parsed, bool) {
+		return bird.RoutesTable(useCache, table)
+	}, nil
+})
+
+// RPSLExportProtocol renders the routes of a protocol as RPSL objects
+var RPSLExportProtocol = rpslExport(func(ps httprouter.Params) (func(bool) (bird.Parsed, bool), error) {
+	protocol, err := ValidateProtocolParam(ps.ByName("protocol"))
+	if err != nil {
+		return nil, err
+	}
+	return func(useCache bool) (bird.Parsed, bool) {
+		return bird.RoutesProto(useCache, protocol)
+	}, nil
+})
@@ -0,0 +1,49 @@
+package endpoints
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestRenderRPSL(t *testing.T) {
+	routes := []bird.Parsed{
+		bird.Parsed{
+			"network":       "2001:db8::/32",
+			"from_protocol": "R1",
+			"bgp":           bird.Parsed{"as_path": []string{"65001", "65002"}},
+		},
+		bird.Parsed{
+			"network":       "10.0.0.0/24",
+			"from_protocol": "R1",
+			"bgp":           bird.Parsed{"as_path": []string{"65001"}},
+		},
+		bird.Parsed{
+			"network":       "10.0.0.0/24",
+			"from_protocol": "R2",
+			"bgp":           bird.Parsed{"as_path": []string{"65003", "65001"}},
+		},
+		// Without origin
+		bird.Parsed{"network": "10.0.1.0/24", "from_protocol": "static1"},
+	}
+
+	buf := &bytes.Buffer{}
+	renderRPSL(buf, routes, "TEST", "MAINT-TEST")
+
+	expected := "route:          10.0.0.0/24\n" +
+		"origin:         AS65001\n" +
+		"descr:          Learned from R1\n" +
+		"mnt-by:         MAINT-TEST\n" +
+		"source:         TEST\n" +
+		"\n" +
+		"route6:         2001:db8::/32\n" +
+		"origin:         AS65002\n" +
+		"descr:          Learned from R1\n" +
+		"mnt-by:         MAINT-TEST\n" +
+		"source:         TEST\n" +
+		"\n"
+	if buf.String() != expected {
+		t.Error("Unexpected RPSL objects:", buf.String())
+	}
+}
@@ -0,0 +1,63 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Hash of the loaded configuration, set on startup
+var ConfigHash string
+
+var startedAt = time.Now()
+
+// StatusSelf returns the health of the birdwatcher, in
+// contrast to /status returning the status of BIRD.
+func StatusSelf(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	mem := runtime.MemStats{}
+	runtime.ReadMemStats(&mem)
+
+	self := bird.SelfStatus()
+	self["version"] = VERSION
+	self["started_at"] = startedAt.UTC()
+	self["uptime_seconds"] = int64(time.Since(startedAt).Seconds())
+	self["goroutines"] = runtime.NumGoroutine()
+	self["heap_inuse_bytes"] = mem.HeapInuse
+	self["config_hash"] = ConfigHash
+	self["readiness"], _ = bird.Readiness()
+
+	return bird.Parsed{"self": self}, false
+}
+
+// Ready reports the readiness of the birdwatcher, with
+// 503 Service Unavailable while BIRD is not available.
+// Like all status endpoints it is subject to allow_from,
+// health checkers must be allowed.
+func Ready(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := CheckAccess(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	state, since := bird.Readiness()
+
+	w.Header().Set("Content-Type", "application/json")
+	if state != bird.ReadinessReady {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready": state == bird.ReadinessReady,
+		"state": state,
+		"since": since,
+	})
+}
+
+// Preflight returns the report of the checks of BIRD and
+// the configuration, which is logged on startup.
+func Preflight(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.Preflight(useCache), false
+}
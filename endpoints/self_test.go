@@ -0,0 +1,24 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyAccess(t *testing.T) {
+	Conf.AllowFrom = []string{"10.0.0.0/8"}
+	InitAccessControl()
+	defer func() {
+		Conf.AllowFrom = nil
+		InitAccessControl()
+	}()
+
+	req := httptest.NewRequest("GET", "/ready", nil)
+	req.RemoteAddr = "172.16.0.1:4242"
+	rec := httptest.NewRecorder()
+	Ready(rec, req, nil)
+	if rec.Code != http.StatusForbidden {
+		t.Error("Expected 403 for a client not allowed, got:", rec.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+// Sorting of results with ?sort=<key>&order=desc
+//
+// Routes are sorted by network, age, as_path_length,
+// local_pref or prefix_length. As protocols are keyed by name, their order
+// (by name, state, state_changed, neighbor_as or
+// routes_imported) is returned as protocols_order.
+
+func sortResult(qs url.Values, res bird.Parsed) bird.Parsed {
+	by := qs.Get("sort")
+	if by == "" || bird.IsSpecial(res) {
+		return res
+	}
+
+	desc := false
+	switch order := qs.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return bird.Parsed{"error": fmt.Sprintf("Invalid order: %s", order)}
+	}
+
+	ret := bird.Parsed{}
+	for k, v := range res {
+		ret[k] = v
+	}
+
+	if routes, ok := res["routes"].([]bird.Parsed); ok {
+		sorted, err := bird.SortRoutes(routes, by, desc)
+		if err != nil {
+			return bird.Parsed{"error": err.Error()}
+		}
+		ret["routes"] = sorted
+		return ret
+	}
+
+	if protocols, ok := res["protocols"].(bird.Parsed); ok {
+		names, err := bird.SortProtocols(protocols, by, desc)
+		if err != nil {
+			return bird.Parsed{"error": err.Error()}
+		}
+		ret["protocols_order"] = names
+		return ret
+	}
+
+	return bird.Parsed{"error": "sort is only available for routes and protocols"}
+}
@@ -26,3 +26,11 @@ func SymbolProtocols(r *http.Request, ps httprouter.Params, useCache bool) (bird
 	}
 	return bird.Parsed{"symbols": val["symbols"].(bird.Parsed)["protocol"]}, from_cache
 }
+
+func Tables(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.Tables(useCache)
+}
+
+func Filters(r *http.Request, ps httprouter.Params, useCache bool) (bird.Parsed, bool) {
+	return bird.Filters(useCache)
+}
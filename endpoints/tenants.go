@@ -0,0 +1,232 @@
+package endpoints
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Tenants scope the view of a shared router, e.g. a route
+// server, to their tables and protocols. The tenant of a
+// request is selected by its bearer token. Tenants can only
+// use the modules whose results are scoped: the table and
+// protocol parameters are checked and protocols and routes
+// of other tenants are removed from the results.
+
+type TenantConfig struct {
+	Tokens []string `toml:"tokens"`
+
+	// Patterns of the names, e.g. ["T_customer1", "R_customer1_*"]
+	Tables    []string `toml:"tables"`
+	Protocols []string `toml:"protocols"`
+}
+
+var Tenants map[string]TenantConfig
+
+// Modules available to tenants
+var tenantModules = []string{
+	"status",
+	"protocols",
+	"protocols_bgp",
+	"protocols_stats",
+	"routes_protocol",
+	"routes_peer",
+	"routes_table",
+	"routes_table_filtered",
+	"routes_table_peer",
+	"routes_count_protocol",
+	"routes_count_table",
+	"routes_count_primary",
+	"routes_filtered",
+	"routes_noexport",
+	"routes_exported",
+	"routes_received",
+	"route_net",
+	"lookup_announcers",
+	"export",
+}
+
+// ValidateTenants checks that no token selects more
+// than one tenant.
+func ValidateTenants(tenants map[string]TenantConfig) error {
+	tokens := map[string]string{}
+	for name, tenant := range tenants {
+		for _, token := range tenant.Tokens {
+			if token == "" {
+				return fmt.Errorf("Empty token in tenant %s", name)
+			}
+			if other, ok := tokens[token]; ok {
+				return fmt.Errorf("Token of tenant %s is also used by %s", name, other)
+			}
+			tokens[token] = name
+		}
+		for _, pattern := range append(tenant.Tables, tenant.Protocols...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("Invalid pattern %s in tenant %s", pattern, name)
+			}
+		}
+	}
+	return nil
+}
+
+func requestTenant(r *http.Request) (string, *TenantConfig) {
+	token := requestToken(r)
+	if token == "" {
+		return "", nil
+	}
+	for name, tenant := range Tenants {
+		for _, t := range tenant.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				tenant := tenant
+				return name, &tenant
+			}
+		}
+	}
+	return "", nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTenantError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	js, _ := json.Marshal(map[string]string{"error": msg})
+	w.Write(js)
+}
+
+// TenantEndpoint checks the module and the table and protocol
+// parameters of tenant requests. With require_tenant requests
+// without a tenant or admin token are rejected.
+func TenantEndpoint(module string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		name, tenant := requestTenant(r)
+		if tenant == nil {
+			if Conf.RequireTenant && !isInternalRequest(r) && !isAdminToken(requestToken(r)) {
+				writeTenantError(w, http.StatusUnauthorized, "a tenant token is required")
+				return
+			}
+			handle(w, r, ps)
+			return
+		}
+
+		if !containsModule(tenantModules, module) {
+			writeTenantError(w, http.StatusForbidden,
+				"module "+module+" is not available to tenant "+name)
+			return
+		}
+		if table := ps.ByName("table"); table != "" && !matchesAny(tenant.Tables, table) {
+			writeTenantError(w, http.StatusForbidden,
+				"table "+table+" is not available to tenant "+name)
+			return
+		}
+		// Results of protocol patterns are filtered
+		protocol := ps.ByName("protocol")
+		if protocol != "" && !bird.IsProtocolPattern(protocol) && !matchesAny(tenant.Protocols, protocol) {
+			writeTenantError(w, http.StatusForbidden,
+				"protocol "+protocol+" is not available to tenant "+name)
+			return
+		}
+		handle(w, r, ps)
+	}
+}
+
+// tenantResult removes the protocols and routes of other
+// tenants. Routes of a table of the tenant are kept. The
+// cached result is not modified.
+func tenantResult(r *http.Request, ps httprouter.Params, ret bird.Parsed) bird.Parsed {
+	_, tenant := requestTenant(r)
+	if tenant == nil {
+		return ret
+	}
+	ownTable := ps.ByName("table") != ""
+
+	res := make(bird.Parsed, len(ret))
+	for k, v := range ret {
+		switch k {
+		case "protocols", "protocol_errors":
+			res[k] = tenantProtocols(tenant, v)
+		case "routes", "announcers":
+			if ownTable {
+				res[k] = v
+				continue
+			}
+			res[k] = tenantRoutes(tenant, v)
+		default:
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func tenantProtocols(tenant *TenantConfig, v interface{}) interface{} {
+	switch protocols := v.(type) {
+	case bird.Parsed:
+		return bird.Parsed(tenantProtocolMap(tenant, protocols))
+	case map[string]interface{}:
+		return tenantProtocolMap(tenant, protocols)
+	case []string:
+		res := []string{}
+		for _, name := range protocols {
+			if matchesAny(tenant.Protocols, name) {
+				res = append(res, name)
+			}
+		}
+		return res
+	}
+	return v
+}
+
+func tenantProtocolMap(tenant *TenantConfig, protocols map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	for name, p := range protocols {
+		if matchesAny(tenant.Protocols, name) {
+			res[name] = p
+		}
+	}
+	return res
+}
+
+// Routes are kept if learned from a protocol of the tenant
+func tenantRoutes(tenant *TenantConfig, v interface{}) interface{} {
+	visible := func(route map[string]interface{}) bool {
+		name, ok := route["from_protocol"].(string)
+		if !ok {
+			name, _ = route["protocol"].(string)
+		}
+		return matchesAny(tenant.Protocols, name)
+	}
+
+	switch routes := v.(type) {
+	case []bird.Parsed:
+		res := []bird.Parsed{}
+		for _, route := range routes {
+			if visible(route) {
+				res = append(res, route)
+			}
+		}
+		return res
+	case []interface{}:
+		res := []interface{}{}
+		for _, r := range routes {
+			if route, ok := r.(bird.Parsed); ok && visible(route) {
+				res = append(res, r)
+			} else if route, ok := r.(map[string]interface{}); ok && visible(route) {
+				res = append(res, r)
+			}
+		}
+		return res
+	}
+	return v
+}
@@ -0,0 +1,107 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestTenantEndpoint(t *testing.T) {
+	formerTenants, formerConf := Tenants, Conf
+	defer func() { Tenants, Conf = formerTenants, formerConf }()
+	Tenants = map[string]TenantConfig{
+		"customer1": {
+			Tokens:    []string{"c1-secret"},
+			Tables:    []string{"T_c1"},
+			Protocols: []string{"R_c1_*"},
+		},
+	}
+	Conf.RequireTenant = true
+	Conf.AdminTokens = []string{"admin-secret"}
+
+	ok := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Write([]byte("ok"))
+	}
+	serve := func(module string, ps httprouter.Params, token string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		TenantEndpoint(module, ok)(rec, req, ps)
+		return rec.Code
+	}
+
+	table := func(name string) httprouter.Params {
+		return httprouter.Params{{Key: "table", Value: name}}
+	}
+	protocol := func(name string) httprouter.Params {
+		return httprouter.Params{{Key: "protocol", Value: name}}
+	}
+
+	tests := []struct {
+		module string
+		ps     httprouter.Params
+		token  string
+		code   int
+	}{
+		{"routes_table", table("T_c1"), "", http.StatusUnauthorized},
+		{"routes_table", table("master"), "admin-secret", http.StatusOK},
+		{"routes_table", table("T_c1"), "c1-secret", http.StatusOK},
+		{"routes_table", table("T_c2"), "c1-secret", http.StatusForbidden},
+		{"routes_protocol", protocol("R_c1_1"), "c1-secret", http.StatusOK},
+		{"routes_protocol", protocol("R_c2_1"), "c1-secret", http.StatusForbidden},
+		{"routes_protocol", protocol("R_*"), "c1-secret", http.StatusOK},
+		{"symbols", nil, "c1-secret", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		if code := serve(test.module, test.ps, test.token); code != test.code {
+			t.Error("Unexpected status for", test.module, test.ps, test.token, "-", code)
+		}
+	}
+}
+
+func TestTenantResult(t *testing.T) {
+	formerTenants := Tenants
+	defer func() { Tenants = formerTenants }()
+	Tenants = map[string]TenantConfig{
+		"customer1": {Tokens: []string{"c1-secret"}, Protocols: []string{"R_c1_*"}},
+	}
+
+	ret := bird.Parsed{
+		"protocols": bird.Parsed{
+			"R_c1_1": bird.Parsed{"state": "up"},
+			"R_c2_1": bird.Parsed{"state": "up"},
+		},
+		"routes": []bird.Parsed{
+			{"network": "192.0.2.0/24", "from_protocol": "R_c1_1"},
+			{"network": "198.51.100.0/24", "from_protocol": "R_c2_1"},
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if res := tenantResult(req, nil, ret); len(res["routes"].([]bird.Parsed)) != 2 {
+		t.Error("Expected the full result without a tenant")
+	}
+
+	req.Header.Set("Authorization", "Bearer c1-secret")
+	res := tenantResult(req, nil, ret)
+	if protocols := res["protocols"].(bird.Parsed); len(protocols) != 1 || protocols["R_c1_1"] == nil {
+		t.Error("Unexpected protocols:", protocols)
+	}
+	if routes := res["routes"].([]bird.Parsed); len(routes) != 1 || routes[0]["network"] != "192.0.2.0/24" {
+		t.Error("Unexpected routes:", routes)
+	}
+	if len(ret["protocols"].(bird.Parsed)) != 2 {
+		t.Error("Expected the cached result to be unchanged")
+	}
+
+	// All routes of a table of the tenant are visible
+	res = tenantResult(req, httprouter.Params{{Key: "table", Value: "T_c1"}}, ret)
+	if len(res["routes"].([]bird.Parsed)) != 2 {
+		t.Error("Expected all routes of the table")
+	}
+}
@@ -0,0 +1,209 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// A minimal looking glass at /ui for small deployments
+// without a separate frontend. The page uses the API:
+// /protocols/bgp for the neighbors and /route/net/:net
+// for the prefix lookup, so these modules must be enabled.
+//
+// The page is kept in the source, as go:embed is not
+// available with the Go version of the module.
+
+// UI serves the looking glass page
+func UI(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := CheckAccess(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy",
+		"default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Write([]byte(uiPage))
+}
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>birdwatcher looking glass</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 1em 2em; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { text-align: left; padding: 2px 8px; border-bottom: 1px solid #ddd; }
+th { background: #f4f4f4; }
+tr.neighbor { cursor: pointer; }
+tr.neighbor:hover { background: #eef; }
+.down { color: #a00; }
+.error { color: #a00; }
+pre { background: #f8f8f8; padding: 1em; }
+nav a { margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>birdwatcher</h1>
+<nav><a href="#neighbors">Neighbors</a><a href="#lookup">Prefix lookup</a></nav>
+
+<section id="lookup-view" hidden>
+<h2>Prefix lookup</h2>
+<form id="lookup-form">
+<input id="prefix" placeholder="192.0.2.1 or 2001:db8::1" size="40">
+<button>Look up</button>
+</form>
+<div id="lookup-result"></div>
+</section>
+
+<section id="neighbors-view" hidden>
+<h2>Neighbors</h2>
+<div id="neighbors"></div>
+</section>
+
+<section id="protocol-view" hidden>
+<h2 id="protocol-name"></h2>
+<div id="protocol"></div>
+</section>
+
+<script>
+"use strict";
+
+function el(tag, text, cls) {
+  var e = document.createElement(tag);
+  if (text !== undefined && text !== null) { e.textContent = String(text); }
+  if (cls) { e.className = cls; }
+  return e;
+}
+
+function table(headers, rows) {
+  var t = el("table");
+  var head = el("tr");
+  headers.forEach(function(h) { head.appendChild(el("th", h)); });
+  t.appendChild(head);
+  rows.forEach(function(r) { t.appendChild(r); });
+  return t;
+}
+
+function row(values) {
+  var tr = el("tr");
+  values.forEach(function(v) { tr.appendChild(el("td", v)); });
+  return tr;
+}
+
+function api(path) {
+  return fetch(path, {headers: {"Accept": "application/json"}}).then(function(res) {
+    return res.json().then(function(body) {
+      if (!res.ok || body.error) {
+        throw new Error(body.error || res.statusText);
+      }
+      return body;
+    });
+  });
+}
+
+function fail(target, err) {
+  target.textContent = "";
+  target.appendChild(el("p", err.message, "error"));
+}
+
+var neighbors = {};
+
+function showNeighbors() {
+  var target = document.getElementById("neighbors");
+  target.textContent = "Loading...";
+  api("protocols/bgp").then(function(body) {
+    neighbors = body.protocols || {};
+    var rows = Object.keys(neighbors).sort().map(function(name) {
+      var p = neighbors[name];
+      var routes = p.routes || {};
+      var tr = row([name, p.neighbor_address, p.neighbor_as, p.description,
+        p.state, p.state_changed, routes.imported, routes.exported, routes.filtered]);
+      tr.className = "neighbor" + (p.state === "up" ? "" : " down");
+      tr.onclick = function() { location.hash = "protocol/" + encodeURIComponent(name); };
+      return tr;
+    });
+    target.textContent = "";
+    target.appendChild(table(["Protocol", "Neighbor", "ASN", "Description",
+      "State", "Since", "Imported", "Exported", "Filtered"], rows));
+  }).catch(function(err) { fail(target, err); });
+}
+
+function showProtocol(name) {
+  document.getElementById("protocol-name").textContent = name;
+  var target = document.getElementById("protocol");
+  var render = function() {
+    target.textContent = "";
+    var p = neighbors[name];
+    if (!p) {
+      target.appendChild(el("p", "Unknown protocol", "error"));
+      return;
+    }
+    var rows = Object.keys(p).sort().map(function(k) {
+      var v = p[k];
+      return row([k, typeof v === "object" ? JSON.stringify(v) : v]);
+    });
+    target.appendChild(table(["Field", "Value"], rows));
+  };
+  if (Object.keys(neighbors).length) {
+    render();
+    return;
+  }
+  target.textContent = "Loading...";
+  api("protocols/bgp").then(function(body) {
+    neighbors = body.protocols || {};
+    render();
+  }).catch(function(err) { fail(target, err); });
+}
+
+function lookup(prefix) {
+  var target = document.getElementById("lookup-result");
+  target.textContent = "Loading...";
+  api("route/net/" + encodeURIComponent(prefix)).then(function(body) {
+    var rows = (body.routes || []).map(function(r) {
+      var bgp = r.bgp || {};
+      return row([r.network, r.gateway, r.from_protocol, r.primary ? "*" : "",
+        (bgp.as_path || []).join(" "), bgp.local_pref, bgp.med,
+        (bgp.communities || []).map(function(c) { return "(" + c.join(",") + ")"; }).join(" ")]);
+    });
+    target.textContent = "";
+    if (!rows.length) {
+      target.appendChild(el("p", "No routes"));
+      return;
+    }
+    target.appendChild(table(["Network", "Gateway", "Protocol", "Best",
+      "AS path", "Local pref", "MED", "Communities"], rows));
+  }).catch(function(err) { fail(target, err); });
+}
+
+document.getElementById("lookup-form").onsubmit = function(e) {
+  e.preventDefault();
+  var prefix = document.getElementById("prefix").value.trim();
+  if (prefix) { lookup(prefix); }
+};
+
+function route() {
+  var hash = location.hash.replace(/^#/, "");
+  ["lookup", "neighbors", "protocol"].forEach(function(v) {
+    document.getElementById(v + "-view").hidden = true;
+  });
+  if (hash.indexOf("protocol/") === 0) {
+    document.getElementById("protocol-view").hidden = false;
+    showProtocol(decodeURIComponent(hash.slice("protocol/".length)));
+  } else if (hash === "lookup") {
+    document.getElementById("lookup-view").hidden = false;
+  } else {
+    document.getElementById("neighbors-view").hidden = false;
+    showNeighbors();
+  }
+}
+
+window.onhashchange = route;
+route();
+</script>
+</body>
+</html>
+`
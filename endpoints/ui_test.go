@@ -0,0 +1,28 @@
+package endpoints
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	UI(rec, httptest.NewRequest("GET", "/ui", nil), nil)
+
+	if rec.Code != 200 {
+		t.Fatal("Unexpected status:", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Error("Unexpected content type:", ct)
+	}
+	body := rec.Body.String()
+	for _, api := range []string{`"protocols/bgp"`, `"route/net/"`} {
+		if !strings.Contains(body, api) {
+			t.Error("Expected the page to use", api)
+		}
+	}
+	if strings.Contains(body, "innerHTML") {
+		t.Error("Expected responses to be inserted as text")
+	}
+}
@@ -20,6 +20,7 @@ type APIInfo struct {
 	Version         string
 	ResultFromCache bool        `json:"result_from_cache"`
 	CacheStatus     CacheStatus `json:"cache_status"`
+	OutputTruncated bool        `json:"output_truncated,omitempty"`
 }
 
 // go generate does not work in subdirectories. Beautious.
@@ -58,6 +59,7 @@ func GetApiInfo(res *bird.Parsed, from_cache bool) *APIInfo {
 	}
 
 	ai.CacheStatus = cacheInfo
+	ai.OutputTruncated, _ = api["output_truncated"].(bool)
 
 	return ai
 }
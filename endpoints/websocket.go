@@ -0,0 +1,197 @@
+package endpoints
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal server side implementation of the websocket
+// protocol (RFC 6455) sufficient for streaming text messages
+// to a client.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xa
+)
+
+type websocketConn struct {
+	sync.Mutex
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+	closed chan struct{}
+	once   sync.Once
+}
+
+func headerContains(header http.Header, key, value string) bool {
+	for _, v := range strings.Split(header.Get(key), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Perform the websocket handshake and take over the connection
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		return nil, errors.New("Not a websocket handshake")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("Connection can not be hijacked")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// Clear the deadlines set by the server timeouts
+	conn.SetDeadline(time.Time{})
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := &websocketConn{
+		conn:   conn,
+		rw:     rw,
+		closed: make(chan struct{}),
+	}
+	go ws.readLoop()
+
+	return ws, nil
+}
+
+func (ws *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	ws.Lock()
+	defer ws.Unlock()
+
+	header := []byte{0x80 | opcode}
+	length := len(payload)
+	switch {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := ws.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := ws.rw.Write(payload); err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
+
+func (ws *websocketConn) WriteText(message []byte) error {
+	return ws.writeFrame(wsOpText, message)
+}
+
+// Closed is signaled when the client closes the connection
+func (ws *websocketConn) Closed() <-chan struct{} {
+	return ws.closed
+}
+
+func (ws *websocketConn) Close() {
+	ws.once.Do(func() {
+		ws.writeFrame(wsOpClose, []byte{})
+		ws.conn.Close()
+		close(ws.closed)
+	})
+}
+
+// Read (and discard) client messages, answer pings and
+// handle the closing of the connection.
+func (ws *websocketConn) readLoop() {
+	defer ws.Close()
+
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(ws.rw, header); err != nil {
+			return
+		}
+
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7f)
+
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(ws.rw, ext); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(ws.rw, ext); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(ext)
+		}
+
+		// We do not expect clients to send large messages
+		if length > 4096 {
+			return
+		}
+
+		mask := make([]byte, 4)
+		if masked {
+			if _, err := io.ReadFull(ws.rw, mask); err != nil {
+				return
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.rw, payload); err != nil {
+			return
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case wsOpClose:
+			return
+		case wsOpPing:
+			ws.writeFrame(wsOpPong, payload)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+// Event publishing: Protocol state changes and route
+// changes are detected by polling bird and published
+// to NATS or Kafka.
+//
+// The events of a poll are published in batches by a
+// background sender. If the publisher can not keep up and
+// the queue is full, batches are dropped.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+type EventsConfig struct {
+	Enabled  bool `toml:"enabled"`
+	Interval int  `toml:"interval"` // seconds
+
+	// The publisher is one of nats or kafka
+	Publisher    string `toml:"publisher"`
+	NatsURL      string `toml:"nats_url"`
+	KafkaRestURL string `toml:"kafka_rest_url"`
+
+	// Credentials of the NATS server, either user and
+	// password or a token
+	NatsUser     string `toml:"nats_user"`
+	NatsPassword string `toml:"nats_password"`
+	NatsToken    string `toml:"nats_token"`
+
+	// Events per message (default 500) and messages
+	// queued for publishing (default 100)
+	BatchSize int `toml:"batch_size"`
+	QueueSize int `toml:"queue_size"`
+
+	ProtocolsTopic string `toml:"protocols_topic"`
+	RoutesTopic    string `toml:"routes_topic"`
+
+	// Publish route changes of these tables
+	RouteTables []string `toml:"route_tables"`
+}
+
+type Event struct {
+	Type string    `json:"type"` // protocol_state, route_added or route_removed
+	Time time.Time `json:"time"`
+
+	Protocol      string `json:"protocol,omitempty"`
+	State         string `json:"state,omitempty"`
+	PreviousState string `json:"previous_state,omitempty"`
+	Info          string `json:"info,omitempty"`
+
+	Table string      `json:"table,omitempty"`
+	Route bird.Parsed `json:"route,omitempty"`
+}
+
+// Publisher sends a batch of encoded events to a topic
+type Publisher interface {
+	Publish(topic string, payloads [][]byte) error
+}
+
+const (
+	defaultEventsBatchSize = 500
+	defaultEventsQueueSize = 100
+)
+
+func NewPublisher(config EventsConfig) (Publisher, error) {
+	switch config.Publisher {
+	case "nats":
+		return NewNatsPublisher(config.NatsURL, NatsCredentials{
+			User:     config.NatsUser,
+			Password: config.NatsPassword,
+			Token:    config.NatsToken,
+		})
+	case "kafka":
+		return NewKafkaRestPublisher(config.KafkaRestURL)
+	}
+	return nil, fmt.Errorf("Unknown event publisher: %s", config.Publisher)
+}
+
+// Compare the protocol states of two polls
+func diffProtocolStates(previous, current bird.Parsed, now time.Time) []Event {
+	names := []string{}
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	events := []Event{}
+	for _, name := range names {
+		state, _ := current[name].(bird.Parsed)
+		former, ok := previous[name].(bird.Parsed)
+		if ok && former["state"] == state["state"] && former["info"] == state["info"] {
+			continue
+		}
+
+		event := Event{
+			Type:     "protocol_state",
+			Time:     now,
+			Protocol: name,
+		}
+		event.State, _ = state["state"].(string)
+		event.Info, _ = state["info"].(string)
+		if ok {
+			event.PreviousState, _ = former["state"].(string)
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func routeKey(route bird.Parsed) string {
+	return fmt.Sprintf("%v %v %v",
+		route["network"], route["from_protocol"], route["gateway"])
+}
+
+func routesByKey(routes []bird.Parsed) map[string]bird.Parsed {
+	res := make(map[string]bird.Parsed, len(routes))
+	for _, route := range routes {
+		res[routeKey(route)] = route
+	}
+	return res
+}
+
+// Compare the routes of a table of two polls
+func diffRoutes(table string, previous, current map[string]bird.Parsed, now time.Time) []Event {
+	events := []Event{}
+	for key, route := range current {
+		if _, ok := previous[key]; !ok {
+			events = append(events, Event{
+				Type: "route_added", Time: now, Table: table, Route: route,
+			})
+		}
+	}
+	for key, route := range previous {
+		if _, ok := current[key]; !ok {
+			events = append(events, Event{
+				Type: "route_removed", Time: now, Table: table, Route: route,
+			})
+		}
+	}
+	return events
+}
+
+type eventBatch struct {
+	topic    string
+	payloads [][]byte
+}
+
+// EventWatcher polls bird and publishes the changes
+// since the last poll.
+type EventWatcher struct {
+	config    EventsConfig
+	publisher Publisher
+	queue     chan eventBatch
+
+	protocols bird.Parsed
+	routes    map[string]map[string]bird.Parsed
+}
+
+func NewEventWatcher(config EventsConfig, publisher Publisher) *EventWatcher {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultEventsBatchSize
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultEventsQueueSize
+	}
+	return &EventWatcher{
+		config:    config,
+		publisher: publisher,
+		queue:     make(chan eventBatch, config.QueueSize),
+		routes:    make(map[string]map[string]bird.Parsed),
+	}
+}
+
+// publish queues the events in batches without blocking the poll
+func (w *EventWatcher) publish(topic string, events []Event) {
+	batch := eventBatch{topic: topic}
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			log.Println("Could not encode event:", err)
+			continue
+		}
+		batch.payloads = append(batch.payloads, payload)
+
+		if len(batch.payloads) < w.config.BatchSize && i < len(events)-1 {
+			continue
+		}
+		select {
+		case w.queue <- batch:
+		default:
+			log.Println("Event queue is full, dropping", len(batch.payloads), "events")
+		}
+		batch = eventBatch{topic: topic}
+	}
+}
+
+// send publishes the queued batches
+func (w *EventWatcher) send() {
+	for batch := range w.queue {
+		if err := w.publisher.Publish(batch.topic, batch.payloads); err != nil {
+			log.Println("Could not publish", len(batch.payloads), "events:", err)
+		}
+	}
+}
+
+// Poll bird once. The first poll only records the state.
+func (w *EventWatcher) Poll(now time.Time) {
+	res, _ := bird.ProtocolsShort(false)
+	if !bird.IsSpecial(res) {
+		protocols, _ := res["protocols"].(bird.Parsed)
+		if w.protocols != nil {
+			w.publish(w.config.ProtocolsTopic,
+				diffProtocolStates(w.protocols, protocols, now))
+		}
+		w.protocols = protocols
+	}
+
+	for _, table := range w.config.RouteTables {
+		res, _ := bird.RoutesTable(false, table)
+		if bird.IsSpecial(res) {
+			continue
+		}
+		tableRoutes, _ := res["routes"].([]bird.Parsed)
+		routes := routesByKey(tableRoutes)
+		if previous, ok := w.routes[table]; ok {
+			w.publish(w.config.RoutesTopic,
+				diffRoutes(table, previous, routes, now))
+		}
+		w.routes[table] = routes
+	}
+}
+
+func (w *EventWatcher) Run() {
+	interval := time.Duration(w.config.Interval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	go w.send()
+
+	for {
+		w.Poll(time.Now())
+		time.Sleep(interval)
+	}
+}
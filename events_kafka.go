@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KafkaRestPublisher produces messages through the
+// Kafka REST proxy (v2 API).
+type KafkaRestPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewKafkaRestPublisher(restURL string) (*KafkaRestPublisher, error) {
+	if restURL == "" {
+		return nil, fmt.Errorf("kafka_rest_url is required")
+	}
+	return &KafkaRestPublisher{
+		url:    strings.TrimRight(restURL, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *KafkaRestPublisher) Publish(topic string, payloads [][]byte) error {
+	body := &bytes.Buffer{}
+	body.WriteString(`{"records":[`)
+	for i, payload := range payloads {
+		if i > 0 {
+			body.WriteString(`,`)
+		}
+		body.WriteString(`{"value":`)
+		body.Write(payload)
+		body.WriteString(`}`)
+	}
+	body.WriteString(`]}`)
+
+	resp, err := p.client.Post(p.url+"/topics/"+topic,
+		"application/vnd.kafka.json.v2+json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Kafka REST proxy responded: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NatsCredentials authenticate the client with
+// user and password or with a token
+type NatsCredentials struct {
+	User     string
+	Password string
+	Token    string
+}
+
+// NatsPublisher publishes messages using the
+// NATS client protocol. The connection is
+// established on demand.
+type NatsPublisher struct {
+	sync.Mutex
+	addr        string
+	credentials NatsCredentials
+	conn        net.Conn
+}
+
+// NewNatsPublisher creates a publisher for the server.
+// Credentials in the url are used unless configured.
+func NewNatsPublisher(natsURL string, credentials NatsCredentials) (*NatsPublisher, error) {
+	if natsURL == "" {
+		natsURL = "nats://localhost:4222"
+	}
+	u, err := url.Parse(natsURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" {
+		return nil, fmt.Errorf("Unsupported NATS url: %s", natsURL)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	if u.User != nil && credentials.User == "" && credentials.Token == "" {
+		if password, ok := u.User.Password(); ok {
+			credentials.User = u.User.Username()
+			credentials.Password = password
+		} else {
+			credentials.Token = u.User.Username()
+		}
+	}
+
+	return &NatsPublisher{addr: addr, credentials: credentials}, nil
+}
+
+func (p *NatsPublisher) connectOptions() ([]byte, error) {
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "birdwatcher",
+	}
+	if p.credentials.User != "" {
+		options["user"] = p.credentials.User
+		options["pass"] = p.credentials.Password
+	}
+	if p.credentials.Token != "" {
+		options["auth_token"] = p.credentials.Token
+	}
+	return json.Marshal(options)
+}
+
+func (p *NatsPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+
+	// The server greets with INFO
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO") {
+		conn.Close()
+		return fmt.Errorf("Unexpected NATS greeting: %q %v", info, err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	options, err := p.connectOptions()
+	if err == nil {
+		_, err = fmt.Fprintf(conn, "CONNECT %s\r\n", options)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	p.conn = conn
+	go p.readLoop(conn, reader)
+	return nil
+}
+
+// Answer the keepalive of the server
+func (p *NatsPublisher) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			p.Lock()
+			fmt.Fprintf(conn, "PONG\r\n")
+			p.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			log.Println("NATS error:", strings.TrimSpace(line))
+		}
+	}
+
+	p.Lock()
+	if p.conn == conn {
+		p.conn = nil
+	}
+	p.Unlock()
+	conn.Close()
+}
+
+// Publish sends one message per payload in a single write
+func (p *NatsPublisher) Publish(subject string, payloads [][]byte) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for _, payload := range payloads {
+		fmt.Fprintf(buf, "PUB %s %d\r\n", subject, len(payload))
+		buf.Write(payload)
+		buf.WriteString("\r\n")
+	}
+	_, err := p.conn.Write(buf.Bytes())
+	if err != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
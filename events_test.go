@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func TestDiffProtocolStates(t *testing.T) {
+	now := time.Now()
+	previous := bird.Parsed{
+		"R1": bird.Parsed{"state": "up", "info": "Established"},
+		"R2": bird.Parsed{"state": "up", "info": "Established"},
+	}
+	current := bird.Parsed{
+		"R1": bird.Parsed{"state": "up", "info": "Established"},
+		"R2": bird.Parsed{"state": "start", "info": "Active"},
+		"R3": bird.Parsed{"state": "up", "info": "Established"},
+	}
+
+	events := diffProtocolStates(previous, current, now)
+	if len(events) != 2 {
+		t.Fatal("Expected 2 events, got:", events)
+	}
+	if events[0].Protocol != "R2" || events[0].State != "start" ||
+		events[0].PreviousState != "up" {
+		t.Error("Unexpected event:", events[0])
+	}
+	if events[1].Protocol != "R3" || events[1].PreviousState != "" {
+		t.Error("Unexpected event:", events[1])
+	}
+}
+
+func TestDiffRoutes(t *testing.T) {
+	now := time.Now()
+	previous := routesByKey([]bird.Parsed{
+		bird.Parsed{"network": "10.0.0.0/24", "from_protocol": "R1", "gateway": "192.0.2.1"},
+		bird.Parsed{"network": "10.0.1.0/24", "from_protocol": "R1", "gateway": "192.0.2.1"},
+	})
+	current := routesByKey([]bird.Parsed{
+		bird.Parsed{"network": "10.0.0.0/24", "from_protocol": "R1", "gateway": "192.0.2.1"},
+		bird.Parsed{"network": "10.0.2.0/24", "from_protocol": "R2", "gateway": "192.0.2.2"},
+	})
+
+	events := diffRoutes("master", previous, current, now)
+	if len(events) != 2 {
+		t.Fatal("Expected 2 events, got:", events)
+	}
+	if events[0].Type != "route_added" || events[0].Route["network"] != "10.0.2.0/24" {
+		t.Error("Unexpected event:", events[0])
+	}
+	if events[1].Type != "route_removed" || events[1].Route["network"] != "10.0.1.0/24" {
+		t.Error("Unexpected event:", events[1])
+	}
+}
+
+func TestNatsPublisher(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {}\r\n"))
+
+		reader := bufio.NewReader(conn)
+		lines := []string{}
+		for i := 0; i < 5; i++ {
+			line, _ := reader.ReadString('\n')
+			lines = append(lines, strings.TrimSpace(line))
+		}
+		received <- lines
+	}()
+
+	publisher, err := NewNatsPublisher("nats://"+listener.Addr().String(),
+		NatsCredentials{User: "birdwatcher", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}
+	if err := publisher.Publish("birdwatcher.protocols", payloads); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := <-received
+	if !strings.HasPrefix(lines[0], "CONNECT ") ||
+		!strings.Contains(lines[0], `"user":"birdwatcher"`) ||
+		!strings.Contains(lines[0], `"pass":"secret"`) {
+		t.Error("Expected CONNECT with credentials, got:", lines[0])
+	}
+	if lines[1] != "PUB birdwatcher.protocols 7" || lines[2] != `{"a":1}` ||
+		lines[3] != "PUB birdwatcher.protocols 7" || lines[4] != `{"b":2}` {
+		t.Error("Unexpected messages:", lines[1:])
+	}
+}
+
+func TestKafkaRestPublisher(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		buf, _ := ioutil.ReadAll(r.Body)
+		body = string(buf)
+	}))
+	defer server.Close()
+
+	publisher, err := NewKafkaRestPublisher(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}
+	if err := publisher.Publish("birdwatcher.routes", payloads); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/topics/birdwatcher.routes" {
+		t.Error("Unexpected path:", path)
+	}
+	if body != `{"records":[{"value":{"a":1}},{"value":{"b":2}}]}` {
+		t.Error("Unexpected body:", body)
+	}
+}
+
+func TestEventWatcherBatches(t *testing.T) {
+	w := NewEventWatcher(EventsConfig{BatchSize: 2, QueueSize: 1}, nil)
+
+	events := []Event{
+		Event{Type: "route_added"},
+		Event{Type: "route_added"},
+		Event{Type: "route_removed"},
+	}
+	w.publish("birdwatcher.routes", events)
+
+	// The second batch is dropped as the queue is full
+	if len(w.queue) != 1 {
+		t.Fatal("Expected one queued batch, got:", len(w.queue))
+	}
+	batch := <-w.queue
+	if batch.topic != "birdwatcher.routes" || len(batch.payloads) != 2 {
+		t.Error("Unexpected batch:", batch)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"log"
+	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
 	"time"
 
 	"github.com/alice-lg/birdwatcher/bird"
@@ -31,6 +34,10 @@ func Housekeeping(config HousekeepingConfig, expireCaches bool) {
 
 			count := bird.ExpireCache()
 			log.Println("Expired", count, "entries (MemoryCache)")
+		} else {
+			// The route counts are cached in memory
+			count := bird.ExpireCountCache()
+			log.Println("Expired", count, "entries (route counts)")
 		}
 
 		if config.ForceReleaseMemory {
@@ -40,3 +47,31 @@ func Housekeeping(config HousekeepingConfig, expireCaches bool) {
 		}
 	}
 }
+
+// RestoreCache loads the cache persisted on the last shutdown
+func RestoreCache(filename string, maxAge int) {
+	count, err := bird.LoadCache(filename, time.Duration(maxAge)*time.Second)
+	if err != nil {
+		log.Println("Could not restore the cache:", err)
+	}
+	if count > 0 {
+		log.Println("Restored", count, "cache entries from", filename)
+	}
+}
+
+// SaveCacheOnShutdown persists the cache when birdwatcher
+// is terminated with SIGTERM or SIGINT
+func SaveCacheOnShutdown(filename string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+
+	log.Println("Received", sig, "- saving the cache to", filename)
+	count, err := bird.SaveCache(filename)
+	if err != nil {
+		log.Println("Could not save the cache:", err)
+		os.Exit(1)
+	}
+	log.Println("Saved", count, "cache entries")
+	os.Exit(0)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config fragments
+//
+// A config file can include fragments, e.g. dropped by
+// automation into a directory:
+//
+//   include = ["/etc/birdwatcher/conf.d/*.conf"]
+//
+// The include must be a top level key, before the first
+// section. Relative patterns are resolved against the
+// directory of the including file. The fragments are merged
+// in lexical order after the including file, a key set again
+// by a fragment overrides the value and is reported as
+// a conflict.
+
+// loadConfigFile decodes the file and its fragments
+func loadConfigFile(filename string) (*Config, []string, error) {
+	doc := map[string]interface{}{}
+	if _, err := toml.DecodeFile(filename, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	patterns, err := includePatterns(doc["include"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	delete(doc, "include")
+
+	fragments, err := includeFiles(filename, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conflicts := []string{}
+	origins := map[string]string{}
+	for key, value := range doc {
+		recordOrigins(value, key, filename, origins)
+	}
+	for _, fragment := range fragments {
+		frag := map[string]interface{}{}
+		if _, err := toml.DecodeFile(fragment, &frag); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := frag["include"]; ok {
+			return nil, nil, fmt.Errorf("%s: include is not supported in fragments", fragment)
+		}
+		mergeConfigDocument(doc, frag, "", fragment, origins, &conflicts)
+	}
+
+	if err := resolveSecrets(doc, ""); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	// Decode the merged document into the config
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(doc); err != nil {
+		return nil, nil, err
+	}
+	config := &Config{}
+	if _, err := toml.Decode(buf.String(), config); err != nil {
+		return nil, nil, err
+	}
+	return config, conflicts, nil
+}
+
+func includePatterns(value interface{}) ([]string, error) {
+	switch include := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{include}, nil
+	case []interface{}:
+		patterns := make([]string, 0, len(include))
+		for _, p := range include {
+			pattern, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("include must be a list of file patterns")
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	}
+	return nil, fmt.Errorf("include must be a list of file patterns")
+}
+
+// includeFiles expands the patterns to a sorted list of
+// files, patterns without a match are allowed.
+func includeFiles(filename string, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(filename), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %s: %s", filename, pattern, err)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// mergeConfigDocument merges the keys of src into dst and
+// records the file setting each key.
+func mergeConfigDocument(
+	dst map[string]interface{},
+	src map[string]interface{},
+	prefix string,
+	filename string,
+	origins map[string]string,
+	conflicts *[]string,
+) {
+	keys := make([]string, 0, len(src))
+	for key := range src {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := src[key]
+		path := prefix + key
+		if table, ok := value.(map[string]interface{}); ok {
+			if dstTable, ok := dst[key].(map[string]interface{}); ok {
+				mergeConfigDocument(dstTable, table, path+".", filename, origins, conflicts)
+				continue
+			}
+		}
+
+		if origin, ok := origins[path]; ok {
+			if !reflect.DeepEqual(dst[key], value) {
+				*conflicts = append(*conflicts, fmt.Sprintf(
+					"%s set by %s is overridden by %s", path, origin, filename))
+			}
+		} else if _, ok := dst[key]; ok {
+			// A section replaced by a value, or the reverse
+			*conflicts = append(*conflicts, fmt.Sprintf(
+				"%s is overridden by %s", path, filename))
+		}
+		dst[key] = value
+		recordOrigins(value, path, filename, origins)
+	}
+}
+
+func recordOrigins(value interface{}, path, filename string, origins map[string]string) {
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		origins[path] = filename
+		return
+	}
+	for key, v := range table {
+		recordOrigins(v, path+"."+key, filename, origins)
+	}
+}
+
+func logConfigConflicts(conflicts []string) {
+	for _, conflict := range conflicts {
+		log.Println("Config conflict:", conflict)
+	}
+}
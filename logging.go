@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"net"
+	"os"
+	"strings"
+)
+
+// Birdwatcher Logging
+
+type LoggingConfig struct {
+	// Output of the application log: stdout, syslog or journald
+	Output         string `toml:"output"`
+	SyslogFacility string `toml:"syslog_facility"`
+	Tag            string `toml:"tag"`
+	JournalSocket  string `toml:"journal_socket"`
+
+	// Access log format: common, combined or json
+	AccessLogFormat string `toml:"access_log_format"`
+	// Write the access log to this file instead of the log output
+	AccessLog string `toml:"access_log"`
+}
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// Create the writer for the configured log output
+func NewLogWriter(config LoggingConfig, fields map[string]string) (io.Writer, error) {
+	tag := config.Tag
+	if tag == "" {
+		tag = "birdwatcher"
+	}
+
+	switch config.Output {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "syslog":
+		facility := syslog.LOG_DAEMON
+		if config.SyslogFacility != "" {
+			f, ok := syslogFacilities[strings.ToLower(config.SyslogFacility)]
+			if !ok {
+				return nil, fmt.Errorf("Unknown syslog facility: %s", config.SyslogFacility)
+			}
+			facility = f
+		}
+		return syslog.New(facility|syslog.LOG_INFO, tag)
+	case "journald":
+		return NewJournalWriter(config.JournalSocket, tag, fields)
+	}
+
+	return nil, fmt.Errorf("Unknown logging output: %s", config.Output)
+}
+
+// JournalWriter sends every write as an entry to the
+// systemd journal using the native journal protocol.
+type JournalWriter struct {
+	conn   net.Conn
+	fields map[string]string
+}
+
+func NewJournalWriter(socket string, tag string, fields map[string]string) (*JournalWriter, error) {
+	if socket == "" {
+		socket = "/run/systemd/journal/socket"
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	entryFields := map[string]string{
+		"SYSLOG_IDENTIFIER": tag,
+		"PRIORITY":          "6", // info
+	}
+	for k, v := range fields {
+		entryFields[k] = v
+	}
+
+	return &JournalWriter{conn: conn, fields: entryFields}, nil
+}
+
+// Encode a field of a journal entry. Values containing
+// newlines need to be length prefixed.
+func appendJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key + "=" + value + "\n")
+		return
+	}
+
+	buf.WriteString(key + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+func encodeJournalEntry(message string, fields map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	appendJournalField(buf, "MESSAGE", message)
+	for k, v := range fields {
+		appendJournalField(buf, k, v)
+	}
+	return buf.Bytes()
+}
+
+// Write implements io.Writer
+func (j *JournalWriter) Write(p []byte) (int, error) {
+	message := strings.TrimRight(string(p), "\n")
+	if _, err := j.conn.Write(encodeJournalEntry(message, j.fields)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Configure the output of the application log
+func SetupLogging(config LoggingConfig, fields map[string]string) io.Writer {
+	writer, err := NewLogWriter(config, fields)
+	if err != nil {
+		log.Println("Could not set up logging, using stdout:", err)
+		writer = os.Stdout
+	}
+
+	log.SetOutput(writer)
+	return writer
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeJournalEntry(t *testing.T) {
+	entry := encodeJournalEntry("Starting Birdwatcher", map[string]string{
+		"SYSLOG_IDENTIFIER": "birdwatcher",
+	})
+	expected := "MESSAGE=Starting Birdwatcher\nSYSLOG_IDENTIFIER=birdwatcher\n"
+	if string(entry) != expected {
+		t.Errorf("Unexpected journal entry: %q", entry)
+	}
+
+	// Multiline messages are length prefixed
+	entry = encodeJournalEntry("foo\nbar", map[string]string{})
+	buf := &bytes.Buffer{}
+	buf.WriteString("MESSAGE\n")
+	binary.Write(buf, binary.LittleEndian, uint64(7))
+	buf.WriteString("foo\nbar\n")
+	if !bytes.Equal(entry, buf.Bytes()) {
+		t.Errorf("Unexpected journal entry: %q", entry)
+	}
+}
+
+func TestNewLogWriterUnknownOutput(t *testing.T) {
+	if _, err := NewLogWriter(LoggingConfig{Output: "carrier-pigeon"}, nil); err == nil {
+		t.Error("Expected an error for an unknown output")
+	}
+	if _, err := NewLogWriter(LoggingConfig{Output: "syslog", SyslogFacility: "nope"}, nil); err == nil {
+		t.Error("Expected an error for an unknown syslog facility")
+	}
+}
@@ -0,0 +1,132 @@
+package metrics
+
+// Metrics in the Prometheus text exposition format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the exported metrics
+type Registry struct {
+	sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// Default is the registry exported by the metrics endpoint
+var Default = NewRegistry()
+
+func (r *Registry) register(m metric) {
+	r.Lock()
+	r.metrics[m.name()] = m
+	r.Unlock()
+}
+
+// Write writes all metrics sorted by name
+func (r *Registry) Write(w io.Writer) error {
+	r.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.Unlock()
+
+	out := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(out)
+	}
+	return out.Flush()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	sync.Mutex
+	metricName string
+	help       string
+	value      float64
+}
+
+// NewCounter creates a counter in the default registry
+func NewCounter(name, help string) *Counter {
+	c := &Counter{metricName: name, help: help}
+	Default.register(c)
+	return c
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	c.Lock()
+	c.value += v
+	c.Unlock()
+}
+
+func (c *Counter) Value() float64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.value
+}
+
+func (c *Counter) name() string {
+	return c.metricName
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.metricName, c.help, "counter")
+	fmt.Fprintf(w, "%s %s\n", c.metricName, formatValue(c.Value()))
+}
+
+// GaugeFunc is a gauge with the value provided by a
+// function when the metrics are collected.
+type GaugeFunc struct {
+	metricName string
+	help       string
+	value      func() float64
+}
+
+// NewGaugeFunc creates a gauge in the default registry
+func NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, value: value}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.value()))
+}
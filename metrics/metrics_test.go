@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+
+	c := &Counter{metricName: "test_requests_total", help: "Requests"}
+	r.register(c)
+	c.Inc()
+	c.Add(2)
+
+	r.register(&GaugeFunc{
+		metricName: "test_queue_depth",
+		help:       "Queue depth",
+		value:      func() float64 { return 0.5 },
+	})
+
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "# HELP test_queue_depth Queue depth\n" +
+		"# TYPE test_queue_depth gauge\n" +
+		"test_queue_depth 0.5\n" +
+		"# HELP test_requests_total Requests\n" +
+		"# TYPE test_requests_total counter\n" +
+		"test_requests_total 3\n"
+	if buf.String() != expected {
+		t.Error("Unexpected metrics output:", buf.String())
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+// Push the per peer prefix counts and session states to
+// InfluxDB (line protocol) or Graphite (plaintext protocol).
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+type MetricsPushConfig struct {
+	Enabled  bool `toml:"enabled"`
+	Interval int  `toml:"interval"` // seconds
+
+	// The format is either influx or graphite
+	Format string `toml:"format"`
+	// InfluxDB write url, e.g. http://localhost:8086/write?db=bird,
+	// or the Graphite address, e.g. localhost:2003
+	URL string `toml:"url"`
+
+	// Influx measurement or Graphite metric prefix.
+	// {hostname} is replaced with the hostname.
+	Measurement string `toml:"measurement"`
+}
+
+var peerCounters = []string{"imported", "exported", "filtered", "preferred"}
+
+type peerSample struct {
+	protocol string
+	tags     map[string]string
+	values   map[string]int64
+}
+
+func collectPeerSamples(protocols bird.Parsed) []peerSample {
+	names := []string{}
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	samples := []peerSample{}
+	for _, name := range names {
+		protocol, ok := protocols[name].(bird.Parsed)
+		if !ok {
+			continue
+		}
+
+		sample := peerSample{
+			protocol: name,
+			tags:     map[string]string{"protocol": name},
+			values:   map[string]int64{},
+		}
+		if address, ok := protocol["neighbor_address"].(string); ok {
+			sample.tags["neighbor_address"] = address
+		}
+		if asn, ok := protocol["neighbor_as"].(int64); ok {
+			sample.tags["neighbor_as"] = fmt.Sprintf("%d", asn)
+		}
+
+		sample.values["up"] = 0
+		if protocol["state"] == "up" {
+			sample.values["up"] = 1
+		}
+		routes, _ := protocol["routes"].(bird.Parsed)
+		for _, key := range peerCounters {
+			n, _ := routes[key].(int64)
+			sample.values[key] = n
+		}
+
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// Format the samples in the InfluxDB line protocol
+func formatInflux(measurement string, samples []peerSample, now time.Time) []byte {
+	buf := &bytes.Buffer{}
+	for _, sample := range samples {
+		buf.WriteString(influxEscaper.Replace(measurement))
+
+		keys := []string{}
+		for key := range sample.tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(buf, ",%s=%s", key, influxEscaper.Replace(sample.tags[key]))
+		}
+
+		buf.WriteString(" ")
+		fmt.Fprintf(buf, "up=%di", sample.values["up"])
+		for _, key := range peerCounters {
+			fmt.Fprintf(buf, ",%s=%di", key, sample.values[key])
+		}
+		fmt.Fprintf(buf, " %d\n", now.UnixNano())
+	}
+	return buf.Bytes()
+}
+
+// Graphite path components may not contain dots
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_")
+
+// Format the samples in the Graphite plaintext protocol
+func formatGraphite(prefix string, samples []peerSample, now time.Time) []byte {
+	buf := &bytes.Buffer{}
+	for _, sample := range samples {
+		path := prefix + "." + graphiteEscaper.Replace(sample.protocol)
+		keys := append([]string{"up"}, peerCounters...)
+		for _, key := range keys {
+			fmt.Fprintf(buf, "%s.%s %d %d\n", path, key, sample.values[key], now.Unix())
+		}
+	}
+	return buf.Bytes()
+}
+
+type MetricsPusher struct {
+	config      MetricsPushConfig
+	measurement string
+	client      *http.Client
+}
+
+func NewMetricsPusher(config MetricsPushConfig) (*MetricsPusher, error) {
+	if config.Format != "influx" && config.Format != "graphite" {
+		return nil, fmt.Errorf("Unknown metrics push format: %s", config.Format)
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("Please specify the url to push metrics to")
+	}
+
+	measurement := config.Measurement
+	if measurement == "" {
+		measurement = "bird_peer"
+		if config.Format == "graphite" {
+			measurement = "birdwatcher.{hostname}.peers"
+		}
+	}
+	hostname, _ := os.Hostname()
+	measurement = strings.Replace(measurement, "{hostname}",
+		graphiteEscaper.Replace(hostname), -1)
+
+	return &MetricsPusher{
+		config:      config,
+		measurement: measurement,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *MetricsPusher) push(samples []peerSample, now time.Time) error {
+	if p.config.Format == "graphite" {
+		conn, err := net.DialTimeout("tcp", p.config.URL, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.Write(formatGraphite(p.measurement, samples, now))
+		return err
+	}
+
+	resp, err := p.client.Post(p.config.URL, "text/plain",
+		bytes.NewReader(formatInflux(p.measurement, samples, now)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("InfluxDB responded: %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *MetricsPusher) Run() {
+	interval := time.Duration(p.config.Interval) * time.Second
+	if interval <= 0 {
+		interval = 60 * time.Second
+	}
+
+	for {
+		res, _ := bird.ProtocolsBgp(true)
+		if !bird.IsSpecial(res) {
+			protocols, _ := res["protocols"].(bird.Parsed)
+			if err := p.push(collectPeerSamples(protocols), time.Now()); err != nil {
+				log.Println("Could not push metrics:", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+func testPeerSamples() []peerSample {
+	return collectPeerSamples(bird.Parsed{
+		"R192_1": bird.Parsed{
+			"neighbor_address": "192.0.2.1",
+			"neighbor_as":      int64(65001),
+			"state":            "up",
+			"routes": bird.Parsed{
+				"imported":  int64(10),
+				"exported":  int64(20),
+				"filtered":  int64(1),
+				"preferred": int64(9),
+			},
+		},
+	})
+}
+
+func TestFormatInflux(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	out := string(formatInflux("bird peer", testPeerSamples(), now))
+
+	expected := `bird\ peer,neighbor_address=192.0.2.1,neighbor_as=65001,protocol=R192_1 ` +
+		"up=1i,imported=10i,exported=20i,filtered=1i,preferred=9i 1500000000000000000\n"
+	if out != expected {
+		t.Error("Unexpected line protocol:", out)
+	}
+}
+
+func TestFormatGraphite(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	out := string(formatGraphite("birdwatcher.rs1.peers", testPeerSamples(), now))
+
+	expected := "birdwatcher.rs1.peers.R192_1.up 1 1500000000\n" +
+		"birdwatcher.rs1.peers.R192_1.imported 10 1500000000\n" +
+		"birdwatcher.rs1.peers.R192_1.exported 20 1500000000\n" +
+		"birdwatcher.rs1.peers.R192_1.filtered 1 1500000000\n" +
+		"birdwatcher.rs1.peers.R192_1.preferred 9 1500000000\n"
+	if out != expected {
+		t.Error("Unexpected graphite output:", out)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/alice-lg/birdwatcher/endpoints"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Modules enabled when neither the configuration nor the
+// command line selects any. These only expose read-only
+// views and leave out the admin and analysis modules.
+var defaultModules = []string{
+	"status",
+	"protocols",
+	"protocols_bgp",
+	"protocols_short",
+	"symbols",
+	"symbols_tables",
+	"symbols_protocols",
+	"tables",
+	"routes_protocol",
+	"routes_peer",
+	"routes_table",
+	"routes_count_protocol",
+	"routes_count_table",
+	"routes_count_primary",
+	"routes_filtered",
+	"routes_prefixed",
+	"routes_noexport",
+	"route_net",
+}
+
+// Parse a comma separated list of modules
+func parseModules(modules string) []string {
+	res := []string{}
+	for _, module := range strings.Split(modules, ",") {
+		module = strings.TrimSpace(module)
+		if module == "" {
+			continue
+		}
+		res = append(res, module)
+	}
+	return res
+}
+
+// Select the enabled modules: The command line flag
+// overrides the configuration, which falls back to
+// the default modules.
+func selectModules(flagModules string, configured []string) []string {
+	if modules := parseModules(flagModules); len(modules) > 0 {
+		return modules
+	}
+	if len(configured) > 0 {
+		return configured
+	}
+	return defaultModules
+}
+
+// moduleRouter registers the endpoints of the enabled
+// modules. The paths of disabled modules respond with
+// a module_disabled error instead of a plain 404.
+//
+// With permission profiles, the modules of all profiles are
+// registered and checked against the profile of each request.
+// Tenant requests are restricted to the tenant modules.
+type moduleRouter struct {
+	*httprouter.Router
+	enabled        []string
+	disabledStatus int
+	paths          []string
+}
+
+func newModuleRouter(r *httprouter.Router, enabled []string, disabledStatus int) *moduleRouter {
+	return &moduleRouter{
+		Router:         r,
+		enabled:        enabled,
+		disabledStatus: disabledStatus,
+	}
+}
+
+func (m *moduleRouter) handle(module string, handle httprouter.Handle) httprouter.Handle {
+	if len(endpoints.Tenants) > 0 {
+		handle = endpoints.TenantEndpoint(module, handle)
+	}
+	if len(endpoints.Profiles) > 0 {
+		return endpoints.ProfileEndpoint(module, m.enabled, m.disabledStatus, handle)
+	}
+	if !isModuleEnabled(module, m.enabled) {
+		return endpoints.ModuleDisabled(module, m.disabledStatus)
+	}
+	return handle
+}
+
+// isAvailable checks if the module is enabled globally
+// or in a permission profile
+func (m *moduleRouter) isAvailable(module string) bool {
+	if isModuleEnabled(module, m.enabled) {
+		return true
+	}
+	for _, profile := range endpoints.Profiles {
+		if isModuleEnabled(module, profile.Modules) {
+			return true
+		}
+	}
+	return false
+}
+
+// customEndpoints are the configured custom endpoints,
+// if the custom_endpoints module is available
+func (m *moduleRouter) customEndpoints() map[string]endpoints.CustomEndpointConfig {
+	if !m.isAvailable("custom_endpoints") {
+		return nil
+	}
+	return endpoints.CustomEndpointsConf
+}
+
+// GET registers the handle for the path if the module is enabled
+func (m *moduleRouter) GET(module, path string, handle httprouter.Handle) {
+	m.paths = append(m.paths, path)
+	m.Router.GET(path, m.handle(module, handle))
+}
+
+// POST registers the handle for the path if the module is enabled
+func (m *moduleRouter) POST(module, path string, handle httprouter.Handle) {
+	m.paths = append(m.paths, path)
+	m.Router.POST(path, m.handle(module, handle))
+}
+
+// Configuration used when no config file exists. Without
+// an access list, it only listens on localhost.
+func DefaultConfig() *Config {
+	return &Config{
+		Bird: bird.BirdConfig{
+			Listen:         "127.0.0.1:29184",
+			ConfigFilename: "/etc/bird.conf",
+			BirdCmd:        "birdc",
+			CacheTtl:       5,
+		},
+		Bird6: bird.BirdConfig{
+			Listen:         "127.0.0.1:29186",
+			ConfigFilename: "/etc/bird6.conf",
+			BirdCmd:        "birdc6",
+			CacheTtl:       5,
+		},
+		Status: bird.StatusConfig{
+			ReconfigTimestampSource: "bird",
+		},
+	}
+}
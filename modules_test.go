@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+func TestSelectModules(t *testing.T) {
+	configured := []string{"status", "protocols"}
+
+	modules := selectModules(" status, routes_protocol,,", configured)
+	if len(modules) != 2 || modules[0] != "status" || modules[1] != "routes_protocol" {
+		t.Error("Expected modules from flag, got:", modules)
+	}
+
+	modules = selectModules("", configured)
+	if len(modules) != 2 || modules[1] != "protocols" {
+		t.Error("Expected configured modules, got:", modules)
+	}
+
+	modules = selectModules("", []string{})
+	if len(modules) != len(defaultModules) {
+		t.Error("Expected default modules, got:", modules)
+	}
+	if isModuleEnabled("querylog_ws", modules) {
+		t.Error("Admin modules should not be enabled by default")
+	}
+}
+
+func TestDisabledModule(t *testing.T) {
+	r := makeRouter(endpoints.ServerConfig{
+		ModulesEnabled:       []string{"status", "metrics"},
+		DisabledModuleStatus: http.StatusForbidden,
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/routes/table/master", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Error("Expected 403 for a disabled module, got:", rec.Code)
+	}
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["code"] != "module_disabled" || res["module"] != "routes_table" {
+		t.Error("Unexpected response:", res)
+	}
+	if _, ok := res["modules_enabled"]; ok {
+		t.Error("Expected the enabled modules not to be listed")
+	}
+
+	// Unknown paths are still plain 404s
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected 404 for an unknown path, got:", rec.Code)
+	}
+}
+
+func TestDisabledModuleAccess(t *testing.T) {
+	formerConf := endpoints.Conf
+	defer func() {
+		endpoints.Conf = formerConf
+		endpoints.InitAccessControl()
+	}()
+	endpoints.Conf.AllowFrom = []string{"10.0.0.0/8"}
+	if err := endpoints.InitAccessControl(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := makeRouter(endpoints.ServerConfig{ModulesEnabled: []string{"status"}})
+	req := httptest.NewRequest("GET", "/routes/table/master", nil)
+	req.RemoteAddr = "172.16.0.1:4242"
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Error("Expected 403 for a client without access, got:", rec.Code)
+	}
+}
+
+func TestCustomEndpointsDisabled(t *testing.T) {
+	formerCustom := endpoints.CustomEndpointsConf
+	defer func() { endpoints.CustomEndpointsConf = formerCustom }()
+
+	// Would conflict with /protocols/bgp if registered
+	endpoints.CustomEndpointsConf = map[string]endpoints.CustomEndpointConfig{
+		"/protocols/:name": {Command: "show protocols {name}"},
+	}
+
+	r := makeRouter(endpoints.ServerConfig{ModulesEnabled: []string{"status"}})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/protocols/foo", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected the custom endpoint not to be registered, got:", rec.Code)
+	}
+}
+
+func TestCustomEndpointsConflict(t *testing.T) {
+	formerCustom := endpoints.CustomEndpointsConf
+	defer func() { endpoints.CustomEndpointsConf = formerCustom }()
+
+	endpoints.CustomEndpointsConf = map[string]endpoints.CustomEndpointConfig{
+		"/protocols/:name":          {Command: "show protocols {name}"},
+		"/ospf/neighbors/:name":     {Command: "show ospf neighbors {name}"},
+		"/ospf/neighbors/:protocol": {Command: "show ospf neighbors {protocol}"},
+	}
+
+	r := makeRouter(endpoints.ServerConfig{
+		ModulesEnabled: []string{"protocols_bgp", "custom_endpoints"},
+	})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/protocols/foo", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected the conflicting endpoint to be skipped, got:", rec.Code)
+	}
+}
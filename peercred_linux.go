@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Get the UID and GID of the peer of a unix socket connection
+func peerCredentials(conn net.Conn) (int, int, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return -1, -1, fmt.Errorf("not a unix socket connection")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return -1, -1, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(
+			int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return -1, -1, err
+	}
+	if credErr != nil {
+		return -1, -1, credErr
+	}
+
+	return int(cred.Uid), int(cred.Gid), nil
+}
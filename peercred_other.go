@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+func peerCredentials(conn net.Conn) (int, int, error) {
+	return -1, -1, fmt.Errorf("peer credentials are only supported on linux")
+}
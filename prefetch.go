@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+// Prefetching of configured queries on startup and before
+// the cached results expire, so these queries are always
+// answered from a warm cache.
+
+type PrefetchConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Queries []string `toml:"queries"`
+
+	// Seconds between the runs, by default shortly
+	// before the cache TTL is over
+	Interval int `toml:"interval"`
+}
+
+// The responses of the prefetched queries are discarded
+type prefetchResponse struct {
+	header http.Header
+	status int
+}
+
+func (r *prefetchResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *prefetchResponse) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return len(p), nil
+}
+
+func (r *prefetchResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+type Prefetcher struct {
+	config  PrefetchConfig
+	handler http.Handler
+	queries []*url.URL
+}
+
+func NewPrefetcher(config PrefetchConfig, handler http.Handler) (*Prefetcher, error) {
+	p := &Prefetcher{config: config, handler: handler}
+	for _, query := range config.Queries {
+		u, err := url.Parse(query)
+		if err != nil || u.Path == "" || u.IsAbs() {
+			return nil, fmt.Errorf("invalid prefetch query: %s", query)
+		}
+		p.queries = append(p.queries, u)
+	}
+	return p, nil
+}
+
+func (p *Prefetcher) interval() time.Duration {
+	if p.config.Interval > 0 {
+		return time.Duration(p.config.Interval) * time.Second
+	}
+
+	ttl := time.Duration(bird.ClientConf.CacheTtl) * time.Minute
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return ttl * 9 / 10
+}
+
+// Prefetch runs all queries, bypassing the cache
+func (p *Prefetcher) Prefetch() {
+	for _, u := range p.queries {
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			continue
+		}
+
+		start := time.Now()
+		res := &prefetchResponse{header: http.Header{}}
+		p.handler.ServeHTTP(res, endpoints.InternalRequest(req))
+
+		if res.status != http.StatusOK {
+			log.Println("Prefetching", u, "failed with status", res.status)
+			continue
+		}
+		log.Println("Prefetched", u, "in", time.Since(start))
+	}
+}
+
+func (p *Prefetcher) Run() {
+	interval := p.interval()
+	for {
+		p.Prefetch()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+func TestPrefetch(t *testing.T) {
+	requested := []string{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := endpoints.CheckAccess(r); err != nil {
+			t.Error("Expected prefetch requests to be allowed:", err)
+		}
+		if endpoints.CheckUseCache(r) {
+			t.Error("Expected prefetch requests to bypass the cache")
+		}
+		requested = append(requested, r.URL.String())
+		w.Write([]byte("{}"))
+	})
+
+	p, err := NewPrefetcher(PrefetchConfig{
+		Queries: []string{"/protocols/bgp", "/routes/table/master?format=pb"},
+	}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Prefetch()
+
+	if len(requested) != 2 || requested[1] != "/routes/table/master?format=pb" {
+		t.Error("Unexpected prefetched queries:", requested)
+	}
+
+	if _, err := NewPrefetcher(PrefetchConfig{
+		Queries: []string{"http://example.com/protocols"},
+	}, handler); err == nil {
+		t.Error("Expected an error for an absolute url")
+	}
+}
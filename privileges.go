@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Dropping privileges after binding the listener and
+// reading the TLS certificates.
+
+type credentials struct {
+	uid int
+	gid int
+}
+
+// Look up the user and group to run as. The group
+// defaults to the primary group of the user.
+func lookupCredentials(userName, groupName string) (*credentials, error) {
+	if userName == "" {
+		if groupName != "" {
+			return nil, fmt.Errorf("group requires a user")
+		}
+		return nil, nil
+	}
+
+	u, err := user.Lookup(userName)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, err
+	}
+
+	gidStr := u.Gid
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return nil, err
+		}
+		gidStr = g.Gid
+	}
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credentials{uid: uid, gid: gid}, nil
+}
+
+// Switch to the user and group. The group has to be
+// changed first, as this is no longer permitted after
+// changing the user.
+func (c *credentials) drop() error {
+	if err := syscall.Setgroups([]int{c.gid}); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(c.gid); err != nil {
+		return err
+	}
+	if err := syscall.Setuid(c.uid); err != nil {
+		return err
+	}
+
+	// Make sure we can not get the privileges back
+	if os.Getuid() != c.uid || os.Geteuid() != c.uid {
+		return fmt.Errorf("could not change the user")
+	}
+	if err := syscall.Setuid(0); err == nil && c.uid != 0 {
+		return fmt.Errorf("privileges could be regained")
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLookupCredentials(t *testing.T) {
+	creds, err := lookupCredentials("", "")
+	if err != nil || creds != nil {
+		t.Error("Expected no credentials without user, got:", creds, err)
+	}
+
+	if _, err := lookupCredentials("", "wheel"); err == nil {
+		t.Error("Expected an error for a group without user")
+	}
+
+	creds, err = lookupCredentials("root", "")
+	if err != nil {
+		t.Skip("No root user:", err)
+	}
+	if creds.uid != 0 || creds.gid != 0 {
+		t.Error("Unexpected credentials for root:", creds)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+// Listeners of the permission profiles. Each listener serves
+// the requests with its profile over plain HTTP, e.g. for an
+// internal network.
+
+func listenProfiles(profiles map[string]endpoints.ProfileConfig, config endpoints.ServerConfig) (map[string]net.Listener, error) {
+	names := []string{}
+	for name, profile := range profiles {
+		if profile.Listen != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	listeners := map[string]net.Listener{}
+	for _, name := range names {
+		listen := profiles[name].Listen
+
+		var listener net.Listener
+		var err error
+		if isUnixListen(listen) {
+			listener, err = ListenUnix(listen, config)
+		} else {
+			listener, err = net.Listen("tcp", listen)
+		}
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners[name] = listener
+	}
+	return listeners, nil
+}
+
+func serveProfiles(listeners map[string]net.Listener, config endpoints.ServerConfig, handler func(profile string) http.Handler) {
+	for name, listener := range listeners {
+		log.Println("Serving profile", name, "on", listener.Addr())
+		server := NewServer(listener.Addr().String(), config, handler(name))
+		go func(server *http.Server, listener net.Listener) {
+			log.Fatal(server.Serve(listener))
+		}(server, listener)
+	}
+}
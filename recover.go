@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/alice-lg/birdwatcher/metrics"
+)
+
+// Recover from panics in endpoints
+
+const requestIDHeader = "X-Request-ID"
+
+var httpPanicsTotal = metrics.NewCounter(
+	"birdwatcher_http_panics_total",
+	"Requests aborted by a panic in an endpoint")
+
+type panicResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+// requestID uses the ID provided by the client or a
+// proxy in front of birdwatcher, or creates a new one.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= 128 {
+		return id
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// RecoverHandler recovers from a panic while serving
+// a request. The stack is logged with the request ID,
+// which is also returned to the client in a JSON error.
+func RecoverHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r)
+		w.Header().Set(requestIDHeader, id)
+
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			httpPanicsTotal.Inc()
+			log.Printf("Panic while serving %s %s (request %s): %v\n%s",
+				r.Method, r.URL.Path, id, err, debug.Stack())
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			js, _ := json.Marshal(panicResponse{
+				Error:     "internal server error",
+				RequestID: id,
+			})
+			w.Write(js)
+		}()
+
+		h.ServeHTTP(w, r)
+	})
+}
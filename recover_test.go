@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoverHandler(t *testing.T) {
+	before := httpPanicsTotal.Value()
+	h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("malformed line")
+	}))
+
+	req := httptest.NewRequest("GET", "/routes/protocol/R192_175", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Error("Expected status 500, got:", rec.Code)
+	}
+	if rec.Header().Get("X-Request-ID") != "req-42" {
+		t.Error("Expected the request id to be returned:", rec.Header())
+	}
+	res := panicResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.RequestID != "req-42" || res.Error == "" {
+		t.Error("Unexpected response:", res)
+	}
+	if httpPanicsTotal.Value() != before+1 {
+		t.Error("Expected the panic to be counted")
+	}
+}
+
+func TestRecoverHandlerPassThrough(t *testing.T) {
+	h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Error("Unexpected response:", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Error("Expected a generated request id")
+	}
+}
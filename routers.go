@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+	"github.com/alice-lg/birdwatcher/endpoints"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Multi-router mode
+//
+// Every router configured in a [routers.<name>] section is
+// served by a birdwatcher child process listening on a unix
+// socket. The parent process supervises the children and
+// proxies requests for /r/<name>/... to them. The children
+// run with their own bird configuration, cache and state.
+
+// Get the path of the unix socket of a router process
+func routerSocket(name string) string {
+	return filepath.Join(
+		os.TempDir(),
+		fmt.Sprintf("birdwatcher-%d-%s.sock", os.Getpid(), name))
+}
+
+// Start and supervise a child process for every router.
+// Returns the sockets of the routers, keyed by name.
+func StartRouters(routers map[string]bird.BirdConfig) map[string]string {
+	sockets := map[string]string{}
+	for name := range routers {
+		socket := routerSocket(name)
+		sockets[name] = socket
+
+		args := append(os.Args[1:], "-router", name, "-router-socket", socket)
+		go superviseRouter(name, args)
+	}
+	return sockets
+}
+
+// Restart the router process when it exits
+func superviseRouter(name string, args []string) {
+	for {
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		log.Println("Starting router process:", name)
+		if err := cmd.Run(); err != nil {
+			log.Println("Router process", name, "exited:", err)
+		} else {
+			log.Println("Router process", name, "exited")
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+// Stop the router process when the parent is gone
+func watchParent() {
+	parent := os.Getppid()
+	for range time.Tick(time.Second) {
+		if os.Getppid() != parent {
+			log.Fatal("Parent process exited, stopping router process")
+		}
+	}
+}
+
+// Listen on the unix socket of a router process
+func listenRouterSocket(socket string) (net.Listener, error) {
+	return listenUnixSocket(socket, 0600)
+}
+
+func newRouterProxy(socket string) *httputil.ReverseProxy {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = "router"
+		},
+		Transport: transport,
+	}
+}
+
+// Path of the router proxy, reserved in all modes
+const routerProxyPath = "/r/:router/*path"
+
+// RouterProxy forwards requests for /r/:router/*path
+// to the process of the router.
+func RouterProxy(sockets map[string]string) httprouter.Handle {
+	proxies := map[string]*httputil.ReverseProxy{}
+	for name, socket := range sockets {
+		proxies[name] = newRouterProxy(socket)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		// Access control is done here, as the router process
+		// does not see the address of the client.
+		if err := endpoints.CheckAccess(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		proxy, ok := proxies[ps.ByName("router")]
+		if !ok {
+			http.Error(w, "Unknown router: "+ps.ByName("router"), http.StatusNotFound)
+			return
+		}
+
+		r.URL.Path = ps.ByName("path")
+		r.URL.RawPath = ""
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+// Register the routes of the router proxy
+func registerRouters(r *httprouter.Router, sockets map[string]string) {
+	if len(sockets) == 0 {
+		return
+	}
+
+	// POST is used by /protocols/query
+	handler := RouterProxy(sockets)
+	r.GET(routerProxyPath, handler)
+	r.HEAD(routerProxyPath, handler)
+	r.POST(routerProxyPath, handler)
+
+	names := []string{}
+	for name := range sockets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r.GET("/routers", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		if err := endpoints.CheckAccess(req); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		js, _ := json.Marshal(map[string][]string{"routers": names})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	})
+}
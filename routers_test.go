@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+func TestRouterProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-routers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "blue.sock")
+	listener, err := listenRouterSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.Path))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	r := makeRouter(endpoints.ServerConfig{
+		ModulesEnabled: []string{"status", "routes_protocol", "route_net"},
+	})
+	registerRouters(r, map[string]string{"blue": socket})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/r/blue/protocols/bgp", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "GET /protocols/bgp" {
+		t.Error("Unexpected proxied response:", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/r/blue/protocols/query", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "POST /protocols/query" {
+		t.Error("Unexpected proxied response:", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/r/red/protocols", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected 404 for an unknown router, got:", rec.Code)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Secrets from files and the environment
+//
+// Secrets can be kept out of the config file with the _file
+// or _env variant of the key, e.g.
+//
+//   admin_tokens_file = "/run/secrets/birdwatcher_tokens"
+//   webhook_url_env = "BIRDWATCHER_WEBHOOK_URL"
+//
+// A token file has one token per line, the tokens of an
+// environment variable are separated by commas.
+
+// The keys supporting the variants and if they are lists
+var secretKeys = map[string]bool{
+	"server.admin_tokens":   true,
+	"server.key_passphrase": false,
+	"redact.tokens":         true,
+	"profiles.*.tokens":     true,
+	"alerts.webhook_url":    false,
+	"cache.redis_password":  false,
+	"events.nats_password":  false,
+	"events.nats_token":     false,
+}
+
+const (
+	secretFileSuffix = "_file"
+	secretEnvSuffix  = "_env"
+)
+
+func secretKey(keyPath string) (bool, bool) {
+	for pattern, isList := range secretKeys {
+		if ok, _ := path.Match(pattern, keyPath); ok {
+			return isList, true
+		}
+	}
+	return false, false
+}
+
+// resolveSecrets replaces the _file and _env keys of the
+// config document with the secrets
+func resolveSecrets(doc map[string]interface{}, prefix string) error {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := doc[key]
+		if table, ok := value.(map[string]interface{}); ok {
+			if err := resolveSecrets(table, prefix+key+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var name, suffix string
+		switch {
+		case strings.HasSuffix(key, secretFileSuffix):
+			name, suffix = strings.TrimSuffix(key, secretFileSuffix), secretFileSuffix
+		case strings.HasSuffix(key, secretEnvSuffix):
+			name, suffix = strings.TrimSuffix(key, secretEnvSuffix), secretEnvSuffix
+		default:
+			continue
+		}
+		isList, ok := secretKey(prefix + name)
+		if !ok {
+			continue
+		}
+
+		source, ok := value.(string)
+		if !ok || source == "" {
+			return fmt.Errorf("%s%s must be a non-empty string", prefix, key)
+		}
+		if _, ok := doc[name]; ok {
+			return fmt.Errorf("%s%s and %s%s are both set", prefix, name, prefix, key)
+		}
+
+		var secret interface{}
+		var err error
+		if suffix == secretFileSuffix {
+			secret, err = readSecretFile(source, isList)
+		} else {
+			secret, err = readSecretEnv(source, isList)
+		}
+		if err != nil {
+			return fmt.Errorf("%s%s: %s", prefix, key, err)
+		}
+		doc[name] = secret
+		delete(doc, key)
+	}
+	return nil
+}
+
+func readSecretFile(filename string, isList bool) (interface{}, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !isList {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	tokens := []interface{}{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	return tokens, nil
+}
+
+func readSecretEnv(name string, isList bool) (interface{}, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	if !isList {
+		return value, nil
+	}
+
+	tokens := []interface{}{}
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
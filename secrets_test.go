@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher_secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokens := filepath.Join(dir, "tokens")
+	ioutil.WriteFile(tokens, []byte("# admins\ntoken1\n\ntoken2\n"), 0600)
+	os.Setenv("BIRDWATCHER_TEST_WEBHOOK", "https://hooks.example.net/secret")
+	os.Setenv("BIRDWATCHER_TEST_TOKENS", "noc1, noc2")
+	defer os.Unsetenv("BIRDWATCHER_TEST_WEBHOOK")
+	defer os.Unsetenv("BIRDWATCHER_TEST_TOKENS")
+
+	conf := filepath.Join(dir, "birdwatcher.conf")
+	ioutil.WriteFile(conf, []byte(`[server]
+admin_tokens_file = "`+tokens+`"
+
+[alerts]
+webhook_url_env = "BIRDWATCHER_TEST_WEBHOOK"
+
+[profiles.noc]
+tokens_env = "BIRDWATCHER_TEST_TOKENS"
+`), 0644)
+
+	config, _, err := loadConfigFile(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Server.AdminTokens) != 2 || config.Server.AdminTokens[1] != "token2" {
+		t.Error("Unexpected admin tokens:", config.Server.AdminTokens)
+	}
+	if config.Alerts.WebhookURL != "https://hooks.example.net/secret" {
+		t.Error("Unexpected webhook url:", config.Alerts.WebhookURL)
+	}
+	if noc := config.Profiles["noc"].Tokens; len(noc) != 2 || noc[0] != "noc1" {
+		t.Error("Unexpected profile tokens:", noc)
+	}
+
+	errors := []map[string]interface{}{
+		{"server": map[string]interface{}{"admin_tokens_env": "BIRDWATCHER_TEST_UNSET"}},
+		{"server": map[string]interface{}{"admin_tokens_file": filepath.Join(dir, "missing")}},
+		{"server": map[string]interface{}{
+			"admin_tokens":     []interface{}{"token"},
+			"admin_tokens_env": "BIRDWATCHER_TEST_TOKENS",
+		}},
+	}
+	for _, doc := range errors {
+		if err := resolveSecrets(doc, ""); err == nil {
+			t.Error("Expected an error for", doc)
+		}
+	}
+
+	// Other keys with the suffixes are kept
+	doc := map[string]interface{}{"server": map[string]interface{}{"ocsp_staple_file": "staple"}}
+	if err := resolveSecrets(doc, ""); err != nil {
+		t.Fatal(err)
+	}
+	if doc["server"].(map[string]interface{})["ocsp_staple_file"] != "staple" {
+		t.Error("Expected ocsp_staple_file to be kept")
+	}
+}
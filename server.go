@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+// Defaults for the HTTP server. There is no default write
+// timeout, as transferring large route dumps to slow clients
+// can take a long time.
+const (
+	defaultReadTimeout = 60
+	defaultIdleTimeout = 120
+)
+
+func secondsOrDefault(value int, defaultValue int) time.Duration {
+	if value == 0 {
+		value = defaultValue
+	}
+	if value < 0 {
+		return 0 // No timeout
+	}
+	return time.Duration(value) * time.Second
+}
+
+// NewServer creates the HTTP server with the timeouts
+// and limits from the server config.
+func NewServer(listen string, config endpoints.ServerConfig, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:           listen,
+		Handler:        handler,
+		ReadTimeout:    secondsOrDefault(config.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:   secondsOrDefault(config.WriteTimeout, 0),
+		IdleTimeout:    secondsOrDefault(config.IdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes: config.MaxHeaderBytes, // 0 is http.DefaultMaxHeaderBytes
+	}
+
+	server.SetKeepAlivesEnabled(!config.DisableKeepAlives)
+
+	// HTTP/2 is negotiated automatically on the TLS listener,
+	// unless the TLSNextProto map is not nil.
+	if config.DisableHTTP2 {
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+
+	return server
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// NewTLSConfig creates the TLS configuration with the minimum
+// version and the cipher suites from the server config.
+// The cipher suites do not apply to TLS 1.3.
+func NewTLSConfig(config endpoints.ServerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if config.TLSMinVersion != "" {
+		version, ok := tlsVersions[config.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Unknown TLS version: %s", config.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	for _, name := range config.TLSCipherSuites {
+		suite, ok := tlsCipherSuites[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown cipher suite: %s", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, suite)
+	}
+
+	return tlsConfig, nil
+}
+
+// LoadKeyPair loads the certificate and the key, which is
+// decrypted with the passphrase if set. Only PEM encryption
+// (Proc-Type: 4,ENCRYPTED) is supported.
+func LoadKeyPair(crtFile, keyFile, passphrase string) (tls.Certificate, error) {
+	if passphrase == "" {
+		return tls.LoadX509KeyPair(crtFile, keyFile)
+	}
+
+	crt, err := ioutil.ReadFile(crtFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return tls.Certificate{}, fmt.Errorf("No PEM data in %s", keyFile)
+	}
+	if !x509.IsEncryptedPEMBlock(block) {
+		return tls.Certificate{}, fmt.Errorf("The key in %s is not encrypted", keyFile)
+	}
+	der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Could not decrypt %s: %s", keyFile, err)
+	}
+	key = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+
+	return tls.X509KeyPair(crt, key)
+}
+
+// stapledCertificate staples the OCSP response from a file
+// to the certificate. The file is maintained outside of
+// birdwatcher (e.g. with `openssl ocsp -respout`) and reloaded
+// periodically.
+type stapledCertificate struct {
+	sync.RWMutex
+	cert     tls.Certificate
+	ocspFile string
+}
+
+func (s *stapledCertificate) reload() error {
+	staple, err := ioutil.ReadFile(s.ocspFile)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.cert.OCSPStaple = staple
+	s.Unlock()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (s *stapledCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.RLock()
+	defer s.RUnlock()
+	cert := s.cert
+	return &cert, nil
+}
+
+func (s *stapledCertificate) refresh(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.reload(); err != nil {
+			log.Println("Could not reload OCSP response:", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	config, err := NewTLSConfig(endpoints.ServerConfig{
+		TLSMinVersion: "1.2",
+		TLSCipherSuites: []string{
+			"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Error("Unexpected min version:", config.MinVersion)
+	}
+	if len(config.CipherSuites) != 1 ||
+		config.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Error("Unexpected cipher suites:", config.CipherSuites)
+	}
+
+	if _, err := NewTLSConfig(endpoints.ServerConfig{TLSMinVersion: "2.0"}); err == nil {
+		t.Error("Expected an error for an unknown TLS version")
+	}
+	if _, err := NewTLSConfig(endpoints.ServerConfig{TLSCipherSuites: []string{"RC4"}}); err == nil {
+		t.Error("Expected an error for an unknown cipher suite")
+	}
+}
+
+func TestStapledCertificate(t *testing.T) {
+	f, err := ioutil.TempFile("", "birdwatcher-ocsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write([]byte("staple"))
+	f.Close()
+
+	stapled := &stapledCertificate{ocspFile: f.Name()}
+	if err := stapled.reload(); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := stapled.GetCertificate(nil)
+	if string(cert.OCSPStaple) != "staple" {
+		t.Error("Unexpected OCSP staple:", string(cert.OCSPStaple))
+	}
+}
+
+func TestLoadKeyPairEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "birdwatcher-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", keyDer, []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crtFile := filepath.Join(dir, "birdwatcher.crt")
+	keyFile := filepath.Join(dir, "birdwatcher.key")
+	ioutil.WriteFile(crtFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)
+
+	if _, err := LoadKeyPair(crtFile, keyFile, "secret"); err != nil {
+		t.Error("Could not load the key pair:", err)
+	}
+	if _, err := LoadKeyPair(crtFile, keyFile, "wrong"); err == nil {
+		t.Error("Expected an error for a wrong passphrase")
+	}
+	if _, err := LoadKeyPair(crtFile, keyFile, ""); err == nil {
+		t.Error("Expected an error for an encrypted key without passphrase")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Prefixes in path parameters
+//
+// The router matches the decoded path, so a prefix in a path
+// parameter, e.g. /route/net/192.0.2.0%2F24, would be split at
+// its slash. The encoded slashes are kept in the path and the
+// parameter is unescaped by the endpoint.
+
+// EncodedSlashHandler keeps encoded slashes in the path
+func EncodedSlashHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := r.URL.RawPath
+		if raw == "" || !strings.Contains(strings.ToUpper(raw), "%2F") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		segments := strings.Split(raw, "/")
+		for i, segment := range segments {
+			decoded, err := url.PathUnescape(segment)
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			segments[i] = strings.Replace(decoded, "/", "%2F", -1)
+		}
+
+		u := *r.URL
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = ""
+		req := *r
+		req.URL = &u
+		h.ServeHTTP(w, &req)
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestEncodedSlashHandler(t *testing.T) {
+	r := httprouter.New()
+	net := ""
+	r.GET("/route/net/:net", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		net = ps.ByName("net")
+	})
+	r.GET("/route/net/:net/table/:table", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		net = ps.ByName("net") + " " + ps.ByName("table")
+	})
+	h := EncodedSlashHandler(r)
+
+	tests := map[string]string{
+		"/route/net/192.0.2.1":                    "192.0.2.1",
+		"/route/net/192.0.2.0%2F24":               "192.0.2.0%2F24",
+		"/route/net/2001%3Adb8%3A%3A%2f32":        "2001:db8::%2F32",
+		"/route/net/192.0.2.0%2F24/table/master4": "192.0.2.0%2F24 master4",
+	}
+	for path, expected := range tests {
+		net = ""
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Error("Expected", path, "to be routed, got:", rec.Code)
+		}
+		if net != expected {
+			t.Error("Expected", expected, "for", path, "got:", net)
+		}
+	}
+}
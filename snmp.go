@@ -0,0 +1,213 @@
+package main
+
+// SNMP: The BGP sessions are exposed as an AgentX subagent
+// with the bgpPeerTable of the BGP4-MIB (RFC 4273). The
+// BGP4-MIB only covers IPv4 peers.
+//
+// The prefix counters have no place in the BGP4-MIB, they
+// are exposed in a table below prefixes_oid if configured:
+//
+//    <prefixes_oid>.1.<column>.<peer address>
+//
+// with the columns imported(1), exported(2), filtered(3)
+// and preferred(4) as Gauge32.
+
+import (
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/bird"
+)
+
+type SnmpConfig struct {
+	Enabled bool `toml:"enabled"`
+
+	// Address of the master agent, either a unix socket
+	// path or tcp:host:port
+	AgentxSocket string `toml:"agentx_socket"`
+	PrefixesOid  string `toml:"prefixes_oid"`
+}
+
+var (
+	bgpMibOid       = mustParseOID("1.3.6.1.2.1.15")
+	bgpLocalAsOid   = bgpMibOid.append(2, 0)
+	bgpPeerEntryOid = bgpMibOid.append(3, 1)
+	bgpIdentOid     = bgpMibOid.append(4, 0)
+)
+
+// bgpPeerState values
+var bgpPeerStates = map[string]uint32{
+	"idle":        1,
+	"connect":     2,
+	"active":      3,
+	"opensent":    4,
+	"openconfirm": 5,
+	"established": 6,
+}
+
+func ipv4Index(address interface{}) (oid, []byte, bool) {
+	s, _ := address.(string)
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return nil, nil, false
+	}
+	return oid{uint32(ip[0]), uint32(ip[1]), uint32(ip[2]), uint32(ip[3])}, []byte(ip), true
+}
+
+// The BGP4-MIB only has 2-byte ASNs, 4-byte ASNs are
+// reported as AS_TRANS.
+func mibAsn(v interface{}) uint32 {
+	n, _ := v.(int64)
+	if n < 0 {
+		return 0
+	}
+	if n > bird.MaxTwoByteAsn {
+		return bird.AsTrans
+	}
+	return uint32(n)
+}
+
+func gauge(v interface{}) uint32 {
+	n, _ := v.(int64)
+	if n < 0 {
+		return 0
+	}
+	return uint32(n)
+}
+
+// Create the view of the MIB from the parsed status and BGP protocols
+func bgpMibView(status bird.Parsed, protocols bird.Parsed, prefixesOid oid) mibView {
+	m := mibView{}
+
+	if _, routerID, ok := ipv4Index(status["router_id"]); ok {
+		m = append(m, varbind{bgpIdentOid, snmpIPAddress, routerID})
+	}
+
+	for _, p := range protocols {
+		protocol, ok := p.(bird.Parsed)
+		if !ok {
+			continue
+		}
+		index, remoteAddr, ok := ipv4Index(protocol["neighbor_address"])
+		if !ok {
+			continue
+		}
+		column := func(c uint32) oid {
+			return bgpPeerEntryOid.append(c).append(index...)
+		}
+
+		if localAs, ok := protocol["local_as"]; ok {
+			m = append(m, varbind{bgpLocalAsOid, snmpInteger, mibAsn(localAs)})
+		}
+
+		_, peerID, ok := ipv4Index(protocol["neighbor_id"])
+		if !ok {
+			peerID = []byte{0, 0, 0, 0}
+		}
+		m = append(m, varbind{column(1), snmpIPAddress, peerID})
+
+		bgpState, _ := protocol["bgp_state"].(string)
+		state, ok := bgpPeerStates[strings.ToLower(bgpState)]
+		if !ok {
+			state = bgpPeerStates["idle"]
+		}
+		m = append(m, varbind{column(2), snmpInteger, state})
+
+		adminStatus := uint32(2) // start
+		if protocol["state"] == "down" {
+			adminStatus = 1 // stop
+		}
+		m = append(m, varbind{column(3), snmpInteger, adminStatus})
+		m = append(m, varbind{column(7), snmpIPAddress, remoteAddr})
+		m = append(m, varbind{column(9), snmpInteger, mibAsn(protocol["neighbor_as"])})
+
+		if len(prefixesOid) > 0 {
+			routes, _ := protocol["routes"].(bird.Parsed)
+			for c, key := range []string{"imported", "exported", "filtered", "preferred"} {
+				name := prefixesOid.append(1, uint32(c+1)).append(index...)
+				m = append(m, varbind{name, snmpGauge32, gauge(routes[key])})
+			}
+		}
+	}
+
+	m.sort()
+
+	// bgpLocalAs is the same for every peer
+	unique := mibView{}
+	for i, vb := range m {
+		if i > 0 && compareOID(vb.name, m[i-1].name) == 0 {
+			continue
+		}
+		unique = append(unique, vb)
+	}
+	return unique
+}
+
+func dialAgentx(address string) (net.Conn, error) {
+	if strings.HasPrefix(address, "tcp:") {
+		return net.DialTimeout("tcp", strings.TrimPrefix(address, "tcp:"), 10*time.Second)
+	}
+	return net.DialTimeout("unix", address, 10*time.Second)
+}
+
+// Connect to the master agent and serve the MIB,
+// reconnecting when the connection is lost.
+func RunSnmpAgent(config SnmpConfig) {
+	address := config.AgentxSocket
+	if address == "" {
+		address = "/var/agentx/master"
+	}
+
+	var prefixesOid oid
+	if config.PrefixesOid != "" {
+		var err error
+		prefixesOid, err = parseOID(config.PrefixesOid)
+		if err != nil {
+			log.Println("SNMP:", err)
+			return
+		}
+	}
+
+	view := func() mibView {
+		status, _ := bird.Status(true)
+		protocols, _ := bird.ProtocolsBgp(true)
+		if bird.IsSpecial(protocols) {
+			return mibView{}
+		}
+		s, _ := status["status"].(bird.Parsed)
+		p, _ := protocols["protocols"].(bird.Parsed)
+		return bgpMibView(s, p, prefixesOid)
+	}
+
+	for {
+		err := serveAgentx(address, prefixesOid, view)
+		log.Println("SNMP: AgentX connection lost:", err)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func serveAgentx(address string, prefixesOid oid, view func() mibView) error {
+	conn, err := dialAgentx(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	session, err := OpenAgentxSession(conn, bgpMibOid, "birdwatcher")
+	if err != nil {
+		return err
+	}
+	if err := session.Register(bgpMibOid); err != nil {
+		return err
+	}
+	if len(prefixesOid) > 0 {
+		if err := session.Register(prefixesOid); err != nil {
+			return err
+		}
+	}
+
+	log.Println("SNMP: Registered with AgentX master at", address)
+	return session.Serve(view)
+}
@@ -0,0 +1,5 @@
+// Package integration contains the end-to-end tests of the
+// birdwatcher against BIRD 1.6 and BIRD 2. The tests are built
+// with the integration tag and require the BIRD instances of
+// docker-compose.yml.
+package integration
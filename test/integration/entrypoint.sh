@@ -0,0 +1,7 @@
+#!/bin/sh
+set -e
+
+# BIRD forks into the background
+bird -c /etc/bird/bird.conf -s /run/bird/bird.ctl
+
+exec /usr/bin/birdwatcher -config /etc/birdwatcher/birdwatcher.conf
@@ -0,0 +1,201 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+type instance struct {
+	name    string
+	url     string
+	version int
+	peer    string // BGP protocol
+}
+
+func instances() []instance {
+	bird1 := os.Getenv("BIRDWATCHER_BIRD1")
+	if bird1 == "" {
+		bird1 = "http://localhost:29184"
+	}
+	bird2 := os.Getenv("BIRDWATCHER_BIRD2")
+	if bird2 == "" {
+		bird2 = "http://localhost:29185"
+	}
+
+	return []instance{
+		{name: "bird1", url: bird1, version: 1, peer: "R65002"},
+		{name: "bird2", url: bird2, version: 2, peer: "R65001"},
+	}
+}
+
+func get(url string) (int, map[string]interface{}, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	doc := map[string]interface{}{}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return res.StatusCode, nil, err
+	}
+	return res.StatusCode, doc, nil
+}
+
+// Wait until the birdwatcher is ready and the BGP
+// session is established
+func waitEstablished(t *testing.T, bird instance) {
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		status, doc, err := get(bird.url + "/protocols/bgp?uncached=true")
+		if err == nil && status == http.StatusOK {
+			protocols, _ := doc["protocols"].(map[string]interface{})
+			peer, _ := protocols[bird.peer].(map[string]interface{})
+			if peer["state"] == "up" {
+				// Give the routes a moment to propagate
+				time.Sleep(2 * time.Second)
+				return
+			}
+		}
+		time.Sleep(time.Second)
+	}
+	t.Fatal(bird.name, "- BGP session", bird.peer, "not established")
+}
+
+func endpoints(bird instance) []string {
+	return []string{
+		"/status",
+		"/status/self",
+		"/capabilities",
+		"/protocols",
+		"/protocols/bgp",
+		"/protocols/short",
+		"/protocols/stats/" + bird.peer,
+		"/neighbors/stats",
+		"/symbols",
+		"/symbols/tables",
+		"/symbols/protocols",
+		"/filters",
+		"/tables",
+		"/routes/protocol/" + bird.peer,
+		"/routes/peer/10.99.0.1" + fmt.Sprint(3-bird.version),
+		"/routes/table/master",
+		"/routes/table/master/filtered",
+		"/routes/count/protocol/" + bird.peer,
+		"/routes/count/table/master",
+		"/routes/count/all",
+		"/routes/count/primary/" + bird.peer,
+		"/routes/filtered/" + bird.peer,
+		"/routes/prefix?prefix=10.0.0.0/8",
+		"/routes/noexport/" + bird.peer,
+		"/routes/exported/" + bird.peer,
+		"/routes/received/" + bird.peer,
+		"/routes/top?by=as_path_length&n=2",
+		"/route/net/10.101.0.0/24",
+		"/routes/stats/aspath/master",
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	for _, bird := range instances() {
+		waitEstablished(t, bird)
+
+		for _, endpoint := range endpoints(bird) {
+			status, doc, err := get(bird.url + endpoint)
+			if err != nil {
+				t.Error(bird.name, endpoint, "-", err)
+				continue
+			}
+			if status != http.StatusOK {
+				t.Error(bird.name, endpoint, "- unexpected status:", status)
+			}
+			if msg, ok := doc["error"]; ok {
+				t.Error(bird.name, endpoint, "- error:", msg)
+			}
+			if _, ok := doc["api"]; !ok {
+				t.Error(bird.name, endpoint, "- missing api info")
+			}
+		}
+	}
+}
+
+func routes(t *testing.T, url string) []map[string]interface{} {
+	status, doc, err := get(url)
+	if err != nil || status != http.StatusOK {
+		t.Fatal(url, "-", status, err)
+	}
+
+	res := []map[string]interface{}{}
+	list, _ := doc["routes"].([]interface{})
+	for _, r := range list {
+		if route, ok := r.(map[string]interface{}); ok {
+			res = append(res, route)
+		}
+	}
+	return res
+}
+
+func networks(routes []map[string]interface{}) map[string]map[string]interface{} {
+	res := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		network, _ := route["network"].(string)
+		res[network] = route
+	}
+	return res
+}
+
+func TestCapabilities(t *testing.T) {
+	for _, bird := range instances() {
+		_, doc, err := get(bird.url + "/capabilities")
+		if err != nil {
+			t.Fatal(err)
+		}
+		caps, _ := doc["capabilities"].(map[string]interface{})
+		if caps["channels"] != (bird.version == 2) {
+			t.Error(bird.name, "- unexpected capabilities:", caps)
+		}
+	}
+}
+
+func TestRoutesAcrossVersions(t *testing.T) {
+	bird1, bird2 := instances()[0], instances()[1]
+	waitEstablished(t, bird1)
+	waitEstablished(t, bird2)
+
+	// BIRD 1.6 accepts the routes of 10.102.0.0/16
+	received := networks(routes(t, bird1.url+"/routes/protocol/"+bird1.peer))
+	route, ok := received["10.102.1.0/24"]
+	if !ok {
+		t.Fatal("bird1 - missing route 10.102.1.0/24:", received)
+	}
+	bgp, _ := route["bgp"].(map[string]interface{})
+	if large, _ := bgp["large_communities"].([]interface{}); len(large) != 1 {
+		t.Error("bird1 - expected the large community, got:", bgp)
+	}
+	if communities, _ := bgp["communities"].([]interface{}); len(communities) != 1 {
+		t.Error("bird1 - expected the community, got:", bgp)
+	}
+
+	filtered := networks(routes(t, bird1.url+"/routes/filtered/"+bird1.peer))
+	if _, ok := filtered["192.0.2.0/24"]; !ok || len(filtered) != 1 {
+		t.Error("bird1 - expected 192.0.2.0/24 to be filtered, got:", filtered)
+	}
+
+	// BIRD 2 accepts all routes
+	received = networks(routes(t, bird2.url+"/routes/protocol/"+bird2.peer))
+	for _, network := range []string{"10.101.0.0/24", "10.101.1.0/24"} {
+		if _, ok := received[network]; !ok {
+			t.Error("bird2 - missing route", network, "got:", received)
+		}
+	}
+	if filtered := routes(t, bird2.url+"/routes/filtered/"+bird2.peer); len(filtered) != 0 {
+		t.Error("bird2 - expected no filtered routes, got:", filtered)
+	}
+}
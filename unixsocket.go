@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+// Serving on a unix domain socket
+//
+// With `listen = "unix:/run/birdwatcher.sock"` the API is
+// served on a unix socket. Clients can be authorized by their
+// UID and GID (SO_PEERCRED) instead of their IP address.
+
+const unixListenPrefix = "unix:"
+
+func isUnixListen(listen string) bool {
+	return strings.HasPrefix(listen, unixListenPrefix)
+}
+
+// Create the unix socket, replacing a stale socket file
+func listenUnixSocket(path string, mode os.FileMode) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Look up a user or group id, accepting names and numeric ids
+func lookupId(name string, lookup func(string) (string, error)) (int, error) {
+	if name == "" {
+		return -1, nil // Unchanged
+	}
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
+
+func lookupUid(name string) (int, error) {
+	return lookupId(name, func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	})
+}
+
+func lookupGid(name string) (int, error) {
+	return lookupId(name, func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return g.Gid, nil
+	})
+}
+
+// ListenUnix creates the unix socket with the mode and
+// owner from the server config.
+func ListenUnix(listen string, config endpoints.ServerConfig) (net.Listener, error) {
+	path := strings.TrimPrefix(listen, unixListenPrefix)
+
+	mode := os.FileMode(0660)
+	if config.SocketMode != "" {
+		m, err := strconv.ParseUint(config.SocketMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid socket_mode: %s", config.SocketMode)
+		}
+		mode = os.FileMode(m)
+	}
+
+	uid, err := lookupUid(config.SocketOwner)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := lookupGid(config.SocketGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := listenUnixSocket(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	if len(config.AllowUids) == 0 && len(config.AllowGids) == 0 {
+		return listener, nil
+	}
+	return &peerCredListener{
+		Listener: listener,
+		uids:     config.AllowUids,
+		gids:     config.AllowGids,
+	}, nil
+}
+
+// peerCredListener only accepts connections from clients
+// with an allowed UID or GID.
+type peerCredListener struct {
+	net.Listener
+	uids []int
+	gids []int
+}
+
+func containsId(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *peerCredListener) isAllowed(uid, gid int) bool {
+	return containsId(l.uids, uid) || containsId(l.gids, gid)
+}
+
+// Accept implements net.Listener
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		uid, gid, err := peerCredentials(conn)
+		if err != nil {
+			log.Println("Could not get peer credentials:", err)
+			conn.Close()
+			continue
+		}
+		if !l.isAllowed(uid, gid) {
+			log.Println("Rejecting access from uid", uid, "gid", gid)
+			conn.Close()
+			continue
+		}
+
+		return conn, nil
+	}
+}
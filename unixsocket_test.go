@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alice-lg/birdwatcher/endpoints"
+)
+
+func TestListenUnixPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Peer credentials are only supported on linux")
+	}
+
+	dir, err := ioutil.TempDir("", "birdwatcher-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "api.sock")
+	listener, err := ListenUnix("unix:"+path, endpoints.ServerConfig{
+		SocketMode: "0600",
+		AllowUids:  []int{os.Getuid()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Error("Unexpected socket mode:", info.Mode().Perm())
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	select {
+	case err := <-accepted:
+		if err != nil {
+			t.Error("Expected the connection to be accepted, got:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Connection of an allowed uid was not accepted")
+	}
+}
+
+func TestPeerCredListenerIsAllowed(t *testing.T) {
+	l := &peerCredListener{uids: []int{0}, gids: []int{1001}}
+	if !l.isAllowed(0, 0) || !l.isAllowed(1000, 1001) {
+		t.Error("Expected uid 0 and gid 1001 to be allowed")
+	}
+	if l.isAllowed(1000, 1000) {
+		t.Error("Expected uid 1000 to be rejected")
+	}
+}